@@ -0,0 +1,59 @@
+// Command nlch-release writes the Homebrew formula, Scoop manifest and .deb
+// and .rpm packages of a release next to its binaries, as described by
+// packaging.yaml. The release workflow runs it before uploading the assets:
+//
+//	go run ./cmd/nlch-release -tag v1.2.3 -dist release-assets
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/release"
+)
+
+func main() {
+	configPath := flag.String("config", "packaging.yaml", "Packaging config")
+	tag := flag.String("tag", "", "Release tag, e.g. v1.2.3")
+	dist := flag.String("dist", "dist", "Directory with the release binaries, where the packages are written")
+	flag.Parse()
+
+	if err := run(*configPath, *tag, *dist); err != nil {
+		fmt.Fprintf(os.Stderr, "nlch-release: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(configPath, tag, dist string) error {
+	if !strings.HasPrefix(tag, "v") {
+		return fmt.Errorf("-tag must be a release tag such as v1.2.3, got %q", tag)
+	}
+	cfg, err := release.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	written, err := release.Build(cfg, tag, dist, buildTime())
+	for _, name := range written {
+		fmt.Println(name)
+	}
+	return err
+}
+
+// buildTime returns the time recorded in the packages: SOURCE_DATE_EPOCH if
+// set, for reproducible builds, or else the time of the current commit.
+func buildTime() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0)
+	}
+	if out, err := exec.Command("git", "log", "-1", "--format=%ct").Output(); err == nil {
+		if epoch, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil {
+			return time.Unix(epoch, 0)
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var aliasCommand = &command{
+	name:    "alias",
+	usage:   "[flags] \"Describe the alias or function\"",
+	summary: "Generate a named shell alias or function and add it to your rc file",
+}
+
+func init() {
+	aliasCommand.run = runAlias
+}
+
+// Maximum number of tokens in a generated alias or function.
+const aliasMaxTokens = 512
+
+func runAlias(args []string) error {
+	fs := newFlagSet(aliasCommand)
+	name := fs.String("name", "", "Name of the alias or function (chosen by the model if empty)")
+	shellFlag := fs.String("shell", "", "Shell to generate for: bash, zsh or fish (default: $SHELL)")
+	yes := fs.Bool("yes", false, "Append to the rc file without asking")
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	description := strings.Join(fs.Args(), " ")
+	if description == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	shellName := *shellFlag
+	if shellName == "" {
+		shellName = filepath.Base(os.Getenv("SHELL"))
+	}
+	rcPath, err := shell.RCFile(shellName)
+	if err != nil {
+		return err
+	}
+
+	cfg, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+
+	ctx := gatherContext()
+	opts := provider.ProviderOptions{
+		Model:     *model,
+		Provider:  providerName,
+		System:    withProjectInstructions(cfg, prompt.DefaultSystemPrompt),
+		MaxTokens: aliasMaxTokens,
+		Raw:       true,
+	}
+	definition, err := prov.GenerateCommand(*ctx, prompt.BuildAliasPrompt(ctx, description, *name, shellName), opts)
+	if err != nil {
+		return fmt.Errorf("provider error: %v", err)
+	}
+	definition = stripCodeFence(definition)
+
+	defName := shell.DefinitionName(definition)
+	if defName == "" {
+		return errors.New("LLM did not return an alias or function definition")
+	}
+
+	fmt.Printf("> %s for %s:\n\n", defName, shellName)
+	fmt.Println(ui.Highlight(definition))
+	fmt.Println()
+
+	if !*yes {
+		answer := strings.ToLower(strings.TrimSpace(shell.ReadLine(fmt.Sprintf("> Add to %s? [y/N]: ", rcPath))))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("> Not added. Copy the definition above to use it.")
+			return nil
+		}
+	}
+
+	if err := shell.AddManagedDefinition(rcPath, defName, definition); err != nil {
+		return fmt.Errorf("failed to update %s: %v", rcPath, err)
+	}
+	fmt.Printf("> Added %s to %s. Open a new shell or source the file to use it.\n", defName, rcPath)
+	return nil
+}
+
+// stripCodeFence removes a surrounding markdown code fence from a multi-line response.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	lines := strings.Split(text, "\n")[1:]
+	if len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[len(lines)-1]), "```") {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
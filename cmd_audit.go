@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var auditCommand = &command{
+	name:    "audit",
+	usage:   "export [flags] | verify [flags] <file>",
+	summary: "Export executed commands as signed receipts, or verify such an export",
+}
+
+// auditExportCommand describes the "audit export" subcommand for its help output.
+var auditExportCommand = &command{
+	name:    "audit export",
+	usage:   "[flags]",
+	summary: "Write the commands nlch ran as receipts signed with the local audit key",
+}
+
+// auditVerifyCommand describes the "audit verify" subcommand for its help output.
+var auditVerifyCommand = &command{
+	name:    "audit verify",
+	usage:   "[flags] <file>",
+	summary: "Check the signatures of an audit export",
+}
+
+func init() {
+	auditCommand.run = runAudit
+}
+
+func runAudit(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runAuditExport(args[1:])
+		case "verify":
+			return runAuditVerify(args[1:])
+		}
+	}
+	newFlagSet(auditCommand).Usage()
+	return errUsage
+}
+
+func runAuditExport(args []string) error {
+	fs := newFlagSet(auditExportCommand)
+	since := fs.String("since", "", "Only export commands newer than this age (e.g. 12h, 7d)")
+	format := fs.String("format", "json", "Output format: json or csv")
+	output := fs.String("o", "", "Write the export to this file instead of stdout")
+	all := fs.Bool("all", false, "Also export commands that were not run, such as dry runs and aborted ones")
+	keyPath := fs.String("key", "", "Sign with the key in this file instead of the local audit key")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown format %q, use csv or json", *format)
+	}
+
+	export := history.AuditExport{Generated: time.Now().UTC()}
+	filter := history.Filter{}
+	if *since != "" {
+		age, err := history.ParseAge(*since)
+		if err != nil {
+			return err
+		}
+		from := export.Generated.Add(-age)
+		filter.Since, export.Since = from, &from
+	}
+	store, err := history.Open()
+	if err != nil {
+		return err
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %v", err)
+	}
+	entries = filter.Apply(entries)
+	if !*all {
+		executed := entries[:0]
+		for _, e := range entries {
+			if e.Decision == history.DecisionExecuted {
+				executed = append(executed, e)
+			}
+		}
+		entries = executed
+	}
+
+	key, err := history.AuditKey(*keyPath, true)
+	if err != nil {
+		return err
+	}
+	export.Receipts = history.Receipts(entries, key)
+	export.Sign(key)
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := history.WriteAudit(out, *format, export); err != nil {
+		return fmt.Errorf("failed to write export: %v", err)
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "> Exported %d receipts to %s, signed with key %s.\n", len(export.Receipts), *output, export.KeyID)
+	}
+	return nil
+}
+
+func runAuditVerify(args []string) error {
+	fs := newFlagSet(auditVerifyCommand)
+	keyPath := fs.String("key", "", "Verify with the key in this file instead of the local audit key")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	export, err := history.ReadAudit(data)
+	if err != nil {
+		return err
+	}
+	receipts := export.Receipts
+	key, err := history.AuditKey(*keyPath, false)
+	if err != nil {
+		return err
+	}
+	if i := history.VerifyReceipts(receipts, key); i >= 0 {
+		return fmt.Errorf("receipt %d (history id %d) does not match its signature: it or an earlier receipt was changed, removed or reordered, or it was signed with another key than %s", i+1, receipts[i].ID, history.KeyID(key))
+	}
+	if !history.VerifyEnvelope(export, key) {
+		return fmt.Errorf("the export does not match its signature: receipts were removed from its end, its time, period or receipt count was changed, or it was signed with another key than %s", history.KeyID(key))
+	}
+	if len(receipts) == 0 {
+		return errors.New("the export has no receipts")
+	}
+	fmt.Printf("> %s %d receipts signed with key %s.\n", ui.Highlight("Verified"), len(receipts), history.KeyID(key))
+	return nil
+}
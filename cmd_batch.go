@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var batchCommand = &command{
+	name:    "batch",
+	usage:   "[flags] <file|->",
+	summary: "Generate a command for each line of a file, then run them all after one approval",
+}
+
+func init() {
+	batchCommand.run = runBatch
+}
+
+// batchItem is one request of a batch and the command generated for it.
+type batchItem struct {
+	request string
+	line    int
+	system  string
+	prompt  string
+	command string // without the danger prefix
+	risk    shell.Risk
+	reason  string
+	rewrote []string // notes of the rewrite rules that changed the command
+	used    app.Usage
+	err     error // the command could not be generated, or may not run
+}
+
+func runBatch(args []string) error {
+	fs := newFlagSet(batchCommand)
+	jobs := fs.Int("jobs", 4, "Number of requests sent to the provider at once")
+	dryRun := fs.Bool("dry-run", false, "Print the commands as a script but do not run them")
+	keepGoing := fs.Bool("keep-going", false, "Run the remaining commands after one fails")
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	shellFlag := fs.String("shell", "", "Write the commands for this shell: bash, sh, zsh, fish or nu (default from shell, or $SHELL when it is fish or nu)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *jobs < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	items, err := readBatch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no requests in %s", fs.Arg(0))
+	}
+
+	cfg, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+	target, program, err := targetShell(cfg, *shellFlag, "")
+	if err != nil && (target == "" || !*dryRun) {
+		return err
+	}
+	modelUsed := resolveModel(prov, cfg, providerName, *model)
+	ctx := gatherContext()
+	promptOpts := prompt.Options{
+		Packs:         cfg.Packs,
+		DisabledPacks: cfg.DisabledPacks,
+		Never:         cfg.Never,
+		Model:         modelUsed,
+		ReadOnly:      cfg.ReadOnly,
+		Shell:         target,
+	}
+	_, promptOpts.Instructions = projectInstructions(cfg, modelUsed)
+	opts := provider.ProviderOptions{Model: *model, Provider: providerName}
+
+	// Each request gets its own prompt, since packs and lessons depend on it
+	total, priced := 0.0, true
+	for _, item := range items {
+		o := promptOpts
+		o.Lessons = feedbackLessons(item.request)
+		fitted := prompt.Fit(ctx, item.request, o, provider.DefaultMaxTokens)
+		item.system, item.prompt = fitted.System, fitted.Prompt
+		cost, ok := estimateCost(modelUsed, item.options(opts), item.prompt)
+		total += cost
+		priced = priced && ok
+	}
+	if err := confirmBatchCost(cfg.MaxCost, modelUsed, total, priced, len(items)); err != nil {
+		return err
+	}
+
+	// Generate concurrently, at most jobs at a time; providers retry requests
+	// that are rate limited, so a full batch slows down rather than fails
+	fmt.Fprintf(os.Stderr, "> Generating %d commands with %s...\n", len(items), modelLabel(providerName, modelUsed))
+	sem := make(chan struct{}, *jobs)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			o := item.options(opts)
+			reply, err := prov.GenerateCommand(*ctx, item.prompt, o)
+			if err != nil {
+				item.err = fmt.Errorf("provider error: %v", err)
+				return
+			}
+			item.used.Add(modelUsed, o, item.prompt, reply)
+			item.command = prompt.CleanCommand(reply)
+		}()
+	}
+	wg.Wait()
+
+	// Apply the same checks as a single request, and find the approval the
+	// most risky command needs
+	check := localCheck(cfg, providerName)
+	confirm := shell.ConfirmYesNo
+	runnable := 0
+	warned := false
+	for _, item := range items {
+		if item.err == nil && item.command == "" {
+			item.err = errors.New("LLM did not return a command")
+		}
+		if item.err != nil {
+			continue
+		}
+		cmd, rewrote, err := app.Rewrite(item.command, cfg.Rewrite)
+		if err != nil && !warned {
+			fmt.Fprintf(os.Stderr, "nlch: warning: %v\n", err)
+			warned = true
+		}
+		item.rewrote = rewrote
+		item.command = strings.TrimPrefix(cmd, DangerPrefix)
+		item.risk, item.reason = app.AssessRisk(cmd)
+		if check != nil {
+			var err error
+			if item.risk, item.reason, err = check.Raise(ctx, item.command, item.risk, item.reason); err != nil {
+				fmt.Fprintf(os.Stderr, "nlch: warning: %v\n", err)
+			}
+		}
+		item.err = app.CheckConstraints(item.command, cfg.Never)
+		if item.err == nil && cfg.ReadOnly {
+			item.err = app.CheckReadOnly(item.command)
+		}
+		if item.err == nil && app.ConfirmationFor(cfg, item.risk) == shell.ConfirmBlock {
+			item.err = fmt.Errorf("%s risk commands are %s", item.risk, shell.ErrBlocked)
+		}
+		if item.err == nil {
+			runnable++
+			confirm = confirm.AtLeast(app.ConfirmationFor(cfg, item.risk))
+		}
+	}
+
+	script := os.Stdout
+	if !*dryRun {
+		script = os.Stderr
+	}
+	printBatch(script, items, target)
+
+	wd, _ := os.Getwd()
+	record := func(item *batchItem, decision string, runErr error, output string) {
+		e := history.Entry{
+			Request:      item.request,
+			Command:      item.command,
+			Provider:     providerName,
+			Model:        modelUsed,
+			Dir:          wd,
+			Decision:     decision,
+			InputTokens:  item.used.Input,
+			OutputTokens: item.used.Output,
+		}
+		if !shell.POSIX(target) {
+			e.Shell = target
+		}
+		if decision == history.DecisionExecuted {
+			e.ExitCode = shell.ExitCode(runErr)
+			e.Output = output
+		}
+		recordHistory(e)
+	}
+	recordAll := func(decision string) {
+		for _, item := range items {
+			if item.err == nil {
+				record(item, decision, nil, "")
+			}
+		}
+	}
+
+	if runnable == 0 {
+		return errors.New("no command in the batch can run")
+	}
+	if *dryRun {
+		recordAll(history.DecisionDryRun)
+		return nil
+	}
+	approved, err := approveBatch(runnable, len(items), confirm)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		fmt.Fprintln(os.Stderr, "> Aborted by user.")
+		recordAll(history.DecisionAborted)
+		return nil
+	}
+
+	// Approval covers the whole batch, so each command runs without asking again
+	exec := newExecutor(shell.Executor{Shell: program})
+	failed := 0
+	for n, item := range items {
+		if item.err != nil {
+			continue
+		}
+		stdout, stderr, runErr := exec.Run(item.command, shell.ConfirmNone)
+		record(item, history.DecisionExecuted, runErr, stdout+stderr)
+		if runErr == nil {
+			continue
+		}
+		failed++
+		fmt.Fprintf(os.Stderr, "> %s\n", ui.Error(fmt.Sprintf("Command %d failed: %v", n+1, runErr)))
+		if !*keepGoing {
+			return fmt.Errorf("stopped after command %d failed, use --keep-going to run the rest anyway", n+1)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d commands failed", failed, runnable)
+	}
+	return nil
+}
+
+// options returns the provider options for the item's request.
+func (item *batchItem) options(opts provider.ProviderOptions) provider.ProviderOptions {
+	opts.System = item.system
+	return opts
+}
+
+// readBatch reads the requests of a batch from a file, or stdin for "-". Each
+// non-empty line is a request; lines starting with # are comments.
+func readBatch(path string) ([]*batchItem, error) {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	var items []*batchItem
+	scanner := bufio.NewScanner(in)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, &batchItem{request: line, line: n})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return items, nil
+}
+
+// printBatch writes the batch as a script for the target shell: each command
+// under its request, with commands that can't run commented out along with
+// the reason.
+func printBatch(w io.Writer, items []*batchItem, target string) {
+	shebang := "#!/bin/sh"
+	if !shell.POSIX(target) {
+		shebang = "#!/usr/bin/env " + target
+	}
+	fmt.Fprintln(w, ui.Dim(shebang))
+	for n, item := range items {
+		fmt.Fprintf(w, "\n%s\n", ui.Dim(fmt.Sprintf("# %d. %s (line %d)", n+1, item.request, item.line)))
+		for _, note := range item.rewrote {
+			fmt.Fprintln(w, ui.Dim("# rewritten: "+note))
+		}
+		switch {
+		case item.err != nil:
+			fmt.Fprintf(w, "%s\n", ui.Error("# not run: "+item.err.Error()))
+			if item.command != "" {
+				fmt.Fprintf(w, "# %s\n", item.command)
+			}
+		case item.risk >= shell.RiskHigh:
+			fmt.Fprintf(w, "%s\n%s\n", ui.Danger(fmt.Sprintf("# %s risk: %s", item.risk, item.reason)), ui.Highlight(item.command))
+		default:
+			fmt.Fprintln(w, ui.Highlight(item.command))
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// approveBatch asks once whether to run every runnable command of the batch,
+// as strictly as its most risky command requires. Without a terminal to ask,
+// nothing runs.
+func approveBatch(runnable, total int, confirm shell.Confirmation) (bool, error) {
+	var what string
+	switch {
+	case total == 1:
+		what = "the command"
+	case runnable == 1:
+		what = "the only command that can run"
+	case runnable < total:
+		what = fmt.Sprintf("the %d commands that can run", runnable)
+	default:
+		what = fmt.Sprintf("all %d commands", runnable)
+	}
+	question := fmt.Sprintf("> Run %s? [y/N]: ", what)
+	if confirm == shell.ConfirmTyped {
+		question = fmt.Sprintf("> Type 'yes' to run %s: ", what)
+	}
+	answer, err := shell.ReadTerminalLine(question)
+	if errors.Is(err, shell.ErrNoTerminal) {
+		return false, fmt.Errorf("%v, use --dry-run to only print the commands", err)
+	}
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if confirm == shell.ConfirmTyped {
+		return answer == "yes", nil
+	}
+	return answer == "y" || answer == "yes", nil
+}
+
+// confirmBatchCost asks before sending a batch estimated to cost more than
+// maxCost in total.
+func confirmBatchCost(maxCost float64, model string, total float64, priced bool, count int) error {
+	if maxCost <= 0 || !priced || total <= maxCost {
+		return nil
+	}
+	question := fmt.Sprintf("> These %d requests to %s are estimated to cost %s, more than max_cost ($%g). Send them? [y/N]: ", count, model, formatCost(total), maxCost)
+	answer, err := shell.ReadTerminalLine(question)
+	if errors.Is(err, shell.ErrNoTerminal) {
+		return fmt.Errorf("the batch is estimated to cost %s, more than max_cost ($%g); raise max_cost to send it", formatCost(total), maxCost)
+	}
+	if err != nil {
+		return err
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return errNotSent
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var benchCommand = &command{
+	name:    "bench",
+	usage:   "[flags]",
+	summary: "Compare providers and models on a suite of requests without running anything",
+}
+
+func init() {
+	benchCommand.run = runBench
+}
+
+// benchRequests is the built-in suite of everyday, non-destructive requests.
+var benchRequests = []string{
+	"list files sorted by size, largest first",
+	"find all .go files modified in the last day",
+	"show the disk usage of each directory here",
+	"count the lines in all markdown files",
+	"show the last 10 git commits, one line each",
+	"find the process listening on port 8080",
+	"compress the logs directory into a tar.gz archive",
+	"replace foo with bar in every .txt file",
+}
+
+// benchTarget is a provider and the model to benchmark it with.
+type benchTarget struct {
+	provider provider.Provider
+	name     string // provider name
+	model    string
+}
+
+func (t benchTarget) String() string {
+	return modelLabel(t.name, t.model)
+}
+
+// benchResult summarises one target's run through the suite.
+type benchResult struct {
+	latencies     []time.Duration
+	errors        int
+	safe, valid   int
+	input, output int
+}
+
+func runBench(args []string) error {
+	fs := newFlagSet(benchCommand)
+	targetsFlag := fs.String("targets", "", "Comma-separated provider[:model] list to compare (default: every configured provider)")
+	requestsFile := fs.String("requests", "", "File with one request per line to use instead of the built-in suite")
+	verbose := fs.Bool("verbose", false, "Show each generated command")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+	provider.RegisterProvidersFromConfig(cfg.Providers)
+	if err := httpclient.Configure(cfg.Network); err != nil {
+		return err
+	}
+	if err := prompt.CheckPacks(cfg.Packs, cfg.DisabledPacks); err != nil {
+		return err
+	}
+
+	targets, err := benchTargets(cfg, *targetsFlag)
+	if err != nil {
+		return err
+	}
+	requests := benchRequests
+	if *requestsFile != "" {
+		if requests, err = readLines(*requestsFile); err != nil {
+			return err
+		}
+	}
+
+	ctx := gatherContext()
+	_, instructions := projectInstructions(cfg, "")
+	fmt.Printf("Running %d requests against %d targets. Nothing will be executed.\n", len(requests), len(targets))
+	results := make([]benchResult, len(targets))
+	for i, t := range targets {
+		fmt.Printf("\n%s\n", ui.Highlight(t.String()))
+		r := &results[i]
+		for _, request := range requests {
+			promptOpts := prompt.Options{Packs: cfg.Packs, DisabledPacks: cfg.DisabledPacks, Never: cfg.Never, Model: t.model, Instructions: instructions}
+			promptStr := prompt.BuildPrompt(ctx, request, promptOpts)
+			opts := provider.ProviderOptions{Model: t.model, Provider: t.name, System: prompt.BuildSystemPrompt(promptOpts)}
+
+			start := time.Now()
+			reply, err := t.provider.GenerateCommand(*ctx, promptStr, opts)
+			elapsed := time.Since(start)
+			if err != nil {
+				r.errors++
+				fmt.Printf("  %s %s: %v\n", ui.Error("error"), request, err)
+				continue
+			}
+			r.latencies = append(r.latencies, elapsed)
+			var used app.Usage
+			used.Add(t.model, opts, promptStr, reply)
+			r.input += used.Input
+			r.output += used.Output
+
+			cmd := prompt.CleanCommand(reply)
+			flagged := strings.HasPrefix(cmd, DangerPrefix)
+			cmd = strings.TrimPrefix(cmd, DangerPrefix)
+			safe := !flagged && !shell.IsDangerousCommand(cmd) && len(shell.ViolatedConstraints(cmd, cfg.Never)) == 0
+			syntaxErr := shell.CheckSyntax(cmd)
+			if safe {
+				r.safe++
+			}
+			if syntaxErr == nil {
+				r.valid++
+			}
+			if *verbose || !safe || syntaxErr != nil {
+				notes := []string{elapsed.Round(time.Millisecond).String()}
+				if !safe {
+					notes = append(notes, "unsafe")
+				}
+				if syntaxErr != nil {
+					notes = append(notes, syntaxErr.Error())
+				}
+				fmt.Printf("  %s\n    %s  %s\n", ui.Dim(request), cmd, ui.Dim("("+strings.Join(notes, ", ")+")"))
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("%-32s %9s %9s %7s %7s %10s\n", "TARGET", "MEDIAN", "ERRORS", "SAFE", "SYNTAX", "COST")
+	for i, t := range targets {
+		r := results[i]
+		answered := len(r.latencies)
+		cost := "unknown"
+		if _, ok := tokens.PriceFor(t.model); ok {
+			cost = fmt.Sprintf("$%.4f", tokens.Cost(t.model, r.input, r.output))
+		}
+		fmt.Printf("%-32s %9s %9d %7s %7s %10s\n", t, medianLatency(r.latencies), r.errors,
+			fmt.Sprintf("%d/%d", r.safe, answered), fmt.Sprintf("%d/%d", r.valid, answered), cost)
+	}
+	return nil
+}
+
+// benchTargets parses the --targets list, defaulting to every configured provider.
+func benchTargets(cfg *config.Config, list string) ([]benchTarget, error) {
+	var specs []string
+	if list != "" {
+		specs = strings.Split(list, ",")
+	} else {
+		for name := range cfg.Providers {
+			// Offline, only the local providers are benchmarked
+			if _, ok := provider.Get(name); ok && checkOffline(cfg, name) == nil {
+				specs = append(specs, name)
+			}
+		}
+		sort.Strings(specs)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no providers to benchmark. Configure one or pass --targets")
+	}
+
+	targets := make([]benchTarget, 0, len(specs))
+	for _, spec := range specs {
+		name, model, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		prov, ok := provider.Get(name)
+		if !ok {
+			return nil, provider.NotFound(name)
+		}
+		if err := checkOffline(cfg, name); err != nil {
+			return nil, err
+		}
+		targets = append(targets, benchTarget{provider: prov, name: name, model: resolveModel(prov, cfg, name, model)})
+	}
+	return targets, nil
+}
+
+// medianLatency returns the median of the latencies, or "-" if there are none.
+func medianLatency(latencies []time.Duration) string {
+	if len(latencies) == 0 {
+		return "-"
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2].Round(time.Millisecond).String()
+}
+
+// readLines returns the non-empty lines of a file.
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+var configCommand = &command{
+	name:    "config",
+	usage:   "[path|show|edit]",
+	summary: "Show, locate or edit the configuration file",
+}
+
+func init() {
+	configCommand.run = runConfig
+}
+
+func runConfig(args []string) error {
+	fs := newFlagSet(configCommand)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	action := "show"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+
+	path, err := config.GetUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "path":
+		fmt.Println(path)
+		return nil
+	case "show":
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(redactConfig(cfg))
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		return nil
+	case "edit":
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return errors.New("no configuration found, run 'nlch init' first")
+		}
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			editor = "vi"
+			if runtime.GOOS == "windows" {
+				editor = "notepad"
+			}
+		}
+		cmd := exec.Command(editor, path)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	default:
+		fs.Usage()
+		return errUsage
+	}
+}
+
+// envReference matches a value that is only a reference to an environment
+// variable, such as $TOKEN, which is safe to show.
+var envReference = regexp.MustCompile(`^\$(\w+|\{\w+\})$`)
+
+// redactConfig returns a copy of the config with API keys and update headers,
+// which usually carry credentials, masked for display.
+func redactConfig(cfg *config.Config) *config.Config {
+	redacted := *cfg
+	redacted.Providers = make(map[string]config.ProviderConfig, len(cfg.Providers))
+	for name, p := range cfg.Providers {
+		p.Key = redactKey(p.Key)
+		redacted.Providers[name] = p
+	}
+	if cfg.Update.Headers != nil {
+		redacted.Update.Headers = make(map[string]string, len(cfg.Update.Headers))
+		for name, value := range cfg.Update.Headers {
+			if !envReference.MatchString(value) {
+				value = redactKey(value)
+			}
+			redacted.Update.Headers[name] = value
+		}
+	}
+	return &redacted
+}
+
+// redactKey masks all but the last four characters of a secret.
+func redactKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 8 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kanishka-sahoo/nlch/internal/daemon"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+)
+
+var daemonCommand = &command{
+	name:    "daemon",
+	usage:   "[flags]",
+	summary: "Keep providers and context warm in the background for faster requests",
+}
+
+func init() {
+	daemonCommand.run = runDaemon
+}
+
+func runDaemon(args []string) error {
+	fs := newFlagSet(daemonCommand)
+	stop := fs.Bool("stop", false, "Stop the running daemon")
+	status := fs.Bool("status", false, "Report whether the daemon is running")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	path, err := daemon.SocketPath()
+	if err != nil {
+		return err
+	}
+
+	if *status {
+		if daemon.Ping(path) != nil {
+			fmt.Println("The nlch daemon is not running.")
+			return nil
+		}
+		fmt.Printf("The nlch daemon is running on %s\n", path)
+		return nil
+	}
+	if *stop {
+		if err := daemon.Stop(path); err != nil {
+			return err
+		}
+		fmt.Println("Stopped the nlch daemon.")
+		return nil
+	}
+
+	server, err := daemon.Listen(path)
+	if err != nil {
+		return fmt.Errorf("failed to start daemon: %v", err)
+	}
+	defer os.Remove(path)
+
+	// Shut down cleanly so the socket is removed
+	stopForwarding := interrupt.Forward(func(os.Signal) { server.Stop() })
+	defer stopForwarding()
+
+	server.Log = os.Stderr
+	fmt.Fprintf(os.Stderr, "nlch daemon listening on %s\n", path)
+	if err := server.Serve(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var doctorCommand = &command{
+	name:    "doctor",
+	summary: "Check the configuration and environment for common problems",
+}
+
+func init() {
+	doctorCommand.run = runDoctor
+}
+
+func runDoctor(args []string) error {
+	fs := newFlagSet(doctorCommand)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	failures := 0
+	report := func(ok bool, format string, a ...any) {
+		mark := ui.Icon("✓", "[ok]")
+		if !ok {
+			mark = ui.Icon("✗", "[fail]")
+			failures++
+		}
+		fmt.Printf("%s %s\n", mark, fmt.Sprintf(format, a...))
+	}
+
+	path, err := config.GetUserConfigPath()
+	if err != nil {
+		report(false, "Could not determine config path: %v", err)
+	} else {
+		_, statErr := os.Stat(path)
+		report(statErr == nil, "Config file %s", path)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		report(false, "Config loads: %v", err)
+	} else {
+		report(true, "Config loads")
+		provider.RegisterProvidersFromConfig(cfg.Providers)
+
+		if err := provider.Invalid(cfg.DefaultProvider); err != nil {
+			report(false, "Default provider %q is configured: %v", cfg.DefaultProvider, err)
+		} else {
+			_, ok := provider.Get(cfg.DefaultProvider)
+			report(ok, "Default provider %q is configured", cfg.DefaultProvider)
+		}
+
+		for name, p := range cfg.Providers {
+			if name == "ollama" {
+				continue
+			}
+			ok, credentials := providerCredentials(name, p)
+			report(ok, "Provider %q credentials: %s", name, credentials)
+		}
+		if err := prompt.CheckPacks(cfg.Packs, cfg.DisabledPacks); err != nil {
+			report(false, "Prompt packs: %v", err)
+		}
+	}
+
+	// Without bash, commands still run with /bin/sh, but bash syntax will fail
+	_, err = exec.LookPath("bash")
+	report(err == nil, "bash is available on PATH (commands run with %s)", shell.Interpreter())
+	_, err = exec.LookPath("git")
+	report(err == nil, "git is available on PATH")
+
+	if failures > 0 {
+		return fmt.Errorf("%d check(s) failed", failures)
+	}
+	fmt.Println("Everything looks good.")
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var explainCommand = &command{
+	name:    "explain",
+	usage:   "[flags] <command>",
+	summary: "Explain an existing shell command in plain English",
+}
+
+func init() {
+	explainCommand.run = runExplain
+}
+
+func runExplain(args []string) error {
+	fs := newFlagSet(explainCommand)
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	// Read the command from the arguments, or from stdin when none are given or "-" is passed
+	target := strings.Join(fs.Args(), " ")
+	if target == "" || target == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read command from stdin: %v", err)
+		}
+		target = string(data)
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return errors.New("no command to explain")
+	}
+
+	_, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+
+	ctx := gatherContext()
+	opts := provider.ProviderOptions{
+		Model:    *model,
+		Provider: providerName,
+	}
+	explanation, err := app.Explain(prov, ctx, target, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("> %s\n\n", ui.Highlight(ui.WrapCommand(target, len("> "))))
+	fmt.Println(ui.Markdown(explanation))
+	return nil
+}
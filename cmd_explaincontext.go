@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var explainContextCommand = &command{
+	name:    "explain-context",
+	usage:   "[flags] [\"request\"]",
+	summary: "Show the context that would be sent to the provider from this directory, without sending anything",
+}
+
+func init() {
+	explainContextCommand.run = runExplainContext
+}
+
+func runExplainContext(args []string) error {
+	fs := newFlagSet(explainContextCommand)
+	model := fs.String("model", "", "Show the context as it would be sent to this model")
+	providerFlag := fs.String("provider", "", "Show the context as it would be sent to this provider")
+	full := fs.Bool("full", false, "Also print the exact system prompt and prompt")
+	fs.BoolVar(&fullGitStatus, "git-status", false, "Include the full git status, without summarizing it, even in repositories with more than 100,000 tracked files")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	userInput := strings.Join(fs.Args(), " ")
+	if userInput == "" {
+		userInput = "<your request>"
+	}
+
+	// The config is only read: auditing must not start the first-time setup
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: %v\n", err)
+		cfg = &config.Config{}
+	}
+	if err := prompt.CheckPacks(cfg.Packs, cfg.DisabledPacks); err != nil {
+		return err
+	}
+	provider.RegisterProvidersFromConfig(cfg.Providers)
+	providerName := cfg.DefaultProvider
+	if *providerFlag != "" {
+		providerName = *providerFlag
+	}
+	modelUsed := *model
+	prov, ok := provider.Get(providerName)
+	if ok {
+		modelUsed = resolveModel(prov, cfg, providerName, *model)
+	}
+
+	ctx := gatherContext()
+	promptOpts := prompt.Options{
+		Packs:         cfg.Packs,
+		DisabledPacks: cfg.DisabledPacks,
+		Never:         cfg.Never,
+		Model:         modelUsed,
+		ReadOnly:      cfg.ReadOnly,
+	}
+	promptOpts.Shell, _ = shell.Target(cfg.Shell)
+	instructionsPath, instructions := projectInstructions(cfg, modelUsed)
+	promptOpts.Instructions = instructions
+	fitted := prompt.Fit(ctx, userInput, promptOpts, provider.DefaultMaxTokens)
+	count := func(text string) string {
+		return ui.Dim(fmt.Sprintf("(%d tokens)", tokens.Estimate(modelUsed, text)))
+	}
+
+	switch {
+	case !ok:
+		fmt.Printf("Provider: %s (not configured)\n", providerName)
+	case destination(prov) != "":
+		fmt.Printf("Provider: %s, sent to %s\n", providerName, destination(prov))
+	default:
+		fmt.Printf("Provider: %s, handled inside nlch\n", providerName)
+	}
+	if modelUsed != "" {
+		fmt.Printf("Model: %s\n", modelUsed)
+	}
+	fmt.Printf("Working directory: %s %s\n", ctx.WorkingDir, count(ctx.WorkingDir))
+
+	budget := prompt.BudgetFor(modelUsed)
+	fmt.Printf("Files: %s in the directory, up to %d sent %s\n", fileCount(ctx), budget.Files, count(strings.Join(ctx.Files[:min(len(ctx.Files), budget.Files)], " ")))
+	for _, name := range ctx.Files[:min(len(ctx.Files), budget.Files)] {
+		fmt.Printf("  %s\n", name)
+	}
+
+	if len(ctx.GitInfo) == 0 {
+		fmt.Println("Git: no repository")
+	} else {
+		fmt.Println("Git:")
+		if branch := ctx.GitInfo["branch"]; branch != "" {
+			fmt.Printf("  Branch: %s\n", branch)
+		}
+		if status := ctx.GitInfo["status"]; status != "" {
+			fmt.Printf("  Status: up to %d tokens sent %s\n%s\n", budget.GitStatus, count(status), indent(status, "    "))
+		}
+		if paths := ctx.GitInfo["status_paths"]; paths != "" {
+			fmt.Printf("  Status: summarized, %s paths changed (use --git-status to list them all)\n", paths)
+		}
+		if tracked := ctx.GitInfo["status_skipped"]; tracked != "" {
+			fmt.Printf("  Status: left out, the repository tracks %s files (use --git-status to include it)\n", tracked)
+		}
+	}
+
+	if ctx.Locale != "" {
+		fmt.Printf("Locale: %s\n", ctx.Locale)
+	}
+	if ctx.Timezone != "" {
+		fmt.Printf("Timezone: %s\n", ctx.Timezone)
+	}
+
+	if len(ctx.Extra) > 0 {
+		fmt.Println("Plugin context:")
+		keys := make([]string, 0, len(ctx.Extra))
+		for k := range ctx.Extra {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value := fmt.Sprint(ctx.Extra[k])
+			fmt.Printf("  %s: %s %s\n", k, value, count(value))
+		}
+	}
+
+	if active := prompt.ActivePacks(ctx, userInput, cfg.Packs, cfg.DisabledPacks); len(active) > 0 {
+		names := make([]string, 0, len(active))
+		for _, p := range active {
+			names = append(names, p.Name)
+		}
+		fmt.Printf("Prompt packs: %s\n", strings.Join(names, ", "))
+	}
+	if !shell.POSIX(promptOpts.Shell) {
+		fmt.Printf("Target shell: %s, with its syntax guidance in the system prompt\n", promptOpts.Shell)
+	}
+	if instructionsPath != "" {
+		fmt.Printf("Project instructions: %s %s\n", instructionsPath, count(instructions))
+	}
+
+	fmt.Printf("\nTotal: about %d tokens, with the system prompt", fitted.Tokens)
+	if fitted.Window > 0 {
+		fmt.Printf(", of a %d-token context window", fitted.Window)
+	}
+	fmt.Println()
+	if len(fitted.Dropped) > 0 {
+		dropped := make([]string, len(fitted.Dropped))
+		for i, section := range fitted.Dropped {
+			dropped[i] = string(section)
+		}
+		fmt.Printf("Left out to fit the context window: %s\n", strings.Join(dropped, ", "))
+	}
+	fmt.Println("Requests may also include the previous command and its output (--continue), feedback on similar past requests and attached images.")
+
+	if *full {
+		fmt.Printf("\n%s\n%s\n\n%s\n%s\n", ui.Dim("--- System prompt ---"), fitted.System, ui.Dim("--- Prompt ---"), fitted.Prompt)
+	}
+	return nil
+}
+
+// fileCount describes how many entries the working directory has, which may
+// be more than are listed.
+func fileCount(ctx *context.Context) string {
+	if ctx.FileCount >= context.MaxCounted {
+		return fmt.Sprintf("at least %d", ctx.FileCount)
+	}
+	return strconv.Itoa(max(ctx.FileCount, len(ctx.Files)))
+}
+
+// destination describes where a provider sends requests: the host of its API
+// endpoint, or its URL when that is on this machine. It is empty for
+// providers that answer inside nlch.
+func destination(prov provider.Provider) string {
+	var endpoint string
+	switch p := prov.(type) {
+	case interface{ GetEndpoint() string }:
+		endpoint = p.GetEndpoint()
+	case *provider.OllamaProvider:
+		endpoint = p.URL
+	default:
+		return ""
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return u.Scheme + "://" + u.Host + " (this machine)"
+	}
+	return host
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var feedbackCommand = &command{
+	name:    "feedback",
+	usage:   "[flags] <good|bad> [note]",
+	summary: "Rate the last generated command to improve future ones",
+}
+
+func init() {
+	feedbackCommand.run = runFeedback
+}
+
+func runFeedback(args []string) error {
+	fs := newFlagSet(feedbackCommand)
+	id := fs.Int("id", 0, "Rate this history entry instead of the last one")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	rating, ok := parseRating(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("invalid rating %q, use good or bad", fs.Arg(0))
+	}
+
+	entry, err := historyEntryOrLast(*id)
+	if err != nil {
+		return err
+	}
+	store, err := history.Open()
+	if err != nil {
+		return err
+	}
+	f := history.Feedback{
+		EntryID: entry.ID,
+		Rating:  rating,
+		Note:    strings.Join(fs.Args()[1:], " "),
+	}
+	if err := store.AddFeedback(f); err != nil {
+		return fmt.Errorf("failed to save feedback: %v", err)
+	}
+	fmt.Printf("Recorded %s feedback for: %s\n", rating, ui.Highlight(entry.Command))
+	return nil
+}
+
+// parseRating maps the accepted spellings of a rating to its stored value.
+func parseRating(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "good", "y", "yes", "+", "+1", "👍":
+		return history.RatingGood, true
+	case "bad", "n", "no", "-", "-1", "👎":
+		return history.RatingBad, true
+	}
+	return "", false
+}
+
+// askFeedback asks the user to rate an executed command and records the answer.
+// An empty answer skips the rating.
+func askFeedback(id int) {
+	if id == 0 {
+		return
+	}
+	answer := shell.ReadLine(fmt.Sprintf("> Was this the right command? %s ", ui.Icon("[👍 y/👎 n/Enter to skip]", "[y/n/Enter to skip]")))
+	rating, ok := parseRating(answer)
+	if !ok {
+		return
+	}
+	f := history.Feedback{EntryID: id, Rating: rating}
+	if rating == history.RatingBad {
+		f.Note = strings.TrimSpace(shell.ReadLine("> What was wrong (optional): "))
+	}
+	store, err := history.Open()
+	if err == nil {
+		err = store.AddFeedback(f)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: failed to save feedback: %v\n", err)
+	}
+}
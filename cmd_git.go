@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/snippets"
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
+)
+
+var gitCommand = &command{
+	name:    "git",
+	usage:   "commit [flags] [description]",
+	summary: "Git workflow helpers, such as writing the commit message for staged changes",
+}
+
+// gitCommitCommand describes the "git commit" subcommand for its help output.
+var gitCommitCommand = &command{
+	name:    "git commit",
+	usage:   "[flags] [description]",
+	summary: "Write a conventional commit message for the staged changes and commit them",
+}
+
+func init() {
+	gitCommand.run = runGit
+}
+
+// Maximum number of tokens in a commit message response.
+const commitMaxTokens = 512
+
+// Number of recent commit subjects shown to the model as examples.
+const commitRecentSubjects = 10
+
+func runGit(args []string) error {
+	if len(args) > 0 && args[0] == "commit" {
+		return runGitCommit(args[1:])
+	}
+	fs := newFlagSet(gitCommand)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	fs.Usage()
+	return errUsage
+}
+
+func runGitCommit(args []string) error {
+	fs := newFlagSet(gitCommitCommand)
+	dryRun := fs.Bool("dry-run", false, "Show the message and the git command but do not commit")
+	printOnly := fs.Bool("print", false, "Print only the message, e.g. from a prepare-commit-msg hook")
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	hint := strings.Join(fs.Args(), " ")
+
+	diff, err := gitOutput("diff", "--cached", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return errors.New("nothing is staged for commit, stage your changes with git add first")
+	}
+	stat, err := gitOutput("diff", "--cached", "--no-color", "--stat")
+	if err != nil {
+		return err
+	}
+	// A repository without commits has no log yet
+	var recent []string
+	if subjects, err := gitOutput("log", "-n", fmt.Sprint(commitRecentSubjects), "--format=%s"); err == nil && strings.TrimSpace(subjects) != "" {
+		recent = strings.Split(strings.TrimSpace(subjects), "\n")
+	}
+
+	cfg, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+	if cfg.ReadOnly && !*printOnly {
+		return errors.New("read-only mode: refusing to commit, use --print to only write the message")
+	}
+	modelUsed := resolveModel(prov, cfg, providerName, *model)
+	diff, truncated := tokens.Truncate(modelUsed, diff, prompt.BudgetFor(modelUsed).Diff)
+
+	ctx := gatherContext()
+	opts := provider.ProviderOptions{
+		Model:     *model,
+		Provider:  providerName,
+		System:    withProjectInstructions(cfg, prompt.CommitSystemPrompt),
+		MaxTokens: commitMaxTokens,
+		Raw:       true,
+	}
+	promptStr := prompt.BuildCommitPrompt(strings.TrimSpace(stat), diff, truncated, recent, hint)
+	if err := confirmCost(cfg, modelUsed, opts, promptStr); err != nil {
+		return err
+	}
+
+	var used app.Usage
+	generate := func(promptStr string, opts provider.ProviderOptions) (string, error) {
+		reply, err := prov.GenerateCommand(*ctx, promptStr, opts)
+		if err != nil {
+			return "", fmt.Errorf("provider error: %v", err)
+		}
+		used.Add(modelUsed, opts, promptStr, reply)
+		message := stripCodeFence(reply)
+		if message == "" {
+			return "", errors.New("LLM did not write a commit message")
+		}
+		return message, nil
+	}
+	message, err := generate(promptStr, opts)
+	if err != nil {
+		return err
+	}
+
+	// A hook only needs the message; git itself makes the commit
+	if *printOnly {
+		fmt.Println(message)
+		return nil
+	}
+
+	exec := newExecutor(shell.Executor{DryRun: *dryRun, AllowRefine: true})
+	var conversation []provider.Message
+	var cmd, stdout, stderr string
+	var risk shell.Risk
+	for {
+		cmd = commitCommand(message)
+		if err := app.CheckConstraints(cmd, cfg.Never); err != nil {
+			return err
+		}
+		fmt.Printf("> Commit message:\n\n%s\n\n", indent(message, "  "))
+		// Committing always asks, and asks for more where the config says so
+		risk, _ = app.AssessRisk(cmd)
+		stdout, stderr, err = exec.Run(cmd, app.ConfirmationFor(cfg, risk).AtLeast(shell.ConfirmYesNo))
+		if !errors.Is(err, shell.ErrRefine) {
+			break
+		}
+
+		// Revise the message with the user's adjustment, keeping the prior exchange as conversation history
+		refinement := strings.TrimSpace(exec.ReadLine("> Refine: "))
+		if refinement == "" {
+			continue
+		}
+		conversation = append(conversation,
+			provider.Message{Role: "user", Content: promptStr},
+			provider.Message{Role: "assistant", Content: message},
+		)
+		promptStr = prompt.BuildCommitRefinePrompt(refinement)
+		refineOpts := opts
+		refineOpts.History = conversation
+		if message, err = generate(promptStr, refineOpts); err != nil {
+			return err
+		}
+	}
+
+	e := history.Entry{
+		Request:      "git commit",
+		Command:      cmd,
+		Provider:     providerName,
+		Model:        modelUsed,
+		Dir:          ctx.WorkingDir,
+		Decision:     app.Decision(*dryRun, err),
+		InputTokens:  used.Input,
+		OutputTokens: used.Output,
+	}
+	if hint != "" {
+		e.Request += ": " + hint
+	}
+	if e.Decision == history.DecisionExecuted {
+		e.ExitCode = shell.ExitCode(err)
+		e.Output = stdout + stderr
+	}
+	recordHistory(e)
+
+	if e.Decision == history.DecisionBlocked {
+		return fmt.Errorf("%s-risk commands are blocked, change confirm.%s in the config to allow them", risk, risk)
+	}
+	if err != nil && e.Decision == history.DecisionExecuted {
+		return fmt.Errorf("git commit failed: %v", err)
+	}
+	return nil
+}
+
+// commitCommand returns the git command that commits with the message, as a
+// -m for the summary line and another for the body, if there is one.
+func commitCommand(message string) string {
+	summary, body, _ := strings.Cut(message, "\n")
+	cmd := "git commit -m " + snippets.Quote(strings.TrimSpace(summary))
+	if body = strings.TrimSpace(body); body != "" {
+		cmd += " -m " + snippets.Quote(body)
+	}
+	return cmd
+}
+
+// indent prefixes every non-empty line of text.
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// gitOutput runs git in the current directory and returns its output, or an
+// error with what git printed on failure.
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return string(out), nil
+}
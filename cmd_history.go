@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var historyCommand = &command{
+	name:    "history",
+	usage:   "[flags] | run <id> | search <query> | pick [query] | purge [flags]",
+	summary: "List, search or re-run past requests and commands",
+}
+
+// historyPurgeCommand describes the "history purge" subcommand for its help output.
+var historyPurgeCommand = &command{
+	name:    "history purge",
+	usage:   "[flags]",
+	summary: "Delete recorded history and feedback",
+}
+
+func init() {
+	historyCommand.run = runHistory
+}
+
+func runHistory(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			return runHistoryRun(args[1:])
+		case "search":
+			return runHistorySearch(args[1:])
+		case "pick":
+			return runHistoryPick(args[1:])
+		case "purge":
+			return runHistoryPurge(args[1:])
+		}
+	}
+
+	fs := newFlagSet(historyCommand)
+	limit := fs.Int("n", 20, "Number of most recent entries to show (0 for all)")
+	providerFlag := fs.String("provider", "", "Only show entries generated by this provider")
+	since := fs.String("since", "", "Only show entries newer than this age (e.g. 12h, 7d)")
+	failed := fs.Bool("failed", false, "Only show commands that exited with an error")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	filter := history.Filter{
+		Provider: *providerFlag,
+		Failed:   *failed,
+		Limit:    *limit,
+	}
+	if *since != "" {
+		age, err := history.ParseAge(*since)
+		if err != nil {
+			return err
+		}
+		filter.Since = time.Now().Add(-age)
+	}
+
+	store, err := history.Open()
+	if err != nil {
+		return err
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %v", err)
+	}
+
+	entries = filter.Apply(entries)
+	if len(entries) == 0 {
+		fmt.Println("No history entries found.")
+		return nil
+	}
+	for _, e := range entries {
+		printHistoryEntry(e)
+	}
+	return nil
+}
+
+// runHistorySearch lists the entries whose request or command matches the query.
+func runHistorySearch(args []string) error {
+	query := strings.Join(args, " ")
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("usage: nlch history search <query>")
+	}
+
+	store, err := history.Open()
+	if err != nil {
+		return err
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %v", err)
+	}
+
+	entries = history.Search(entries, query)
+	if len(entries) == 0 {
+		fmt.Println("No matching history entries found.")
+		return nil
+	}
+	for _, e := range entries {
+		printHistoryEntry(e)
+	}
+	return nil
+}
+
+// runHistoryPick opens fzf over past commands, annotated with the requests
+// that produced them, and prints the selected command. The shell integration
+// binds it to a key to insert the command into the command line.
+func runHistoryPick(args []string) error {
+	if _, err := exec.LookPath("fzf"); err != nil {
+		return fmt.Errorf("nlch history pick needs fzf, see https://github.com/junegunn/fzf; use nlch history search instead")
+	}
+
+	store, err := history.Open()
+	if err != nil {
+		return err
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %v", err)
+	}
+
+	// Newest first, each command once, with the request dimmed beside it.
+	// Lines start with the entry ID, which fzf hides, to look the command up
+	// again since multi-line commands are flattened for display
+	var lines strings.Builder
+	commands := map[int]string{}
+	seen := map[string]bool{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Command == "" || e.Decision == history.DecisionBlocked || seen[e.Command] {
+			continue
+		}
+		seen[e.Command] = true
+		commands[e.ID] = e.Command
+		shown := strings.Join(strings.Fields(e.Command), " ")
+		fmt.Fprintf(&lines, "%d\t%s\t\x1b[2m# %s\x1b[0m\n", e.ID, shown, strings.Join(strings.Fields(e.Request), " "))
+	}
+	if len(commands) == 0 {
+		return fmt.Errorf("no commands in the history yet")
+	}
+
+	fzf := exec.Command("fzf", "--ansi", "--delimiter=\t", "--with-nth=2..", "--tiebreak=index",
+		"--height=40%", "--layout=reverse", "--prompt=nlch> ", "--query="+strings.Join(args, " "))
+	fzf.Stdin = strings.NewReader(lines.String())
+	fzf.Stderr = os.Stderr
+	out, err := fzf.Output()
+	if err != nil {
+		// fzf exits with 1 when nothing matched and 130 when cancelled
+		if code := shell.ExitCode(err); code == 1 || code == 130 {
+			return nil
+		}
+		return fmt.Errorf("fzf failed: %v", err)
+	}
+	id, _, _ := strings.Cut(string(out), "\t")
+	if n, err := strconv.Atoi(id); err == nil {
+		fmt.Println(commands[n])
+	}
+	return nil
+}
+
+// runHistoryPurge deletes all history, or the entries older than a given age.
+func runHistoryPurge(args []string) error {
+	fs := newFlagSet(historyPurgeCommand)
+	olderThan := fs.String("older-than", "", "Only delete entries older than this age (e.g. 30d)")
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var before time.Time
+	what := "all history and feedback"
+	if *olderThan != "" {
+		age, err := history.ParseAge(*olderThan)
+		if err != nil {
+			return err
+		}
+		before = time.Now().Add(-age)
+		what = "history entries older than " + *olderThan
+	}
+
+	if !*yes {
+		answer := strings.ToLower(strings.TrimSpace(shell.ReadLine(fmt.Sprintf("> Delete %s? [y/N]: ", what))))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("> Aborted by user.")
+			return nil
+		}
+	}
+
+	store, err := history.Open()
+	if err != nil {
+		return err
+	}
+	removed, err := store.Purge(before)
+	if err != nil {
+		return fmt.Errorf("failed to purge history: %v", err)
+	}
+	fmt.Printf("Deleted %d history entries.\n", removed)
+	return nil
+}
+
+// printHistoryEntry prints a single entry as a two-line summary.
+func printHistoryEntry(e history.Entry) {
+	fmt.Printf("%5d  %s  %-10s %s\n", e.ID, e.Time.Local().Format("2006-01-02 15:04"), historyStatus(e), ui.Highlight(e.Command))
+	fmt.Printf("       %s\n", ui.Dim(e.Request))
+	if e.Capture != "" {
+		fmt.Printf("       %s\n", ui.Dim("output in "+e.Capture))
+	}
+}
+
+// historyStatus summarises the outcome of an entry.
+func historyStatus(e history.Entry) string {
+	status := e.Decision
+	if e.Decision == history.DecisionExecuted {
+		status = "exit " + strconv.Itoa(e.ExitCode)
+	}
+	if e.Corrected {
+		status += "*"
+	}
+	return status
+}
+
+// runHistoryRun re-executes a past command after confirmation.
+func runHistoryRun(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: nlch history run <id>")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid history id %q", args[0])
+	}
+
+	store, err := history.Open()
+	if err != nil {
+		return err
+	}
+	entry, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("> Request: %s\n", entry.Request)
+
+	wd, _ := os.Getwd()
+	for _, change := range entry.EnvironmentChanges(wd, gitBefore()) {
+		fmt.Printf("> %s %s\n", ui.Danger("Note:"), change)
+	}
+	cfg, _ := config.Load() // nil when unreadable, which uses the default confirmations
+	// A replayed command is still subject to the constraints configured now
+	if cfg != nil {
+		if err := app.CheckConstraints(entry.Command, cfg.Never); err != nil {
+			return err
+		}
+		if cfg.ReadOnly {
+			if err := app.CheckReadOnly(entry.Command); err != nil {
+				return err
+			}
+		}
+	}
+	// The command runs in the shell it was written for, whatever the default is now
+	program, err := shell.Program(entry.Shell)
+	if err != nil {
+		return err
+	}
+	exec := newExecutor(shell.Executor{Shell: program})
+	stdout, stderr, runErr := exec.Run(entry.Command, app.ReplayConfirmation(cfg, entry.Command))
+	e := history.Entry{
+		Request:  entry.Request,
+		Command:  entry.Command,
+		Provider: entry.Provider,
+		Model:    entry.Model,
+		Dir:      wd,
+		Decision: app.Decision(false, runErr),
+		Shell:    entry.Shell,
+	}
+	if e.Decision == history.DecisionExecuted {
+		e.ExitCode = shell.ExitCode(runErr)
+		e.Output = stdout + stderr
+	}
+	recordHistory(e)
+
+	if errors.Is(runErr, shell.ErrBlocked) {
+		return fmt.Errorf("this command is %s", runErr)
+	}
+	if runErr != nil && e.Decision == history.DecisionExecuted {
+		return fmt.Errorf("command failed: %v", runErr)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+)
+
+var initCommand = &command{
+	name:    "init",
+	usage:   "[flags]",
+	summary: "Run the setup wizard to add or reconfigure providers",
+}
+
+func init() {
+	initCommand.run = runInit
+}
+
+func runInit(args []string) error {
+	fs := newFlagSet(initCommand)
+	reset := fs.Bool("reset", false, "Discard the existing configuration and start over")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	path, err := config.GetUserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	// Without an existing config (or when starting over), run the first-time setup
+	if _, err := os.Stat(path); os.IsNotExist(err) || *reset {
+		_, err = config.CreateInitialConfig()
+		return err
+	}
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		return fmt.Errorf("existing configuration could not be loaded (use --reset to start over): %v", err)
+	}
+	fmt.Printf("Updating the configuration at %s.\n\n", path)
+	return config.AddProviders(cfg)
+}
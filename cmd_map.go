@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var mapCommand = &command{
+	name:    "map",
+	usage:   "[flags] \"Describe the transformation\" < input",
+	summary: "Build a sed/awk/jq filter for the records on stdin and apply it",
+}
+
+func init() {
+	mapCommand.run = runMap
+}
+
+func runMap(args []string) error {
+	fs := newFlagSet(mapCommand)
+	yes := fs.Bool("yes", false, "Apply the filter without asking for confirmation")
+	printOnly := fs.Bool("print", false, "Print the filter to stdout instead of applying it")
+	sampleSize := fs.Int("sample", 10, "Number of input records shown to the LLM")
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	shellFlag := fs.String("shell", "", "Write the filter for this shell: bash, sh, zsh, fish or nu (default from shell, or $SHELL when it is fish or nu)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	description := strings.Join(fs.Args(), " ")
+	if description == "" {
+		fs.Usage()
+		return errUsage
+	}
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return errors.New("nlch map transforms the records on stdin; pipe some input into it")
+	}
+
+	// Read a sample of the input, keeping it to feed to the filter ahead of the rest
+	input := bufio.NewReader(os.Stdin)
+	var head bytes.Buffer
+	var sample []string
+	for len(sample) < *sampleSize {
+		line, err := input.ReadString('\n')
+		head.WriteString(line)
+		if line != "" {
+			sample = append(sample, strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			break
+		}
+	}
+	if len(sample) == 0 {
+		return errors.New("no input on stdin")
+	}
+
+	cfg, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+	target, program, err := targetShell(cfg, *shellFlag, "")
+	if err != nil && (target == "" || !*printOnly) {
+		return err
+	}
+
+	ctx := gatherContext()
+	opts := provider.ProviderOptions{
+		Model:    *model,
+		Provider: providerName,
+		System:   withProjectInstructions(cfg, prompt.MapSystem(target)),
+	}
+	promptStr := prompt.BuildMapPrompt(ctx, description, sample)
+	modelUsed := resolveModel(prov, cfg, providerName, *model)
+	if err := confirmCost(cfg, modelUsed, opts, promptStr); err != nil {
+		return err
+	}
+	reply, err := prov.GenerateCommand(*ctx, promptStr, opts)
+	if err != nil {
+		return fmt.Errorf("provider error: %v", err)
+	}
+	var used app.Usage
+	used.Add(modelUsed, opts, promptStr, reply)
+
+	filter := prompt.CleanCommand(reply)
+	if filter == "" {
+		return errors.New("LLM did not return a filter")
+	}
+	risk, reason := app.AssessRisk(filter)
+	dangerous := strings.HasPrefix(filter, DangerPrefix)
+	filter = strings.TrimPrefix(filter, DangerPrefix)
+	if check := localCheck(cfg, providerName); check != nil {
+		if risk, reason, err = check.Raise(ctx, filter, risk, reason); err != nil {
+			fmt.Fprintf(os.Stderr, "nlch: warning: %v\n", err)
+		}
+	}
+	record := func(decision string, runErr error) {
+		e := history.Entry{
+			Request:      "map: " + description,
+			Command:      filter,
+			Provider:     providerName,
+			Model:        modelUsed,
+			Dir:          ctx.WorkingDir,
+			Decision:     decision,
+			InputTokens:  used.Input,
+			OutputTokens: used.Output,
+		}
+		if !shell.POSIX(target) {
+			e.Shell = target
+		}
+		if decision == history.DecisionExecuted {
+			e.ExitCode = shell.ExitCode(runErr)
+		}
+		recordHistory(e)
+	}
+
+	if err := app.CheckConstraints(filter, cfg.Never); err != nil {
+		record(history.DecisionBlocked, nil)
+		return err
+	}
+	if cfg.ReadOnly {
+		if err := app.CheckReadOnly(filter); err != nil {
+			record(history.DecisionBlocked, nil)
+			return err
+		}
+	}
+	// A filter only reads stdin and writes stdout, so anything flagged dangerous is refused
+	if dangerous {
+		fmt.Fprintf(os.Stderr, "> %s %s\n", ui.Danger("Dangerous filter:"), ui.Highlight(filter))
+		record(history.DecisionBlocked, nil)
+		return errors.New("the generated filter is dangerous and was not applied")
+	}
+
+	if *printOnly {
+		fmt.Println(filter)
+		record(history.DecisionPrinted, nil)
+		return nil
+	}
+
+	// --yes answers the Y/n question, but not the typed confirmation or a block
+	confirm := app.ConfirmationFor(cfg, risk).AtLeast(shell.ConfirmYesNo)
+	if *yes && confirm == shell.ConfirmYesNo {
+		confirm = shell.ConfirmNone
+	}
+	label := "Filter:"
+	if confirm == shell.ConfirmTyped {
+		label = ui.Danger(fmt.Sprintf("%s-risk filter:", risk))
+	}
+	fmt.Fprintf(os.Stderr, "> %s %s\n", label, ui.Highlight(filter))
+	if reason != "" && confirm == shell.ConfirmTyped {
+		fmt.Fprintf(os.Stderr, "> It %s.\n", reason)
+	}
+	switch confirm {
+	case shell.ConfirmBlock:
+		record(history.DecisionBlocked, nil)
+		return fmt.Errorf("%s-risk commands are blocked, change confirm.%s in the config to allow them", risk, risk)
+	case shell.ConfirmTyped, shell.ConfirmYesNo:
+		// stdin is the input, so the question is asked on the terminal
+		question := "> Apply it to the input? [Y/n]: "
+		if confirm == shell.ConfirmTyped {
+			question = "> Type 'yes' to apply it to the input: "
+		}
+		answer, err := shell.ReadTerminalLine(question)
+		if errors.Is(err, shell.ErrNoTerminal) {
+			if confirm == shell.ConfirmTyped {
+				return fmt.Errorf("%v; %s-risk filters must be confirmed interactively", err, risk)
+			}
+			return fmt.Errorf("%v; pass --yes to apply the filter without asking", err)
+		}
+		if err != nil {
+			return err
+		}
+		answer = strings.TrimSpace(answer)
+		declined := answer != "" && (answer[0] == 'n' || answer[0] == 'N')
+		if confirm == shell.ConfirmTyped {
+			declined = !strings.EqualFold(answer, "yes")
+		}
+		if declined {
+			fmt.Fprintln(os.Stderr, "> Aborted by user.")
+			record(history.DecisionAborted, nil)
+			return nil
+		}
+	}
+
+	// Stream the whole input, starting with the sample already read, through the filter
+	exec := newExecutor(shell.Executor{
+		Shell:  program,
+		Stdout: os.Stderr,
+		Input:  io.MultiReader(&head, input),
+		Output: os.Stdout,
+	})
+	_, _, runErr := exec.Run(filter, shell.ConfirmNone)
+	record(history.DecisionExecuted, runErr)
+	if runErr != nil {
+		return fmt.Errorf("filter failed: %v", runErr)
+	}
+	return nil
+}
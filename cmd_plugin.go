@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kanishka-sahoo/nlch/internal/plugin"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+)
+
+var pluginCommand = &command{
+	name:    "plugin",
+	usage:   "[list]",
+	summary: "List context plugins and prompt packs",
+}
+
+func init() {
+	pluginCommand.run = runPlugin
+}
+
+func runPlugin(args []string) error {
+	fs := newFlagSet(pluginCommand)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 && fs.Arg(0) != "list" {
+		fs.Usage()
+		return errUsage
+	}
+
+	names := []string{}
+	for _, p := range plugin.List() {
+		names = append(names, p.Name())
+	}
+	sort.Strings(names)
+
+	fmt.Println("Context plugins:")
+	if len(names) == 0 {
+		fmt.Println("  (none registered)")
+	}
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+
+	fmt.Println("Prompt packs:")
+	for _, name := range prompt.PackNames() {
+		fmt.Printf("  %s\n", name)
+	}
+	return nil
+}
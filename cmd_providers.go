@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var providersCommand = &command{
+	name:    "providers",
+	summary: "List the configured providers, their models, credentials and endpoints",
+}
+
+func init() {
+	providersCommand.run = runProviders
+}
+
+func runProviders(args []string) error {
+	fs := newFlagSet(providersCommand)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("no usable configuration, run 'nlch init' first: %v", err)
+	}
+	if len(cfg.Providers) == 0 {
+		return errors.New("no providers configured, add one with 'nlch init'")
+	}
+
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		p := cfg.Providers[name]
+		if i > 0 {
+			fmt.Println()
+		}
+		if name == cfg.DefaultProvider {
+			fmt.Printf("* %s %s\n", name, ui.Dim("(default)"))
+		} else {
+			fmt.Printf("  %s\n", name)
+		}
+
+		model := p.DefaultModel
+		switch {
+		case model != "":
+		case name == "bedrock" || name == "vertex":
+			model = config.DefaultModels[name] + " " + ui.Dim("(built-in default)")
+		default:
+			model = ui.Dim("none, pass --model")
+		}
+		fmt.Printf("    Model:       %s\n", model)
+		ok, credentials := providerCredentials(name, p)
+		if !ok {
+			credentials = ui.Error(credentials)
+		}
+		fmt.Printf("    Credentials: %s\n", credentials)
+		if _, err := provider.New(name, p); err != nil && ok {
+			fmt.Printf("    Unusable:    %s\n", ui.Error(err.Error()))
+		}
+		switch {
+		case name == "ollama":
+			fmt.Printf("    URL:         %s\n", provider.OllamaURL(p))
+		case p.Endpoint != "":
+			fmt.Printf("    Endpoint:    %s %s\n", p.Endpoint, ui.Dim("(overridden)"))
+		}
+		if p.Region != "" {
+			fmt.Printf("    Region:      %s\n", p.Region)
+		}
+		if p.Project != "" {
+			fmt.Printf("    Project:     %s\n", p.Project)
+		}
+		if p.Profile != "" {
+			fmt.Printf("    Profile:     %s\n", p.Profile)
+		}
+	}
+	provider.RegisterProvidersFromConfig(cfg.Providers)
+	if err := provider.Invalid(cfg.DefaultProvider); err != nil {
+		fmt.Printf("\n%s\n", ui.Error(fmt.Sprintf("The default provider can't be used: %v", err)))
+	} else if _, ok := provider.Get(cfg.DefaultProvider); !ok {
+		fmt.Printf("\n%s\n", ui.Error(fmt.Sprintf("The default provider %q is not configured; choose one with nlch use.", cfg.DefaultProvider)))
+	}
+	return nil
+}
+
+// providerCredentials tells whether a configured provider has the credentials
+// it authenticates with, describing them or what is missing.
+func providerCredentials(name string, p config.ProviderConfig) (bool, string) {
+	switch name {
+	case "ollama", "mock":
+		return true, "none needed"
+	case "bedrock":
+		source, err := provider.AWSCredentialSource(p.Profile)
+		if err != nil {
+			return false, err.Error()
+		}
+		return true, "AWS credentials from " + source
+	case "vertex":
+		source, err := provider.GoogleCredentialSource()
+		if err != nil {
+			return false, err.Error()
+		}
+		return true, "Application Default Credentials from " + source
+	}
+	if p.Key == "" {
+		return false, "no API key"
+	}
+	return true, "API key set"
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/container"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+	"github.com/kanishka-sahoo/nlch/internal/models"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+	"github.com/kanishka-sahoo/nlch/internal/update"
+)
+
+var runCommand = &command{
+	name:    "run",
+	usage:   "[flags] \"Describe your command here\"",
+	summary: "Generate a shell command from a description and run it (default)",
+}
+
+func init() {
+	runCommand.run = runRun
+}
+
+// How long a command interrupted at the deadline has to exit before it is killed.
+const deadlineGrace = 5 * time.Second
+
+func runRun(args []string) error {
+	fs := newFlagSet(runCommand)
+	dryRun := fs.Bool("dry-run", false, "Show the command but do not execute it")
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	yesSure := fs.Bool("yes-im-sure", false, "Run the command without confirmation, unless its risk level is blocked")
+	verbose := fs.Bool("verbose", false, "Show provider and model information")
+	printOnly := fs.Bool("print", false, "Print the generated command to stdout instead of running it")
+	explain := fs.Bool("explain", false, "Show the generated command with a flag-by-flag breakdown instead of running it")
+	candidates := fs.Int("candidates", 1, "Ask for N alternative commands and pick one from a menu")
+	cont := fs.Bool("continue", false, "Follow up on the last request, giving the LLM its command and output")
+	inContainer := fs.String("in-container", "", "Gather context from and run the command inside this running container")
+	ensemble := fs.String("ensemble", "", "Also ask this provider[:model] and choose between the commands if they disagree")
+	readOnly := fs.Bool("read-only", false, "Only generate commands that change nothing, and refuse to run any other")
+	compare := fs.String("compare", "", "Generate with each of these comma-separated models (model or provider:model) and pick one command")
+	capture := fs.String("capture", "", "Also write the command's output to this file, and record the file in the history")
+	shellFlag := fs.String("shell", "", "Write the command for this shell: bash, sh, zsh, fish or nu (default from shell, or $SHELL when it is fish or nu)")
+	interactive := fs.Bool("i", false, "Read requests one after another, each of which may refer to the ones before it")
+	sessionName := fs.String("session", "", "Keep the conversation in this named session, to resume it later (see nlch sessions)")
+	jsonOut := fs.Bool("json", false, "With --print, read the request as a JSON envelope from stdin and write the result as JSON, for editor integrations")
+	fs.BoolVar(&fullGitStatus, "git-status", false, "Include the full git status: every changed path rather than a summary, even in repositories with more than 100,000 tracked files, where it is left out for speed")
+	watchFor := fs.Duration("watch-limit", 0, "Stop commands that run until stopped, such as tail -f, after this long (default from watch_limit, or 10m)")
+	deadline := fs.Duration("deadline", 0, "Give up once this long has passed in all, e.g. 2m, whether generating, running or correcting the command, and exit with status 124")
+	var imagePaths []string
+	fs.Func("image", "Attach an image, such as a screenshot of an error, for vision-capable models (repeatable)", func(path string) error {
+		imagePaths = append(imagePaths, path)
+		return nil
+	})
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *jsonOut && editorRequest == nil {
+		switch {
+		case !*printOnly:
+			return errors.New("--json requires --print")
+		case fs.NArg() > 0:
+			return errors.New("with --json the request is read from stdin")
+		case *interactive || *candidates > 1 || *compare != "" || *ensemble != "":
+			return errors.New("--json cannot be combined with -i, --candidates, --compare or --ensemble, which ask the user")
+		}
+		return runEditorRequest(args)
+	}
+	if *deadline < 0 {
+		return errors.New("--deadline must not be negative")
+	}
+	// The deadline covers the whole process, so a turn of a session doesn't set it again
+	if *deadline > 0 && repl == nil {
+		if *interactive {
+			return errors.New("--deadline cannot be combined with -i, which waits for requests")
+		}
+		interrupt.SetDeadline(*deadline, deadlineGrace)
+	}
+	if repl == nil && (*interactive || *sessionName != "") {
+		// Each request then runs as a turn of the session, with the same flags
+		flags := slices.Clone(args[:len(args)-fs.NArg()])
+		if len(flags) > 0 && flags[len(flags)-1] == "--" {
+			flags = flags[:len(flags)-1]
+		}
+		if *interactive {
+			return runInteractive(flags, *sessionName, strings.Join(fs.Args(), " "))
+		}
+		if fs.NArg() < 1 {
+			fs.Usage()
+			return errUsage
+		}
+		r, err := openSession(*sessionName)
+		if err != nil {
+			return err
+		}
+		return runTurn(r, flags, strings.Join(fs.Args(), " "))
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	userInput := strings.Join(fs.Args(), " ")
+	if *compare != "" && (*candidates > 1 || *ensemble != "") {
+		return errors.New("--compare cannot be combined with --candidates or --ensemble")
+	}
+	if *capture != "" {
+		// The history keeps the path, so it must not depend on the directory
+		path, err := filepath.Abs(*capture)
+		if err != nil {
+			return err
+		}
+		*capture = path
+	}
+	images := make([]provider.Image, 0, len(imagePaths))
+	for _, path := range imagePaths {
+		img, err := provider.LoadImage(path)
+		if err != nil {
+			return err
+		}
+		images = append(images, img)
+	}
+
+	// Informational output goes to stderr when stdout carries the command
+	info := io.Writer(os.Stdout)
+	if *printOnly {
+		info = os.Stderr
+	}
+
+	cfg, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+
+	// Commands that are only shown don't need their shell installed
+	target, program, err := targetShell(cfg, *shellFlag, *inContainer)
+	if err != nil && (target == "" || !*dryRun && !*printOnly && !*explain) {
+		return err
+	}
+
+	// Check for updates in the background (non-blocking)
+	if !*printOnly {
+		update.NotifyUpdateAvailable()
+	}
+	modelUsed := resolveModel(prov, cfg, providerName, *model)
+	if caps, known := models.Lookup(modelUsed); known && len(images) > 0 && !caps.Vision {
+		return fmt.Errorf("model '%s' cannot read images, choose a vision-capable model with --model to use --image", modelUsed)
+	}
+
+	// Gather context, from inside the container when the command runs there
+	var ctx *context.Context
+	if *inContainer != "" {
+		if ctx, err = container.Gather(*inContainer); err != nil {
+			return err
+		}
+	} else {
+		ctx = gatherContext()
+	}
+	if editorRequest != nil {
+		editorRequest.addContext(ctx)
+	}
+
+	// Build prompt
+	promptOpts := prompt.Options{
+		Packs:         cfg.Packs,
+		DisabledPacks: cfg.DisabledPacks,
+		Never:         cfg.Never,
+		Model:         modelUsed,
+		Candidates:    *candidates,
+		Lessons:       feedbackLessons(userInput),
+		Images:        len(images),
+		ReadOnly:      *readOnly || cfg.ReadOnly,
+		Shell:         target,
+		JSON:          provider.JSONMode(providerName, modelUsed) != "",
+	}
+	instructionsPath, instructions := projectInstructions(cfg, modelUsed)
+	promptOpts.Instructions = instructions
+	if repl != nil {
+		promptOpts.Earlier, promptOpts.Previous = repl.promptContext()
+	}
+	if *cont {
+		if promptOpts.Previous, err = lastExchange(); err != nil {
+			return err
+		}
+	}
+	// Generate command, or several candidates to choose from
+	maxTokens := provider.DefaultMaxTokens
+	if *candidates > 1 {
+		maxTokens = 128 * *candidates
+	}
+
+	// Leave out the least important context if the prompt is too long for the model
+	fitted := prompt.Fit(ctx, userInput, promptOpts, maxTokens)
+	promptStr := fitted.Prompt
+	if !fitted.Fits(maxTokens) {
+		fmt.Fprintf(os.Stderr, "nlch: warning: the prompt (about %d tokens) may not fit in the context window of %s (%d tokens)\n", fitted.Tokens, modelUsed, fitted.Window)
+	}
+
+	// Provider options
+	opts := provider.ProviderOptions{
+		Model:    *model,
+		Provider: providerName,
+		System:   fitted.System,
+		Images:   images,
+	}
+	genOpts := opts
+	if *candidates > 1 {
+		genOpts.Raw = true
+		genOpts.MaxTokens = maxTokens
+		genOpts.JSON = prompt.JSONCandidates(promptOpts)
+		if genOpts.JSON {
+			genOpts.Schema = prompt.CandidatesSchema()
+		}
+	}
+
+	if *verbose {
+		fmt.Fprintf(info, "Provider: %s\n", providerName)
+		if cost, ok := estimateCost(modelUsed, genOpts, promptStr); ok {
+			fmt.Fprintf(info, "Model: %s (%s)\n", modelUsed, formatCost(cost))
+		} else {
+			fmt.Fprintf(info, "Model: %s\n", modelUsed)
+		}
+		if caps, known := models.Lookup(modelUsed); known {
+			fmt.Fprintf(info, "Context window: %d tokens\n", caps.ContextWindow)
+		} else {
+			fmt.Fprintf(info, "Context window: %d tokens (unknown model, assumed)\n", models.Unknown.ContextWindow)
+		}
+		if active := prompt.ActivePacks(ctx, userInput, cfg.Packs, cfg.DisabledPacks); len(active) > 0 {
+			names := make([]string, 0, len(active))
+			for _, p := range active {
+				names = append(names, p.Name)
+			}
+			fmt.Fprintf(info, "Prompt packs: %s\n", strings.Join(names, ", "))
+		}
+		if !shell.POSIX(target) {
+			fmt.Fprintf(info, "Shell: %s\n", target)
+		}
+		if *candidates > 1 {
+			if mode := provider.JSONMode(providerName, modelUsed); mode != "" {
+				fmt.Fprintf(info, "JSON mode: native, with %s\n", mode)
+			} else {
+				fmt.Fprintln(info, "JSON mode: none, the prompt asks for one command per line")
+			}
+		}
+		if instructionsPath != "" {
+			fmt.Fprintf(info, "Instructions: %s\n", instructionsPath)
+		}
+		fmt.Fprintf(info, "Prompt: %d tokens\n", fitted.Tokens)
+		if len(fitted.Dropped) > 0 {
+			dropped := make([]string, len(fitted.Dropped))
+			for i, section := range fitted.Dropped {
+				dropped[i] = string(section)
+			}
+			fmt.Fprintf(info, "Left out to fit the context window: %s\n", strings.Join(dropped, ", "))
+		}
+	}
+
+	check := localCheck(cfg, providerName)
+	if *verbose && check != nil {
+		fmt.Fprintf(info, "Local check: %s\n", check.Model)
+	}
+
+	if err := confirmCost(cfg, modelUsed, genOpts, promptStr); err != nil {
+		return err
+	}
+	var cmd string
+	var used app.Usage
+	var compared []string
+	chosen := false
+	if *compare != "" {
+		// Continue with whichever model's command the user picks
+		picked, labels, err := compareModels(cfg, *compare, providerName, ctx, promptStr, genOpts, &used, info)
+		if errors.Is(err, shell.ErrAborted) {
+			fmt.Fprintln(info, "> Aborted by user.")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		prov, providerName, modelUsed = picked.provider, picked.name, picked.model
+		opts.Provider, opts.Model = picked.name, picked.model
+		cmd, compared, chosen = picked.command, labels, len(labels) > 1
+	} else {
+		cmd, err = prov.GenerateCommand(*ctx, promptStr, genOpts)
+		if err != nil {
+			// Offer the models the provider has when it doesn't know this one
+			var picked string
+			picked, err = pickAvailableModel(cfg, providerName, modelUsed, err)
+			if errors.Is(err, shell.ErrAborted) {
+				fmt.Fprintln(info, "> Aborted by user.")
+				return nil
+			}
+			if err == nil {
+				modelUsed, opts.Model, genOpts.Model = picked, picked, picked
+				cmd, err = prov.GenerateCommand(*ctx, promptStr, genOpts)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("provider error: %v", err)
+		}
+		used.Add(modelUsed, genOpts, promptStr, cmd)
+	}
+
+	if *candidates > 1 {
+		cmd, err = chooseCandidate(prompt.ParseCandidates(cmd), info)
+		if errors.Is(err, shell.ErrAborted) {
+			fmt.Fprintln(info, "> Aborted by user.")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		chosen = true
+	}
+
+	// Clean up the command (remove markdown code blocks, etc.)
+	cmd = prompt.CleanCommand(cmd)
+
+	// Have a second model double-check dangerous commands, or every command when asked to
+	second := cfg.Ensemble.With
+	if *ensemble != "" {
+		second = *ensemble
+	}
+	if second != "" && *candidates <= 1 && (*ensemble != "" || cfg.Ensemble.Always || isDangerous(cmd)) {
+		first := modelLabel(providerName, modelUsed)
+		var picked bool
+		cmd, picked, err = secondOpinion(cfg, second, ctx, promptStr, opts, first, cmd, &used, info)
+		if errors.Is(err, shell.ErrAborted) {
+			fmt.Fprintln(info, "> Aborted by user.")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		chosen = chosen || picked
+	}
+
+	record := recordHistory
+	if repl != nil {
+		record = repl.record(cfg.History)
+	}
+	a := &app.App{
+		Config:       cfg,
+		Provider:     prov,
+		ProviderName: providerName,
+		Model:        modelUsed,
+		Context:      ctx,
+		Executor:     newExecutor(shell.Executor{DryRun: *dryRun, AllowRefine: true, Container: *inContainer, Capture: *capture, Shell: program, WatchLimit: *watchFor}),
+		Out:          os.Stdout,
+		Err:          os.Stderr,
+		Record:       record,
+		LocalCheck:   check,
+		Shell:        target,
+	}
+	if editorRequest != nil {
+		a.Out, a.Err = &editorRequest.out, &editorRequest.notes
+		editorRequest.provider, editorRequest.model, editorRequest.shell = providerName, modelUsed, target
+	}
+	res, err := a.Run(cmd, app.Options{
+		Request:   userInput,
+		Prompt:    promptStr,
+		Generate:  opts,
+		DryRun:    *dryRun,
+		YesImSure: *yesSure,
+		ReadOnly:  promptOpts.ReadOnly,
+		Print:     *printOnly,
+		Explain:   *explain,
+		Chosen:    chosen,
+		Usage:     used,
+		Compared:  compared,
+		Capture:   *capture,
+	})
+	if editorRequest != nil {
+		editorRequest.res = res
+	}
+	if res != nil && res.Rated && cfg.AskFeedback {
+		askFeedback(res.ID)
+	}
+	return err
+}
+
+// chooseCandidate shows a numbered menu of candidate commands and returns the one the user picks.
+func chooseCandidate(candidates []prompt.Candidate, out io.Writer) (string, error) {
+	if len(candidates) == 0 {
+		return "", errors.New("LLM did not provide any candidate commands")
+	}
+	if len(candidates) == 1 {
+		return candidates[0].Command, nil
+	}
+
+	fmt.Fprintln(out, "> Candidate commands:")
+	for i, c := range candidates {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, ui.Highlight(strings.TrimPrefix(c.Command, DangerPrefix)))
+		if strings.HasPrefix(c.Command, DangerPrefix) {
+			fmt.Fprintf(out, "     %s\n", ui.Danger("potentially dangerous"))
+		}
+		if c.Description != "" {
+			fmt.Fprintf(out, "     %s\n", ui.Dim(c.Description))
+		}
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		fmt.Fprintf(out, "> Choose [1-%d, q to quit]: ", len(candidates))
+		answer := strings.TrimSpace(shell.ReadLine(""))
+		if answer == "q" || answer == "Q" {
+			return "", shell.ErrAborted
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(candidates) {
+			return candidates[n-1].Command, nil
+		}
+		fmt.Fprintln(out, "> Invalid choice.")
+	}
+	return "", shell.ErrAborted
+}
+
+// modelLabel names a provider and model as provider:model, or just the provider if the model is unknown.
+func modelLabel(providerName, model string) string {
+	if model == "" {
+		return providerName
+	}
+	return providerName + ":" + model
+}
+
+// isDangerous reports whether a generated command is high risk or worse.
+func isDangerous(cmd string) bool {
+	risk, _ := app.AssessRisk(cmd)
+	return risk >= shell.RiskHigh
+}
+
+// secondOpinion asks a second model, given as provider[:model], the same request.
+// If its command differs meaningfully from the first model's, both are shown
+// with their differences and the user picks one. It reports whether the user
+// made a choice, which counts as confirming the command.
+func secondOpinion(cfg *config.Config, target string, ctx *context.Context, promptStr string, opts provider.ProviderOptions, firstLabel, first string, used *app.Usage, out io.Writer) (string, bool, error) {
+	name, model, _ := strings.Cut(target, ":")
+	prov, ok := provider.Get(name)
+	if !ok {
+		if err := provider.Invalid(name); err != nil {
+			return "", false, fmt.Errorf("ensemble %v", err)
+		}
+		return "", false, fmt.Errorf("ensemble provider '%s' not found. Available: %v", name, provider.Names())
+	}
+	if err := checkOffline(cfg, name); err != nil {
+		return "", false, err
+	}
+	model = resolveModel(prov, cfg, name, model)
+	opts.Provider, opts.Model = name, model
+	reply, err := validated(cfg, prov).GenerateCommand(*ctx, promptStr, opts)
+	if err != nil {
+		// The first command is still usable without a second opinion
+		fmt.Fprintf(out, "> %s\n", ui.Dim(fmt.Sprintf("No second opinion from %s: %v", target, err)))
+		return first, false, nil
+	}
+	used.Add(model, opts, promptStr, reply)
+	second := prompt.CleanCommand(reply)
+
+	plainFirst, plainSecond := strings.TrimPrefix(first, DangerPrefix), strings.TrimPrefix(second, DangerPrefix)
+	if shell.Equivalent(plainFirst, plainSecond) {
+		fmt.Fprintf(out, "> %s\n", ui.Dim(modelLabel(name, model)+" agrees."))
+		// Either model calling the command dangerous is enough
+		if strings.HasPrefix(second, DangerPrefix) && !strings.HasPrefix(first, DangerPrefix) {
+			first = DangerPrefix + first
+		}
+		return first, false, nil
+	}
+
+	before, after := ui.WordDiff(plainFirst, plainSecond)
+	fmt.Fprintln(out, "> The models disagree:")
+	for i, option := range []struct{ label, cmd, shown string }{
+		{firstLabel, first, before},
+		{modelLabel(name, model), second, after},
+	} {
+		fmt.Fprintf(out, "  %d) %s\n     %s\n", i+1, ui.Dim(option.label), option.shown)
+		if isDangerous(option.cmd) {
+			fmt.Fprintf(out, "     %s\n", ui.Danger("potentially dangerous"))
+		}
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		fmt.Fprint(out, "> Choose [1-2, q to quit]: ")
+		switch strings.TrimSpace(shell.ReadLine("")) {
+		case "1":
+			return first, true, nil
+		case "2":
+			return second, true, nil
+		case "q", "Q":
+			return "", false, shell.ErrAborted
+		}
+		fmt.Fprintln(out, "> Invalid choice.")
+	}
+	return "", false, shell.ErrAborted
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/snippets"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var saveCommand = &command{
+	name:    "save",
+	usage:   "[flags] <name> [command]",
+	summary: "Save a command under a name (defaults to the last generated command)",
+}
+
+var runSavedCommand = &command{
+	name:    "run-saved",
+	usage:   "[flags] <name> [args...]",
+	summary: "Run a saved command, filling {{1}}, {{2}}, ... and {{@}} with args and asking for {{name}} placeholders",
+}
+
+func init() {
+	saveCommand.run = runSave
+	runSavedCommand.run = runRunSaved
+}
+
+func runSave(args []string) error {
+	fs := newFlagSet(saveCommand)
+	id := fs.Int("id", 0, "Save the command from this history entry instead of the last one")
+	list := fs.Bool("list", false, "List saved commands")
+	del := fs.Bool("delete", false, "Delete the saved command with the given name")
+	shellFlag := fs.String("shell", "", "Shell the command is written for: bash, sh, zsh, fish or nu (default: the history entry's, or bash for a command given here)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	store, err := snippets.Open()
+	if err != nil {
+		return err
+	}
+	if *list {
+		return listSnippets(store)
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	name := fs.Arg(0)
+
+	if *del {
+		if err := store.Delete(name); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", name)
+		return nil
+	}
+
+	// Take the command from the arguments, or from history
+	snippet := snippets.Snippet{Command: strings.Join(fs.Args()[1:], " ")}
+	if snippet.Command == "" {
+		entry, err := historyEntryOrLast(*id)
+		if err != nil {
+			return err
+		}
+		snippet.Command = entry.Command
+		snippet.Request = entry.Request
+		snippet.Shell = entry.Shell
+	}
+	if *shellFlag != "" {
+		target, err := shell.Target(*shellFlag)
+		if err != nil {
+			return err
+		}
+		// Like history entries, snippets only name the shells that aren't POSIX
+		snippet.Shell = ""
+		if !shell.POSIX(target) {
+			snippet.Shell = target
+		}
+	}
+
+	if err := store.Save(name, snippet); err != nil {
+		return fmt.Errorf("failed to save command: %v", err)
+	}
+	fmt.Printf("Saved %s: %s\n", name, ui.Highlight(snippet.Command))
+	return nil
+}
+
+// listSnippets prints all saved commands.
+func listSnippets(store *snippets.Store) error {
+	saved, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if len(saved) == 0 {
+		fmt.Println("No saved commands.")
+		return nil
+	}
+	for _, name := range snippets.Names(saved) {
+		s := saved[name]
+		line := ui.Highlight(s.Command)
+		if s.Shell != "" {
+			line += ui.Dim(" (" + s.Shell + ")")
+		}
+		fmt.Printf("%-20s %s\n", name, line)
+		if s.Request != "" {
+			fmt.Printf("%-20s %s\n", "", ui.Dim(s.Request))
+		}
+	}
+	return nil
+}
+
+func runRunSaved(args []string) error {
+	fs := newFlagSet(runSavedCommand)
+	dryRun := fs.Bool("dry-run", false, "Show the command but do not execute it")
+	values := valuesFlag{}
+	fs.Var(values, "set", "Give the {{name}} placeholder a value as `name=value` instead of asking (repeatable)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	name := fs.Arg(0)
+
+	store, err := snippets.Open()
+	if err != nil {
+		return err
+	}
+	snippet, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	if err := askVariables(snippet.Command, values); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	cmd, err := snippets.Expand(snippet.Command, fs.Args()[1:], values, snippet.Shell)
+	if err != nil {
+		return err
+	}
+
+	// Saved commands are still subject to the configured constraints
+	// A config that fails to load leaves cfg nil, and the default confirmations apply
+	cfg, err := config.Load()
+	if err == nil {
+		ui.SetTheme(cfg.Theme)
+		ui.SetAccessible(cfg.Accessible)
+		if err := app.CheckConstraints(cmd, cfg.Never); err != nil {
+			return err
+		}
+		if cfg.ReadOnly {
+			if err := app.CheckReadOnly(cmd); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The command runs in the shell it was written for, whatever the default is now
+	program, err := shell.Program(snippet.Shell)
+	if err != nil {
+		return err
+	}
+	wd, _ := os.Getwd()
+	exec := newExecutor(shell.Executor{DryRun: *dryRun, Shell: program})
+	stdout, stderr, runErr := exec.Run(cmd, app.ReplayConfirmation(cfg, cmd))
+	e := history.Entry{
+		Request:  "saved: " + name,
+		Command:  cmd,
+		Shell:    snippet.Shell,
+		Dir:      wd,
+		Decision: app.Decision(*dryRun, runErr),
+	}
+	if e.Decision == history.DecisionExecuted {
+		e.ExitCode = shell.ExitCode(runErr)
+		e.Output = stdout + stderr
+	}
+	recordHistory(e)
+
+	if errors.Is(runErr, shell.ErrBlocked) {
+		return fmt.Errorf("this command is %s", runErr)
+	}
+	if runErr != nil && e.Decision == history.DecisionExecuted {
+		return fmt.Errorf("command failed: %v", runErr)
+	}
+	return nil
+}
+
+// valuesFlag collects repeated name=value flags.
+type valuesFlag map[string]string
+
+func (v valuesFlag) String() string { return "" }
+
+func (v valuesFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	v[name] = value
+	return nil
+}
+
+// askVariables asks for the named placeholders of a saved command that have
+// no value yet, offering their defaults. Values must name placeholders the
+// command has.
+func askVariables(command string, values map[string]string) error {
+	vars := snippets.Variables(command)
+	for name := range values {
+		if !slices.ContainsFunc(vars, func(v snippets.Variable) bool { return v.Name == name }) {
+			return fmt.Errorf("no placeholder {{%s}}", name)
+		}
+	}
+	for _, v := range vars {
+		if _, ok := values[v.Name]; ok {
+			continue
+		}
+		question := fmt.Sprintf("> %s: ", v.Name)
+		if v.HasDefault {
+			question = fmt.Sprintf("> %s [%s]: ", v.Name, v.Default)
+		}
+		answer := strings.TrimSpace(shell.ReadLine(question))
+		switch {
+		case answer != "":
+			values[v.Name] = answer
+		case !v.HasDefault:
+			return fmt.Errorf("no value for {{%s}}", v.Name)
+		}
+	}
+	return nil
+}
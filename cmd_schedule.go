@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/schedule"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var scheduleCommand = &command{
+	name:    "schedule",
+	usage:   "[flags] \"Describe what to run and when\"",
+	summary: "Generate a recurring job and install it as a cron, systemd or launchd schedule",
+}
+
+func init() {
+	scheduleCommand.run = runSchedule
+}
+
+// Maximum number of tokens in a generated job.
+const scheduleMaxTokens = 512
+
+func runSchedule(args []string) error {
+	fs := newFlagSet(scheduleCommand)
+	with := fs.String("with", "", "Scheduler to install into: "+strings.Join(schedule.Backends, ", ")+" (default: the system's usual one)")
+	dryRun := fs.Bool("dry-run", false, "Show the job and its schedule but do not install it")
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	description := strings.Join(fs.Args(), " ")
+	if description == "" {
+		fs.Usage()
+		return errUsage
+	}
+	backend := *with
+	if backend == "" {
+		backend = schedule.DefaultBackend()
+	}
+	if !slices.Contains(schedule.Backends, backend) {
+		return fmt.Errorf("unknown scheduler %q, use one of %s", backend, strings.Join(schedule.Backends, ", "))
+	}
+
+	cfg, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+
+	ctx := gatherContext()
+	opts := provider.ProviderOptions{
+		Model:     *model,
+		Provider:  providerName,
+		System:    withProjectInstructions(cfg, prompt.ScheduleSystemPrompt),
+		MaxTokens: scheduleMaxTokens,
+		Raw:       true,
+	}
+	promptStr := prompt.BuildSchedulePrompt(ctx, description)
+	reply, err := prov.GenerateCommand(*ctx, promptStr, opts)
+	if err != nil {
+		return fmt.Errorf("provider error: %v", err)
+	}
+	modelUsed := resolveModel(prov, cfg, providerName, *model)
+	var used app.Usage
+	used.Add(modelUsed, opts, promptStr, reply)
+
+	job, err := schedule.Parse(stripCodeFence(reply))
+	if err != nil {
+		return err
+	}
+	risk, reason := app.AssessRisk(job.Command)
+	confirm := app.ConfirmationFor(cfg, risk)
+	dangerous := risk >= shell.RiskHigh || confirm == shell.ConfirmTyped
+	job.Command = strings.TrimPrefix(job.Command, DangerPrefix)
+
+	record := func(decision string) {
+		recordHistory(history.Entry{
+			Request:      "schedule: " + description,
+			Command:      job.Command,
+			Provider:     providerName,
+			Model:        modelUsed,
+			Dir:          ctx.WorkingDir,
+			Decision:     decision,
+			InputTokens:  used.Input,
+			OutputTokens: used.Output,
+		})
+	}
+
+	if err := app.CheckConstraints(job.Command, cfg.Never); err != nil {
+		record(history.DecisionBlocked)
+		return err
+	}
+	if cfg.ReadOnly {
+		if err := app.CheckReadOnly(job.Command); err != nil {
+			record(history.DecisionBlocked)
+			return err
+		}
+	}
+	// A job runs unattended, so what the config blocks is never installed
+	if confirm == shell.ConfirmBlock {
+		record(history.DecisionBlocked)
+		if reason != "" {
+			reason = " (" + reason + ")"
+		}
+		return fmt.Errorf("%s-risk commands are blocked%s, change confirm.%s in the config to allow them", risk, reason, risk)
+	}
+	if err := shell.CheckSyntax(job.Command); err != nil {
+		return fmt.Errorf("the generated command is not valid shell: %v", err)
+	}
+	preview, err := job.Render(backend)
+	if err != nil {
+		return err
+	}
+
+	// Show both the command and exactly what would be installed
+	label := "Command:"
+	if dangerous {
+		label = ui.Danger("Dangerous command:")
+	}
+	fmt.Printf("> %s %s\n", label, ui.Highlight(job.Command))
+	if job.Description != "" {
+		fmt.Printf("> %s\n", job.Description)
+	}
+	fmt.Printf("> Schedule (%s): %s\n", backend, job.Cron.Expr)
+	if job.Installed(backend) {
+		fmt.Printf("> %s this replaces the %s job %s that is already installed\n", ui.Danger("Warning:"), backend, job.Name)
+	}
+	fmt.Println()
+	fmt.Println(preview)
+
+	if *dryRun {
+		record(history.DecisionPrinted)
+		return nil
+	}
+
+	// Installing always needs an explicit answer from the user; a dangerous
+	// command, which will later run unattended, needs "yes" spelled out
+	question, accepted := "> Install this schedule? [y/N]: ", []string{"y", "yes"}
+	if dangerous {
+		question, accepted = "> This command is dangerous and will run unattended. Type 'yes' to install it: ", []string{"yes"}
+	}
+	answer, err := shell.ReadTerminalLine(question)
+	if errors.Is(err, shell.ErrNoTerminal) {
+		return fmt.Errorf("%v; installing a schedule must be confirmed interactively, use --dry-run to only show it", err)
+	}
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(accepted, strings.ToLower(strings.TrimSpace(answer))) {
+		fmt.Println("> Not installed.")
+		record(history.DecisionAborted)
+		return nil
+	}
+
+	if err := job.Install(backend); err != nil {
+		return err
+	}
+	record(history.DecisionExecuted)
+	fmt.Printf("> Installed %s as a %s job.\n", job.Name, backend)
+	return nil
+}
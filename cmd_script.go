@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+)
+
+var scriptCommand = &command{
+	name:    "script",
+	usage:   "[flags] \"Describe what the script should do\"",
+	summary: "Generate a complete, commented shell script file (never executed)",
+}
+
+func init() {
+	scriptCommand.run = runScript
+}
+
+// Maximum number of tokens in a generated script.
+const scriptMaxTokens = 4096
+
+func runScript(args []string) error {
+	fs := newFlagSet(scriptCommand)
+	output := fs.String("o", "", "Write the script to this file instead of stdout")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists")
+	shellName := fs.String("shell", "bash", "Shell the script is written for")
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	fix := fs.Bool("fix", false, "Ask the model to fix what shellcheck finds in the script")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	description := strings.Join(fs.Args(), " ")
+	if description == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	if *output != "" && !*force {
+		if _, err := os.Stat(*output); err == nil {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", *output)
+		}
+	}
+
+	cfg, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+
+	ctx := gatherContext()
+	opts := provider.ProviderOptions{
+		Model:     *model,
+		Provider:  providerName,
+		System:    withProjectInstructions(cfg, prompt.ScriptSystemPrompt),
+		MaxTokens: scriptMaxTokens,
+		Raw:       true,
+	}
+	promptStr := prompt.BuildScriptPrompt(ctx, description, *shellName)
+	if err := confirmCost(cfg, resolveModel(prov, cfg, providerName, *model), opts, promptStr); err != nil {
+		return err
+	}
+	script, err := prov.GenerateCommand(*ctx, promptStr, opts)
+	if err != nil {
+		return fmt.Errorf("provider error: %v", err)
+	}
+	script = stripCodeFence(script)
+	if script == "" {
+		return errors.New("LLM did not return a script")
+	}
+	if !cfg.Lint.Disabled {
+		script, err = lintScript(prov, ctx, script, *shellName, opts, *fix || cfg.Lint.Fix)
+		if err != nil {
+			return err
+		}
+	}
+	script += "\n"
+
+	if *output == "" {
+		fmt.Print(script)
+		return nil
+	}
+	if err := os.WriteFile(*output, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write %s: %v", *output, err)
+	}
+	fmt.Printf("> Wrote %s (%d lines). Review it before running; nlch never executes generated scripts.\n", *output, strings.Count(script, "\n"))
+	return nil
+}
+
+// lintScript warns about the problems shellcheck finds in a script. With fix
+// set, it first asks the provider once to fix them and returns the result.
+func lintScript(prov provider.Provider, ctx *context.Context, script, shellName string, opts provider.ProviderOptions, fix bool) (string, error) {
+	findings, err := shell.Lint(script, shellName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: %v\n", err)
+		return script, nil
+	}
+	if len(findings) > 0 && fix {
+		fmt.Fprintf(os.Stderr, "> shellcheck found %d problem(s), asking the LLM to fix them...\n", len(findings))
+		problems := make([]string, len(findings))
+		for i, f := range findings {
+			problems[i] = f.String()
+		}
+		fixed, err := prov.GenerateCommand(*ctx, prompt.BuildScriptFixPrompt(script, problems), opts)
+		if err != nil {
+			return "", fmt.Errorf("provider error: %v", err)
+		}
+		if fixed = stripCodeFence(fixed); fixed != "" {
+			script = fixed
+			if findings, err = shell.Lint(script, shellName); err != nil {
+				findings = nil
+			}
+		}
+	}
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "nlch: warning: shellcheck: %s\n", f)
+	}
+	return script, nil
+}
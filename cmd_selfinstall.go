@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kanishka-sahoo/nlch/internal/update"
+)
+
+var selfInstallCommand = &command{
+	name:    "self-install",
+	usage:   "[flags]",
+	summary: "Install this nlch binary into a per-user directory and add it to PATH",
+}
+
+func init() {
+	selfInstallCommand.run = runSelfInstall
+}
+
+func runSelfInstall(args []string) error {
+	fs := newFlagSet(selfInstallCommand)
+	dir := fs.String("dir", "", "Install into this directory instead of the standard per-user one")
+	noPath := fs.Bool("no-path", false, "Leave PATH unchanged")
+	force := fs.Bool("force", false, "Install even if nlch is managed by a package manager")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if pm := update.ManagedInstall(); pm != nil && !*force {
+		return fmt.Errorf("nlch was installed with %s, which would not update a copy elsewhere; use --force to install one anyway", pm.Name)
+	}
+	if *dir == "" {
+		d, err := update.UserInstallDir()
+		if err != nil {
+			return fmt.Errorf("failed to find the install directory: %v", err)
+		}
+		*dir = d
+	}
+
+	path, err := update.SelfInstall(*dir)
+	if err != nil {
+		return fmt.Errorf("install failed: %v", err)
+	}
+	fmt.Printf("Installed nlch to %s\n", path)
+
+	switch {
+	case update.OnPath(*dir):
+		return nil
+	case *noPath:
+		fmt.Printf("%s is not on your PATH.\n", *dir)
+		return nil
+	}
+	err = update.AddToUserPath(*dir)
+	switch {
+	case err == nil:
+		fmt.Printf("Added %s to your user PATH. Open a new terminal to run nlch from anywhere.\n", *dir)
+	case errors.Is(err, errors.ErrUnsupported):
+		fmt.Printf("%s is not on your PATH. Add it in your shell's startup file, e.g.:\n  export PATH=\"%s:$PATH\"\n", *dir, *dir)
+	default:
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kanishka-sahoo/nlch/internal/session"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var sessionsCommand = &command{
+	name:    "sessions",
+	usage:   "| show <name> | delete <name>...",
+	summary: "List, show or delete the named sessions of interactive mode",
+}
+
+func init() {
+	sessionsCommand.run = runSessions
+}
+
+func runSessions(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "show":
+			return runSessionsShow(args[1:])
+		case "delete", "rm":
+			return runSessionsDelete(args[1:])
+		}
+	}
+	fs := newFlagSet(sessionsCommand)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	store, err := session.Open()
+	if err != nil {
+		return err
+	}
+	sessions, err := store.List()
+	if err != nil {
+		return fmt.Errorf("failed to read sessions: %v", err)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No sessions. Start one with: nlch -i --session <name>")
+		return nil
+	}
+	for _, s := range sessions {
+		fmt.Printf("%-20s %3d requests  %s  %s\n", s.Name, len(s.Turns), s.Updated.Local().Format("2006-01-02 15:04"), ui.Dim(s.Dir))
+	}
+	return nil
+}
+
+// runSessionsShow prints the requests and commands of a session.
+func runSessionsShow(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: nlch sessions show <name>")
+	}
+	store, err := session.Open()
+	if err != nil {
+		return err
+	}
+	s, err := store.Load(args[0])
+	if errors.Is(err, session.ErrNotFound) {
+		return fmt.Errorf("no session named %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Session %s, started %s in %s\n", s.Name, s.Created.Local().Format("2006-01-02 15:04"), s.Dir)
+	for _, t := range s.Turns {
+		status := "not run"
+		if t.Executed {
+			status = fmt.Sprintf("exit %d", t.ExitCode)
+		}
+		fmt.Printf("\n%s %s\n", ui.Dim(t.Time.Local().Format("15:04")), t.Request)
+		if t.Command != "" {
+			fmt.Printf("      %s  %s\n", ui.Highlight(t.Command), ui.Dim(status))
+		}
+	}
+	return nil
+}
+
+// runSessionsDelete deletes sessions by name.
+func runSessionsDelete(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: nlch sessions delete <name>...")
+	}
+	store, err := session.Open()
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		err := store.Delete(name)
+		if errors.Is(err, session.ErrNotFound) {
+			return fmt.Errorf("no session named %q", name)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted session %s.\n", name)
+	}
+	return nil
+}
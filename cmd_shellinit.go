@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+)
+
+var shellInitCommand = &command{
+	name:    "shell-init",
+	usage:   "<zsh|bash|fish>",
+	summary: "Print the keybinding integration script for your shell",
+}
+
+func init() {
+	shellInitCommand.run = runShellInit
+}
+
+func runShellInit(args []string) error {
+	fs := newFlagSet(shellInitCommand)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	shellName := fs.Arg(0)
+	if shellName == "" {
+		// Fall back to the user's login shell
+		shellName = filepath.Base(os.Getenv("SHELL"))
+	}
+	script, err := shell.IntegrationScript(shellName)
+	if err != nil {
+		return err
+	}
+	fmt.Print(script)
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/history"
+)
+
+var statsCommand = &command{
+	name:    "stats",
+	usage:   "[flags]",
+	summary: "Show usage statistics from the history",
+}
+
+func init() {
+	statsCommand.run = runStats
+}
+
+// statsTop is the number of commands and providers listed.
+const statsTop = 5
+
+func runStats(args []string) error {
+	fs := newFlagSet(statsCommand)
+	since := fs.String("since", "", "Only include entries newer than this age (e.g. 12h, 7d)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var filter history.Filter
+	if *since != "" {
+		age, err := history.ParseAge(*since)
+		if err != nil {
+			return err
+		}
+		filter.Since = time.Now().Add(-age)
+	}
+
+	store, err := history.Open()
+	if err != nil {
+		return err
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to read history: %v", err)
+	}
+	entries = filter.Apply(entries)
+	if len(entries) == 0 {
+		fmt.Println("No history entries found.")
+		return nil
+	}
+
+	s := history.Summarize(entries)
+	fmt.Printf("Requests:        %d (%d executed)\n", s.Total, s.Executed)
+	fmt.Printf("First attempts:  %s\n", successRate(s.FirstAttemptsPassed, s.FirstAttempts))
+	fmt.Printf("Corrections:     %s\n", successRate(s.CorrectionsPassed, s.Corrections))
+	fmt.Printf("Tokens:          %d in, %d out (estimated)\n", s.InputTokens, s.OutputTokens)
+	fmt.Printf("Estimated spend: $%.4f\n", s.Spend)
+
+	printCounts("Most used commands", s.Commands)
+	printCounts("Providers", s.Providers)
+	printPicks(s.Picks)
+	return nil
+}
+
+// successRate formats passed/total as a percentage.
+func successRate(passed, total int) string {
+	if total == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%d/%d succeeded (%.0f%%)", passed, total, float64(passed)*100/float64(total))
+}
+
+// printCounts prints the most frequent names under a heading.
+func printCounts(title string, counts []history.Count) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for i, c := range counts {
+		if i == statsTop {
+			break
+		}
+		fmt.Printf("  %-20s %d\n", c.Name, c.Count)
+	}
+}
+
+// printPicks prints how often each model's command was picked in comparisons.
+func printPicks(picks []history.Pick) {
+	if len(picks) == 0 {
+		return
+	}
+	fmt.Println("\nModel comparisons:")
+	for i, p := range picks {
+		if i == statsTop {
+			break
+		}
+		fmt.Printf("  %-20s picked %d of %d (%.0f%%)\n", p.Model, p.Picked, p.Compared, float64(p.Picked)*100/float64(p.Compared))
+	}
+}
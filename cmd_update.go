@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/update"
+)
+
+var updateCommand = &command{
+	name:    "update",
+	usage:   "[flags]",
+	summary: "Check for and install updates",
+}
+
+func init() {
+	updateCommand.run = runUpdate
+}
+
+func runUpdate(args []string) error {
+	fs := newFlagSet(updateCommand)
+	check := fs.Bool("check", false, "Check for updates without installing")
+	force := fs.Bool("force", false, "Install the latest release even if already up to date or managed by a package manager")
+	yes := fs.Bool("yes", false, "Install without asking for confirmation")
+	stage := fs.Bool("stage", false, "Quietly download a newer release to install on the next start, as automatic updates do")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	// Update settings are optional, so a missing config just means the defaults
+	if cfg, err := config.Load(); err == nil {
+		if err := update.Configure(cfg.Update); err != nil {
+			return err
+		}
+		if err := httpclient.Configure(cfg.Network); err != nil {
+			return err
+		}
+	}
+
+	if httpclient.IsOffline() {
+		return errors.New("offline mode: updates are disabled, install new versions by hand")
+	}
+
+	if *stage {
+		return update.StageLatest()
+	}
+
+	if *check {
+		release, hasUpdate, err := update.CheckForUpdates()
+		if err != nil {
+			return fmt.Errorf("update check failed: %v", err)
+		}
+		if hasUpdate {
+			fmt.Println(update.Message(release))
+			fmt.Printf("Run '%s' to install the update.\n", update.UpgradeCommand())
+		} else {
+			fmt.Println("nlch is up to date.")
+		}
+		return nil
+	}
+
+	if err := update.AutoUpdate(*force, *yes); err != nil {
+		return fmt.Errorf("update failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var useCommand = &command{
+	name:    "use",
+	usage:   "[flags] [provider[:model] | search]",
+	summary: "Choose the default provider and model from the configured ones",
+}
+
+func init() {
+	useCommand.run = runUse
+}
+
+// useChoice is a provider and model that can be made the default.
+type useChoice struct {
+	provider string
+	model    string
+	source   string // where the model is known from
+}
+
+func (c useChoice) label() string { return modelLabel(c.provider, c.model) }
+
+func runUse(args []string) error {
+	fs := newFlagSet(useCommand)
+	list := fs.Bool("list", false, "List the providers and models to choose from, without choosing")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("no usable configuration, run 'nlch init' first: %v", err)
+	}
+	if len(cfg.Providers) == 0 {
+		return errors.New("no providers configured, add one with 'nlch init'")
+	}
+	current := modelLabel(cfg.DefaultProvider, cfg.Providers[cfg.DefaultProvider].DefaultModel)
+	choices := useChoices(cfg)
+
+	if *list {
+		for _, c := range choices {
+			marker := " "
+			if c.label() == current {
+				marker = "*"
+			}
+			fmt.Printf("%s %-40s %s\n", marker, c.label(), ui.Dim(c.source))
+		}
+		return nil
+	}
+
+	query := strings.Join(fs.Args(), " ")
+	var picked useChoice
+	name, model, _ := strings.Cut(query, ":")
+	if _, ok := cfg.Providers[name]; ok {
+		// An exact provider[:model] needs no menu, and the model need not be known yet
+		picked = useChoice{provider: name, model: model}
+	} else {
+		matches := choices
+		if query != "" {
+			matches = slices.DeleteFunc(slices.Clone(choices), func(c useChoice) bool { return !fuzzyMatch(query, c.label()) })
+		}
+		switch {
+		case len(matches) == 0:
+			return fmt.Errorf("no configured provider or known model matches %q, see nlch use --list", query)
+		case len(matches) == 1 && query != "":
+			picked = matches[0]
+		default:
+			picked, err = pickChoice(matches, current, query)
+			if errors.Is(err, shell.ErrAborted) {
+				fmt.Println("> Aborted by user.")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := config.SetDefault(picked.provider, picked.model); err != nil {
+		return fmt.Errorf("failed to save configuration: %v", err)
+	}
+	if picked.model == "" {
+		picked.model = cfg.Providers[picked.provider].DefaultModel
+	}
+	fmt.Printf("> Now using %s.\n", ui.Highlight(picked.label()))
+	return nil
+}
+
+// useChoices lists each configured provider with its default model, the
+// models it was used with according to the history, newest first, and for
+// Ollama the models pulled into it. Providers are in alphabetical order.
+func useChoices(cfg *config.Config) []useChoice {
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	used := map[string][]string{}
+	if store, err := history.Open(); err == nil {
+		if entries, err := store.Load(); err == nil {
+			for i := len(entries) - 1; i >= 0; i-- {
+				e := entries[i]
+				if e.Model != "" && !slices.Contains(used[e.Provider], e.Model) {
+					used[e.Provider] = append(used[e.Provider], e.Model)
+				}
+			}
+		}
+	}
+
+	var choices []useChoice
+	for _, name := range names {
+		seen := map[string]bool{}
+		add := func(model, source string) {
+			if !seen[model] {
+				seen[model] = true
+				choices = append(choices, useChoice{provider: name, model: model, source: source})
+			}
+		}
+		if model := cfg.Providers[name].DefaultModel; model != "" {
+			add(model, "default model")
+		} else {
+			add("", "provider default")
+		}
+		for _, model := range used[name] {
+			add(model, "used before")
+		}
+		if name == "ollama" {
+			installed, _ := (&provider.OllamaProvider{URL: provider.OllamaURL(cfg.Providers[name])}).InstalledModels(ollamaProbeTimeout)
+			for _, model := range installed {
+				add(strings.TrimSuffix(model, ":latest"), "pulled into Ollama")
+			}
+		}
+	}
+	return choices
+}
+
+// pickChoice lets the user pick one of the choices, with fzf when it is
+// installed and from a numbered menu otherwise.
+func pickChoice(choices []useChoice, current, query string) (useChoice, error) {
+	if _, err := exec.LookPath("fzf"); err == nil {
+		var lines strings.Builder
+		for i, c := range choices {
+			fmt.Fprintf(&lines, "%d\t%s\t\x1b[2m%s\x1b[0m\n", i, c.label(), c.source)
+		}
+		fzf := exec.Command("fzf", "--ansi", "--delimiter=\t", "--with-nth=2..", "--nth=1", "--tiebreak=index",
+			"--height=40%", "--layout=reverse", "--prompt=use> ", "--header=current: "+current, "--query="+query)
+		fzf.Stdin = strings.NewReader(lines.String())
+		fzf.Stderr = os.Stderr
+		out, err := fzf.Output()
+		if err != nil {
+			// fzf exits with 1 when nothing matched and 130 when cancelled
+			if code := shell.ExitCode(err); code == 1 || code == 130 {
+				return useChoice{}, shell.ErrAborted
+			}
+			return useChoice{}, fmt.Errorf("fzf failed: %v", err)
+		}
+		i, _, _ := strings.Cut(string(out), "\t")
+		if n, err := strconv.Atoi(i); err == nil && n >= 0 && n < len(choices) {
+			return choices[n], nil
+		}
+		return useChoice{}, shell.ErrAborted
+	}
+
+	fmt.Println("> Providers and models:")
+	for i, c := range choices {
+		marker := " "
+		if c.label() == current {
+			marker = "*"
+		}
+		fmt.Printf(" %s%2d) %-40s %s\n", marker, i+1, c.label(), ui.Dim(c.source))
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		answer := strings.TrimSpace(shell.ReadLine(fmt.Sprintf("> Choose [1-%d, q to quit]: ", len(choices))))
+		if answer == "q" || answer == "Q" {
+			return useChoice{}, shell.ErrAborted
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+			return choices[n-1], nil
+		}
+		fmt.Println("> Invalid choice.")
+	}
+	return useChoice{}, shell.ErrAborted
+}
+
+// fuzzyMatch reports whether the characters of query appear in text in
+// order, ignoring case and spaces, as fzf matches.
+func fuzzyMatch(query, text string) bool {
+	text = strings.ToLower(text)
+	for _, r := range strings.ToLower(query) {
+		if r == ' ' {
+			continue
+		}
+		i := strings.IndexRune(text, r)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(string(r)):]
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+var whyCommand = &command{
+	name:    "why",
+	usage:   "[flags] [history id]",
+	summary: "Diagnose why the last command failed, without running anything",
+}
+
+func init() {
+	whyCommand.run = runWhy
+}
+
+// Maximum number of tokens in a diagnosis response.
+const whyMaxTokens = 1024
+
+// Only the end of piped output is sent, where errors usually are.
+const whyMaxOutputBytes = 8192
+
+// errNothingToDiagnose is returned when no failed command is known.
+var errNothingToDiagnose = errors.New("no failed command to diagnose; pipe its output into nlch why, or set up the shell integration with nlch shell-init")
+
+// failure is a failed command to diagnose. An exit code below zero is unknown.
+type failure struct {
+	command  string
+	exitCode int
+	output   string
+}
+
+func runWhy(args []string) error {
+	fs := newFlagSet(whyCommand)
+	model := fs.String("model", "", "Override the model to use")
+	providerFlag := fs.String("provider", "", "Override the provider to use")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	id := 0
+	if fs.NArg() == 1 {
+		n, err := strconv.Atoi(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid history id %q", fs.Arg(0))
+		}
+		id = n
+	}
+
+	f, err := findFailure(id)
+	if err != nil {
+		return err
+	}
+
+	cfg, prov, providerName, err := setupProvider(*providerFlag)
+	if err != nil {
+		return err
+	}
+	ctx := gatherContext()
+	opts := provider.ProviderOptions{
+		Model:     *model,
+		Provider:  providerName,
+		System:    withProjectInstructions(cfg, prompt.WhySystemPrompt),
+		MaxTokens: whyMaxTokens,
+		Raw:       true,
+	}
+	diagnosis, err := prov.GenerateCommand(*ctx, prompt.BuildWhyPrompt(ctx, f.command, f.exitCode, f.output), opts)
+	if err != nil {
+		return fmt.Errorf("provider error: %v", err)
+	}
+
+	if f.command != "" {
+		status := ""
+		if f.exitCode >= 0 {
+			status = fmt.Sprintf(" (exit status %d)", f.exitCode)
+		}
+		fmt.Printf("> %s%s\n\n", ui.Highlight(f.command), ui.Dim(status))
+	}
+	fmt.Println(ui.Markdown(strings.TrimSpace(diagnosis)))
+	return nil
+}
+
+// findFailure returns the failure to diagnose: output piped into nlch, the
+// given history entry, the last failed command line recorded by the shell
+// integration, or else the last command nlch ran.
+func findFailure(id int) (*failure, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 && id == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read output from stdin: %v", err)
+		}
+		if len(data) > whyMaxOutputBytes {
+			data = append([]byte("...\n"), data[len(data)-whyMaxOutputBytes:]...)
+		}
+		if strings.TrimSpace(string(data)) != "" {
+			return &failure{exitCode: -1, output: string(data)}, nil
+		}
+	}
+
+	if command := os.Getenv("NLCH_LAST_COMMAND"); command != "" && id == 0 {
+		code, err := strconv.Atoi(os.Getenv("NLCH_LAST_STATUS"))
+		if err != nil {
+			code = -1
+		}
+		return &failure{command: command, exitCode: code}, nil
+	}
+
+	entry, err := historyEntryOrLast(id)
+	if err != nil {
+		if id == 0 {
+			return nil, errNothingToDiagnose
+		}
+		return nil, err
+	}
+	switch {
+	case entry.Decision != history.DecisionExecuted:
+		return nil, fmt.Errorf("history entry %d was not run (%s), nothing to diagnose", entry.ID, entry.Decision)
+	case entry.ExitCode == 0:
+		return nil, fmt.Errorf("history entry %d succeeded, nothing to diagnose", entry.ID)
+	}
+	// The captured file has more of the output than the history keeps
+	if entry.Capture != "" {
+		if output, err := readTail(entry.Capture, whyMaxOutputBytes); err == nil {
+			return &failure{command: entry.Command, exitCode: entry.ExitCode, output: output}, nil
+		}
+		fmt.Fprintln(os.Stderr, ui.Dim("> The output captured in "+entry.Capture+" is no longer readable, using the history."))
+	}
+	if entry.Output == "" && entry.OutputHash != "" {
+		fmt.Fprintln(os.Stderr, ui.Dim("> The output of this command was not recorded (history.hash_outputs), diagnosing from the command alone."))
+	}
+	return &failure{command: entry.Command, exitCode: entry.ExitCode, output: entry.Output}, nil
+}
+
+// readTail returns at most the last limit bytes of a file.
+func readTail(path string, limit int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	prefix := ""
+	if info.Size() > limit {
+		if _, err := f.Seek(-limit, io.SeekEnd); err != nil {
+			return "", err
+		}
+		prefix = "...\n"
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return prefix + string(data), nil
+}
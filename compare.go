@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+// comparison is the command one model generated for a --compare request.
+type comparison struct {
+	provider provider.Provider
+	name     string // provider name
+	model    string
+	command  string
+	latency  time.Duration
+	used     app.Usage
+	err      error
+}
+
+// label names the model of the comparison as provider:model.
+func (c *comparison) label() string {
+	return modelLabel(c.name, c.model)
+}
+
+// parseCompareTargets splits a --compare list into comparisons. An entry is a
+// provider:model, or a model of the default provider when the part before the
+// colon is not a provider name, as in Ollama's "llama3:8b".
+func parseCompareTargets(cfg *config.Config, list, defaultProvider string) ([]*comparison, error) {
+	var targets []*comparison
+	for _, target := range strings.Split(list, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		name, model := defaultProvider, target
+		if before, after, ok := strings.Cut(target, ":"); ok && slices.Contains(provider.Names(), before) {
+			name, model = before, after
+		}
+		prov, ok := provider.Get(name)
+		if !ok {
+			return nil, provider.NotFound(name)
+		}
+		if err := checkOffline(cfg, name); err != nil {
+			return nil, err
+		}
+		targets = append(targets, &comparison{provider: validated(cfg, prov), name: name, model: resolveModel(prov, cfg, name, model)})
+	}
+	if len(targets) < 2 {
+		return nil, errors.New("--compare needs at least two models, separated by commas")
+	}
+	return targets, nil
+}
+
+// compareModels generates the command with every model in the list at once,
+// shows the commands with their latency and cost, and lets the user pick one.
+// It returns the picked comparison and the labels of the models that answered;
+// when only one did, its command is used without asking.
+func compareModels(cfg *config.Config, list, defaultProvider string, ctx *context.Context, promptStr string, opts provider.ProviderOptions, used *app.Usage, out io.Writer) (*comparison, []string, error) {
+	targets, err := parseCompareTargets(cfg, list, defaultProvider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o := opts
+			o.Provider, o.Model = c.name, c.model
+			start := time.Now()
+			reply, err := c.provider.GenerateCommand(*ctx, promptStr, o)
+			c.latency, c.err = time.Since(start), err
+			if err == nil {
+				c.command = prompt.CleanCommand(reply)
+				c.used.Add(c.model, o, promptStr, reply)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every request was paid for, whichever command is picked
+	var answered []*comparison
+	for _, c := range targets {
+		used.Input += c.used.Input
+		used.Output += c.used.Output
+		if c.err == nil && c.command == "" {
+			c.err = errors.New("LLM did not return a command")
+		}
+		if c.err == nil {
+			answered = append(answered, c)
+		}
+	}
+	switch len(answered) {
+	case 0:
+		return nil, nil, fmt.Errorf("provider error: %v", targets[0].err)
+	case 1:
+		for _, c := range targets {
+			if c.err != nil {
+				fmt.Fprintf(out, "> %s\n", ui.Dim(fmt.Sprintf("No command from %s: %v", c.label(), c.err)))
+			}
+		}
+		return answered[0], nil, nil
+	}
+
+	// With two commands, mark the words that differ
+	shown := make([]string, len(answered))
+	for i, c := range answered {
+		shown[i] = strings.TrimPrefix(c.command, DangerPrefix)
+	}
+	if len(answered) == 2 {
+		shown[0], shown[1] = ui.WordDiff(shown[0], shown[1])
+	}
+
+	width := 0
+	for _, c := range targets {
+		width = max(width, len(c.label()))
+	}
+	fmt.Fprintf(out, "> Comparing %d models:\n", len(targets))
+	n := 0
+	for _, c := range targets {
+		if c.err != nil {
+			fmt.Fprintf(out, "  -) %s\n     %s\n", ui.Dim(c.label()), ui.Error(fmt.Sprintf("no command: %v", c.err)))
+			continue
+		}
+		cost := "cost unknown"
+		if _, ok := tokens.PriceFor(c.model); ok {
+			cost = formatCost(tokens.Cost(c.model, c.used.Input, c.used.Output))
+		}
+		fmt.Fprintf(out, "  %d) %s\n     %s\n", n+1, ui.Dim(fmt.Sprintf("%-*s  %5.1fs  %s", width, c.label(), c.latency.Seconds(), cost)), shown[n])
+		if isDangerous(c.command) {
+			fmt.Fprintf(out, "     %s\n", ui.Danger("potentially dangerous"))
+		}
+		n++
+	}
+
+	labels := make([]string, len(answered))
+	for i, c := range answered {
+		labels[i] = c.label()
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		fmt.Fprintf(out, "> Choose [1-%d, q to quit]: ", len(answered))
+		answer := strings.TrimSpace(shell.ReadLine(""))
+		if answer == "q" || answer == "Q" {
+			return nil, nil, shell.ErrAborted
+		}
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(answered) {
+			return answered[i-1], labels, nil
+		}
+		fmt.Fprintln(out, "> Invalid choice.")
+	}
+	return nil, nil, shell.ErrAborted
+}
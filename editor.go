@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/editor"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+)
+
+// maxSelectionBytes bounds the editor selection added to the prompt.
+const maxSelectionBytes = 4000
+
+// editorTurn is a request from an editor integration, while it runs.
+type editorTurn struct {
+	request  *editor.Request
+	out      bytes.Buffer // the printed command
+	notes    bytes.Buffer // warnings the pipeline would print to stderr
+	res      *app.Result
+	provider string
+	model    string
+	shell    string
+}
+
+// editorRequest is set while runRun answers an editor integration.
+var editorRequest *editorTurn
+
+// runEditorRequest answers the request envelope on stdin with a JSON
+// response on stdout, running the request with the run command's flags.
+func runEditorRequest(flags []string) error {
+	req, err := editor.ReadRequest(os.Stdin)
+	if err != nil {
+		return writeEditorResponse(editor.Fail(err, editor.CodeInvalidRequest), err)
+	}
+	if req.Dir != "" {
+		if err := os.Chdir(req.Dir); err != nil {
+			err = fmt.Errorf("cannot use dir: %v", err)
+			return writeEditorResponse(editor.Fail(err, editor.CodeInvalidRequest), err)
+		}
+	}
+	// The envelope's settings come last, so they take precedence over flags
+	flags = slices.Clone(flags)
+	if req.Provider != "" {
+		flags = append(flags, "--provider", req.Provider)
+	}
+	if req.Model != "" {
+		flags = append(flags, "--model", req.Model)
+	}
+	if req.Shell != "" {
+		flags = append(flags, "--shell", req.Shell)
+	}
+	if req.ReadOnly {
+		flags = append(flags, "--read-only")
+	}
+
+	turn := &editorTurn{request: req}
+	editorRequest = turn
+	err = runRun(append(flags, "--", req.Request))
+	editorRequest = nil
+
+	if err != nil {
+		resp := editor.Fail(err, editor.CodeFailed)
+		if turn.res != nil && turn.res.Command != "" {
+			// The command was generated, then refused by a constraint or read-only mode
+			// before its risk was assessed
+			resp.Error.Code = editor.CodeRefused
+			turn.res.Risk, turn.res.Reason = app.AssessRisk(turn.res.Command)
+			turn.fill(resp)
+		}
+		return writeEditorResponse(resp, err)
+	}
+	if turn.res == nil || turn.res.Command == "" {
+		err := errors.New("no command was generated")
+		return writeEditorResponse(editor.Fail(err, editor.CodeFailed), err)
+	}
+	resp := &editor.Response{}
+	turn.fill(resp)
+	return writeEditorResponse(resp, nil)
+}
+
+// fill sets the command and what is known about it in a response.
+func (t *editorTurn) fill(resp *editor.Response) {
+	resp.Command = t.res.Command
+	resp.Risk = t.res.Risk.String()
+	resp.RiskReason = t.res.Reason
+	resp.Dangerous = t.res.Risk >= shell.RiskHigh
+	resp.Provider, resp.Model, resp.Shell = t.provider, t.model, t.shell
+	resp.HistoryID = t.res.ID
+	for _, line := range strings.Split(t.notes.String(), "\n") {
+		if line = strings.TrimSpace(strings.TrimPrefix(line, "nlch: warning: ")); line != "" {
+			resp.Warnings = append(resp.Warnings, line)
+		}
+	}
+}
+
+// addContext gives the model the file open in the editor and the selected text.
+func (t *editorTurn) addContext(ctx *context.Context) {
+	if t.request.File != "" {
+		file := t.request.File
+		if rel, err := filepath.Rel(ctx.WorkingDir, file); err == nil && filepath.IsAbs(file) && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		ctx.Extra["file open in the editor"] = file
+	}
+	if selection := t.request.Selection; selection != "" {
+		if len(selection) > maxSelectionBytes {
+			selection = selection[:maxSelectionBytes] + "\n... (truncated)"
+		}
+		ctx.Extra["text selected in the editor"] = "\n" + selection
+	}
+}
+
+// writeEditorResponse writes the response and returns err, so the exit
+// status still tells the request failed.
+func writeEditorResponse(resp *editor.Response, err error) error {
+	if werr := editor.Write(os.Stdout, resp); werr != nil {
+		return werr
+	}
+	return err
+}
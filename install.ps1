@@ -4,9 +4,16 @@
 param(
     [switch]$Help,
     [switch]$Version,
-    [string]$InstallDir = "$env:USERPROFILE\bin"
+    [string]$InstallDir = "$env:USERPROFILE\bin",
+    [switch]$PerUser,
+    [switch]$AddToPath
 )
 
+# The standard per-user location, also used by 'nlch self-install'
+if ($PerUser) {
+    $InstallDir = Join-Path $env:LOCALAPPDATA "Programs\nlch"
+}
+
 # Configuration
 $REPO = "kanishka-sahoo/nlch"
 $BINARY_NAME = "nlch"
@@ -51,6 +58,8 @@ function Show-Help {
     Write-Host "  -Help          Show this help message"
     Write-Host "  -Version       Show script version"
     Write-Host "  -InstallDir    Installation directory (default: $env:USERPROFILE\bin)"
+    Write-Host "  -PerUser       Install to $env:LOCALAPPDATA\Programs\nlch"
+    Write-Host "  -AddToPath     Add the installation directory to your user PATH"
     Write-Host ""
     Write-Host "This script downloads and installs the latest release of nlch"
     Write-Host "from the GitHub repository: https://github.com/$REPO"
@@ -155,6 +164,18 @@ function Install-Binary {
     }
 }
 
+# Add a directory to the PATH stored in the user's environment
+function Add-ToUserPath {
+    param([string]$Directory)
+
+    $userPath = [Environment]::GetEnvironmentVariable('Path', 'User')
+    $entries = @($userPath -split ';' | Where-Object { $_ -ne '' })
+    if ($entries -notcontains $Directory) {
+        [Environment]::SetEnvironmentVariable('Path', (($entries + $Directory) -join ';'), 'User')
+        Write-Success "Added $Directory to your user PATH"
+    }
+}
+
 # Verify installation
 function Test-Installation {
     $binaryPath = Join-Path $InstallDir "$BINARY_NAME.exe"
@@ -174,10 +195,14 @@ function Test-Installation {
         if ($pathDirs -contains $InstallDir) {
             Write-Info "You can now use '$BINARY_NAME' from anywhere in your terminal"
         }
+        elseif ($AddToPath) {
+            Add-ToUserPath -Directory $InstallDir
+            Write-Info "Open a new terminal to use '$BINARY_NAME' from anywhere"
+        }
         else {
             Write-Warning "Install directory is not in your PATH"
-            Write-Info "To add to PATH, run this command in an elevated PowerShell:"
-            Write-Info "  [Environment]::SetEnvironmentVariable('PATH', `$env:PATH + ';$InstallDir', 'User')"
+            Write-Info "To add it, run this script with -AddToPath, or run:"
+            Write-Info "  & '$binaryPath' self-install --dir '$InstallDir'"
             Write-Info "Or restart your terminal and run: $binaryPath"
         }
     }
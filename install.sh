@@ -54,6 +54,9 @@ detect_platform() {
         Linux)
             os="linux"
             ;;
+        FreeBSD)
+            os="freebsd"
+            ;;
         CYGWIN*|MINGW*|MSYS*)
             os="windows"
             ;;
@@ -71,8 +74,18 @@ detect_platform() {
         arm64|aarch64)
             arch="arm64"
             ;;
+        armv7*|armhf)
+            arch="armv7"
+            ;;
+        armv6*)
+            arch="armv6"
+            ;;
+        riscv64)
+            arch="riscv64"
+            ;;
         *)
             log_error "Unsupported architecture: $(uname -m)"
+            log_error "Build from source instead: go install github.com/kanishka-sahoo/nlch@latest"
             exit 1
             ;;
     esac
@@ -120,8 +133,15 @@ get_download_url() {
     local download_url
     download_url=$(echo "$release_info" | grep -o "\"browser_download_url\":\s*\"[^\"]*${asset_name}\"" | cut -d'"' -f4)
     
+    # ARMv7 machines can also run the ARMv6 build
+    if [ -z "$download_url" ] && [[ "$platform" == *"-armv7" ]]; then
+        asset_name="${BINARY_NAME}-${platform%armv7}armv6"
+        download_url=$(echo "$release_info" | grep -o "\"browser_download_url\":\s*\"[^\"]*${asset_name}\"" | cut -d'"' -f4)
+    fi
+    
     if [ -z "$download_url" ]; then
         log_error "No release asset found for platform: $platform"
+        log_info "Build from source instead: go install github.com/kanishka-sahoo/nlch@latest"
         log_info "Available assets:"
         echo "$release_info" | grep -o "\"name\":\s*\"[^\"]*\"" | cut -d'"' -f4 | grep "$BINARY_NAME" || true
         exit 1
@@ -0,0 +1,362 @@
+// Package app runs generated commands through nlch's pipeline: safety checks,
+// confirmation, execution, refinement and the correction of failed commands.
+// Its dependencies are injected, so the CLI, a REPL, a server or a test can
+// each drive it with their own provider, executor and output.
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+// Maximum number of tokens in an explanation response.
+const explainMaxTokens = 1024
+
+// App holds what the pipeline needs to handle a request.
+type App struct {
+	Config       *config.Config
+	Provider     provider.Provider
+	ProviderName string
+	Model        string // the model in use, for history and token estimates
+	Context      *context.Context
+	Executor     shell.CommandExecutor
+	Out          io.Writer               // messages, prompts and printed commands
+	Err          io.Writer               // warnings
+	Record       func(history.Entry) int // stores an outcome, returning its history ID
+	LocalCheck   *LocalCheck             // second opinion on whether commands are destructive; nil for none
+	Shell        string                  // shell the commands are written for, such as fish; bash if empty
+}
+
+// Options are the settings of a single request.
+type Options struct {
+	Request  string                   // what the user asked for, as recorded in the history
+	Prompt   string                   // the prompt the command was generated from
+	Generate provider.ProviderOptions // the options it was generated with
+
+	DryRun    bool
+	YesImSure bool // run without confirmation, unless the risk level is blocked
+	ReadOnly  bool // refuse commands that are not known to only read
+	Print     bool // print the command instead of running it
+	Explain   bool // break the command down instead of running it
+	Chosen    bool // the user picked the command from a menu, which counts as a Y/n confirmation
+
+	Usage    Usage    // tokens spent on the command so far
+	Compared []string // models the command was picked from
+	Capture  string   // file the executor also writes the output to, recorded in the history
+}
+
+// Result is the outcome of a request.
+type Result struct {
+	Command string // the last command that was run or offered
+	ID      int    // history ID of its record
+	Rated   bool   // the command ran to completion, so the user may rate it
+	Risk    shell.Risk
+	Reason  string // why the command has its risk level
+}
+
+// request is the state of one Run.
+type request struct {
+	Options
+	res            *Result
+	stdout, stderr string // output of the last command run
+}
+
+// Run takes a generated command through the pipeline. The user may refine it
+// at the confirmation prompt, and if it fails the provider is asked for a
+// corrected command, which is confirmed and run in turn.
+func (a *App) Run(cmd string, o Options) (*Result, error) {
+	r := &request{Options: o, res: &Result{}}
+	var conversation []provider.Message
+	var err error
+	for {
+		// Rewrite rules apply first, so that the checks see the command that would run
+		notes := a.Out
+		if r.Print {
+			notes = a.Err
+		}
+		cmd = a.rewrite(cmd, notes)
+
+		// Safety and confirmation logic - the LLM's danger marker and the local rules set the risk
+		if err := a.check(cmd, r.ReadOnly); err != nil {
+			a.record(r, cmd, history.DecisionBlocked, nil, false)
+			return r.res, err
+		}
+		risk, reason := a.assess(cmd)
+		r.res.Risk, r.res.Reason = risk, reason
+		cmd = strings.TrimPrefix(cmd, prompt.DangerPrefix)
+
+		// In print mode the command is handed back to the caller (e.g. a shell widget) unexecuted
+		if r.Print {
+			if risk >= shell.RiskHigh {
+				fmt.Fprintln(a.Err, ui.Danger(fmt.Sprintf("nlch: warning: this command is %s risk (%s), review it before running", risk, reason)))
+			}
+			fmt.Fprintln(a.Out, cmd)
+			a.record(r, cmd, history.DecisionPrinted, nil, false)
+			return r.res, nil
+		}
+
+		// In explain mode the command is broken down but never executed
+		if r.Explain {
+			fmt.Fprintf(a.Out, "> Command: %s\n", ui.Highlight(ui.WrapCommand(cmd, len("> Command: "))))
+			if risk >= shell.RiskHigh {
+				fmt.Fprintf(a.Out, "> %s\n", ui.Danger(fmt.Sprintf("Risk: %s (%s)", risk, reason)))
+			} else {
+				fmt.Fprintf(a.Out, "> Risk: %s\n", risk)
+			}
+			explanation, err := Explain(a.Provider, a.Context, cmd, r.Generate)
+			if err != nil {
+				return r.res, err
+			}
+			fmt.Fprintf(a.Out, "\n%s\n", ui.Markdown(explanation))
+			a.record(r, cmd, history.DecisionDryRun, nil, false)
+			return r.res, nil
+		}
+
+		cmd = a.answerPrompts(cmd)
+		a.lint(cmd)
+		confirm, gateErr := a.gate(cmd, risk, reason, r.YesImSure, r.Chosen)
+		if gateErr != nil {
+			a.record(r, cmd, history.DecisionBlocked, nil, false)
+			return r.res, gateErr
+		}
+		a.showPipeline(r, cmd, confirm)
+
+		r.stdout, r.stderr, err = a.Executor.Run(cmd, confirm)
+		if !errors.Is(err, shell.ErrRefine) {
+			break
+		}
+
+		// Regenerate with the user's adjustment, keeping the prior exchange as conversation history
+		refinement := strings.TrimSpace(a.Executor.ReadLine("> Refine: "))
+		if refinement == "" {
+			continue
+		}
+		conversation = append(conversation,
+			provider.Message{Role: "user", Content: r.Prompt},
+			provider.Message{Role: "assistant", Content: cmd},
+		)
+		r.Prompt = prompt.BuildRefinePrompt(refinement)
+		refineOpts := r.Generate
+		refineOpts.History = conversation
+		cmd, err = a.Provider.GenerateCommand(*a.Context, r.Prompt, refineOpts)
+		if err != nil {
+			return r.res, fmt.Errorf("provider error: %v", err)
+		}
+		r.Usage.Add(a.Model, refineOpts, r.Prompt, cmd)
+		cmd = prompt.CleanCommand(cmd)
+		r.Chosen = false
+	}
+	a.record(r, cmd, Decision(r.DryRun, err), err, false)
+	switch {
+	case errors.Is(err, shell.ErrAborted):
+		return r.res, nil
+	case shell.Interrupted(err):
+		return r.res, interrupt.ErrInterrupted
+	case err == nil:
+		r.res.Rated = !r.DryRun
+		return r.res, nil
+	case r.DryRun:
+		return r.res, fmt.Errorf("command failed: %v", err)
+	}
+	return r.res, a.correct(r, cmd, err)
+}
+
+// correct asks the provider to fix a failed command and runs the correction
+// after confirming it, showing what changed.
+func (a *App) correct(r *request, cmd string, runErr error) error {
+	// A command killed at the deadline is not worth correcting
+	if interrupt.Interrupted() {
+		return interrupt.ErrInterrupted
+	}
+	fmt.Fprintln(a.Out, "\n> Command failed. Asking LLM to provide a corrected version...")
+
+	// Build a prompt with the error information
+	errorPrompt := fmt.Sprintf(
+		"The previous command failed:\n"+
+			"Command: %s\n"+
+			"Error: %s\n"+
+			"Stderr: %s\n"+
+			"Stdout: %s\n\n"+
+			"Please provide a corrected command for the original request: %s\n"+
+			"Return ONLY the shell command, nothing else. Do not use markdown code blocks.",
+		cmd, runErr.Error(), r.stderr, r.stdout, r.Request)
+
+	// Get corrected command from LLM
+	correctedCmd, err := a.Provider.GenerateCommand(*a.Context, errorPrompt, r.Generate)
+	if err != nil {
+		return fmt.Errorf("failed to get corrected command: %v", err)
+	}
+	r.Usage.Add(a.Model, r.Generate, errorPrompt, correctedCmd)
+
+	// Clean up the corrected command (remove markdown code blocks, etc.)
+	correctedCmd = prompt.CleanCommand(correctedCmd)
+
+	// Check if we got a valid corrected command
+	if strings.TrimSpace(correctedCmd) == "" {
+		return errors.New("LLM did not provide a valid corrected command")
+	}
+
+	correctedCmd = a.rewrite(correctedCmd, a.Out)
+	if err := a.check(correctedCmd, r.ReadOnly); err != nil {
+		a.record(r, correctedCmd, history.DecisionBlocked, nil, true)
+		return err
+	}
+	risk, reason := a.assess(correctedCmd)
+	correctedCmd = strings.TrimPrefix(correctedCmd, prompt.DangerPrefix)
+	correctedCmd = a.answerPrompts(correctedCmd)
+	confirm, err := a.gate(correctedCmd, risk, reason, r.YesImSure, false)
+	if err != nil {
+		a.record(r, correctedCmd, history.DecisionBlocked, nil, true)
+		return err
+	}
+	a.showPipeline(r, correctedCmd, confirm)
+
+	// Show what changed against the failed command before asking to run it
+	failed, corrected := ui.WordDiff(cmd, correctedCmd)
+	fmt.Fprintln(a.Out, "\n> Trying corrected command:")
+	fmt.Fprintf(a.Out, "  %s %s\n", ui.Dim("failed:   "), failed)
+	fmt.Fprintf(a.Out, "  %s %s\n", ui.Dim("corrected:"), corrected)
+	if shell.Equivalent(cmd, correctedCmd) {
+		fmt.Fprintln(a.Out, "> The corrected command does the same as the one that failed.")
+	}
+	r.stdout, r.stderr, err = a.Executor.Run(correctedCmd, confirm)
+	a.record(r, correctedCmd, Decision(false, err), err, true)
+	switch {
+	case errors.Is(err, shell.ErrAborted):
+		return nil
+	case shell.Interrupted(err):
+		return interrupt.ErrInterrupted
+	}
+	r.res.Rated = true
+	if err != nil {
+		return fmt.Errorf("corrected command also failed: %v", err)
+	}
+	return nil
+}
+
+// record stores an outcome in the history, along with the tokens spent since the last record.
+func (a *App) record(r *request, command, decision string, runErr error, corrected bool) {
+	exitCode, output, capture, target := 0, "", "", ""
+	if decision == history.DecisionExecuted {
+		exitCode = shell.ExitCode(runErr)
+		output = r.stdout + r.stderr
+		capture = r.Capture
+	}
+	if !shell.POSIX(a.Shell) {
+		target = a.Shell
+	}
+	r.res.Command = strings.TrimPrefix(command, prompt.DangerPrefix)
+	r.res.ID = a.Record(history.Entry{
+		Request:   r.Request,
+		Command:   r.res.Command,
+		Provider:  a.ProviderName,
+		Model:     a.Model,
+		Dir:       a.Context.WorkingDir,
+		Decision:  decision,
+		ExitCode:  exitCode,
+		Corrected: corrected,
+		Output:    output,
+
+		InputTokens:  r.Usage.Input,
+		OutputTokens: r.Usage.Output,
+		Compared:     r.Compared,
+		Capture:      capture,
+		Shell:        target,
+	})
+	r.Usage, r.Compared = Usage{}, nil
+}
+
+// check applies the configured constraints, and in read-only mode refuses
+// commands that may change anything.
+func (a *App) check(cmd string, readOnly bool) error {
+	cmd = strings.TrimPrefix(cmd, prompt.DangerPrefix)
+	if err := CheckConstraints(cmd, a.Config.Never); err != nil {
+		return err
+	}
+	if readOnly {
+		return CheckReadOnly(cmd)
+	}
+	return nil
+}
+
+// answerPrompts adds the options that answer the confirmations a command
+// would stop to ask for, such as -y for apt-get install, unless the config
+// says to leave such commands as they are. The user confirms the command
+// with the options added.
+func (a *App) answerPrompts(cmd string) string {
+	if a.Config.Interactive == shell.InteractiveTerminal {
+		return cmd
+	}
+	cmd, added := shell.NonInteractive(cmd)
+	if len(added) > 0 {
+		fmt.Fprintf(a.Out, "> Added %s so the command doesn't stop to ask for confirmation.\n", strings.Join(added, ", "))
+	}
+	return cmd
+}
+
+// lint warns about problems shellcheck finds in a multi-line command before
+// the user confirms it.
+func (a *App) lint(cmd string) {
+	if a.Config.Lint.Disabled || !shell.POSIX(a.Shell) || !strings.Contains(strings.TrimSpace(cmd), "\n") {
+		return
+	}
+	findings, err := shell.Lint(cmd, filepath.Base(shell.Interpreter()))
+	if err != nil {
+		fmt.Fprintf(a.Err, "nlch: warning: %v\n", err)
+		return
+	}
+	for _, f := range findings {
+		fmt.Fprintf(a.Out, "> %s\n", ui.Danger("shellcheck: "+f.String()))
+	}
+}
+
+// gate decides how a command of the given risk has to be confirmed,
+// returning an error when its risk level is blocked. --yes-im-sure skips any
+// confirmation short of a block, and a command the user already picked from
+// a menu needs no further Y/n question.
+func (a *App) gate(cmd string, risk shell.Risk, reason string, yesSure, chosen bool) (shell.Confirmation, error) {
+	confirm := ConfirmationFor(a.Config, risk)
+	if confirm == shell.ConfirmBlock {
+		fmt.Fprintf(a.Out, "> %s %s\n", ui.Danger(fmt.Sprintf("Blocked %s-risk command:", risk)), ui.Highlight(cmd))
+		if reason != "" {
+			reason = " (" + reason + ")"
+		}
+		return confirm, fmt.Errorf("%s-risk commands are blocked%s, change confirm.%s in the config to allow them", risk, reason, risk)
+	}
+	if risk >= shell.RiskHigh {
+		fmt.Fprintf(a.Out, "> %s\n", ui.Danger(fmt.Sprintf("This command is %s risk: %s.", risk, reason)))
+	}
+	switch {
+	case yesSure:
+		confirm = shell.ConfirmNone
+	case chosen && confirm == shell.ConfirmYesNo:
+		confirm = shell.ConfirmNone
+	}
+	return confirm, nil
+}
+
+// Explain asks the provider for a structured explanation of a command.
+func Explain(prov provider.Provider, ctx *context.Context, target string, opts provider.ProviderOptions) (string, error) {
+	opts.System = prompt.ExplainSystemPrompt
+	opts.MaxTokens = explainMaxTokens
+	opts.Raw = true
+	opts.History = nil
+	opts.Images = nil
+	explanation, err := prov.GenerateCommand(*ctx, prompt.BuildExplainPrompt(ctx, target), opts)
+	if err != nil {
+		return "", fmt.Errorf("provider error: %v", err)
+	}
+	return explanation, nil
+}
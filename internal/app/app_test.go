@@ -0,0 +1,287 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+)
+
+// fakeRunner records the commands it is asked to run and fails those in fail.
+type fakeRunner struct {
+	ran  []string
+	fail map[string]error
+}
+
+func (f *fakeRunner) Run(cmd string, stdout, stderr io.Writer) error {
+	f.ran = append(f.ran, cmd)
+	if err := f.fail[cmd]; err != nil {
+		io.WriteString(stderr, "something went wrong\n")
+		return err
+	}
+	io.WriteString(stdout, "done\n")
+	return nil
+}
+
+// testApp is an App whose executor answers the confirmations from answers
+// and runs nothing, whose provider gives the responses in order, and whose
+// history is kept in entries.
+type testApp struct {
+	*App
+	runner  *fakeRunner
+	mock    *provider.MockProvider
+	out     bytes.Buffer
+	entries []history.Entry
+}
+
+func newTestApp(cfg *config.Config, answers string, responses ...string) *testApp {
+	t := &testApp{runner: &fakeRunner{}, mock: &provider.MockProvider{Responses: responses}}
+	t.App = &App{
+		Config:   cfg,
+		Provider: t.mock,
+		Context:  &context.Context{WorkingDir: "/work"},
+		Executor: &shell.Executor{AllowRefine: true, Stdin: strings.NewReader(answers), Stdout: &t.out, Stderr: &t.out, Runner: t.runner},
+		Out:      &t.out,
+		Err:      &t.out,
+		Record: func(e history.Entry) int {
+			t.entries = append(t.entries, e)
+			return len(t.entries)
+		},
+	}
+	return t
+}
+
+// decisions returns the commands recorded in the history with their decisions.
+func (t *testApp) decisions() []string {
+	var d []string
+	for _, e := range t.entries {
+		entry := e.Command + ": " + e.Decision
+		if e.Corrected {
+			entry += " (corrected)"
+		}
+		d = append(d, entry)
+	}
+	return d
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    config.Config
+		cmd       string
+		opts      Options
+		answers   string
+		wantErr   bool
+		wantRan   []string
+		decisions []string
+	}{
+		{
+			name:      "low risk runs without asking",
+			cmd:       "ls -la",
+			wantRan:   []string{"ls -la"},
+			decisions: []string{"ls -la: executed"},
+		},
+		{
+			name:      "medium risk runs when confirmed",
+			cmd:       "mkdir out",
+			answers:   "y\n",
+			wantRan:   []string{"mkdir out"},
+			decisions: []string{"mkdir out: executed"},
+		},
+		{
+			name:      "medium risk is declined",
+			cmd:       "mkdir out",
+			answers:   "n\n",
+			decisions: []string{"mkdir out: aborted"},
+		},
+		{
+			name:      "a command picked from a menu needs no Y/n",
+			cmd:       "mkdir out",
+			opts:      Options{Chosen: true},
+			wantRan:   []string{"mkdir out"},
+			decisions: []string{"mkdir out: executed"},
+		},
+		{
+			name:      "high risk needs yes typed in full",
+			cmd:       "rm -rf build",
+			answers:   "y\n",
+			decisions: []string{"rm -rf build: aborted"},
+		},
+		{
+			name:      "the danger marker raises the risk and is removed",
+			cmd:       "danger: ls -la",
+			answers:   "yes\n",
+			wantRan:   []string{"ls -la"},
+			decisions: []string{"ls -la: executed"},
+		},
+		{
+			name:      "--yes-im-sure skips confirmation",
+			cmd:       "rm -rf build",
+			opts:      Options{YesImSure: true},
+			wantRan:   []string{"rm -rf build"},
+			decisions: []string{"rm -rf build: executed"},
+		},
+		{
+			name:      "critical risk is blocked",
+			cmd:       "rm -rf /",
+			opts:      Options{YesImSure: true},
+			wantErr:   true,
+			decisions: []string{"rm -rf /: blocked"},
+		},
+		{
+			name:      "the config blocks a risk level",
+			config:    config.Config{Confirm: config.ConfirmConfig{Medium: "block"}},
+			cmd:       "mkdir out",
+			wantErr:   true,
+			decisions: []string{"mkdir out: blocked"},
+		},
+		{
+			name:      "never constraints block",
+			config:    config.Config{Never: []string{"rm -rf"}},
+			cmd:       "rm -rf build",
+			opts:      Options{YesImSure: true},
+			wantErr:   true,
+			decisions: []string{"rm -rf build: blocked"},
+		},
+		{
+			name:      "read-only mode blocks changes",
+			cmd:       "touch file",
+			opts:      Options{ReadOnly: true},
+			wantErr:   true,
+			decisions: []string{"touch file: blocked"},
+		},
+		{
+			name:      "rewrite rules apply before the checks",
+			config:    config.Config{Rewrite: []config.RewriteRule{{Match: `^rm -rf `, Replace: "trash "}}, Never: []string{"rm -rf"}},
+			cmd:       "rm -rf build",
+			answers:   "y\n",
+			wantRan:   []string{"trash build"},
+			decisions: []string{"trash build: executed"},
+		},
+		{
+			name:      "dry runs run nothing",
+			cmd:       "mkdir out",
+			opts:      Options{DryRun: true},
+			decisions: []string{"mkdir out: dry-run"},
+		},
+		{
+			name:      "print mode only prints",
+			cmd:       "danger: rm -rf build",
+			opts:      Options{Print: true},
+			decisions: []string{"rm -rf build: printed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			a := newTestApp(&cfg, tt.answers)
+			// As in the CLI, a dry run is both a request option and an executor setting
+			a.Executor.(*shell.Executor).DryRun = tt.opts.DryRun
+			_, err := a.Run(tt.cmd, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if strings.Join(a.runner.ran, "|") != strings.Join(tt.wantRan, "|") {
+				t.Errorf("ran %q, want %q", a.runner.ran, tt.wantRan)
+			}
+			if got := a.decisions(); strings.Join(got, "|") != strings.Join(tt.decisions, "|") {
+				t.Errorf("history %q, want %q\noutput:\n%s", got, tt.decisions, a.out.String())
+			}
+		})
+	}
+}
+
+func TestRunPrintsCommand(t *testing.T) {
+	a := newTestApp(&config.Config{}, "")
+	res, err := a.Run("danger: rm -rf build", Options{Print: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(a.out.String(), "rm -rf build\n") || strings.Contains(a.out.String(), "danger:") {
+		t.Errorf("output:\n%s", a.out.String())
+	}
+	if res.Command != "rm -rf build" || res.Risk < shell.RiskHigh {
+		t.Errorf("result = %q, %s risk", res.Command, res.Risk)
+	}
+}
+
+func TestRunCorrectsFailedCommand(t *testing.T) {
+	a := newTestApp(&config.Config{}, "", "cat notes.txt")
+	a.runner.fail = map[string]error{"cat missing.txt": errors.New("exit status 1")}
+	res, err := a.Run("cat missing.txt", Options{Request: "show the notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "cat missing.txt|cat notes.txt"; strings.Join(a.runner.ran, "|") != want {
+		t.Errorf("ran %q, want %s", a.runner.ran, want)
+	}
+	want := []string{"cat missing.txt: executed", "cat notes.txt: executed (corrected)"}
+	if got := a.decisions(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("history %q, want %q", got, want)
+	}
+	if !res.Rated || res.Command != "cat notes.txt" || res.ID != 2 {
+		t.Errorf("result = %+v", res)
+	}
+	if a.mock.Calls() != 1 {
+		t.Errorf("provider asked %d times, want once", a.mock.Calls())
+	}
+}
+
+func TestRunCorrectionGoesThroughTheChecks(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     config.Config
+		correction string
+		answers    string
+		decision   string
+	}{
+		{"a never constraint blocks it", config.Config{Never: []string{"rm -rf"}}, "rm -rf build", "", "rm -rf build: blocked (corrected)"},
+		{"its own risk is confirmed", config.Config{}, "danger: make clean", "n\n", "make clean: aborted (corrected)"},
+		{"rewrite rules apply to it", config.Config{Rewrite: []config.RewriteRule{{Match: "^make ", Replace: "make -j4 "}}}, "make all", "", "make -j4 all: executed (corrected)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			a := newTestApp(&cfg, tt.answers, tt.correction)
+			a.runner.fail = map[string]error{"ls missing": errors.New("exit status 2")}
+			a.Run("ls missing", Options{})
+			decisions := a.decisions()
+			if len(decisions) != 2 || decisions[1] != tt.decision {
+				t.Errorf("history %q, want the correction recorded as %q", decisions, tt.decision)
+			}
+		})
+	}
+}
+
+func TestRunReportsFailedCorrection(t *testing.T) {
+	a := newTestApp(&config.Config{}, "", "ls missing-too")
+	a.runner.fail = map[string]error{"ls missing": errors.New("exit status 2"), "ls missing-too": errors.New("exit status 2")}
+	_, err := a.Run("ls missing", Options{})
+	if err == nil || !strings.Contains(err.Error(), "corrected command also failed") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRunRefinesCommand(t *testing.T) {
+	// The user refines the first command at the prompt, then accepts the new one
+	a := newTestApp(&config.Config{}, "r\nbiggest first\ny\n", "ls -S")
+	if _, err := a.Run("mkdir -p out && ls", Options{Prompt: "list files"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ls -S"; strings.Join(a.runner.ran, "|") != want {
+		t.Errorf("ran %q, want %s", a.runner.ran, want)
+	}
+	if a.mock.Calls() != 1 {
+		t.Errorf("provider asked %d times, want once", a.mock.Calls())
+	}
+	if got := a.decisions(); len(got) != 1 || got[0] != "ls -S: executed" {
+		t.Errorf("history %q", got)
+	}
+}
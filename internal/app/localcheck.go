@@ -0,0 +1,72 @@
+// Package app double-checks generated commands with a model running on this
+// machine, so safety decisions don't rest on a single model's formatting
+// discipline.
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+)
+
+// Maximum number of tokens in a classification reply.
+const classifyMaxTokens = 64
+
+// LocalCheck asks a local model whether a command is destructive, as a second
+// opinion next to the provider's danger marker and the local rules.
+type LocalCheck struct {
+	Provider provider.Provider
+	Model    string
+}
+
+// Raise asks the local model about a command rated below high risk, and
+// raises it to high when the model finds it destructive. Commands already
+// rated high or above are not sent.
+func (l *LocalCheck) Raise(ctx *context.Context, cmd string, risk shell.Risk, reason string) (shell.Risk, string, error) {
+	if risk >= shell.RiskHigh {
+		return risk, reason, nil
+	}
+	opts := provider.ProviderOptions{
+		Provider:  l.Provider.Name(),
+		Model:     l.Model,
+		System:    prompt.ClassifySystemPrompt,
+		MaxTokens: classifyMaxTokens,
+		Raw:       true,
+	}
+	reply, err := l.Provider.GenerateCommand(*ctx, prompt.BuildClassifyPrompt(ctx.WorkingDir, cmd), opts)
+	if err != nil {
+		return risk, reason, fmt.Errorf("local check with %s failed: %v", l.Model, err)
+	}
+	destructive, why, err := prompt.ParseClassification(reply)
+	if err != nil {
+		return risk, reason, fmt.Errorf("local check with %s: %v", l.Model, err)
+	}
+	if !destructive {
+		return risk, reason, nil
+	}
+	if why == "" {
+		why = "no reason given"
+	}
+	return shell.RiskHigh, fmt.Sprintf("%s rated it destructive: %s", l.Model, why), nil
+}
+
+// assess rates a generated command like AssessRisk, then checks its quoting
+// of paths and has the local check, if there is one, look at commands rated
+// below high. A failed local check is a warning: the rating of the local
+// rules stands.
+func (a *App) assess(cmd string) (shell.Risk, string) {
+	risk, reason := AssessRisk(cmd)
+	risk, reason = a.checkQuoting(cmd, risk, reason)
+	if a.LocalCheck == nil {
+		return risk, reason
+	}
+	risk, reason, err := a.LocalCheck.Raise(a.Context, strings.TrimPrefix(cmd, prompt.DangerPrefix), risk, reason)
+	if err != nil {
+		fmt.Fprintf(a.Err, "nlch: warning: %v\n", err)
+	}
+	return risk, reason
+}
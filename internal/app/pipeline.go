@@ -0,0 +1,71 @@
+// Package app shows what each stage of a piped command does before the user
+// confirms it.
+package app
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+// Maximum number of tokens in the description of a pipeline.
+const pipelineMaxTokens = 256
+
+// Widest a stage is shown in the table before it is shortened.
+const maxStageWidth = 48
+
+// showPipeline prints a table of the stages of a piped command, each with a
+// one-line description from the provider, so that a stage that doesn't
+// belong stands out. It prints nothing for commands without a pipe, and only
+// a warning when the stages can't be described.
+func (a *App) showPipeline(r *request, cmd string, confirm shell.Confirmation) {
+	if r.DryRun || (confirm != shell.ConfirmYesNo && confirm != shell.ConfirmTyped) {
+		return
+	}
+	if a.Config != nil && a.Config.HidePipelines {
+		return
+	}
+	stages := shell.PipelineStages(cmd)
+	if len(stages) < 2 {
+		return
+	}
+	opts := r.Generate
+	opts.System = prompt.PipelineSystemPrompt
+	opts.MaxTokens = pipelineMaxTokens
+	opts.Raw = true
+	opts.JSON = false
+	opts.History = nil
+	opts.Images = nil
+	promptStr := prompt.BuildPipelinePrompt(cmd, stages)
+	reply, err := a.Provider.GenerateCommand(*a.Context, promptStr, opts)
+	if err != nil {
+		fmt.Fprintf(a.Err, "nlch: warning: could not describe the pipeline stages: %v\n", err)
+		return
+	}
+	r.Usage.Add(a.Model, opts, promptStr, reply)
+	descriptions := prompt.ParseStageDescriptions(reply, len(stages))
+
+	shown := make([]string, len(stages))
+	width := 0
+	for i, stage := range stages {
+		stage = strings.Join(strings.Fields(stage), " ")
+		if utf8.RuneCountInString(stage) > maxStageWidth {
+			stage = string([]rune(stage)[:maxStageWidth-1]) + "…"
+		}
+		shown[i] = stage
+		width = max(width, utf8.RuneCountInString(stage))
+	}
+	fmt.Fprintln(a.Out, "> Pipeline stages:")
+	for i, stage := range shown {
+		if ui.Accessible() {
+			fmt.Fprintf(a.Out, "  Stage %d: %s: %s\n", i+1, stage, descriptions[i])
+			continue
+		}
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(stage))
+		fmt.Fprintf(a.Out, "  %d  %s%s  %s\n", i+1, ui.Highlight(stage), padding, ui.Dim(descriptions[i]))
+	}
+}
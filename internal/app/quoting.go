@@ -0,0 +1,34 @@
+// Package app checks that generated commands quote the paths from the
+// context that the shell would split, such as file names with spaces.
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/snippets"
+)
+
+// checkQuoting warns about the working directory or files in it that cmd
+// names without the quotes they need, and raises a low risk to medium, since
+// the command would act on other paths than the ones meant.
+func (a *App) checkQuoting(cmd string, risk shell.Risk, reason string) (shell.Risk, string) {
+	if a.Context == nil {
+		return risk, reason
+	}
+	names := append([]string{a.Context.WorkingDir}, a.Context.Files...)
+	unquoted := shell.UnquotedNames(strings.TrimPrefix(cmd, prompt.DangerPrefix), names)
+	if len(unquoted) == 0 {
+		return risk, reason
+	}
+	for i, name := range unquoted {
+		unquoted[i] = snippets.Quote(name)
+	}
+	fmt.Fprintf(a.Err, "nlch: warning: the command leaves %s unquoted, so the shell would not pass it on as one name\n", strings.Join(unquoted, ", "))
+	if risk < shell.RiskMedium {
+		risk, reason = shell.RiskMedium, "a path with spaces or quotes is not quoted"
+	}
+	return risk, reason
+}
@@ -0,0 +1,69 @@
+// Package app applies the configured rewrite rules to generated commands.
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+)
+
+// Rewrite applies the rewrite rules to a command in order, returning it with
+// a description of each rule that changed it. The danger marker, if any, is
+// kept. Rules with invalid regular expressions are skipped and reported in err.
+func Rewrite(cmd string, rules []config.RewriteRule) (string, []string, error) {
+	marker := ""
+	if strings.HasPrefix(cmd, prompt.DangerPrefix) {
+		marker, cmd = prompt.DangerPrefix, strings.TrimPrefix(cmd, prompt.DangerPrefix)
+	}
+	var applied []string
+	var errs []error
+	for _, rule := range rules {
+		match, err := regexp.Compile(rule.Match)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rewrite rule %q: %v", rule.Match, err))
+			continue
+		}
+		if rule.Unless != "" {
+			unless, err := regexp.Compile(rule.Unless)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rewrite rule %q: unless: %v", rule.Match, err))
+				continue
+			}
+			if unless.MatchString(cmd) {
+				continue
+			}
+		}
+		rewritten := match.ReplaceAllString(cmd, rule.Replace)
+		if rewritten == cmd {
+			continue
+		}
+		cmd = rewritten
+		if rule.Note != "" {
+			applied = append(applied, rule.Note)
+		} else {
+			applied = append(applied, fmt.Sprintf("`%s` → `%s`", rule.Match, rule.Replace))
+		}
+	}
+	return marker + cmd, applied, errors.Join(errs...)
+}
+
+// rewrite applies the configured rewrite rules, telling the user which
+// changed the command, on out, before it is confirmed.
+func (a *App) rewrite(cmd string, out io.Writer) string {
+	if len(a.Config.Rewrite) == 0 {
+		return cmd
+	}
+	cmd, applied, err := Rewrite(cmd, a.Config.Rewrite)
+	if err != nil {
+		fmt.Fprintf(a.Err, "nlch: warning: %v\n", err)
+	}
+	for _, note := range applied {
+		fmt.Fprintf(out, "> Rewrote the command: %s\n", note)
+	}
+	return cmd
+}
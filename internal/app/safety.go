@@ -0,0 +1,96 @@
+// Package app decides whether and how generated commands may run.
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+)
+
+// CheckReadOnly returns an error unless the command is known to only read,
+// for read-only mode. It fails closed: anything shell.ReadOnly does not
+// recognise is refused. Like constraints, it cannot be bypassed with --yes-im-sure.
+func CheckReadOnly(cmd string) error {
+	if ok, reason := shell.ReadOnly(cmd); !ok {
+		return fmt.Errorf("read-only mode: refusing to run a command that is not known to only read (%s)", reason)
+	}
+	return nil
+}
+
+// CheckConstraints returns an error if the command violates any of the configured `never:` rules.
+// Constraints are hard limits and cannot be bypassed with --yes-im-sure.
+func CheckConstraints(cmd string, never []string) error {
+	if violated := shell.ViolatedConstraints(cmd, never); len(violated) > 0 {
+		return fmt.Errorf("this command violates a configured constraint (%s), refusing to run it", strings.Join(violated, "; "))
+	}
+	return nil
+}
+
+// AssessRisk rates a generated command with the local rules, raising it to
+// at least high when the LLM marked it as dangerous.
+func AssessRisk(cmd string) (shell.Risk, string) {
+	risk, reason := shell.Classify(strings.TrimPrefix(cmd, prompt.DangerPrefix))
+	if strings.HasPrefix(cmd, prompt.DangerPrefix) && risk < shell.RiskHigh {
+		risk, reason = shell.RiskHigh, "marked as dangerous by the LLM"
+	}
+	return risk, reason
+}
+
+// defaultConfirmations are used for risk levels the config leaves empty.
+var defaultConfirmations = map[shell.Risk]shell.Confirmation{
+	shell.RiskLow:      shell.ConfirmNone,
+	shell.RiskMedium:   shell.ConfirmYesNo,
+	shell.RiskHigh:     shell.ConfirmTyped,
+	shell.RiskCritical: shell.ConfirmBlock,
+}
+
+// ConfirmationFor returns what the `confirm:` config requires before a command
+// of the given risk runs. cfg may be nil, in which case the defaults apply.
+func ConfirmationFor(cfg *config.Config, risk shell.Risk) shell.Confirmation {
+	var configured string
+	if cfg != nil {
+		configured = map[shell.Risk]string{
+			shell.RiskLow:      cfg.Confirm.Low,
+			shell.RiskMedium:   cfg.Confirm.Medium,
+			shell.RiskHigh:     cfg.Confirm.High,
+			shell.RiskCritical: cfg.Confirm.Critical,
+		}[risk]
+	}
+	if configured == "" {
+		return defaultConfirmations[risk]
+	}
+	c := shell.Confirmation(configured)
+	if !slices.Contains(shell.Confirmations, c) {
+		fmt.Fprintf(os.Stderr, "nlch: warning: invalid confirm.%s %q in config, using %q\n", risk, configured, defaultConfirmations[risk])
+		return defaultConfirmations[risk]
+	}
+	return c
+}
+
+// ReplayConfirmation returns the confirmation for running a saved or past
+// command, which is never less than a Y/n question.
+func ReplayConfirmation(cfg *config.Config, cmd string) shell.Confirmation {
+	risk, _ := AssessRisk(cmd)
+	return ConfirmationFor(cfg, risk).AtLeast(shell.ConfirmYesNo)
+}
+
+// Decision returns the history decision for the result of Executor.Run.
+func Decision(dryRun bool, err error) string {
+	switch {
+	case dryRun:
+		return history.DecisionDryRun
+	case errors.Is(err, shell.ErrAborted):
+		return history.DecisionAborted
+	case errors.Is(err, shell.ErrBlocked):
+		return history.DecisionBlocked
+	default:
+		return history.DecisionExecuted
+	}
+}
@@ -0,0 +1,21 @@
+// Package app accounts for the tokens a request spends.
+package app
+
+import (
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
+)
+
+// Usage accumulates the estimated tokens spent generating a command.
+type Usage struct {
+	Input, Output int
+}
+
+// Add counts one provider request and its reply.
+func (u *Usage) Add(model string, opts provider.ProviderOptions, promptStr, reply string) {
+	u.Input += tokens.Estimate(model, opts.System+promptStr)
+	for _, m := range opts.History {
+		u.Input += tokens.Estimate(model, m.Content)
+	}
+	u.Output += tokens.Estimate(model, reply)
+}
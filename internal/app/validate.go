@@ -0,0 +1,45 @@
+// Package app asks the provider again when it replies to a request for a
+// command with something that is not one.
+package app
+
+import (
+	"fmt"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+)
+
+// DefaultValidationRetries is how many times a reply that is not a command
+// is asked for again when the config doesn't say.
+const DefaultValidationRetries = 2
+
+// ValidatingProvider checks the commands a provider generates and asks again,
+// with stricter instructions, when a reply is an apology, an explanation,
+// markdown or prose wrapped in echo. Raw requests, for scripts, explanations
+// and candidate lists, are passed through unchecked.
+type ValidatingProvider struct {
+	provider.Provider
+	Retries int
+}
+
+func (v *ValidatingProvider) GenerateCommand(ctx context.Context, promptStr string, opts provider.ProviderOptions) (string, error) {
+	reply, err := v.Provider.GenerateCommand(ctx, promptStr, opts)
+	if err != nil || opts.Raw {
+		return reply, err
+	}
+	for attempt := 0; ; attempt++ {
+		problem := prompt.ValidateCommand(prompt.CleanCommand(reply))
+		if problem == nil {
+			return reply, nil
+		}
+		if attempt == v.Retries {
+			return "", fmt.Errorf("the LLM did not return a command, its reply %v: %s", problem, reply)
+		}
+		provider.RetryNotice(fmt.Sprintf("the reply %v, asking again (%d/%d)...", problem, attempt+1, v.Retries))
+		reply, err = v.Provider.GenerateCommand(ctx, prompt.BuildRetryPrompt(promptStr, reply, problem), opts)
+		if err != nil {
+			return "", err
+		}
+	}
+}
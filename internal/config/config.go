@@ -6,22 +6,135 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/kanishka-sahoo/nlch/internal/ui"
 	"gopkg.in/yaml.v3"
 )
 
 // ProviderConfig holds configuration for a single provider.
 type ProviderConfig struct {
-	Key          string `yaml:"key,omitempty"`
-	DefaultModel string `yaml:"default_model,omitempty"`
-	URL          string `yaml:"url,omitempty"`
+	Key          string   `yaml:"key,omitempty"`
+	DefaultModel string   `yaml:"default_model,omitempty"`
+	URL          string   `yaml:"url,omitempty"`
+	Endpoint     string   `yaml:"endpoint,omitempty"`  // Base URL of a compatible gateway, such as LiteLLM, to send requests to instead of the provider's API
+	Region       string   `yaml:"region,omitempty"`    // AWS region of the bedrock provider, or Google Cloud region of the vertex provider
+	Project      string   `yaml:"project,omitempty"`   // Google Cloud project of the vertex provider (default: GOOGLE_CLOUD_PROJECT or the credentials' project)
+	Profile      string   `yaml:"profile,omitempty"`   // AWS profile the bedrock provider takes credentials from (default: the standard credential chain)
+	Responses    []string `yaml:"responses,omitempty"` // Canned replies of the mock provider
 }
 
 // Config holds the overall nlch configuration.
 type Config struct {
 	DefaultProvider string                    `yaml:"default_provider"`
 	Providers       map[string]ProviderConfig `yaml:"providers"`
+	Packs           []string                  `yaml:"packs,omitempty"`          // Prompt packs to always include
+	DisabledPacks   []string                  `yaml:"disabled_packs,omitempty"` // Prompt packs to never include
+	Never           []string                  `yaml:"never,omitempty"`          // Hard constraints for generated commands
+	Theme           string                    `yaml:"theme,omitempty"`          // Output color theme: default, dark, light or none
+	Accessible      bool                      `yaml:"accessible,omitempty"`     // Screen-reader-friendly output without color or emoji
+	AskFeedback     bool                      `yaml:"ask_feedback,omitempty"`   // Ask for a rating after each executed command
+	History         HistoryConfig             `yaml:"history,omitempty"`        // What the history records and for how long
+	Update          UpdateConfig              `yaml:"update,omitempty"`         // Where and how nlch updates itself
+	Network         NetworkConfig             `yaml:"network,omitempty"`        // HTTP settings shared by providers and the updater
+	Ensemble        EnsembleConfig            `yaml:"ensemble,omitempty"`       // A second model that double-checks commands
+	MaxCost         float64                   `yaml:"max_cost,omitempty"`       // Ask before requests estimated to cost more than this many US dollars
+	Confirm         ConfirmConfig             `yaml:"confirm,omitempty"`        // How commands are confirmed, by risk level
+	ReadOnly        bool                      `yaml:"read_only,omitempty"`      // Only generate and run commands that change nothing
+	IgnoreProject   bool                      `yaml:"ignore_project,omitempty"` // Don't add projects' .nlch/instructions.md to the prompt
+	Models          map[string]ModelConfig    `yaml:"models,omitempty"`         // Capabilities of models, by name prefix, overriding the built-in ones
+	Interactive     string                    `yaml:"interactive,omitempty"`    // Commands that ask for input: "flags" (default) adds answers such as -y, "terminal" leaves them as they are
+	Lint            LintConfig                `yaml:"lint,omitempty"`           // Checking generated scripts with shellcheck
+	Validation      ValidationConfig          `yaml:"validation,omitempty"`     // Asking again when a reply is not a command
+	LocalCheck      LocalCheckConfig          `yaml:"local_check,omitempty"`    // Second opinion on dangerous commands from a local model
+	HidePipelines   bool                      `yaml:"hide_pipelines,omitempty"` // Don't describe each stage of piped commands before confirming them
+	Rewrite         []RewriteRule             `yaml:"rewrite,omitempty"`        // Regular expression rules that change generated commands before they are confirmed
+	WatchLimit      string                    `yaml:"watch_limit,omitempty"`    // How long commands that run until stopped, such as tail -f, may run, e.g. 30m (default 10m, 0 for no limit)
+	Shell           string                    `yaml:"shell,omitempty"`          // Shell to write and run commands for: bash, sh, zsh, fish or nu (default: $SHELL when it is fish or nu, otherwise bash)
+}
+
+// ModelConfig declares what the models whose names start with a prefix can
+// do, e.g. a local model with a larger context window than nlch assumes.
+// Unset fields keep the built-in value.
+type ModelConfig struct {
+	ContextWindow   int   `yaml:"context_window,omitempty"`   // Tokens per request, prompt and reply together
+	JSONMode        *bool `yaml:"json_mode,omitempty"`        // Replies can be constrained to JSON
+	Vision          *bool `yaml:"vision,omitempty"`           // Images can be attached
+	FunctionCalling *bool `yaml:"function_calling,omitempty"` // The model can call tools
+}
+
+// LintConfig controls how generated scripts and multi-line commands are
+// checked with shellcheck, or its built-in subset when it is not installed.
+type LintConfig struct {
+	Disabled bool `yaml:"disabled,omitempty"` // Don't check anything
+	Fix      bool `yaml:"fix,omitempty"`      // Ask the model to fix what is found in generated scripts
+}
+
+// ValidationConfig controls how replies that are not commands, such as
+// apologies or explanations, are handled.
+type ValidationConfig struct {
+	Disabled bool `yaml:"disabled,omitempty"` // Accept any reply
+	Retries  int  `yaml:"retries,omitempty"`  // Times to ask again, default 2
+}
+
+// LocalCheckConfig sets up a second, local-only check of whether generated
+// commands are destructive, by a model in Ollama. It applies when the provider
+// is not Ollama itself and Ollama is running.
+type LocalCheckConfig struct {
+	Enabled bool   `yaml:"enabled,omitempty"`
+	Model   string `yaml:"model,omitempty"` // default: the ollama provider's default model, or llama3.2
+	URL     string `yaml:"url,omitempty"`   // default: the ollama provider's URL, or http://localhost:11434
+}
+
+// RewriteRule changes generated commands: every match of Match is replaced
+// with Replace, which may refer to groups as $1. Rules apply in order.
+type RewriteRule struct {
+	Match   string `yaml:"match"`
+	Replace string `yaml:"replace"`
+	Unless  string `yaml:"unless,omitempty"` // Leave commands that match this regular expression alone
+	Note    string `yaml:"note,omitempty"`   // Shown when the rule changes a command, instead of the rule itself
+}
+
+// ConfirmConfig says what happens before a command of each risk level runs:
+// "run" runs it immediately, "confirm" asks Y/n, "type" requires typing yes,
+// and "block" refuses to run it. Empty levels use the defaults.
+type ConfirmConfig struct {
+	Low      string `yaml:"low,omitempty"`      // default: run
+	Medium   string `yaml:"medium,omitempty"`   // default: confirm
+	High     string `yaml:"high,omitempty"`     // default: type
+	Critical string `yaml:"critical,omitempty"` // default: block
+}
+
+// EnsembleConfig sets up a second model that is asked the same request, so
+// that disagreement between the two can be shown before anything runs.
+type EnsembleConfig struct {
+	With   string `yaml:"with,omitempty"`   // The second model as provider[:model]
+	Always bool   `yaml:"always,omitempty"` // Ask it for every request, not only for dangerous commands
+}
+
+// NetworkConfig holds the settings of the shared HTTP client.
+type NetworkConfig struct {
+	Timeout      string `yaml:"timeout,omitempty"`       // How long to wait for a response to start, e.g. 60s (default 120s)
+	DisableHTTP2 bool   `yaml:"disable_http2,omitempty"` // Use HTTP/1.1 only, for proxies that mishandle HTTP/2
+	Offline      bool   `yaml:"offline,omitempty"`       // Air-gapped: only local providers, no update checks, no connections to other machines
+}
+
+// UpdateConfig holds the settings of the self-updater.
+type UpdateConfig struct {
+	Channel string            `yaml:"channel,omitempty"` // Release channel: stable (default), beta or nightly
+	URL     string            `yaml:"url,omitempty"`     // Releases API endpoint or JSON manifest to update from instead of GitHub
+	Headers map[string]string `yaml:"headers,omitempty"` // Extra headers, e.g. for auth, sent to the update URL's host; $VARS are expanded
+	Auto    bool              `yaml:"auto,omitempty"`    // Download updates in the background and install them on the next start
+}
+
+// HistoryConfig holds the privacy settings of the command history.
+type HistoryConfig struct {
+	Disabled    bool     `yaml:"disabled,omitempty"`     // Record nothing at all
+	MaxDays     int      `yaml:"max_days,omitempty"`     // Delete entries older than this many days (0 keeps them forever)
+	MaxEntries  int      `yaml:"max_entries,omitempty"`  // Keep at most this many entries (0 for no limit)
+	HashOutputs bool     `yaml:"hash_outputs,omitempty"` // Store a SHA-256 hash of command output instead of the output itself
+	ExcludeDirs []string `yaml:"exclude_dirs,omitempty"` // Never record requests made in these directories or below
 }
 
 // GetProviders returns the providers configuration
@@ -98,63 +211,161 @@ func LoadOrCreate() (*Config, error) {
 
 // GetUserConfigPath returns the user's configuration file path
 func GetUserConfigPath() (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// GetConfigDir returns the directory holding the configuration and other nlch data files
+func GetConfigDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".config", "nlch", "config.yaml"), nil
+	return filepath.Join(home, ".config", "nlch"), nil
+}
+
+// AvailableProviders lists the providers offered by the setup wizard, in menu order
+var AvailableProviders = []ProviderInfo{
+	{"openrouter", "OpenRouter (supports many models)", "https://openrouter.ai", "sk-or-v1-"},
+	{"anthropic", "Anthropic Claude", "https://console.anthropic.com", "sk-ant-"},
+	{"openai", "OpenAI GPT", "https://platform.openai.com", "sk-"},
+	{"gemini", "Google Gemini", "https://aistudio.google.com", ""},
+	{"groq", "Groq (fast inference)", "https://console.groq.com", "gsk_"},
+	{"cohere", "Cohere Command", "https://dashboard.cohere.com", ""},
+	{"bedrock", "Amazon Bedrock (AWS credentials)", "https://aws.amazon.com/bedrock", ""},
+	{"vertex", "Google Vertex AI (Application Default Credentials)", "https://cloud.google.com/vertex-ai", ""},
+	{"ollama", "Ollama (local)", "https://ollama.ai", ""},
+}
+
+// DefaultModels holds the model used for each provider when none is chosen during setup
+var DefaultModels = map[string]string{
+	"openrouter": "openai/gpt-4o-mini",
+	"anthropic":  "claude-3-5-sonnet-20241022",
+	"openai":     "gpt-4o-mini",
+	"gemini":     "gemini-1.5-flash",
+	"groq":       "llama-3.1-8b-instant",
+	"cohere":     "command-r-08-2024",
+	"bedrock":    "anthropic.claude-3-5-haiku-20241022-v1:0",
+	"vertex":     "gemini-1.5-flash",
+	"ollama":     "llama3.2",
 }
 
 // CreateInitialConfig prompts the user for provider information and creates a config file
 func CreateInitialConfig() (*Config, error) {
-	fmt.Println("\n🎉 Welcome to nlch! Let's set up your configuration.")
+	fmt.Printf("\n%sWelcome to nlch! Let's set up your configuration.\n", ui.Icon("🎉 ", ""))
 	fmt.Println("nlch supports multiple AI providers. Let's configure one to get started.")
 	fmt.Println()
 
-	// Available providers
-	providers := map[string]ProviderInfo{
-		"1": {"openrouter", "OpenRouter (supports many models)", "https://openrouter.ai", "sk-or-v1-"},
-		"2": {"anthropic", "Anthropic Claude", "https://console.anthropic.com", "sk-ant-"},
-		"3": {"openai", "OpenAI GPT", "https://platform.openai.com", "sk-"},
-		"4": {"gemini", "Google Gemini", "https://aistudio.google.com", ""},
-		"5": {"ollama", "Ollama (local)", "https://ollama.ai", ""},
+	reader := bufio.NewReader(os.Stdin)
+	selectedProvider, providerConfig := promptProvider(reader, nil)
+
+	// Create config
+	config := &Config{
+		DefaultProvider: selectedProvider.Key,
+		Providers: map[string]ProviderConfig{
+			selectedProvider.Key: providerConfig,
+		},
+	}
+
+	// Save config
+	err := SaveConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save configuration: %v", err)
+	}
+
+	fmt.Printf("\n%sConfiguration saved successfully!\n", ui.Icon("✅ ", ""))
+	fmt.Printf("Provider: %s\n", selectedProvider.Name)
+	fmt.Printf("Default model: %s\n", providerConfig.DefaultModel)
+	fmt.Println("\nYou can now use nlch. Try: nlch \"list files in this directory\"")
+
+	return config, nil
+}
+
+// AddProviders runs the setup wizard against an existing config, letting the user
+// add or reconfigure any number of providers and choose the default. The config is saved afterwards.
+func AddProviders(config *Config) error {
+	reader := bufio.NewReader(os.Stdin)
+	if config.Providers == nil {
+		config.Providers = map[string]ProviderConfig{}
+	}
+
+	for {
+		selectedProvider, providerConfig := promptProvider(reader, config.Providers)
+		config.Providers[selectedProvider.Key] = providerConfig
+
+		if config.DefaultProvider == "" {
+			config.DefaultProvider = selectedProvider.Key
+		} else if config.DefaultProvider != selectedProvider.Key &&
+			askYesNo(reader, fmt.Sprintf("Make %s the default provider?", selectedProvider.Name)) {
+			config.DefaultProvider = selectedProvider.Key
+		}
+
+		if !askYesNo(reader, "Configure another provider?") {
+			break
+		}
+		fmt.Println()
+	}
+
+	if err := SaveConfig(config); err != nil {
+		return fmt.Errorf("failed to save configuration: %v", err)
 	}
+	fmt.Printf("\n%sConfiguration saved successfully!\n", ui.Icon("✅ ", ""))
+	fmt.Printf("Default provider: %s\n", config.DefaultProvider)
+	return nil
+}
 
+// promptProvider asks the user to pick a provider and enter its settings.
+// Providers already present in existing can be reconfigured, keeping their current values by default.
+func promptProvider(reader *bufio.Reader, existing map[string]ProviderConfig) (ProviderInfo, ProviderConfig) {
 	// Display options
 	fmt.Println("Available providers:")
-	for key, provider := range providers {
-		fmt.Printf("  %s. %s\n", key, provider.Name)
+	for i, provider := range AvailableProviders {
+		suffix := ""
+		if _, ok := existing[provider.Key]; ok {
+			suffix = " (configured)"
+		}
+		fmt.Printf("  %d. %s%s\n", i+1, provider.Name, suffix)
 	}
 
 	// Get user choice
-	reader := bufio.NewReader(os.Stdin)
 	var selectedProvider ProviderInfo
 	for {
-		fmt.Print("\nSelect a provider (1-5): ")
+		fmt.Printf("\nSelect a provider (1-%d): ", len(AvailableProviders))
 		choice, _ := reader.ReadString('\n')
 		choice = strings.TrimSpace(choice)
 
-		if provider, ok := providers[choice]; ok {
-			selectedProvider = provider
+		if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(AvailableProviders) {
+			selectedProvider = AvailableProviders[n-1]
 			break
 		}
-		fmt.Println("Invalid choice. Please select 1-5.")
+		fmt.Printf("Invalid choice. Please select 1-%d.\n", len(AvailableProviders))
 	}
 
 	fmt.Printf("\nYou selected: %s\n", selectedProvider.Name)
+	current := existing[selectedProvider.Key]
 
-	// Get API key
+	// Get API key; Bedrock and Vertex AI use the cloud's own credentials instead
 	var apiKey string
-	if selectedProvider.Key != "ollama" {
+	if selectedProvider.Key != "ollama" && selectedProvider.Key != "bedrock" && selectedProvider.Key != "vertex" {
 		fmt.Printf("You'll need an API key from: %s\n", selectedProvider.Website)
 		if selectedProvider.KeyPrefix != "" {
 			fmt.Printf("API keys typically start with: %s\n", selectedProvider.KeyPrefix)
 		}
 
 		for {
-			fmt.Print("Enter your API key: ")
+			if current.Key != "" {
+				fmt.Print("Enter your API key (press Enter to keep the current key): ")
+			} else {
+				fmt.Print("Enter your API key: ")
+			}
 			apiKey, _ = reader.ReadString('\n')
 			apiKey = strings.TrimSpace(apiKey)
+			if apiKey == "" {
+				apiKey = current.Key
+			}
 
 			if apiKey != "" {
 				break
@@ -166,59 +377,93 @@ func CreateInitialConfig() (*Config, error) {
 	// Get URL for Ollama
 	var url string
 	if selectedProvider.Key == "ollama" {
-		fmt.Print("Enter Ollama URL (press Enter for http://localhost:11434): ")
+		defaultURL := "http://localhost:11434"
+		if current.URL != "" {
+			defaultURL = current.URL
+		}
+		fmt.Printf("Enter Ollama URL (press Enter for %s): ", defaultURL)
 		url, _ = reader.ReadString('\n')
 		url = strings.TrimSpace(url)
 		if url == "" {
-			url = "http://localhost:11434"
+			url = defaultURL
 		}
 	}
 
-	// Get default model
-	fmt.Print("Enter default model (press Enter for provider default): ")
-	defaultModel, _ := reader.ReadString('\n')
-	defaultModel = strings.TrimSpace(defaultModel)
-
-	// Set provider-specific default models
-	if defaultModel == "" {
-		switch selectedProvider.Key {
-		case "openrouter":
-			defaultModel = "openai/gpt-4o-mini"
-		case "anthropic":
-			defaultModel = "claude-3-5-sonnet-20241022"
-		case "openai":
-			defaultModel = "gpt-4o-mini"
-		case "gemini":
-			defaultModel = "gemini-1.5-flash"
-		case "ollama":
-			defaultModel = "llama3.2"
+	// Get the AWS region for Bedrock; empty leaves it to AWS_REGION or the AWS config
+	region := current.Region
+	if selectedProvider.Key == "bedrock" {
+		fmt.Println("Requests are signed with your AWS credentials: AWS_ACCESS_KEY_ID, a profile in ~/.aws or the instance's role.")
+		if region != "" {
+			fmt.Printf("Enter AWS region (press Enter for %s): ", region)
+		} else {
+			fmt.Print("Enter AWS region, e.g. us-east-1 (press Enter to use AWS_REGION or ~/.aws/config): ")
+		}
+		if r, _ := reader.ReadString('\n'); strings.TrimSpace(r) != "" {
+			region = strings.TrimSpace(r)
 		}
 	}
 
-	// Create config
-	config := &Config{
-		DefaultProvider: selectedProvider.Key,
-		Providers: map[string]ProviderConfig{
-			selectedProvider.Key: {
-				Key:          apiKey,
-				DefaultModel: defaultModel,
-				URL:          url,
-			},
-		},
+	// Get the Google Cloud project and region for Vertex AI
+	project := current.Project
+	if selectedProvider.Key == "vertex" {
+		fmt.Println("Requests use your Application Default Credentials: gcloud auth application-default login, or GOOGLE_APPLICATION_CREDENTIALS.")
+		if project != "" {
+			fmt.Printf("Enter Google Cloud project (press Enter for %s): ", project)
+		} else {
+			fmt.Print("Enter Google Cloud project (press Enter to use GOOGLE_CLOUD_PROJECT or the credentials' project): ")
+		}
+		if p, _ := reader.ReadString('\n'); strings.TrimSpace(p) != "" {
+			project = strings.TrimSpace(p)
+		}
+		defaultRegion := region
+		if defaultRegion == "" {
+			defaultRegion = "us-central1"
+		}
+		fmt.Printf("Enter region (press Enter for %s): ", defaultRegion)
+		region = defaultRegion
+		if r, _ := reader.ReadString('\n'); strings.TrimSpace(r) != "" {
+			region = strings.TrimSpace(r)
+		}
 	}
 
-	// Save config
-	err := SaveConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to save configuration: %v", err)
+	// Get default model
+	defaultModel := current.DefaultModel
+	if defaultModel == "" {
+		defaultModel = DefaultModels[selectedProvider.Key]
+	}
+	fmt.Printf("Enter default model (press Enter for %s): ", defaultModel)
+	model, _ := reader.ReadString('\n')
+	if model = strings.TrimSpace(model); model != "" {
+		defaultModel = model
 	}
 
-	fmt.Printf("\n✅ Configuration saved successfully!\n")
-	fmt.Printf("Provider: %s\n", selectedProvider.Name)
-	fmt.Printf("Default model: %s\n", defaultModel)
-	fmt.Println("\nYou can now use nlch. Try: nlch \"list files in this directory\"")
+	current.Key = apiKey
+	current.URL = url
+	current.Region = region
+	current.Project = project
+	current.DefaultModel = defaultModel
+	return selectedProvider, current
+}
 
-	return config, nil
+// askYesNo asks a yes/no question, defaulting to no
+func askYesNo(reader *bufio.Reader, question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// LoadFile reads and parses a single config file
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &cfg, nil
 }
 
 // ProviderInfo holds information about available providers
@@ -251,3 +496,92 @@ func SaveConfig(config *Config) error {
 	// Write to file
 	return os.WriteFile(configPath, data, 0644)
 }
+
+// SetDefault makes the provider, with the model when one is given, the default
+// in the user's config file. The file is edited in place, so its comments and
+// layout survive.
+func SetDefault(provider, model string) error {
+	return editConfig(func(root *yaml.Node) {
+		setString(mappingValue(root, "default_provider", yaml.ScalarNode), provider)
+		if model != "" {
+			setModel(root, provider, model)
+		}
+	})
+}
+
+// SetModel makes the model the provider's default in the user's config file,
+// leaving the default provider as it is.
+func SetModel(provider, model string) error {
+	return editConfig(func(root *yaml.Node) {
+		setModel(root, provider, model)
+	})
+}
+
+// setModel sets the default model of a provider in the config's root mapping.
+func setModel(root *yaml.Node, provider, model string) {
+	providers := mappingValue(root, "providers", yaml.MappingNode)
+	setString(mappingValue(mappingValue(providers, provider, yaml.MappingNode), "default_model", yaml.ScalarNode), model)
+}
+
+// editConfig edits the user's config file in place with edit, which is given
+// the root mapping of the file, keeping comments and indentation.
+func editConfig(edit func(root *yaml.Node)) error {
+	path, err := GetUserConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: not a mapping", path)
+	}
+	edit(doc.Content[0])
+
+	var out strings.Builder
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(yamlIndent(string(data)))
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(out.String()), 0644)
+}
+
+// mappingValue returns the value of key in a mapping node, adding the key
+// with an empty value of the given kind when it is missing.
+func mappingValue(mapping *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			// An empty "providers:" is a null scalar
+			if value.Kind != kind {
+				*value = yaml.Node{Kind: kind}
+			}
+			return value
+		}
+	}
+	value := &yaml.Node{Kind: kind}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// setString sets a scalar node to a string, quoted if it would read as another type.
+func setString(node *yaml.Node, value string) {
+	node.Tag, node.Value = "!!str", value
+}
+
+// yamlIndent returns the indentation the file uses, the first indented line's.
+func yamlIndent(text string) int {
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if n := len(line) - len(trimmed); n > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "- ") {
+			return n
+		}
+	}
+	return 4
+}
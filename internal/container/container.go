@@ -0,0 +1,87 @@
+// Package container gathers context from, and runs commands in, running
+// Docker (or Podman) containers.
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// Engine returns the container CLI to use: docker, or podman where docker isn't installed.
+func Engine() string {
+	if _, err := exec.LookPath("docker"); err != nil {
+		if _, err := exec.LookPath("podman"); err == nil {
+			return "podman"
+		}
+	}
+	return "docker"
+}
+
+// separator divides the sections of the gather script's output.
+const separator = "--- nlch ---"
+
+// gatherScript prints, in sections, the working directory, its files, the OS
+// release and the git branch and status. It only needs a POSIX sh.
+var gatherScript = strings.Join([]string{
+	"pwd",
+	"ls -A",
+	"cat /etc/os-release 2>/dev/null",
+	"git rev-parse --abbrev-ref HEAD 2>/dev/null",
+	"git status --short 2>/dev/null",
+}, "; echo '"+separator+"'; ")
+
+// Gather collects the context of a running container: its working directory
+// and files, git information and operating system.
+func Gather(name string) (*context.Context, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(Engine(), "exec", name, "sh", "-c", gatherScript)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil && stdout.Len() == 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("failed to inspect container %s: %s", name, msg)
+	}
+
+	sections := strings.Split(stdout.String(), separator+"\n")
+	for len(sections) < 5 {
+		sections = append(sections, "")
+	}
+	ctx := &context.Context{
+		WorkingDir: strings.TrimSpace(sections[0]),
+		Files:      strings.Fields(sections[1]),
+		GitInfo:    map[string]string{},
+		Extra:      map[string]any{"container": name},
+	}
+	if osName := osRelease(sections[2]); osName != "" {
+		ctx.Extra["container OS"] = osName
+	}
+	if branch := strings.TrimSpace(sections[3]); branch != "" {
+		ctx.GitInfo["branch"] = branch
+	}
+	if status := strings.TrimSpace(sections[4]); status != "" {
+		ctx.GitInfo["status"], _ = context.SummarizeStatus(status)
+	}
+	return ctx, nil
+}
+
+// osRelease returns the PRETTY_NAME from the contents of /etc/os-release.
+func osRelease(contents string) string {
+	for _, line := range strings.Split(contents, "\n") {
+		if value, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// Command returns a command that runs the shell command cmd inside the container.
+func Command(name, cmd string) *exec.Cmd {
+	return exec.Command(Engine(), "exec", "-i", name, "sh", "-c", cmd)
+}
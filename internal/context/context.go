@@ -2,29 +2,90 @@
 package context
 
 import (
+	"encoding/binary"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// LargeRepoFiles is the number of tracked files above which git status is
+// left out unless asked for, since it can take seconds in such a repository.
+const LargeRepoFiles = 100000
+
 // Context holds information about the current environment for command generation.
 type Context struct {
 	WorkingDir string            // Current working directory
 	GitInfo    map[string]string // Git-related info (branch, status, etc.)
-	Files      []string          // List of files in the directory
+	Files      []string          // List of files in the directory, a sample of MaxFiles in larger ones
+	FileCount  int               // Entries in the directory, counted up to MaxCounted
 	Extra      map[string]any    // Additional context from plugins
+	Locale     string            // Locale for date and time formats, e.g. de_DE.UTF-8
+	Timezone   string            // Local timezone, e.g. Europe/Berlin (CEST, UTC+02:00)
 }
 
 // GatherGitInfo populates GitInfo with branch and status if in a git repo.
-func (c *Context) GatherGitInfo() {
+// Git runs in WorkingDir, or the process's directory if it is empty. Unless
+// full is set, status listing more than StatusPaths paths is summarized, with
+// GitInfo["status_paths"] holding their number, and in a repository tracking
+// more than LargeRepoFiles files it is left out, with
+// GitInfo["status_skipped"] holding the file count.
+func (c *Context) GatherGitInfo(full bool) {
 	c.GitInfo = map[string]string{}
+	// Get the index path, which also tells whether this is a repository
+	index, err := c.git("rev-parse", "--git-path", "index")
+	if err != nil {
+		return
+	}
 	// Get branch
-	branch, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	branch, err := c.git("rev-parse", "--abbrev-ref", "HEAD")
 	if err == nil {
 		c.GitInfo["branch"] = strings.TrimSpace(string(branch))
 	}
-	// Get status (short)
-	status, err := exec.Command("git", "status", "--short").Output()
-	if err == nil {
-		c.GitInfo["status"] = strings.TrimSpace(string(status))
+	if !full {
+		if tracked := c.trackedFiles(strings.TrimSpace(string(index))); tracked > LargeRepoFiles {
+			c.GitInfo["status_skipped"] = strconv.Itoa(tracked)
+			return
+		}
+	}
+	// Get status (short), summarized when long unless full is set
+	status, err := c.git("status", "--short")
+	if err != nil {
+		return
+	}
+	c.GitInfo["status"] = strings.TrimSpace(string(status))
+	if !full {
+		if summary, paths := SummarizeStatus(c.GitInfo["status"]); paths > StatusPaths {
+			c.GitInfo["status"] = summary
+			c.GitInfo["status_paths"] = strconv.Itoa(paths)
+		}
+	}
+}
+
+// trackedFiles returns the number of entries in the git index at path,
+// relative to WorkingDir, from its header, or 0 if it can't be read.
+func (c *Context) trackedFiles(path string) int {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.WorkingDir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
 	}
+	defer f.Close()
+	// "DIRC", the version and the number of entries, each four bytes
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:4]) != "DIRC" {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(header[8:]))
+}
+
+// git runs a git command in the working directory and returns its output.
+func (c *Context) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = c.WorkingDir
+	return cmd.Output()
 }
@@ -0,0 +1,48 @@
+// Package context lists the working directory, sampling directories too
+// large to list in full.
+package context
+
+import (
+	"os"
+	"sort"
+)
+
+// MaxFiles is how many entries of a directory are listed. Larger directories
+// are sampled: this many names are kept and the rest only counted.
+const MaxFiles = 1000
+
+// MaxCounted bounds the counting, so a directory of millions of entries takes
+// no longer than one of this many. FileCount is then a lower bound.
+const MaxCounted = 100000
+
+// GatherFiles populates Files with the names of the entries in WorkingDir,
+// sorted, and FileCount with how many there are.
+func (c *Context) GatherFiles() {
+	c.Files, c.FileCount = listFiles(c.WorkingDir)
+}
+
+// listFiles returns up to MaxFiles names of entries in dir, sorted, and the
+// number of entries, counted up to MaxCounted. Names are read in batches in
+// the directory's own order, which sampling keeps cheap.
+func listFiles(dir string) ([]string, int) {
+	files := []string{}
+	f, err := os.Open(dir)
+	if err != nil {
+		return files, 0
+	}
+	defer f.Close()
+	count := 0
+	for count < MaxCounted {
+		names, err := f.Readdirnames(1024)
+		count += len(names)
+		if room := MaxFiles - len(files); room > 0 {
+			files = append(files, names[:min(room, len(names))]...)
+		}
+		if err != nil {
+			// io.EOF at the end of the directory
+			break
+		}
+	}
+	sort.Strings(files)
+	return files, count
+}
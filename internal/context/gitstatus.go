@@ -0,0 +1,75 @@
+// Package context summarizes long git status output, so a dirty monorepo
+// doesn't fill the prompt with thousands of paths.
+package context
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StatusPaths is how many paths of git status are listed in full. Longer
+// status output is summarized: counts by change type and the first this many.
+const StatusPaths = 30
+
+// statusTypes are the change types of a summary, in the order they are listed.
+var statusTypes = []string{"modified", "added", "deleted", "renamed", "copied", "type changed", "conflicted", "untracked"}
+
+// SummarizeStatus summarizes the output of git status --short when it lists
+// more than StatusPaths paths, returning the summary and the number of paths.
+// Shorter output is returned as is.
+func SummarizeStatus(status string) (string, int) {
+	lines := strings.Split(strings.TrimSpace(status), "\n")
+	if len(lines) <= StatusPaths {
+		return status, len(lines)
+	}
+	counts := map[string]int{}
+	staged := 0
+	for _, line := range lines {
+		counts[changeType(line)]++
+		if len(line) > 0 && line[0] != ' ' && line[0] != '?' && line[0] != '!' {
+			staged++
+		}
+	}
+	var parts []string
+	for _, t := range statusTypes {
+		if counts[t] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[t], t))
+		}
+	}
+	summary := fmt.Sprintf("%d changed paths: %s", len(lines), strings.Join(parts, ", "))
+	if staged > 0 {
+		summary += fmt.Sprintf("; %d staged", staged)
+	}
+	summary += fmt.Sprintf("\nThe first %d:\n%s\n... (%d more)", StatusPaths, strings.Join(lines[:StatusPaths], "\n"), len(lines)-StatusPaths)
+	return summary, len(lines)
+}
+
+// changeType returns the change type of a line of git status --short, whose
+// first two characters are the states of the index and the work tree.
+func changeType(line string) string {
+	if len(line) < 2 {
+		return "modified"
+	}
+	x, y := line[0], line[1]
+	switch {
+	case x == '?':
+		return "untracked"
+	case x == 'U' || y == 'U' || x == 'A' && y == 'A' || x == 'D' && y == 'D':
+		return "conflicted"
+	}
+	for _, c := range []byte{x, y} {
+		switch c {
+		case 'R':
+			return "renamed"
+		case 'C':
+			return "copied"
+		case 'A':
+			return "added"
+		case 'D':
+			return "deleted"
+		case 'T':
+			return "type changed"
+		}
+	}
+	return "modified"
+}
@@ -0,0 +1,38 @@
+// Package context finds the instructions a project gives nlch.
+package context
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InstructionsFile is where a project keeps its instructions for nlch,
+// relative to the project's root or any directory in it.
+const InstructionsFile = ".nlch/instructions.md"
+
+// FindInstructions returns the path and contents of the instructions file
+// nearest to dir, looking in dir and its parents up to the root of the git
+// repository dir is in. The path is empty when there is no such file.
+func FindInstructions(dir string) (path, text string, err error) {
+	for {
+		candidate := filepath.Join(dir, InstructionsFile)
+		data, err := os.ReadFile(candidate)
+		if err == nil {
+			return candidate, strings.TrimSpace(string(data)), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", "", err
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,61 @@
+// Package context detects the locale and timezone commands are run in.
+package context
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GatherLocale populates Locale and Timezone from the environment and the
+// system clock, so date, sort and number formats can match the user's.
+func (c *Context) GatherLocale() {
+	c.Locale = locale()
+	c.Timezone = timezone(time.Now())
+}
+
+// locale returns the locale that governs date and time formats, in the order
+// of precedence POSIX gives the variables. "C" and "POSIX" are reported as is.
+func locale() string {
+	for _, name := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// timezone describes the local timezone by its IANA name, when it can be
+// found, and its current abbreviation and UTC offset, e.g.
+// "Europe/Berlin (CEST, UTC+02:00)".
+func timezone(now time.Time) string {
+	abbrev, offset := now.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	zone := fmt.Sprintf("%s, UTC%s%02d:%02d", abbrev, sign, offset/3600, offset%3600/60)
+	if name := zoneName(); name != "" {
+		return fmt.Sprintf("%s (%s)", name, zone)
+	}
+	return zone
+}
+
+// zoneName returns the IANA name of the local timezone from TZ, the
+// /etc/localtime symlink or /etc/timezone, or "" if none of them names it.
+func zoneName() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" && !filepath.IsAbs(tz) {
+		return tz
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	if data, err := os.ReadFile("/etc/timezone"); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
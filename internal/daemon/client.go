@@ -0,0 +1,126 @@
+// Package daemon provides the client side of the daemon protocol.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+)
+
+// dialTimeout keeps invocations fast when no daemon is listening.
+const dialTimeout = 100 * time.Millisecond
+
+// Client talks to a running daemon.
+type Client struct {
+	path string
+}
+
+// errUnavailable reports that the daemon could not be reached.
+var errUnavailable = errors.New("nlch daemon is not running")
+
+// Dial returns a client for the daemon listening at path, or an error if none is running.
+func Dial(path string) (*Client, error) {
+	if err := Ping(path); err != nil {
+		return nil, err
+	}
+	return &Client{path: path}, nil
+}
+
+// Ping checks whether a daemon is answering at path.
+func Ping(path string) error {
+	_, err := call(path, Request{Op: OpPing})
+	return err
+}
+
+// Stop asks the daemon at path to shut down.
+func Stop(path string) error {
+	_, err := call(path, Request{Op: OpStop})
+	return err
+}
+
+// GitInfo returns git info for dir from the daemon's cache.
+func (c *Client) GitInfo(dir string) (map[string]string, error) {
+	resp, err := call(c.path, Request{Op: OpGitInfo, Dir: dir})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GitInfo, nil
+}
+
+// call sends one request on a fresh connection and waits for the response.
+func call(path string, req Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, errUnavailable
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return &resp, &remoteError{id: resp.ID, msg: resp.Error, modelNotFound: resp.ModelNotFound}
+	}
+	return &resp, nil
+}
+
+// remoteError is an error reported by the daemon itself rather than by the
+// connection. The request ID finds it in the daemon's log.
+type remoteError struct {
+	id            string
+	msg           string
+	modelNotFound bool
+}
+
+func (e *remoteError) Error() string {
+	if e.id == "" {
+		return e.msg
+	}
+	return fmt.Sprintf("%s (daemon request %s)", e.msg, e.id)
+}
+
+// ModelNotFound reports whether the provider didn't know the requested model,
+// see provider.ModelNotFound.
+func (e *remoteError) ModelNotFound() bool { return e.modelNotFound }
+
+// Remote is a provider whose requests are served by the daemon. If the daemon
+// can't be reached, requests fall back to the local provider.
+type Remote struct {
+	client *Client
+	local  provider.Provider
+}
+
+// NewRemote wraps a local provider so its requests go through the daemon.
+func NewRemote(client *Client, local provider.Provider) *Remote {
+	return &Remote{client: client, local: local}
+}
+
+func (r *Remote) Name() string { return r.local.Name() }
+
+func (r *Remote) GenerateCommand(ctx context.Context, prompt string, opts provider.ProviderOptions) (string, error) {
+	resp, err := call(r.client.path, Request{
+		ID:       NewRequestID(),
+		Op:       OpGenerate,
+		Provider: r.local.Name(),
+		Prompt:   prompt,
+		Options:  opts,
+		Context:  &ctx,
+	})
+	var remote *remoteError
+	if errors.As(err, &remote) {
+		return "", err
+	}
+	if err != nil {
+		return r.local.GenerateCommand(ctx, prompt, opts)
+	}
+	return resp.Result, nil
+}
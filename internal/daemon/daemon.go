@@ -0,0 +1,257 @@
+// Package daemon implements a long-running nlch process that keeps providers,
+// HTTP connections and context caches warm, and the client the CLI uses to
+// talk to it over a unix socket.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+)
+
+// Operations understood by the daemon.
+const (
+	OpPing     = "ping"     // check that the daemon is running
+	OpGenerate = "generate" // generate a command with a provider
+	OpGitInfo  = "git"      // return (possibly cached) git info for a directory
+	OpStop     = "stop"     // shut the daemon down
+)
+
+// How long cached git info for a directory stays valid while the repository's
+// HEAD and index are unchanged. Edits to tracked files don't touch either, so
+// the cache must also expire on its own.
+const gitInfoTTL = 10 * time.Second
+
+// Request is a single call to the daemon.
+type Request struct {
+	ID       string                   `json:"id,omitempty"` // identifies the request in logs; assigned by the daemon if empty
+	Op       string                   `json:"op"`
+	Provider string                   `json:"provider,omitempty"`
+	Prompt   string                   `json:"prompt,omitempty"`
+	Options  provider.ProviderOptions `json:"options,omitempty"`
+	Context  *context.Context         `json:"context,omitempty"`
+	Dir      string                   `json:"dir,omitempty"`
+}
+
+// Response is the daemon's answer to a Request.
+type Response struct {
+	ID            string            `json:"id,omitempty"`             // the request's ID
+	CoalescedWith string            `json:"coalesced_with,omitempty"` // ID of an identical request whose result was reused
+	Result        string            `json:"result,omitempty"`
+	GitInfo       map[string]string `json:"git_info,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	ModelNotFound bool              `json:"model_not_found,omitempty"` // the error is the provider not knowing the model
+}
+
+// SocketPath returns the location of the daemon's unix socket.
+func SocketPath() (string, error) {
+	dir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon.sock"), nil
+}
+
+// Server answers requests on a unix socket.
+type Server struct {
+	listener net.Listener
+	Log      io.Writer // where requests are logged, if anywhere
+
+	mu          sync.Mutex
+	configMod   time.Time            // modification time of the loaded config
+	gitInfo     map[string]gitResult // cached git info by directory
+	flights     map[string]*flight   // recent generate requests by dedup key
+	stopOnce    sync.Once
+	stoppedChan chan struct{}
+}
+
+type gitResult struct {
+	info        map[string]string
+	fingerprint string
+	at          time.Time
+}
+
+// Listen creates the socket at path, replacing a stale one left by a daemon that
+// is no longer running.
+func Listen(path string) (*Server, error) {
+	if Ping(path) == nil {
+		return nil, errors.New("the nlch daemon is already running")
+	}
+	os.Remove(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Only the owner may talk to the daemon, since it holds API keys
+	os.Chmod(path, 0600)
+	return &Server{listener: listener, gitInfo: map[string]gitResult{}, flights: map[string]*flight{}, stoppedChan: make(chan struct{})}, nil
+}
+
+// Serve handles connections until Stop is called.
+func (s *Server) Serve() error {
+	if err := s.reloadConfig(); err != nil {
+		return err
+	}
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.stoppedChan:
+				return nil
+			default:
+				return err
+			}
+		}
+		go s.handle(conn)
+	}
+}
+
+// Stop closes the socket and makes Serve return.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stoppedChan)
+		s.listener.Close()
+	})
+}
+
+// handle answers the single request sent on a connection.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	if req.ID == "" {
+		req.ID = NewRequestID()
+	}
+	resp := s.dispatch(req)
+	resp.ID = req.ID
+	_ = json.NewEncoder(conn).Encode(resp)
+	if req.Op == OpStop {
+		s.Stop()
+	}
+}
+
+// dispatch performs a request.
+func (s *Server) dispatch(req Request) Response {
+	switch req.Op {
+	case OpPing, OpStop:
+		return Response{}
+	case OpGitInfo:
+		return Response{GitInfo: s.cachedGitInfo(req.Dir)}
+	case OpGenerate:
+		if err := s.reloadConfig(); err != nil {
+			return Response{Error: err.Error()}
+		}
+		prov, ok := provider.Get(req.Provider)
+		if !ok {
+			return Response{Error: provider.NotFound(req.Provider).Error()}
+		}
+		ctx := req.Context
+		if ctx == nil {
+			ctx = &context.Context{}
+		}
+		return s.coalesce(req, func() Response {
+			s.logf("request %s: generating with %s", req.ID, req.Provider)
+			start := time.Now()
+			result, err := prov.GenerateCommand(*ctx, req.Prompt, req.Options)
+			if err != nil {
+				s.logf("request %s: failed after %s: %v", req.ID, time.Since(start).Round(time.Millisecond), err)
+				return Response{Error: err.Error(), ModelNotFound: provider.ModelNotFound(err)}
+			}
+			s.logf("request %s: done in %s", req.ID, time.Since(start).Round(time.Millisecond))
+			return Response{Result: result}
+		})
+	}
+	return Response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+}
+
+// logf writes a line to the server's log, if it has one.
+func (s *Server) logf(format string, args ...any) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "%s nlch daemon: %s\n", time.Now().Format(time.TimeOnly), fmt.Sprintf(format, args...))
+	}
+}
+
+// reloadConfig registers the configured providers again when the config file has changed.
+func (s *Server) reloadConfig() error {
+	path, err := config.GetUserConfigPath()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info.ModTime().Equal(s.configMod) {
+		return nil
+	}
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+	provider.RegisterProvidersFromConfig(cfg.Providers)
+	if err := httpclient.Configure(cfg.Network); err != nil {
+		return err
+	}
+	s.configMod = info.ModTime()
+	return nil
+}
+
+// cachedGitInfo returns git info for dir, reusing a recent result while the
+// repository's HEAD and index are unchanged.
+func (s *Server) cachedGitInfo(dir string) map[string]string {
+	fingerprint := gitFingerprint(dir)
+
+	s.mu.Lock()
+	cached, ok := s.gitInfo[dir]
+	s.mu.Unlock()
+	if ok && cached.fingerprint == fingerprint && time.Since(cached.at) < gitInfoTTL {
+		return cached.info
+	}
+
+	ctx := &context.Context{WorkingDir: dir}
+	ctx.GatherGitInfo(false)
+
+	s.mu.Lock()
+	s.gitInfo[dir] = gitResult{info: ctx.GitInfo, fingerprint: fingerprint, at: time.Now()}
+	s.mu.Unlock()
+	return ctx.GitInfo
+}
+
+// gitFingerprint summarises the state of the repository containing dir by the
+// modification times of its HEAD and index.
+func gitFingerprint(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		gitDir := filepath.Join(d, ".git")
+		if _, err := os.Stat(gitDir); err == nil {
+			fp := ""
+			for _, name := range []string{"HEAD", "index"} {
+				if info, err := os.Stat(filepath.Join(gitDir, name)); err == nil {
+					fp += info.ModTime().String() + ";"
+				}
+			}
+			return fp
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Package daemon coalesces identical generate requests, such as those sent by
+// a keybinding that fires twice, into a single provider call.
+package daemon
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// How long the result of a finished request is reused for an identical one.
+// Long enough to catch a double-fired keybinding, short enough that asking
+// again on purpose gets a fresh command.
+const dedupWindow = 2 * time.Second
+
+// flight is a generate request that is in progress or finished recently.
+type flight struct {
+	id       string        // ID of the request that made the provider call
+	done     chan struct{} // closed when the call returns
+	resp     Response
+	finished time.Time
+}
+
+// NewRequestID returns a random ID for a request.
+func NewRequestID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// dedupKey identifies what a generate request asks for: the same provider,
+// prompt and options give the same key, whatever the request ID.
+func dedupKey(req Request) string {
+	data, _ := json.Marshal(struct {
+		Provider string
+		Prompt   string
+		Options  any
+	}{req.Provider, req.Prompt, req.Options})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// coalesce runs generate for req unless an identical request is in progress
+// or finished within the dedup window, in which case it waits for and
+// returns that request's response instead.
+func (s *Server) coalesce(req Request, generate func() Response) Response {
+	key := dedupKey(req)
+	now := time.Now()
+
+	s.mu.Lock()
+	for k, f := range s.flights {
+		if !f.finished.IsZero() && now.Sub(f.finished) > dedupWindow {
+			delete(s.flights, k)
+		}
+	}
+	if f, ok := s.flights[key]; ok {
+		s.mu.Unlock()
+		s.logf("request %s: same as request %s, reusing its result", req.ID, f.id)
+		<-f.done
+		resp := f.resp
+		resp.ID, resp.CoalescedWith = req.ID, f.id
+		return resp
+	}
+	f := &flight{id: req.ID, done: make(chan struct{})}
+	s.flights[key] = f
+	s.mu.Unlock()
+
+	f.resp = generate()
+	s.mu.Lock()
+	f.finished = time.Now()
+	s.mu.Unlock()
+	close(f.done)
+	// Errors are not reused: asking again may well succeed
+	if f.resp.Error != "" {
+		s.mu.Lock()
+		if s.flights[key] == f {
+			delete(s.flights, key)
+		}
+		s.mu.Unlock()
+	}
+	return f.resp
+}
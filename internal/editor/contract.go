@@ -0,0 +1,115 @@
+// Package editor defines the JSON contract editor integrations, such as VS
+// Code or Neovim plugins, use to ask nlch for a command: one request
+// envelope on stdin and one response on stdout, from nlch --json --print.
+//
+// The contract is versioned. Fields may be added to either side without a
+// new version, so clients must ignore fields they don't know; a change that
+// would break existing clients, such as removing or renaming a field or
+// changing its meaning, comes with a new Version.
+package editor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Version is the version of the contract this nlch speaks.
+const Version = 1
+
+// maxRequestBytes bounds the size of a request envelope.
+const maxRequestBytes = 1 << 20
+
+// Error codes of a Response.
+const (
+	CodeInvalidRequest     = "invalid_request"     // the envelope could not be read or lacks the request
+	CodeUnsupportedVersion = "unsupported_version" // the client speaks a newer version of the contract
+	CodeRefused            = "refused"             // a command was generated, but a constraint or read-only mode refuses it
+	CodeFailed             = "failed"              // no command could be generated, e.g. the provider failed
+)
+
+// Request is the envelope a client sends.
+type Request struct {
+	Version   int    `json:"version"`             // contract version the client speaks; 0 is taken as 1
+	Request   string `json:"request"`             // what the user asked for, in natural language
+	Dir       string `json:"dir,omitempty"`       // directory to gather context from and write the command for; nlch's own if empty
+	File      string `json:"file,omitempty"`      // the file open in the editor
+	Selection string `json:"selection,omitempty"` // text selected in the editor, given to the model as context
+	Provider  string `json:"provider,omitempty"`  // provider to use instead of the default
+	Model     string `json:"model,omitempty"`     // model to use instead of the provider's default
+	Shell     string `json:"shell,omitempty"`     // shell to write the command for: bash, sh, zsh, fish or nu
+	ReadOnly  bool   `json:"read_only,omitempty"` // only commands that change nothing may be generated
+}
+
+// Response is the single JSON object nlch writes back.
+type Response struct {
+	Version    int      `json:"version"`               // contract version of the response, always Version
+	Command    string   `json:"command,omitempty"`     // the generated command, ready to insert or run
+	Risk       string   `json:"risk,omitempty"`        // low, medium, high or critical
+	RiskReason string   `json:"risk_reason,omitempty"` // why the command has that risk
+	Dangerous  bool     `json:"dangerous,omitempty"`   // the risk is high or critical, so the user should review it
+	Provider   string   `json:"provider,omitempty"`
+	Model      string   `json:"model,omitempty"`
+	Shell      string   `json:"shell,omitempty"`      // shell the command is written for
+	HistoryID  int      `json:"history_id,omitempty"` // ID of the request in nlch's history, for nlch feedback --id
+	Warnings   []string `json:"warnings,omitempty"`   // notes for the user, such as rewrite rules applied
+	Error      *Error   `json:"error,omitempty"`      // set when no command can be offered
+}
+
+// Error explains why a request failed.
+type Error struct {
+	Code    string `json:"code"` // one of the Code constants, for clients to act on
+	Message string `json:"message"`
+}
+
+// RequestError is a failure to accept a request, with its Error code.
+type RequestError struct {
+	Code    string
+	Message string
+}
+
+func (e *RequestError) Error() string { return e.Message }
+
+// ReadRequest reads and checks a request envelope.
+func ReadRequest(r io.Reader) (*Request, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxRequestBytes+1))
+	if err != nil {
+		return nil, &RequestError{CodeInvalidRequest, fmt.Sprintf("failed to read the request: %v", err)}
+	}
+	if len(data) > maxRequestBytes {
+		return nil, &RequestError{CodeInvalidRequest, "the request is larger than 1 MiB"}
+	}
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, &RequestError{CodeInvalidRequest, fmt.Sprintf("invalid request envelope: %v", err)}
+	}
+	if req.Version > Version {
+		return nil, &RequestError{CodeUnsupportedVersion, fmt.Sprintf("contract version %d is not supported, this nlch speaks version %d", req.Version, Version)}
+	}
+	if req.Version < 0 {
+		return nil, &RequestError{CodeInvalidRequest, fmt.Sprintf("invalid contract version %d", req.Version)}
+	}
+	req.Request = strings.TrimSpace(req.Request)
+	if req.Request == "" {
+		return nil, &RequestError{CodeInvalidRequest, "the request is empty"}
+	}
+	return &req, nil
+}
+
+// Fail returns the response for an error, using its code if it is a
+// RequestError and code otherwise.
+func Fail(err error, code string) *Response {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		code = reqErr.Code
+	}
+	return &Response{Version: Version, Error: &Error{Code: code, Message: strings.TrimSpace(err.Error())}}
+}
+
+// Write writes a response as a single line of JSON.
+func Write(w io.Writer, resp *Response) error {
+	resp.Version = Version
+	return json.NewEncoder(w).Encode(resp)
+}
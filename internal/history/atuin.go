@@ -0,0 +1,43 @@
+// Package history copies executed commands into atuin's shell history.
+package history
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// AtuinEnabled reports whether the user's shell records its history with
+// atuin, which sets ATUIN_SESSION in every shell it is initialised in.
+func AtuinEnabled() bool {
+	if os.Getenv("ATUIN_SESSION") == "" {
+		return false
+	}
+	_, err := exec.LookPath("atuin")
+	return err == nil
+}
+
+// RecordAtuin adds an executed command to atuin's history, so it can be found
+// with atuin's search like commands typed into the shell. Commands nlch runs
+// are otherwise invisible to the shell's history.
+func RecordAtuin(e Entry) error {
+	if e.Decision != DecisionExecuted || e.Command == "" {
+		return nil
+	}
+	start := exec.Command("atuin", "history", "start", "--", e.Command)
+	start.Dir = e.Dir
+	out, err := start.Output()
+	if err != nil {
+		return fmt.Errorf("atuin history start: %v", err)
+	}
+	id := strings.TrimSpace(string(out))
+	if id == "" {
+		return nil
+	}
+	if err := exec.Command("atuin", "history", "end", "--exit", strconv.Itoa(e.ExitCode), id).Run(); err != nil {
+		return fmt.Errorf("atuin history end: %v", err)
+	}
+	return nil
+}
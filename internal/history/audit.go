@@ -0,0 +1,282 @@
+// Package history exports executed commands as signed receipts, for teams
+// that attach terminal activity to change requests. Each receipt is signed
+// with HMAC-SHA256 using a local key, chained to the receipt before it, so
+// changed, removed or reordered receipts fail verification. The export as a
+// whole is signed too, so receipts cut from the end are noticed as well.
+package history
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+)
+
+// AuditKeyFile is the name of the local signing key in the config directory.
+const AuditKeyFile = "audit.key"
+
+// Receipt is the audit record of one history entry.
+type Receipt struct {
+	ID           int       `json:"id"`
+	Time         time.Time `json:"time"`
+	User         string    `json:"user"`
+	Host         string    `json:"host"`
+	Dir          string    `json:"dir"`
+	Request      string    `json:"request"`
+	Command      string    `json:"command"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	Decision     string    `json:"decision"`
+	ExitCode     int       `json:"exit_code"`
+	OutputSHA256 string    `json:"output_sha256"` // hash of the recorded output, empty if none was recorded
+	Signature    string    `json:"signature"`     // HMAC of the fields above and the previous receipt's signature
+}
+
+// AuditExport is a signed export in the JSON format.
+type AuditExport struct {
+	Generated time.Time  `json:"generated"`
+	Since     *time.Time `json:"since,omitempty"`
+	KeyID     string     `json:"key_id"` // identifies the key without revealing it
+	Count     int        `json:"count"`
+	Receipts  []Receipt  `json:"receipts"`
+	Signature string     `json:"signature"` // HMAC of the fields above and the last receipt's signature
+}
+
+// csvHeader is the header row of the CSV format, in the order of Receipt's fields.
+var csvHeader = []string{"id", "time", "user", "host", "dir", "request", "command", "provider", "model", "decision", "exit_code", "output_sha256", "signature"}
+
+// csvEnvelope marks the last row of the CSV format, which holds the fields
+// and signature of the export itself rather than a receipt.
+const csvEnvelope = "envelope"
+
+// AuditKey returns the signing key in path, or in the config directory when
+// path is empty. With create, a random key is made there on first use.
+func AuditKey(path string, create bool) ([]byte, error) {
+	if path == "" {
+		dir, err := config.GetConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, AuditKeyFile)
+		if _, err := os.Stat(path); os.IsNotExist(err) && create {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+				return nil, fmt.Errorf("failed to create audit key: %v", err)
+			}
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit key: %v", err)
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) < 16 {
+		return nil, fmt.Errorf("audit key %s is too short, use at least 16 bytes", path)
+	}
+	return key, nil
+}
+
+// KeyID identifies a key by the start of its hash, so exports can say which
+// key signed them.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Receipts turns entries into receipts signed with key, in order.
+func Receipts(entries []Entry, key []byte) []Receipt {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	receipts := make([]Receipt, len(entries))
+	previous := ""
+	for i, e := range entries {
+		r := Receipt{
+			ID:           e.ID,
+			Time:         e.Time.UTC(),
+			User:         name,
+			Host:         host,
+			Dir:          e.Dir,
+			Request:      e.Request,
+			Command:      e.Command,
+			Provider:     e.Provider,
+			Model:        e.Model,
+			Decision:     e.Decision,
+			ExitCode:     e.ExitCode,
+			OutputSHA256: e.OutputHash,
+		}
+		if e.Output != "" {
+			sum := sha256.Sum256([]byte(e.Output))
+			r.OutputSHA256 = hex.EncodeToString(sum[:])
+		}
+		r.Signature = r.sign(key, previous)
+		previous = r.Signature
+		receipts[i] = r
+	}
+	return receipts
+}
+
+// fields returns the signed fields of the receipt, as they appear in a CSV row.
+func (r Receipt) fields() []string {
+	return []string{
+		strconv.Itoa(r.ID), r.Time.Format(time.RFC3339Nano), r.User, r.Host, r.Dir, r.Request, r.Command,
+		r.Provider, r.Model, r.Decision, strconv.Itoa(r.ExitCode), r.OutputSHA256,
+	}
+}
+
+// sign computes the receipt's signature, chained to the previous one.
+func (r Receipt) sign(key []byte, previous string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(previous))
+	for _, f := range r.fields() {
+		// Lengths keep field boundaries unambiguous
+		fmt.Fprintf(mac, "\n%d:%s", len(f), f)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign counts the export's receipts and signs it with key.
+func (e *AuditExport) Sign(key []byte) {
+	e.KeyID = KeyID(key)
+	e.Count = len(e.Receipts)
+	e.Signature = e.sign(key)
+}
+
+// envelope returns the signed fields of the export, as they appear in the
+// CSV format's last row.
+func (e AuditExport) envelope() []string {
+	since := ""
+	if e.Since != nil {
+		since = e.Since.Format(time.RFC3339Nano)
+	}
+	last := ""
+	if len(e.Receipts) > 0 {
+		last = e.Receipts[len(e.Receipts)-1].Signature
+	}
+	return []string{e.Generated.Format(time.RFC3339Nano), since, e.KeyID, strconv.Itoa(e.Count), last}
+}
+
+// sign computes the export's signature over its envelope.
+func (e AuditExport) sign(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(csvEnvelope))
+	for _, f := range e.envelope() {
+		fmt.Fprintf(mac, "\n%d:%s", len(f), f)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WriteAudit writes a signed export as "json" or "csv".
+func WriteAudit(w io.Writer, format string, export AuditExport) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(export)
+	case "csv":
+		out := csv.NewWriter(w)
+		out.Write(csvHeader)
+		for _, r := range export.Receipts {
+			out.Write(append(r.fields(), r.Signature))
+		}
+		// The envelope row is padded to the width of the others, as CSV readers expect
+		envelope := append([]string{csvEnvelope}, export.envelope()...)
+		envelope = append(envelope, export.Signature)
+		out.Write(append(envelope, make([]string, len(csvHeader)-len(envelope))...))
+		out.Flush()
+		return out.Error()
+	}
+	return fmt.Errorf("unknown format %q, use csv or json", format)
+}
+
+// ReadAudit reads an export in either format.
+func ReadAudit(data []byte) (AuditExport, error) {
+	var export AuditExport
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &export); err != nil {
+			return export, fmt.Errorf("invalid JSON export: %v", err)
+		}
+		return export, nil
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return export, fmt.Errorf("invalid CSV export: %v", err)
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(csvHeader, ",") {
+		return export, errors.New("not an nlch audit export")
+	}
+	rows = rows[1:]
+	if n := len(rows); n > 0 && rows[n-1][0] == csvEnvelope {
+		row := rows[n-1]
+		generated, err1 := time.Parse(time.RFC3339Nano, row[1])
+		count, err2 := strconv.Atoi(row[4])
+		if err := errors.Join(err1, err2); err != nil {
+			return export, fmt.Errorf("invalid CSV export, envelope row: %v", err)
+		}
+		export.Generated, export.KeyID, export.Count, export.Signature = generated, row[3], count, row[6]
+		if row[2] != "" {
+			since, err := time.Parse(time.RFC3339Nano, row[2])
+			if err != nil {
+				return export, fmt.Errorf("invalid CSV export, envelope row: %v", err)
+			}
+			export.Since = &since
+		}
+		rows = rows[:n-1]
+	}
+	export.Receipts = make([]Receipt, 0, len(rows))
+	for n, row := range rows {
+		id, err1 := strconv.Atoi(row[0])
+		t, err2 := time.Parse(time.RFC3339Nano, row[1])
+		code, err3 := strconv.Atoi(row[10])
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return export, fmt.Errorf("invalid CSV export, row %d: %v", n+2, err)
+		}
+		export.Receipts = append(export.Receipts, Receipt{
+			ID: id, Time: t, User: row[2], Host: row[3], Dir: row[4], Request: row[5], Command: row[6],
+			Provider: row[7], Model: row[8], Decision: row[9], ExitCode: code, OutputSHA256: row[11], Signature: row[12],
+		})
+	}
+	return export, nil
+}
+
+// VerifyReceipts checks every receipt's signature and the chain between them.
+// It returns the index of the first receipt that fails, or -1 if all pass.
+func VerifyReceipts(receipts []Receipt, key []byte) int {
+	previous := ""
+	for i, r := range receipts {
+		if !hmac.Equal([]byte(r.sign(key, previous)), []byte(r.Signature)) {
+			return i
+		}
+		previous = r.Signature
+	}
+	return -1
+}
+
+// VerifyEnvelope checks the export's own signature, which covers its time,
+// period, key, number of receipts and the last receipt's signature.
+func VerifyEnvelope(export AuditExport, key []byte) bool {
+	return export.Signature != "" && export.Count == len(export.Receipts) &&
+		hmac.Equal([]byte(export.sign(key)), []byte(export.Signature))
+}
@@ -0,0 +1,50 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestVerifyAuditExport(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-24 * time.Hour)
+	entries := []Entry{
+		{ID: 1, Time: now.Add(-3 * time.Hour), Command: "ls", Decision: DecisionExecuted},
+		{ID: 2, Time: now.Add(-2 * time.Hour), Command: "make", Decision: DecisionExecuted},
+		{ID: 3, Time: now.Add(-time.Hour), Command: "rm -rf build", Decision: DecisionExecuted},
+	}
+	tests := []struct {
+		name   string
+		change func(*AuditExport)
+		want   bool
+	}{
+		{"unchanged", func(*AuditExport) {}, true},
+		{"receipts cut from the end", func(e *AuditExport) { e.Receipts = e.Receipts[:2]; e.Count = 2 }, false},
+		{"period widened", func(e *AuditExport) { from := since.Add(-time.Hour); e.Since = &from }, false},
+		{"time changed", func(e *AuditExport) { e.Generated = e.Generated.Add(time.Hour) }, false},
+		{"signed with another key", func(e *AuditExport) { e.Sign([]byte("another key of 16 bytes")) }, false},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"json", "csv"} {
+			t.Run(tt.name+" "+format, func(t *testing.T) {
+				export := AuditExport{Generated: now, Since: &since, Receipts: Receipts(entries, key)}
+				export.Sign(key)
+				tt.change(&export)
+				var out bytes.Buffer
+				if err := WriteAudit(&out, format, export); err != nil {
+					t.Fatal(err)
+				}
+				read, err := ReadAudit(out.Bytes())
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := VerifyReceipts(read.Receipts, key) < 0 && VerifyEnvelope(read, key)
+				if got != tt.want {
+					t.Errorf("verified = %v, want %v\n%s", got, tt.want, out.String())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Package history records the git state a command ran in, so re-running it
+// somewhere that differs can be flagged.
+package history
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GitState is the state of the git repository a command ran in.
+type GitState struct {
+	Branch string `json:"branch,omitempty"` // "HEAD" when detached
+	Commit string `json:"commit,omitempty"`
+	Dirty  bool   `json:"dirty,omitempty"` // tracked files had uncommitted changes
+}
+
+// SnapshotGit returns the git state of dir, or nil when dir is not in a repository.
+func SnapshotGit(dir string) *GitState {
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	branch, err := git("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil
+	}
+	state := &GitState{Branch: branch}
+	state.Commit, _ = git("rev-parse", "--short", "HEAD")
+	// Untracked files are left out: they rarely change what a command does
+	// and listing them is slow in large trees
+	if status, err := git("status", "--porcelain", "--untracked-files=no"); err == nil {
+		state.Dirty = status != ""
+	}
+	return state
+}
+
+// describe names where the repository was: on a branch, or at a commit when detached.
+func (g *GitState) describe() string {
+	if g.Branch == "HEAD" {
+		return "at commit " + g.Commit
+	}
+	return "on branch " + g.Branch
+}
+
+// EnvironmentChanges lists how running the entry's command in dir, whose git
+// state is now, differs materially from where it ran: another directory,
+// another branch, or uncommitted changes that weren't there. Entries recorded
+// before environments were kept report nothing.
+func (e *Entry) EnvironmentChanges(dir string, now *GitState) []string {
+	var changes []string
+	if e.Dir != "" && dir != e.Dir {
+		changes = append(changes, fmt.Sprintf("this was run in %s; you are now in %s", e.Dir, dir))
+	}
+	was := e.Git
+	switch {
+	case was == nil:
+	case now == nil:
+		changes = append(changes, fmt.Sprintf("this was run in a git repository %s; you are now outside a repository", was.describe()))
+	case was.describe() != now.describe():
+		changes = append(changes, fmt.Sprintf("this was run %s; you are now %s", was.describe(), now.describe()))
+	case !was.Dirty && now.Dirty:
+		changes = append(changes, "this was run without uncommitted changes; you now have some")
+	}
+	return changes
+}
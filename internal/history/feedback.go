@@ -0,0 +1,156 @@
+// Package history stores user feedback on generated commands and derives
+// lessons from it for similar future requests.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Ratings a user can give a generated command.
+const (
+	RatingGood = "good"
+	RatingBad  = "bad"
+)
+
+// Feedback is a user's rating of a history entry.
+type Feedback struct {
+	EntryID int       `json:"entry_id"`
+	Time    time.Time `json:"time"`
+	Rating  string    `json:"rating"`
+	Note    string    `json:"note,omitempty"` // what was wrong or right about the command
+}
+
+// feedbackPath returns the file feedback is stored in, next to the history file.
+func (s *Store) feedbackPath() string {
+	return filepath.Join(filepath.Dir(s.Path), "feedback.jsonl")
+}
+
+// AddFeedback records feedback for an entry. Later feedback for the same entry replaces earlier feedback.
+// It holds the history lock, so it can't be lost to a rewrite of the feedback by Prune or Purge.
+func (s *Store) AddFeedback(f Feedback) error {
+	if f.Time.IsZero() {
+		f.Time = time.Now()
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	path := s.feedbackPath()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Feedback returns the latest feedback for each entry, keyed by entry ID.
+func (s *Store) Feedback() (map[int]Feedback, error) {
+	feedback := map[int]Feedback{}
+	file, err := os.Open(s.feedbackPath())
+	if os.IsNotExist(err) {
+		return feedback, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var f Feedback
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			continue
+		}
+		feedback[f.EntryID] = f
+	}
+	return feedback, scanner.Err()
+}
+
+// Lesson is what was learned from a past request: a command to avoid, a
+// command that worked, or both.
+type Lesson struct {
+	Request string
+	Bad     string // command the user rejected or that failed
+	Note    string // the user's explanation, if any
+	Good    string // command the user approved or that fixed the failure
+}
+
+// Lessons returns up to max lessons from the most recent entries whose request
+// is similar to the given one. Lessons come from explicit feedback and from
+// failed commands that were successfully corrected.
+func Lessons(entries []Entry, feedback map[int]Feedback, request string, max int) []Lesson {
+	words := significantWords(request)
+	if len(words) == 0 || max <= 0 {
+		return nil
+	}
+
+	var lessons []Lesson
+	for i := len(entries) - 1; i >= 0 && len(lessons) < max; i-- {
+		e := entries[i]
+		if !similar(words, significantWords(e.Request)) {
+			continue
+		}
+		f, rated := feedback[e.ID]
+		switch {
+		case rated && f.Rating == RatingBad:
+			lessons = append(lessons, Lesson{Request: e.Request, Bad: e.Command, Note: f.Note})
+		case rated && f.Rating == RatingGood:
+			lessons = append(lessons, Lesson{Request: e.Request, Good: e.Command, Note: f.Note})
+		case e.Corrected && e.Succeeded():
+			lesson := Lesson{Request: e.Request, Good: e.Command}
+			// The failed attempt is the previous executed entry for the same request
+			for j := i - 1; j >= 0 && j >= i-3; j-- {
+				if entries[j].Request == e.Request && entries[j].Decision == DecisionExecuted && !entries[j].Corrected {
+					lesson.Bad = entries[j].Command
+					break
+				}
+			}
+			lessons = append(lessons, lesson)
+		}
+	}
+	return lessons
+}
+
+// similar reports whether two requests share at least half the words of the shorter one.
+func similar(a, b map[string]bool) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return shared*2 >= min(len(a), len(b))
+}
+
+// significantWords returns the lower-case words of a request, ignoring short filler words.
+func significantWords(request string) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(request), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_')
+	}) {
+		if len(w) > 2 && !fillerWords[w] {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+var fillerWords = map[string]bool{
+	"the": true, "and": true, "all": true, "for": true, "with": true, "from": true,
+	"that": true, "this": true, "into": true, "are": true, "show": true, "please": true,
+}
@@ -0,0 +1,236 @@
+// Package history stores a record of every request, generated command and outcome.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+)
+
+// Decisions recorded for a generated command.
+const (
+	DecisionExecuted = "executed" // the command was run
+	DecisionAborted  = "aborted"  // the user declined to run the command
+	DecisionDryRun   = "dry-run"  // the command was shown but not run
+	DecisionBlocked  = "blocked"  // the command was refused by a safety check
+	DecisionPrinted  = "printed"  // the command was printed for the caller to run
+)
+
+// Entry is a single history record.
+type Entry struct {
+	ID        int       `json:"id"`
+	Time      time.Time `json:"time"`
+	Request   string    `json:"request"`
+	Command   string    `json:"command"`
+	Provider  string    `json:"provider,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Dir       string    `json:"dir,omitempty"`
+	Git       *GitState `json:"git,omitempty"` // state of the repository in Dir before the command ran
+	Decision  string    `json:"decision"`
+	ExitCode  int       `json:"exit_code"`
+	Corrected bool      `json:"corrected,omitempty"` // the command is an LLM correction of a failed one
+	Compared  []string  `json:"compared,omitempty"`  // provider:model of every model the command was picked from
+	Capture   string    `json:"capture,omitempty"`   // file the output was also written to, with --capture
+	Shell     string    `json:"shell,omitempty"`     // shell the command was written for when not a POSIX one, e.g. fish
+
+	// Output of an executed command, truncated, or only its hash when outputs are hashed
+	Output     string `json:"output,omitempty"`
+	OutputHash string `json:"output_hash,omitempty"`
+
+	// Estimated tokens sent to and received from the provider to produce the command
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+}
+
+// Succeeded reports whether the entry was executed and exited successfully.
+func (e *Entry) Succeeded() bool {
+	return e.Decision == DecisionExecuted && e.ExitCode == 0
+}
+
+// Store is a JSONL file holding history entries, one per line.
+type Store struct {
+	Path string
+}
+
+// Open returns the store at the default location in the nlch config directory.
+func Open() (*Store, error) {
+	dir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Path: filepath.Join(dir, "history.jsonl")}, nil
+}
+
+// Load returns all entries in the order they were recorded.
+func (s *Store) Load() ([]Entry, error) {
+	file, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			// Skip corrupt lines rather than losing the whole history
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Append assigns the next ID and timestamp to the entry and writes it to the
+// store. The store is locked from reading the last ID to writing the entry, so
+// concurrent processes never hand out the same ID.
+func (s *Store) Append(e *Entry) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := s.Load()
+	if err != nil {
+		return err
+	}
+	// IDs continue from the counter, so they are not reused once pruning or
+	// purging has removed the newest entries
+	e.ID = s.nextID()
+	if len(entries) > 0 && entries[len(entries)-1].ID >= e.ID {
+		e.ID = entries[len(entries)-1].ID + 1
+	}
+	if err := writeFileAtomic(s.nextIDPath(), strconv.Itoa(e.ID+1)+"\n"); err != nil {
+		return err
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// nextIDPath returns the file that keeps the next ID, next to the history file.
+func (s *Store) nextIDPath() string {
+	return s.Path + ".next_id"
+}
+
+// nextID returns the ID the counter holds, or 1 if there is none yet.
+func (s *Store) nextID() int {
+	data, err := os.ReadFile(s.nextIDPath())
+	if err != nil {
+		return 1
+	}
+	id, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || id < 1 {
+		return 1
+	}
+	return id
+}
+
+// Get returns the entry with the given ID.
+func (s *Store) Get(id int) (*Entry, error) {
+	entries, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].ID == id {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no history entry with id %d", id)
+}
+
+// Filter selects history entries.
+type Filter struct {
+	Provider string    // only entries generated by this provider
+	Since    time.Time // only entries recorded at or after this time
+	Failed   bool      // only entries that ran and exited non-zero
+	Limit    int       // at most this many of the most recent entries (0 for all)
+}
+
+// Apply returns the entries matching the filter, oldest first.
+func (f Filter) Apply(entries []Entry) []Entry {
+	var matched []Entry
+	for _, e := range entries {
+		if f.Provider != "" && e.Provider != f.Provider {
+			continue
+		}
+		if !f.Since.IsZero() && e.Time.Before(f.Since) {
+			continue
+		}
+		if f.Failed && (e.Decision != DecisionExecuted || e.ExitCode == 0) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[len(matched)-f.Limit:]
+	}
+	return matched
+}
+
+// Search returns the entries whose request or command contains every word of
+// the query, ignoring case, oldest first.
+func Search(entries []Entry, query string) []Entry {
+	words := strings.Fields(strings.ToLower(query))
+	var matched []Entry
+	for _, e := range entries {
+		text := strings.ToLower(e.Request + "\n" + e.Command)
+		found := true
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				found = false
+				break
+			}
+		}
+		if found {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// ParseAge parses a duration such as "36h", "30m" or "7d" (days).
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
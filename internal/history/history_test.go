@@ -0,0 +1,116 @@
+package history
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendAssignsDistinctIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	// Writers in other processes have stores of their own on the same file
+	const writers, each = 10, 20
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := &Store{Path: path}
+			for i := 0; i < each; i++ {
+				if err := store.Append(&Entry{Command: "ls"}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := (&Store{Path: path}).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != writers*each {
+		t.Fatalf("%d entries, want %d", len(entries), writers*each)
+	}
+	seen := map[int]bool{}
+	for _, e := range entries {
+		if seen[e.ID] {
+			t.Errorf("ID %d handed out twice", e.ID)
+		}
+		seen[e.ID] = true
+	}
+}
+
+func TestIDsAreNotReusedAfterRemoval(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(*Store) error
+	}{
+		{"purge", func(s *Store) error { _, err := s.Purge(time.Time{}); return err }},
+		{"purge before now", func(s *Store) error { _, err := s.Purge(time.Now().Add(time.Hour)); return err }},
+		{"prune", func(s *Store) error { _, err := s.Prune(0, 1); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &Store{Path: filepath.Join(t.TempDir(), "history.jsonl")}
+			for i := 0; i < 3; i++ {
+				if err := store.Append(&Entry{Command: "ls"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.remove(store); err != nil {
+				t.Fatal(err)
+			}
+			e := &Entry{Command: "pwd"}
+			if err := store.Append(e); err != nil {
+				t.Fatal(err)
+			}
+			if e.ID != 4 {
+				t.Errorf("ID = %d, want 4", e.ID)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	store := &Store{Path: filepath.Join(t.TempDir(), "history.jsonl")}
+	for _, cmd := range []string{"ls", "pwd"} {
+		if err := store.Append(&Entry{Command: cmd}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, err := store.Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Command != "pwd" {
+		t.Errorf("entry 2 is %q, want pwd", e.Command)
+	}
+	if _, err := store.Get(3); err == nil {
+		t.Error("expected an error for a missing entry")
+	}
+}
+
+func TestAddFeedbackWaitsForTheLock(t *testing.T) {
+	store := &Store{Path: filepath.Join(t.TempDir(), "history.jsonl")}
+	unlock, err := store.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- store.AddFeedback(Feedback{EntryID: 1, Rating: RatingGood}) }()
+
+	time.Sleep(50 * time.Millisecond)
+	if feedback, _ := store.Feedback(); len(feedback) > 0 {
+		t.Error("feedback was written while another process held the lock")
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if feedback, _ := store.Feedback(); feedback[1].Rating != RatingGood {
+		t.Errorf("feedback = %v", feedback)
+	}
+}
@@ -0,0 +1,47 @@
+// Package history serializes writers of the store, since several nlch
+// processes (the daemon, batch runs, shell widgets) may record at once.
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockWait is how long a writer waits for another to finish.
+	lockWait = 5 * time.Second
+	// lockStale is the age after which a lock is assumed to be left over from
+	// a process that died holding it.
+	lockStale = 10 * time.Second
+)
+
+// lock takes the store's lock file, waiting while another process holds it,
+// and returns the function that releases it. A lock file is used rather than
+// a lock on the store itself because rewrite replaces the store by renaming.
+func (s *Store) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return nil, err
+	}
+	path := s.Path + ".lock"
+	deadline := time.Now().Add(lockWait)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("history is locked by another nlch process (remove %s if none is running)", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,177 @@
+// Package history applies privacy settings: what is recorded, in which
+// directories, and for how long.
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+)
+
+// OptOutFile disables history for the directory containing it and everything below.
+const OptOutFile = ".nlch-no-history"
+
+// Maximum number of bytes of command output kept in an entry.
+const maxOutputBytes = 2048
+
+// Excluded reports whether nothing should be recorded for requests made in dir.
+func Excluded(cfg config.HistoryConfig, dir string) bool {
+	if cfg.Disabled {
+		return true
+	}
+	if dir == "" {
+		return false
+	}
+	home, _ := os.UserHomeDir()
+	for _, excluded := range cfg.ExcludeDirs {
+		if rest, ok := strings.CutPrefix(excluded, "~"); ok && home != "" {
+			excluded = home + rest
+		}
+		excluded = filepath.Clean(excluded)
+		if dir == excluded || strings.HasPrefix(dir, excluded+string(filepath.Separator)) {
+			return true
+		}
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, OptOutFile)); err == nil {
+			return true
+		}
+		if filepath.Dir(d) == d {
+			return false
+		}
+	}
+}
+
+// ApplyPolicy prepares an entry's output for storage: it keeps only the end of
+// long output, or replaces the output with its hash when outputs are hashed.
+func ApplyPolicy(e *Entry, cfg config.HistoryConfig) {
+	if e.Output == "" {
+		return
+	}
+	if cfg.HashOutputs {
+		sum := sha256.Sum256([]byte(e.Output))
+		e.OutputHash = hex.EncodeToString(sum[:])
+		e.Output = ""
+		return
+	}
+	if len(e.Output) > maxOutputBytes {
+		e.Output = "...\n" + e.Output[len(e.Output)-maxOutputBytes:]
+	}
+}
+
+// Prune removes entries older than maxDays and all but the newest maxEntries
+// entries. Zero disables either limit. It returns the number of entries removed.
+func (s *Store) Prune(maxDays, maxEntries int) (int, error) {
+	if maxDays <= 0 && maxEntries <= 0 {
+		return 0, nil
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	entries, err := s.Load()
+	if err != nil {
+		return 0, err
+	}
+	keep := entries
+	if maxDays > 0 {
+		cutoff := time.Now().Add(-time.Duration(maxDays) * 24 * time.Hour)
+		keep = nil
+		for _, e := range entries {
+			if !e.Time.Before(cutoff) {
+				keep = append(keep, e)
+			}
+		}
+	}
+	if maxEntries > 0 && len(keep) > maxEntries {
+		keep = keep[len(keep)-maxEntries:]
+	}
+	if len(keep) == len(entries) {
+		return 0, nil
+	}
+	return len(entries) - len(keep), s.rewrite(keep)
+}
+
+// Purge removes the entries recorded before the given time, or all entries
+// if before is zero, together with their feedback. It returns the number removed.
+func (s *Store) Purge(before time.Time) (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	entries, err := s.Load()
+	if err != nil {
+		return 0, err
+	}
+	if before.IsZero() {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+		if err := os.Remove(s.feedbackPath()); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+		return len(entries), nil
+	}
+	var keep []Entry
+	for _, e := range entries {
+		if !e.Time.Before(before) {
+			keep = append(keep, e)
+		}
+	}
+	return len(entries) - len(keep), s.rewrite(keep)
+}
+
+// rewrite replaces the store's contents with the given entries and drops
+// feedback for entries that no longer exist.
+func (s *Store) rewrite(entries []Entry) error {
+	var b strings.Builder
+	ids := map[int]bool{}
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+		ids[e.ID] = true
+	}
+	if err := writeFileAtomic(s.Path, b.String()); err != nil {
+		return err
+	}
+
+	feedback, err := s.Feedback()
+	if err != nil || len(feedback) == 0 {
+		return err
+	}
+	b.Reset()
+	for id, f := range feedback {
+		if !ids[id] {
+			continue
+		}
+		data, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	return writeFileAtomic(s.feedbackPath(), b.String())
+}
+
+// writeFileAtomic replaces a file's contents via a temporary file so that an
+// interrupted write never leaves it truncated.
+func writeFileAtomic(path, content string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,130 @@
+// Package history summarises usage statistics over recorded entries.
+package history
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
+)
+
+// Count is a name with the number of times it occurs.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Pick counts how often a model's command was chosen when compared with other models.
+type Pick struct {
+	Model    string // provider:model
+	Picked   int
+	Compared int
+}
+
+// Stats summarises a set of history entries.
+type Stats struct {
+	Total     int     // all entries
+	Executed  int     // entries that were run
+	Commands  []Count // programs run, most used first
+	Providers []Count // providers used, most used first
+
+	FirstAttempts       int // executed commands that were not corrections
+	FirstAttemptsPassed int
+	Corrections         int // executed corrections of failed commands
+	CorrectionsPassed   int
+
+	InputTokens  int
+	OutputTokens int
+	Spend        float64 // estimated cost in US dollars of priced models
+
+	Picks []Pick // models in --compare requests, most often compared first
+}
+
+// Summarize computes statistics over the entries.
+func Summarize(entries []Entry) Stats {
+	var s Stats
+	commands := map[string]int{}
+	providers := map[string]int{}
+	picks := map[string]*Pick{}
+	for _, e := range entries {
+		s.Total++
+		chosen := e.Provider
+		if e.Model != "" {
+			chosen += ":" + e.Model
+		}
+		for _, model := range e.Compared {
+			if picks[model] == nil {
+				picks[model] = &Pick{Model: model}
+			}
+			picks[model].Compared++
+			if model == chosen {
+				picks[model].Picked++
+			}
+		}
+		if e.Provider != "" {
+			providers[e.Provider]++
+		}
+		s.InputTokens += e.InputTokens
+		s.OutputTokens += e.OutputTokens
+		s.Spend += tokens.Cost(e.Model, e.InputTokens, e.OutputTokens)
+
+		if e.Decision != DecisionExecuted {
+			continue
+		}
+		s.Executed++
+		if name := programName(e.Command); name != "" {
+			commands[name]++
+		}
+		if e.Corrected {
+			s.Corrections++
+			if e.ExitCode == 0 {
+				s.CorrectionsPassed++
+			}
+		} else {
+			s.FirstAttempts++
+			if e.ExitCode == 0 {
+				s.FirstAttemptsPassed++
+			}
+		}
+	}
+	s.Commands = sortCounts(commands)
+	s.Providers = sortCounts(providers)
+	for _, p := range picks {
+		s.Picks = append(s.Picks, *p)
+	}
+	sort.Slice(s.Picks, func(i, j int) bool {
+		if s.Picks[i].Compared != s.Picks[j].Compared {
+			return s.Picks[i].Compared > s.Picks[j].Compared
+		}
+		return s.Picks[i].Model < s.Picks[j].Model
+	})
+	return s
+}
+
+// programName returns the program a command line starts with, skipping
+// environment assignments and sudo.
+func programName(command string) string {
+	for _, field := range strings.Fields(command) {
+		if field == "sudo" || strings.Contains(field, "=") && !strings.HasPrefix(field, "-") {
+			continue
+		}
+		return filepath.Base(field)
+	}
+	return ""
+}
+
+// sortCounts orders the counts by frequency, then name.
+func sortCounts(m map[string]int) []Count {
+	counts := make([]Count, 0, len(m))
+	for name, n := range m {
+		counts = append(counts, Count{name, n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
@@ -0,0 +1,165 @@
+// Package httpclient provides the shared HTTP client used by providers and the
+// updater, which in offline mode connects to nothing but this machine.
+package httpclient
+
+import (
+	gocontext "context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+)
+
+// Default timeouts. Responses from LLMs can take a while to start when they
+// generate long output, so the response header timeout is generous.
+const (
+	dialTimeout           = 10 * time.Second
+	tlsHandshakeTimeout   = 10 * time.Second
+	defaultResponseHeader = 120 * time.Second
+	idleConnTimeout       = 90 * time.Second
+)
+
+// Options controls how the client is built.
+type Options struct {
+	ResponseTimeout time.Duration // time to wait for response headers (0 for the default)
+	DisableHTTP2    bool
+}
+
+// OfflineEnv turns on offline mode whatever the config says, as --offline does.
+const OfflineEnv = "NLCH_OFFLINE"
+
+// ErrOffline is returned for connections to other machines in offline mode.
+var ErrOffline = errors.New("offline mode")
+
+var (
+	mu      sync.RWMutex
+	client  = New(Options{})
+	offline bool // set from the config by Configure
+)
+
+// IsOffline reports whether offline mode is on, in the network settings or
+// through NLCH_OFFLINE. Clients built by New check it on every connection.
+func IsOffline() bool {
+	mu.RLock()
+	on := offline
+	mu.RUnlock()
+	if on {
+		return true
+	}
+	on, err := strconv.ParseBool(os.Getenv(OfflineEnv))
+	return err == nil && on
+}
+
+// IsLocal reports whether a URL points at this machine: localhost or a
+// loopback address. Other names are not resolved, since that would take a
+// DNS query.
+func IsLocal(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return isLoopback(u.Hostname())
+}
+
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// New builds a client with connection pooling, timeouts and proxy settings from the environment.
+func New(opts Options) *http.Client {
+	responseTimeout := opts.ResponseTimeout
+	if responseTimeout <= 0 {
+		responseTimeout = defaultResponseHeader
+	}
+	dial := (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           offlineGuard(dial),
+		ForceAttemptHTTP2:     !opts.DisableHTTP2,
+		MaxIdleConns:          20,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ResponseHeaderTimeout: responseTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	if opts.DisableHTTP2 {
+		// A non-nil, empty map stops the transport from negotiating HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// NewDirect builds a client that never uses a proxy, for endpoints that are
+// only reachable from this machine, such as cloud metadata servers. Like the
+// clients built by New, it connects to no other machine in offline mode.
+func NewDirect(timeout time.Duration) *http.Client {
+	dial := (&net.Dialer{Timeout: dialTimeout}).DialContext
+	return &http.Client{Timeout: timeout, Transport: &http.Transport{DialContext: offlineGuard(dial)}}
+}
+
+// proxy uses the proxy from the environment, except in offline mode.
+func proxy(req *http.Request) (*url.URL, error) {
+	if IsOffline() {
+		return nil, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// offlineGuard fails closed in offline mode: connections to other hosts are
+// refused before even a DNS lookup.
+func offlineGuard(dial func(gocontext.Context, string, string) (net.Conn, error)) func(gocontext.Context, string, string) (net.Conn, error) {
+	return func(ctx gocontext.Context, network, addr string) (net.Conn, error) {
+		if IsOffline() {
+			if host, _, err := net.SplitHostPort(addr); err != nil || !isLoopback(host) {
+				return nil, fmt.Errorf("%w: refusing to connect to %s", ErrOffline, addr)
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// Default returns the shared client.
+func Default() *http.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return client
+}
+
+// SetDefault replaces the shared client, e.g. with one whose transport is a test double.
+func SetDefault(c *http.Client) {
+	mu.Lock()
+	defer mu.Unlock()
+	client = c
+}
+
+// Configure rebuilds the shared client from the network settings in the config.
+func Configure(cfg config.NetworkConfig) error {
+	var opts Options
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid network timeout %q", cfg.Timeout)
+		}
+		opts.ResponseTimeout = d
+	}
+	opts.DisableHTTP2 = cfg.DisableHTTP2
+	SetDefault(New(opts))
+	mu.Lock()
+	offline = cfg.Offline
+	mu.Unlock()
+	return nil
+}
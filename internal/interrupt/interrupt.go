@@ -0,0 +1,164 @@
+// Package interrupt coordinates the handling of SIGINT and SIGTERM, and of
+// the deadline of --deadline, so that nlch stops cleanly: in-flight provider
+// requests are cancelled, signals are forwarded to a running command, and
+// otherwise the process exits at once.
+package interrupt
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrInterrupted is returned by operations stopped by a signal or the deadline.
+var ErrInterrupted = errors.New("interrupted")
+
+// DeadlineExitCode is the exit status when the deadline passes, that of timeout(1).
+const DeadlineExitCode = 124
+
+var (
+	mu          sync.Mutex
+	ctx, cancel = gocontext.WithCancel(gocontext.Background())
+	forward     func(os.Signal) // receives signals while a command runs
+	busy        int             // number of operations that a signal cancels
+	received    os.Signal
+	expired     bool // the deadline has passed
+)
+
+// Start installs the signal handlers. Until then signals have their default effect.
+func Start() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			handle(sig)
+		}
+	}()
+}
+
+// handle reacts to a signal. A running command gets the signal; in-flight work
+// is cancelled so its caller can record the outcome and return; with nothing to
+// cancel, or on a second signal, the process exits.
+func handle(sig os.Signal) {
+	mu.Lock()
+	if forward != nil {
+		f := forward
+		received = sig
+		mu.Unlock()
+		f(sig)
+		return
+	}
+	if busy == 0 || received != nil {
+		code := exitCode(sig)
+		mu.Unlock()
+		// Finish any partially printed prompt line before exiting
+		fmt.Fprintln(os.Stderr)
+		os.Exit(code)
+	}
+	received = sig
+	cancel()
+	mu.Unlock()
+}
+
+// Context is cancelled when a signal interrupts in-flight work.
+func Context() gocontext.Context {
+	return ctx
+}
+
+// Interrupted reports whether a signal has cancelled in-flight work.
+func Interrupted() bool {
+	return ctx.Err() != nil
+}
+
+// ExitCode returns the conventional exit status for the last signal received:
+// 128 plus the signal number.
+func ExitCode() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return exitCode(received)
+}
+
+func exitCode(sig os.Signal) int {
+	if expired {
+		return DeadlineExitCode
+	}
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 130
+}
+
+// Busy marks the start of work that a signal should cancel through Context
+// rather than abandon by exiting. Call the returned function when it is done.
+func Busy() (done func()) {
+	mu.Lock()
+	busy++
+	mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			busy--
+			mu.Unlock()
+		})
+	}
+}
+
+// Forward sends signals to f, typically to pass them on to a child process,
+// until the returned function is called.
+func Forward(f func(os.Signal)) (stop func()) {
+	mu.Lock()
+	forward = f
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		forward = nil
+		mu.Unlock()
+	}
+}
+
+// SetDeadline stops nlch's work once d has passed, so that generating,
+// running and correcting a command together keep to one time budget:
+// in-flight requests are cancelled and nothing new is started, a running
+// command is interrupted, and killed if it hasn't exited after grace, and
+// with neither the process exits at once with DeadlineExitCode.
+func SetDeadline(d, grace time.Duration) {
+	time.AfterFunc(d, func() {
+		mu.Lock()
+		expired = true
+		f := forward
+		if f == nil && busy == 0 {
+			mu.Unlock()
+			fmt.Fprintln(os.Stderr, "\nnlch: deadline exceeded")
+			os.Exit(DeadlineExitCode)
+		}
+		if received == nil {
+			received = os.Interrupt
+		}
+		cancel()
+		mu.Unlock()
+		if f != nil {
+			f(os.Interrupt)
+			time.AfterFunc(grace, func() {
+				mu.Lock()
+				f := forward
+				mu.Unlock()
+				if f != nil {
+					f(os.Kill)
+				}
+			})
+		}
+	})
+}
+
+// DeadlineExceeded reports whether the deadline set with SetDeadline has passed.
+func DeadlineExceeded() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return expired
+}
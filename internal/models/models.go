@@ -0,0 +1,154 @@
+// Package models keeps a registry of what LLMs can do, so prompts and context
+// budgets can adapt to the model a request is sent to.
+package models
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+)
+
+// Capabilities describes what a model supports.
+type Capabilities struct {
+	ContextWindow   int  // tokens the model accepts per request, prompt and reply together
+	JSONMode        bool // the API can constrain replies to valid JSON
+	Vision          bool // images may be attached to the prompt
+	FunctionCalling bool // the model can call tools
+}
+
+// Unknown is assumed for models that are not in the registry, such as most
+// local models: a small context window and no optional features.
+var Unknown = Capabilities{ContextWindow: 8192}
+
+type entry struct {
+	prefix string
+	caps   Capabilities
+}
+
+// registry maps model name prefixes to capabilities. More specific prefixes
+// must come before the prefixes they extend.
+var (
+	mu      sync.RWMutex
+	builtin = []entry{
+		{"gpt-4o-mini", Capabilities{128000, true, true, true}},
+		{"gpt-4o", Capabilities{128000, true, true, true}},
+		{"gpt-4.1", Capabilities{1047576, true, true, true}},
+		{"gpt-4-turbo", Capabilities{128000, true, true, true}},
+		{"gpt-4", Capabilities{8192, false, false, true}},
+		{"gpt-3.5-turbo", Capabilities{16385, true, false, true}},
+		{"o1-mini", Capabilities{128000, false, false, false}},
+		{"o1", Capabilities{200000, true, true, true}},
+		{"o3-mini", Capabilities{200000, true, false, true}},
+		{"o3", Capabilities{200000, true, true, true}},
+		{"o4-mini", Capabilities{200000, true, true, true}},
+		{"claude-", Capabilities{200000, false, true, true}},
+		{"command-a-vision", Capabilities{128000, true, true, false}},
+		{"command-a", Capabilities{256000, true, false, true}},
+		{"command-r", Capabilities{128000, true, false, true}},
+		{"gemini-1.0", Capabilities{32760, false, false, true}},
+		{"gemini-1.5", Capabilities{1048576, true, true, true}},
+		{"gemini-2", Capabilities{1048576, true, true, true}},
+		{"llama-3.3", Capabilities{131072, true, false, true}},
+		{"llama-3.1", Capabilities{131072, true, false, true}},
+		{"llama3.2-vision", Capabilities{131072, true, true, false}},
+		{"llama3.1", Capabilities{131072, true, false, true}},
+		{"llama3.2", Capabilities{131072, true, false, true}},
+		{"llama3", Capabilities{8192, true, false, false}},
+		{"llava", Capabilities{4096, true, true, false}},
+		{"qwen2.5", Capabilities{32768, true, false, true}},
+		{"mistral", Capabilities{32768, true, false, true}},
+		{"phi3", Capabilities{4096, true, false, false}},
+	}
+	registry = builtin
+)
+
+// normalize lowercases a model name and drops router prefixes such as
+// "openai/" and Ollama tags such as ":8b".
+func normalize(model string) string {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	if i := strings.Index(model, ":"); i >= 0 {
+		model = model[:i]
+	}
+	return model
+}
+
+// Lookup returns the capabilities of a model, reporting false when it is not
+// in the registry.
+func Lookup(model string) (Capabilities, bool) {
+	model = normalize(model)
+	if model == "" {
+		return Capabilities{}, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, e := range registry {
+		if strings.HasPrefix(model, e.prefix) {
+			return e.caps, true
+		}
+	}
+	return Capabilities{}, false
+}
+
+// For returns the capabilities of a model, or Unknown if it is not in the registry.
+func For(model string) Capabilities {
+	if caps, ok := Lookup(model); ok {
+		return caps
+	}
+	return Unknown
+}
+
+// Register adds or replaces the capabilities of models whose names start with
+// prefix, taking precedence over the built-in entries.
+func Register(prefix string, caps Capabilities) {
+	prefix = normalize(prefix)
+	mu.Lock()
+	defer mu.Unlock()
+	kept := make([]entry, 0, len(registry)+1)
+	kept = append(kept, entry{prefix, caps})
+	for _, e := range registry {
+		if e.prefix != prefix {
+			kept = append(kept, e)
+		}
+	}
+	registry = kept
+}
+
+// Configure registers the models declared in the config, starting from the
+// built-in registry. Longer prefixes are registered last so they take precedence.
+func Configure(cfg map[string]config.ModelConfig) {
+	mu.Lock()
+	registry = builtin
+	mu.Unlock()
+	prefixes := make([]string, 0, len(cfg))
+	for prefix := range cfg {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) < len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	for _, prefix := range prefixes {
+		m := cfg[prefix]
+		caps := For(prefix)
+		if m.ContextWindow > 0 {
+			caps.ContextWindow = m.ContextWindow
+		}
+		if m.JSONMode != nil {
+			caps.JSONMode = *m.JSONMode
+		}
+		if m.Vision != nil {
+			caps.Vision = *m.Vision
+		}
+		if m.FunctionCalling != nil {
+			caps.FunctionCalling = *m.FunctionCalling
+		}
+		Register(prefix, caps)
+	}
+}
@@ -0,0 +1,237 @@
+// Package plugin provides the forge plugin, which adds where a git repository
+// is hosted and whether the gh or glab CLI can act on it to the context, so
+// requests like "open a PR for this branch" get a complete command.
+package plugin
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+func init() {
+	Register(forgePlugin{})
+}
+
+// forge is a hosting platform and the CLI that works with it.
+type forge struct {
+	name     string // e.g. GitHub
+	cli      string // e.g. gh
+	host     string // default host, e.g. github.com
+	tokenEnv []string
+}
+
+var (
+	github = forge{name: "GitHub", cli: "gh", host: "github.com", tokenEnv: []string{"GH_TOKEN", "GITHUB_TOKEN", "GH_ENTERPRISE_TOKEN"}}
+	gitlab = forge{name: "GitLab", cli: "glab", host: "gitlab.com", tokenEnv: []string{"GITLAB_TOKEN", "GITLAB_ACCESS_TOKEN"}}
+)
+
+// Most commits of the branch listed, newest first.
+const maxBranchCommits = 10
+
+type forgePlugin struct{}
+
+func (forgePlugin) Name() string { return "forge" }
+
+// Gather adds the hosting platform and repository, the state of its CLI, the
+// default branch, the current branch's upstream and its commits since the
+// default branch. Repositories without a recognised remote are left alone.
+func (forgePlugin) Gather(ctx *context.Context) error {
+	branch := ctx.GitInfo["branch"]
+	if branch == "" {
+		return nil
+	}
+	remote := pickRemote(gitLines(ctx.WorkingDir, "remote"))
+	if remote == "" {
+		return nil
+	}
+	rawURL := gitLine(ctx.WorkingDir, "remote", "get-url", remote)
+	host, repo := parseRemote(rawURL)
+	f, ok := detectForge(host)
+	if !ok {
+		return nil
+	}
+
+	ctx.Extra["hosting"] = f.name + ", repository " + repo + " on " + host + " (remote " + remote + ")"
+	ctx.Extra[f.cli+" CLI"] = f.cliState(host)
+
+	base := defaultBranch(ctx.WorkingDir, remote)
+	if base != "" {
+		ctx.Extra["default branch"] = base
+	}
+	if branch == "HEAD" || branch == base {
+		return nil
+	}
+	if upstream := gitLine(ctx.WorkingDir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); upstream != "" {
+		ctx.Extra["branch upstream"] = upstream
+	} else {
+		ctx.Extra["branch upstream"] = "none, the branch has not been pushed"
+	}
+	if base != "" {
+		commits := gitLines(ctx.WorkingDir, "log", "--format=%s", "--max-count="+strconv.Itoa(maxBranchCommits+1), remote+"/"+base+"..HEAD")
+		if len(commits) > maxBranchCommits {
+			commits = append(commits[:maxBranchCommits], "...")
+		}
+		if len(commits) > 0 {
+			ctx.Extra["commits on this branch"] = strings.Join(commits, "; ")
+		}
+	}
+	return nil
+}
+
+// pickRemote prefers origin, then upstream, then the first remote.
+func pickRemote(remotes []string) string {
+	for _, name := range []string{"origin", "upstream"} {
+		if slices.Contains(remotes, name) {
+			return name
+		}
+	}
+	if len(remotes) > 0 {
+		return remotes[0]
+	}
+	return ""
+}
+
+// parseRemote returns the host and owner/name path of a remote URL, in any of
+// the forms git accepts: https://host/o/r.git, ssh://git@host:22/o/r.git or
+// git@host:o/r.git.
+func parseRemote(raw string) (host, repo string) {
+	if raw == "" {
+		return "", ""
+	}
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", ""
+		}
+		host, repo = u.Hostname(), u.Path
+	} else if at, path, ok := strings.Cut(raw, ":"); ok && !filepath.IsAbs(raw) {
+		host, repo = at[strings.LastIndex(at, "@")+1:], path
+	} else {
+		return "", ""
+	}
+	return strings.ToLower(host), strings.TrimSuffix(strings.Trim(repo, "/"), ".git")
+}
+
+// detectForge recognises github.com, gitlab.com and self-hosted instances
+// named after them, or hosts the gh or glab CLI is logged in to.
+func detectForge(host string) (forge, bool) {
+	if host == "" {
+		return forge{}, false
+	}
+	for _, f := range []forge{github, gitlab} {
+		if host == f.host || strings.Contains(host, strings.ToLower(f.name)) || slices.Contains(f.loggedInHosts(), host) {
+			return f, true
+		}
+	}
+	return forge{}, false
+}
+
+// cliState describes whether the forge's CLI is installed and logged in to host.
+func (f forge) cliState(host string) string {
+	if _, err := exec.LookPath(f.cli); err != nil {
+		return "not installed"
+	}
+	if slices.Contains(f.loggedInHosts(), host) {
+		return "installed, logged in to " + host
+	}
+	if host == f.host {
+		for _, name := range f.tokenEnv {
+			if os.Getenv(name) != "" {
+				return "installed, authenticated with $" + name
+			}
+		}
+	}
+	return "installed, not logged in to " + host
+}
+
+// loggedInHosts reads the hosts the CLI has credentials for from its config.
+// Reading the config avoids the network round trip of `gh auth status`.
+func (f forge) loggedInHosts() []string {
+	var path string
+	switch f.cli {
+	case "gh":
+		path = filepath.Join(cliConfigDir("GH_CONFIG_DIR", "gh"), "hosts.yml")
+	case "glab":
+		path = filepath.Join(cliConfigDir("GLAB_CONFIG_DIR", "glab-cli"), "config.yml")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var hosts map[string]any
+	if f.cli == "gh" {
+		err = yaml.Unmarshal(data, &hosts)
+	} else {
+		var cfg struct {
+			Hosts map[string]any `yaml:"hosts"`
+		}
+		err = yaml.Unmarshal(data, &cfg)
+		hosts = cfg.Hosts
+	}
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, strings.ToLower(name))
+	}
+	return names
+}
+
+// cliConfigDir returns the config directory of a CLI: the directory in env
+// when set, otherwise name under $XDG_CONFIG_HOME or ~/.config.
+func cliConfigDir(env, name string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", name)
+}
+
+// defaultBranch returns the branch the remote's HEAD points to, falling back
+// to main or master when the remote HEAD isn't known locally.
+func defaultBranch(dir, remote string) string {
+	if head := gitLine(dir, "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD"); head != "" {
+		return strings.TrimPrefix(head, remote+"/")
+	}
+	for _, name := range []string{"main", "master"} {
+		if gitLine(dir, "rev-parse", "--verify", "--quiet", "refs/remotes/"+remote+"/"+name) != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// gitLine runs git in dir and returns its trimmed output, or "" if it fails.
+func gitLine(dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// gitLines runs git in dir and returns the non-empty lines of its output.
+func gitLines(dir string, args ...string) []string {
+	var lines []string
+	for _, line := range strings.Split(gitLine(dir, args...), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
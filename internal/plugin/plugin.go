@@ -2,6 +2,9 @@
 package plugin
 
 import (
+	"sort"
+	"sync"
+
 	"github.com/kanishka-sahoo/nlch/internal/context"
 )
 
@@ -11,25 +14,63 @@ type Plugin interface {
 	Gather(ctx *context.Context) error
 }
 
-// Registry holds registered plugins.
-var registry = make(map[string]Plugin)
+// Registry holds registered plugins. It is safe for concurrent use.
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Plugin)
+)
 
-// Register adds a plugin to the registry.
+// Register adds a plugin to the registry, replacing any with the same name.
 func Register(p Plugin) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
 	registry[p.Name()] = p
 }
 
+// Unregister removes a plugin, reporting whether there was one.
+func Unregister(name string) bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	_, ok := registry[name]
+	delete(registry, name)
+	return ok
+}
+
+// Swap registers p in place of the plugin with the same name and returns a
+// function that puts the previous one, or none, back.
+func Swap(p Plugin) (restore func()) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name := p.Name()
+	previous, hadPrevious := registry[name]
+	registry[name] = p
+	return func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		if hadPrevious {
+			registry[name] = previous
+		} else {
+			delete(registry, name)
+		}
+	}
+}
+
 // Get returns a plugin by name.
 func Get(name string) (Plugin, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	p, ok := registry[name]
 	return p, ok
 }
 
-// List returns all registered plugins.
+// List returns all registered plugins, sorted by name so they always run in the same order.
 func List() []Plugin {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	plugins := make([]Plugin, 0, len(registry))
 	for _, p := range registry {
 		plugins = append(plugins, p)
 	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name() < plugins[j].Name() })
 	return plugins
 }
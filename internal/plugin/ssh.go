@@ -0,0 +1,162 @@
+// Package plugin provides the ssh plugin, which adds the host aliases of
+// ~/.ssh/config and the names in ~/.ssh/known_hosts to the context, so
+// requests like "copy the build to the staging box" get the right scp or
+// rsync target. Only names are added, never addresses, users or keys.
+package plugin
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+func init() {
+	Register(sshPlugin{})
+}
+
+// Most host names of each kind added to the context.
+const (
+	maxSSHAliases    = 40
+	maxSSHKnownHosts = 20
+)
+
+// maxSSHIncludeDepth bounds how deeply Include directives are followed.
+const maxSSHIncludeDepth = 5
+
+type sshPlugin struct{}
+
+func (sshPlugin) Name() string { return "ssh" }
+
+// Gather adds the host aliases the user's ssh config defines and the other
+// hosts ssh knows by name.
+func (sshPlugin) Gather(ctx *context.Context) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	dir := filepath.Join(home, ".ssh")
+	aliases := sshAliases(dir, filepath.Join(dir, "config"), 0, nil)
+	if len(aliases) > 0 {
+		ctx.Extra["ssh host aliases"] = listNames(aliases, maxSSHAliases) + " (from ~/.ssh/config; use an alias as the host of ssh, scp and rsync, since it sets the address, user and key)"
+	}
+	var known []string
+	for _, name := range knownHosts(filepath.Join(dir, "known_hosts")) {
+		if !slices.Contains(aliases, name) && !slices.Contains(known, name) {
+			known = append(known, name)
+		}
+	}
+	if len(known) > 0 {
+		ctx.Extra["ssh known hosts"] = listNames(known, maxSSHKnownHosts)
+	}
+	return nil
+}
+
+// sshAliases returns the host aliases in an ssh config file and the files it
+// includes, without wildcard patterns, in the order they are defined.
+func sshAliases(dir, path string, depth int, aliases []string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return aliases
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		keyword, args := sshDirective(scanner.Text())
+		switch keyword {
+		case "host":
+			for _, name := range args {
+				if !strings.ContainsAny(name, "*?!") && !slices.Contains(aliases, name) {
+					aliases = append(aliases, name)
+				}
+			}
+		case "include":
+			if depth >= maxSSHIncludeDepth {
+				continue
+			}
+			for _, pattern := range args {
+				pattern = expandHome(pattern)
+				if !filepath.IsAbs(pattern) {
+					// Relative includes are relative to ~/.ssh
+					pattern = filepath.Join(dir, pattern)
+				}
+				matches, _ := filepath.Glob(pattern)
+				for _, match := range matches {
+					aliases = sshAliases(dir, match, depth+1, aliases)
+				}
+			}
+		}
+	}
+	return aliases
+}
+
+// sshDirective splits a line of ssh config into its lower-cased keyword and
+// arguments; the keyword may be separated from them by spaces or "=".
+func sshDirective(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+	keyword, rest, _ := strings.Cut(line, " ")
+	if k, v, ok := strings.Cut(keyword, "="); ok {
+		keyword, rest = k, v+" "+rest
+	}
+	rest = strings.TrimPrefix(strings.TrimSpace(rest), "=")
+	var args []string
+	for _, arg := range strings.Fields(rest) {
+		args = append(args, strings.Trim(arg, `"`))
+	}
+	return strings.ToLower(keyword), args
+}
+
+// knownHosts returns the host names in a known_hosts file. Hashed entries,
+// markers such as @cert-authority and bare IP addresses are skipped.
+func knownHosts(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var names []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "@") || strings.HasPrefix(fields[0], "|") {
+			continue
+		}
+		for _, host := range strings.Split(fields[0], ",") {
+			// [host]:port is a host on a non-standard port
+			if strings.HasPrefix(host, "[") {
+				host, _, _ = strings.Cut(strings.TrimPrefix(host, "["), "]")
+			}
+			if host == "" || net.ParseIP(host) != nil || strings.ContainsAny(host, "*?!") || slices.Contains(names, host) {
+				continue
+			}
+			names = append(names, host)
+		}
+	}
+	return names
+}
+
+// listNames joins names with commas, up to limit of them.
+func listNames(names []string, limit int) string {
+	if len(names) <= limit {
+		return strings.Join(names, ", ")
+	}
+	return strings.Join(names[:limit], ", ") + ", ..."
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
@@ -0,0 +1,324 @@
+// Package plugin provides the workspace plugin, which adds the monorepo
+// workspace the working directory is in and the package it belongs to, so
+// build and test commands target that package rather than the whole tree.
+package plugin
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+func init() {
+	Register(workspacePlugin{})
+}
+
+// workspace is a monorepo layout found at root.
+type workspace struct {
+	kind     string   // e.g. "Go workspace"
+	file     string   // the file that declares it, e.g. go.work
+	manifest string   // the file each member has, e.g. go.mod
+	members  []string // member directories or glob patterns, relative to root
+	exclude  []string
+	root     string
+}
+
+type workspacePlugin struct{}
+
+func (workspacePlugin) Name() string { return "workspace" }
+
+// Gather adds the nearest workspace above the working directory and the
+// member the working directory is in, with how to target that member.
+func (workspacePlugin) Gather(ctx *context.Context) error {
+	w, ok := findWorkspace(ctx.WorkingDir)
+	if !ok {
+		return nil
+	}
+	ctx.Extra["workspace"] = w.kind + " rooted at " + w.root + " (" + w.file + ")"
+
+	dir, rel, ok := w.memberOf(ctx.WorkingDir)
+	if !ok {
+		if filepath.Clean(ctx.WorkingDir) == w.root {
+			ctx.Extra["workspace package"] = "none, at the workspace root; commands run here apply to every package"
+		} else {
+			ctx.Extra["workspace package"] = "none, the working directory is not in a member of the workspace"
+		}
+		return nil
+	}
+	name := w.packageName(dir)
+	desc := "./" + rel
+	if name != "" {
+		desc = name + " in ./" + rel
+	}
+	if hint := w.target(name, rel); hint != "" {
+		desc += "; " + hint
+	}
+	ctx.Extra["workspace package"] = desc
+	return nil
+}
+
+// findWorkspace looks for a workspace in dir and its parents.
+func findWorkspace(dir string) (workspace, bool) {
+	dir = filepath.Clean(dir)
+	for {
+		if w, ok := readWorkspace(dir); ok {
+			return w, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return workspace{}, false
+		}
+		dir = parent
+	}
+}
+
+// readWorkspace returns the workspace declared in dir, if any.
+func readWorkspace(dir string) (workspace, bool) {
+	if data, err := os.ReadFile(filepath.Join(dir, "go.work")); err == nil {
+		return workspace{kind: "Go workspace", file: "go.work", manifest: "go.mod", members: goWorkUses(string(data)), root: dir}, true
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "pnpm-workspace.yaml")); err == nil {
+		var spec struct {
+			Packages []string `yaml:"packages"`
+		}
+		if yaml.Unmarshal(data, &spec) == nil {
+			members, exclude := splitExcludes(spec.Packages)
+			return workspace{kind: "pnpm workspace", file: "pnpm-workspace.yaml", manifest: "package.json", members: members, exclude: exclude, root: dir}, true
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "Cargo.toml")); err == nil {
+		if table := tomlTable(string(data), "workspace"); table != "" {
+			return workspace{kind: "Cargo workspace", file: "Cargo.toml", manifest: "Cargo.toml", members: tomlArray(table, "members"), exclude: tomlArray(table, "exclude"), root: dir}, true
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		if patterns := packageWorkspaces(data); len(patterns) > 0 {
+			kind := "npm workspace"
+			if _, err := os.Stat(filepath.Join(dir, "yarn.lock")); err == nil {
+				kind = "Yarn workspace"
+			}
+			members, exclude := splitExcludes(patterns)
+			return workspace{kind: kind, file: "package.json", manifest: "package.json", members: members, exclude: exclude, root: dir}, true
+		}
+	}
+	return workspace{}, false
+}
+
+// memberOf returns the directory of the member that contains dir, and its
+// path relative to the workspace root.
+func (w workspace) memberOf(dir string) (string, string, bool) {
+	for dir = filepath.Clean(dir); dir != w.root; dir = filepath.Dir(dir) {
+		if !strings.HasPrefix(dir, w.root+string(filepath.Separator)) {
+			return "", "", false
+		}
+		if _, err := os.Stat(filepath.Join(dir, w.manifest)); err != nil {
+			continue
+		}
+		rel, _ := filepath.Rel(w.root, dir)
+		rel = filepath.ToSlash(rel)
+		if matchAny(w.members, rel) && !matchAny(w.exclude, rel) {
+			return dir, rel, true
+		}
+	}
+	return "", "", false
+}
+
+// packageName reads the name of the member in dir from its manifest.
+func (w workspace) packageName(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, w.manifest))
+	if err != nil {
+		return ""
+	}
+	switch w.manifest {
+	case "go.mod":
+		if m := goModule.FindSubmatch(data); m != nil {
+			return string(m[1])
+		}
+	case "package.json":
+		var pkg struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			return pkg.Name
+		}
+	case "Cargo.toml":
+		if m := tomlName.FindStringSubmatch(tomlTable(string(data), "package")); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// target describes how to build or test only the member from the workspace root.
+func (w workspace) target(name, rel string) string {
+	switch w.kind {
+	case "Go workspace":
+		if name != "" {
+			return "target it with go build/test " + name + "/..."
+		}
+		return "target it with go build/test ./" + rel + "/..."
+	case "pnpm workspace":
+		if name != "" {
+			return "target it with pnpm --filter " + name
+		}
+	case "Cargo workspace":
+		if name != "" {
+			return "target it with cargo build/test -p " + name
+		}
+	case "npm workspace":
+		return "target it with npm --workspace " + rel
+	case "Yarn workspace":
+		if name != "" {
+			return "target it with yarn workspace " + name
+		}
+	}
+	return ""
+}
+
+var (
+	goModule = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)`)
+	tomlName = regexp.MustCompile(`(?m)^\s*name\s*=\s*"([^"]+)"`)
+	tomlStr  = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+)
+
+// goWorkUses returns the directories of the use directives of a go.work file,
+// both the single-line and the block form.
+func goWorkUses(text string) []string {
+	var dirs []string
+	inBlock := false
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			dirs = append(dirs, cleanMember(strings.Trim(fields[0], `"`)))
+		case fields[0] == "use" && len(fields) > 1 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "use" && len(fields) > 1:
+			dirs = append(dirs, cleanMember(strings.Trim(fields[1], `"`)))
+		}
+	}
+	return dirs
+}
+
+// tomlTable returns the body of a [name] table of a TOML file, or "" if it
+// has none. Only what this plugin needs is handled.
+func tomlTable(text, name string) string {
+	var body strings.Builder
+	in, found := false, false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			in = trimmed == "["+name+"]"
+			found = found || in
+			continue
+		}
+		if in {
+			body.WriteString(line + "\n")
+		}
+	}
+	if !found {
+		return ""
+	}
+	// An empty [workspace] table still declares a workspace
+	return body.String() + "\n"
+}
+
+// tomlArray returns the strings of a key's array in a TOML table body, which
+// may span several lines.
+func tomlArray(table, key string) []string {
+	m := regexp.MustCompile(`(?m)^\s*` + regexp.QuoteMeta(key) + `\s*=\s*\[([^\]]*)\]`).FindStringSubmatch(table)
+	if m == nil {
+		return nil
+	}
+	var values []string
+	for _, s := range tomlStr.FindAllStringSubmatch(m[1], -1) {
+		values = append(values, cleanMember(s[1]+s[2]))
+	}
+	return values
+}
+
+// packageWorkspaces returns the workspaces of a package.json, given either as
+// an array or as {"packages": [...]}.
+func packageWorkspaces(data []byte) []string {
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if json.Unmarshal(data, &pkg) != nil || len(pkg.Workspaces) == 0 {
+		return nil
+	}
+	var patterns []string
+	if json.Unmarshal(pkg.Workspaces, &patterns) == nil {
+		return patterns
+	}
+	var nested struct {
+		Packages []string `json:"packages"`
+	}
+	if json.Unmarshal(pkg.Workspaces, &nested) == nil {
+		return nested.Packages
+	}
+	return nil
+}
+
+// splitExcludes separates patterns negated with ! from the others.
+func splitExcludes(patterns []string) (members, exclude []string) {
+	for _, p := range patterns {
+		if rest, ok := strings.CutPrefix(p, "!"); ok {
+			exclude = append(exclude, cleanMember(rest))
+		} else {
+			members = append(members, cleanMember(p))
+		}
+	}
+	return members, exclude
+}
+
+// cleanMember normalises a member path or pattern to the slash-separated form
+// relative to the workspace root that matchGlob expects.
+func cleanMember(p string) string {
+	return path.Clean("/" + filepath.ToSlash(p))[1:]
+}
+
+// matchAny reports whether rel matches one of the patterns.
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if matchGlob(strings.Split(p, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches path segments against pattern segments, where a ** segment
+// matches any number of segments.
+func matchGlob(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlob(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+		return false
+	}
+	return matchGlob(pattern[1:], segments[1:])
+}
@@ -0,0 +1,26 @@
+// Package prompt provides the prompt used to generate shell aliases and functions.
+package prompt
+
+import (
+	"fmt"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// BuildAliasPrompt constructs a prompt asking the LLM for a reusable alias or function.
+func BuildAliasPrompt(ctx *context.Context, description, name, shellName string) string {
+	naming := "Choose a short, memorable, lower-case name that does not shadow a common command."
+	if name != "" {
+		naming = fmt.Sprintf("The alias or function must be named `%s`.", name)
+	}
+	return fmt.Sprintf(
+		"Write a reusable %s shell alias or function for the description below.\n"+
+			"Use an alias when a fixed command suffices; use a function when it needs arguments (\"$1\", \"$@\" or $argv in fish) or multiple steps.\n"+
+			"%s\n"+
+			"Return ONLY the definition, ready to paste into the shell's rc file, with at most one short comment line above it. Do not use markdown code blocks.\n\n"+
+			"Working Directory: %s\n"+
+			"Description: %s\n"+
+			"Definition:",
+		shellName, naming, ctx.WorkingDir, description,
+	)
+}
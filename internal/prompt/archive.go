@@ -0,0 +1,140 @@
+// Package prompt gathers facts for archive requests: the size of what is to
+// be archived and the compressors installed, so tar commands pick a tool and
+// level that suit the data.
+package prompt
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// Limits on measuring the size of an archive's targets, which may be large trees.
+const (
+	maxMeasureFiles = 200000
+	maxMeasureTime  = 500 * time.Millisecond
+	maxTargets      = 5
+)
+
+// compressors are the archive and compression tools looked for, parallel
+// variants after the tool they speed up.
+var compressors = []string{"zstd", "pzstd", "gzip", "pigz", "xz", "pixz", "bzip2", "pbzip2", "lz4", "zip", "7z"}
+
+var (
+	archiveFactsMu    sync.Mutex
+	archiveFactsCache = map[string]string{} // by working directory and request, since prompts are built more than once to fit
+)
+
+// archiveFacts returns the size of the files and directories the request
+// names, or of the working directory when it names none, the compressors
+// installed and the number of CPU cores.
+func archiveFacts(ctx *context.Context, request string) string {
+	key := ctx.WorkingDir + "\x00" + request
+	archiveFactsMu.Lock()
+	defer archiveFactsMu.Unlock()
+	if facts, ok := archiveFactsCache[key]; ok {
+		return facts
+	}
+
+	var b strings.Builder
+	b.WriteString("Facts for archiving:\n")
+	targets := archiveTargets(ctx.WorkingDir, request)
+	if len(targets) == 0 && ctx.WorkingDir != "" {
+		targets = []string{"."}
+	}
+	for _, target := range targets {
+		size, files, complete := measure(filepath.Join(ctx.WorkingDir, target))
+		at := ""
+		if !complete {
+			at = "at least "
+		}
+		name := target
+		if target == "." {
+			name = "the working directory"
+		}
+		fmt.Fprintf(&b, "- Size of %s: %s%s in %d files\n", name, at, formatSize(size), files)
+	}
+	var installed, missing []string
+	for _, tool := range compressors {
+		if _, err := exec.LookPath(tool); err == nil {
+			installed = append(installed, tool)
+		} else {
+			missing = append(missing, tool)
+		}
+	}
+	fmt.Fprintf(&b, "- Installed: %s; not installed: %s\n", listOrNone(installed), listOrNone(missing))
+	fmt.Fprintf(&b, "- CPU cores: %d\n", runtime.NumCPU())
+	archiveFactsCache[key] = b.String()
+	return b.String()
+}
+
+// archiveTargets returns the words of the request that name files or
+// directories in dir.
+func archiveTargets(dir, request string) []string {
+	var targets []string
+	for _, word := range strings.Fields(request) {
+		word = strings.Trim(word, "\"'`,;:()")
+		word = strings.TrimSuffix(word, ".")
+		if word == "" || word == "." || word == ".." || len(targets) == maxTargets {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, word)); err == nil {
+			targets = append(targets, word)
+		}
+	}
+	return targets
+}
+
+// measure returns the total size and number of regular files under path,
+// and whether it counted them all within the limits.
+func measure(path string) (size int64, files int, complete bool) {
+	deadline := time.Now().Add(maxMeasureTime)
+	complete = true
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if files >= maxMeasureFiles || files%1000 == 0 && time.Now().After(deadline) {
+			complete = false
+			return filepath.SkipAll
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+				files++
+			}
+		}
+		return nil
+	})
+	return size, files, complete
+}
+
+// formatSize formats a number of bytes with a binary unit, e.g. 2.3 GiB.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// listOrNone joins names with commas, or returns "none".
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
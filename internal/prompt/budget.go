@@ -0,0 +1,54 @@
+// Package prompt sizes the context sections of prompts to the model's context window.
+package prompt
+
+import "github.com/kanishka-sahoo/nlch/internal/models"
+
+// Budget limits how much of each kind of context goes into a prompt. The
+// token limits are for the section alone, not the whole prompt.
+type Budget struct {
+	Files          int // file names listed from the working directory
+	GitStatus      int // tokens of git status output
+	PreviousOutput int // tokens of a previous command's output, when following up on it
+	Instructions   int // tokens of the project's instructions
+	Diff           int // tokens of a staged diff to write a commit message for
+}
+
+// standardBudget is used for models with a context window of at least standardWindow tokens.
+var standardBudget = Budget{
+	Files:          20,
+	GitStatus:      400,
+	PreviousOutput: 500,
+	Instructions:   1000,
+	Diff:           6000,
+}
+
+const standardWindow = 32768
+
+// BudgetFor returns the context budget for a model. Models with smaller
+// context windows get proportionally less context, but never less than a
+// quarter of the standard budget. An empty model, for prompts that are not
+// built for one in particular, gets the standard budget.
+func BudgetFor(model string) Budget {
+	if model == "" {
+		return standardBudget
+	}
+	window := models.For(model).ContextWindow
+	if window >= standardWindow {
+		return standardBudget
+	}
+	window = max(window, standardWindow/4)
+	scale := func(n int) int { return n * window / standardWindow }
+	return Budget{
+		Files:          scale(standardBudget.Files),
+		GitStatus:      scale(standardBudget.GitStatus),
+		PreviousOutput: scale(standardBudget.PreviousOutput),
+		Instructions:   scale(standardBudget.Instructions),
+		Diff:           scale(standardBudget.Diff),
+	}
+}
+
+// JSONCandidates reports whether several candidate commands are asked for as
+// a JSON object, which is done when the provider can hold the model to JSON.
+func JSONCandidates(opts Options) bool {
+	return opts.Candidates > 1 && opts.JSON
+}
@@ -3,20 +3,113 @@ package prompt
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/snippets"
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
 )
 
+// DefaultSystemPrompt is the base system prompt sent to every provider.
+const DefaultSystemPrompt = "You are a helpful assistant that generates safe, concise shell commands for the user's request."
+
+// Options controls optional sections of the generated prompt.
+type Options struct {
+	Packs         []string   // prompt packs to always include
+	DisabledPacks []string   // prompt packs to never include
+	Never         []string   // hard constraints the generated command must respect
+	Model         string     // model the prompt is built for, used for token budgeting
+	Candidates    int        // number of alternative commands to ask for (0 or 1 for a single command)
+	Lessons       []Lesson   // feedback on similar past requests
+	Previous      *Exchange  // the last request, when following up on it
+	Earlier       []Exchange // earlier requests of an interactive session, oldest first, before Previous
+	Images        int        // number of images attached to the request
+	Instructions  string     // the project's own instructions, from .nlch/instructions.md
+	ReadOnly      bool       // only commands that change nothing may be generated
+	Shell         string     // shell the command runs in, such as fish; bash if empty
+	JSON          bool       // the provider can hold the reply to a JSON schema, see provider.JSONMode
+}
+
+// Exchange is an earlier request and its outcome that a follow-up request may refer to.
+type Exchange struct {
+	Request  string
+	Command  string
+	Executed bool   // the command was run
+	ExitCode int    // exit status, if the command was run
+	Output   string // output, if it was run and recorded
+}
+
+// Lesson is feedback on a past request similar to the current one.
+type Lesson struct {
+	Request string
+	Bad     string // command that was rejected or failed
+	Note    string // the user's explanation, if any
+	Good    string // command that worked
+}
+
+// BuildSystemPrompt returns the system prompt, including any hard constraints
+// from config, the target shell's syntax and the project's instructions.
+func BuildSystemPrompt(opts Options) string {
+	system := DefaultSystemPrompt
+	if len(opts.Never) > 0 {
+		system += "\n\nHard constraints (these must never be violated, even if the user asks):\n"
+		for _, rule := range opts.Never {
+			rule = strings.TrimSpace(rule)
+			if !strings.HasPrefix(strings.ToLower(rule), "never") {
+				rule = "never " + rule
+			}
+			system += fmt.Sprintf("- %s\n", rule)
+		}
+		system = strings.TrimRight(system, "\n")
+	}
+	if opts.ReadOnly {
+		system += "\n\n" + readOnlyRule
+	}
+	if rule := dialectRule(opts.Shell); rule != "" {
+		system += "\n\n" + strings.TrimRight(rule, "\n")
+	}
+	return WithInstructions(system, opts.Instructions)
+}
+
+// readOnlyRule restricts the generated commands in read-only mode.
+const readOnlyRule = "Read-only mode: only generate commands that inspect the system, such as listing, reading, searching or showing status. " +
+	"Never generate a command that creates, modifies, moves or deletes files, changes configuration, installs software, starts or stops services or processes, or changes any other state. " +
+	"If the request cannot be done without changing something, generate a read-only command that shows what would be affected instead."
+
+// WithInstructions appends a project's instructions to a system prompt.
+func WithInstructions(system, instructions string) string {
+	if instructions == "" {
+		return system
+	}
+	return system + "\n\nProject instructions from the team working in this directory (follow them unless they conflict with the hard constraints):\n" + instructions
+}
+
 // BuildPrompt constructs a structured prompt for the LLM using context and user input.
-func BuildPrompt(ctx *context.Context, userInput string) string {
+func BuildPrompt(ctx *context.Context, userInput string, opts Options) string {
+	return buildPrompt(ctx, userInput, opts, nil)
+}
+
+// buildPrompt constructs the prompt, leaving out the context sections in omit.
+func buildPrompt(ctx *context.Context, userInput string, opts Options, omit map[Section]bool) string {
+	budget := BudgetFor(opts.Model)
+
 	// Format file list (truncate if too long)
-	maxFiles := 20
+	maxFiles := budget.Files
 	files := ctx.Files
 	fileList := ""
-	if len(files) > 0 {
-		if len(files) > maxFiles {
-			files = files[:maxFiles]
-			fileList = fmt.Sprintf("%v ... (and %d more)", files, len(ctx.Files)-maxFiles)
+	if omit[SectionFiles] {
+		fileList = "(not listed)"
+	} else if len(files) > 0 {
+		total := max(ctx.FileCount, len(files))
+		files = quoteNames(files[:min(maxFiles, len(files))])
+		if total > maxFiles {
+			more := "and"
+			if ctx.FileCount >= context.MaxCounted {
+				more = "and at least"
+			}
+			fileList = fmt.Sprintf("%v ... (%s %d more)", files, more, total-len(files))
 		} else {
 			fileList = fmt.Sprintf("%v", files)
 		}
@@ -24,27 +117,152 @@ func BuildPrompt(ctx *context.Context, userInput string) string {
 		fileList = "(none)"
 	}
 
+	if note := quotingNote(ctx); note != "" && !omit[SectionFiles] {
+		fileList += "\n" + note
+	}
+
 	// Format git info
 	gitInfo := ""
 	if branch, ok := ctx.GitInfo["branch"]; ok && branch != "" {
 		gitInfo += fmt.Sprintf("Branch: %s\n", branch)
 	}
-	if status, ok := ctx.GitInfo["status"]; ok && status != "" {
+	if status, ok := ctx.GitInfo["status"]; ok && status != "" && !omit[SectionGitStatus] {
+		if truncated, cut := tokens.Truncate(opts.Model, status, budget.GitStatus); cut {
+			status = truncated + "\n... (truncated)"
+		}
 		gitInfo += fmt.Sprintf("Status:\n%s\n", status)
 	}
+	if tracked := ctx.GitInfo["status_skipped"]; tracked != "" && !omit[SectionGitStatus] {
+		gitInfo += fmt.Sprintf("Status: not gathered, the repository tracks %s files\n", tracked)
+	}
 	if gitInfo == "" {
 		gitInfo = "No git repository detected.\n"
 	}
 
+	// Format the locale and timezone, which date and number formats depend on
+	locale := ""
+	if ctx.Locale != "" {
+		locale += fmt.Sprintf("Locale: %s\n", ctx.Locale)
+	}
+	if ctx.Timezone != "" {
+		locale += fmt.Sprintf("Timezone: %s\n", ctx.Timezone)
+	}
+	if locale != "" {
+		locale += "When the request involves dates, times or numbers, use formats and times that match this locale and timezone unless the user asks otherwise.\n"
+	}
+
 	// Format plugin extras
 	extras := ""
-	if len(ctx.Extra) > 0 {
+	if len(ctx.Extra) > 0 && !omit[SectionPlugins] {
 		extras += "Additional context:\n"
-		for k, v := range ctx.Extra {
-			extras += fmt.Sprintf("- %s: %v\n", k, v)
+		keys := make([]string, 0, len(ctx.Extra))
+		for k := range ctx.Extra {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			extras += fmt.Sprintf("- %s: %v\n", k, ctx.Extra[k])
+		}
+	}
+
+	// Format domain prompt packs
+	guidance := ""
+	if !omit[SectionPacks] {
+		for _, p := range ActivePacks(ctx, userInput, opts.Packs, opts.DisabledPacks) {
+			guidance += p.format()
+			if p.Facts != nil {
+				guidance += p.Facts(ctx, userInput)
+			}
+			guidance += "\n"
 		}
 	}
 
+	// Format feedback from similar past requests
+	lessons := opts.Lessons
+	if omit[SectionLessons] {
+		lessons = nil
+	}
+	for i, l := range lessons {
+		if i == 0 {
+			guidance += "Feedback on similar past requests:\n"
+		}
+		guidance += "- Request: " + l.Request + "\n"
+		if l.Bad != "" {
+			guidance += "  Rejected: " + l.Bad + "\n"
+		}
+		if l.Note != "" {
+			guidance += "  User note: " + l.Note + "\n"
+		}
+		if l.Good != "" {
+			guidance += "  Worked: " + l.Good + "\n"
+		}
+		if i == len(lessons)-1 {
+			guidance += "\n"
+		}
+	}
+
+	// Summarize the earlier requests of an interactive session
+	previous := ""
+	if len(opts.Earlier) > 0 && !omit[SectionEarlier] {
+		previous = "Earlier requests in this session, oldest first:\n"
+		for _, x := range opts.Earlier {
+			outcome := "not run"
+			if x.Executed {
+				outcome = fmt.Sprintf("exit status %d", x.ExitCode)
+			}
+			previous += fmt.Sprintf("- %s\n  Command: %s (%s)\n", x.Request, x.Command, outcome)
+		}
+		previous += "\n"
+	}
+
+	// Format the exchange a follow-up request refers to
+	if p := opts.Previous; p != nil {
+		previous += fmt.Sprintf("Previous request: %s\nPrevious command: %s\n", p.Request, p.Command)
+		switch {
+		case !p.Executed:
+			previous += "The previous command was not run.\n"
+		case p.Output == "":
+			previous += fmt.Sprintf("It exited with status %d; its output was not recorded.\n", p.ExitCode)
+		case omit[SectionPreviousOutput]:
+			previous += fmt.Sprintf("It exited with status %d; its output is left out for length.\n", p.ExitCode)
+		default:
+			output := strings.TrimRight(p.Output, "\n")
+			if truncated, cut := tokens.Truncate(opts.Model, output, budget.PreviousOutput); cut {
+				output = truncated + "\n... (truncated)"
+			}
+			previous += fmt.Sprintf("It exited with status %d and printed:\n%s\n", p.ExitCode, output)
+		}
+		previous += "The user's request is a follow-up to this and may refer to it.\n\n"
+	}
+
+	// Point the model at attached images, which are sent alongside the prompt
+	attached := ""
+	switch {
+	case opts.Images == 1:
+		attached = "The user attached an image, such as a screenshot of an error or a terminal. Use what it shows to understand the request.\n"
+	case opts.Images > 1:
+		attached = fmt.Sprintf("The user attached %d images, such as screenshots of an error or a terminal. Use what they show to understand the request.\n", opts.Images)
+	}
+
+	// Ask for a single command, or for several alternatives
+	answer := "Shell Command:"
+	switch {
+	case JSONCandidates(opts):
+		answer = fmt.Sprintf(
+			"Provide %d genuinely different commands that each accomplish the request, as a JSON object in the format:\n"+
+				`{"commands": [{"command": "<command>", "description": "<brief description of the approach>"}]}`+"\n"+
+				"Write 'danger: ' before a command inside its string if it is potentially dangerous and destructive.\n"+
+				"JSON:",
+			opts.Candidates)
+	case opts.Candidates > 1:
+		answer = fmt.Sprintf(
+			"Provide %d genuinely different commands that each accomplish the request, one per line, in the format:\n"+
+				"<command> %s <brief description of the approach>\n"+
+				"Do not number the lines.\n"+
+				"Shell Commands:",
+			opts.Candidates, CandidateSeparator)
+	}
+
 	return fmt.Sprintf(
 		"You are an expert terminal assistant. Given the following project context, generate a smart, concise shell command for the user's request. Do not wrap your command in code blocks, provide it directly.\n\n"+
 			"When running commands such as `ls`, make sure to pick flags to make it user-friendly. Avoid confusing the user with too much information.\n\n"+
@@ -53,8 +271,61 @@ func BuildPrompt(ctx *context.Context, userInput string) string {
 			"Files: %s\n"+
 			"Git Info:\n%s"+
 			"%s"+
+			"%s"+
+			"%s"+
+			"%s"+
+			"%s"+
 			"User Request: %s\n"+
+			"%s",
+		ctx.WorkingDir, fileList, gitInfo, locale, extras, guidance, previous, attached, userInput, answer,
+	)
+}
+
+// quoteNames returns names with those the shell would split or expand in
+// quotes, so that a list of them shows where each name ends.
+func quoteNames(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = name
+		if shell.NeedsQuoting(name) {
+			quoted[i] = snippets.Quote(name)
+		}
+	}
+	return quoted
+}
+
+// quotingNote tells the model to quote paths when the working directory or
+// any file in it has a name the shell would split or expand, naming a few.
+func quotingNote(ctx *context.Context) string {
+	var names []string
+	if shell.NeedsQuoting(ctx.WorkingDir) {
+		names = append(names, "the working directory")
+	}
+	special := 0
+	for _, name := range ctx.Files {
+		if shell.NeedsQuoting(name) {
+			if special < 3 {
+				names = append(names, snippets.Quote(name))
+			}
+			special++
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	if special > 3 {
+		names = append(names, fmt.Sprintf("%d more files", special-3))
+	}
+	return fmt.Sprintf("Some paths contain spaces, quotes or other characters special to the shell (%s). Quote every path in the command, in single quotes unless it needs expanding, so that each reaches the program as one word.", strings.Join(names, ", "))
+}
+
+// BuildRefinePrompt asks the LLM to adjust its previous command according to the user's feedback.
+func BuildRefinePrompt(refinement string) string {
+	return fmt.Sprintf(
+		"Adjust the previous command according to this request: %s\n"+
+			"Keep everything else about the command the same. If the adjusted command is potentially dangerous and destructive, write 'danger: ' before it.\n"+
+			"Return ONLY the shell command, nothing else. Do not use markdown code blocks.\n"+
 			"Shell Command:",
-		ctx.WorkingDir, fileList, gitInfo, extras, userInput,
+		refinement,
 	)
 }
@@ -0,0 +1,108 @@
+// Package prompt provides parsing for responses containing several candidate commands.
+package prompt
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// CandidateSeparator separates a candidate command from its description.
+const CandidateSeparator = "##"
+
+// Candidate is one of several alternative commands proposed by the LLM.
+type Candidate struct {
+	Command     string
+	Description string
+}
+
+// ParseCandidates extracts candidate commands from a response, either a JSON
+// object as asked of models with a JSON mode or one command per line.
+// Lines that are empty, code fences or duplicates are skipped.
+func ParseCandidates(response string) []Candidate {
+	if candidates, ok := parseJSONCandidates(response); ok {
+		return candidates
+	}
+	var candidates []Candidate
+	seen := map[string]bool{}
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+		// Drop list markers the model may add despite instructions
+		line = strings.TrimLeft(line, "-*• ")
+		if i := strings.IndexAny(line, ".)"); i > 0 && i <= 2 && isDigits(line[:i]) {
+			line = strings.TrimSpace(line[i+1:])
+		}
+
+		cmd, desc, _ := strings.Cut(line, CandidateSeparator)
+		cmd = strings.Trim(strings.TrimSpace(cmd), "`")
+		if cmd == "" || seen[cmd] {
+			continue
+		}
+		seen[cmd] = true
+		candidates = append(candidates, Candidate{
+			Command:     cmd,
+			Description: strings.TrimSpace(desc),
+		})
+	}
+	return candidates
+}
+
+// CandidatesSchema returns the JSON schema of the reply with several
+// candidates, for providers that hold the model to one.
+func CandidatesSchema() map[string]any {
+	candidate := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"command":     map[string]any{"type": "string"},
+			"description": map[string]any{"type": "string"},
+		},
+		"required":             []string{"command", "description"},
+		"additionalProperties": false,
+	}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"commands": map[string]any{"type": "array", "items": candidate},
+		},
+		"required":             []string{"commands"},
+		"additionalProperties": false,
+	}
+}
+
+// parseJSONCandidates extracts candidate commands from a JSON response,
+// reporting false if the response is not the JSON object that was asked for.
+func parseJSONCandidates(response string) ([]Candidate, bool) {
+	response = strings.TrimSpace(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.Trim(response, "`\n ")
+	var reply struct {
+		Commands []Candidate `json:"commands"`
+	}
+	if err := json.Unmarshal([]byte(response), &reply); err != nil || len(reply.Commands) == 0 {
+		return nil, false
+	}
+	var candidates []Candidate
+	seen := map[string]bool{}
+	for _, c := range reply.Commands {
+		c.Command = strings.TrimSpace(c.Command)
+		c.Description = strings.TrimSpace(c.Description)
+		if c.Command == "" || seen[c.Command] {
+			continue
+		}
+		seen[c.Command] = true
+		candidates = append(candidates, c)
+	}
+	return candidates, true
+}
+
+// isDigits reports whether s consists only of ASCII digits.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
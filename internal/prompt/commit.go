@@ -0,0 +1,48 @@
+// Package prompt provides the prompts used to write git commit messages.
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CommitSystemPrompt is the system prompt used when writing a commit message.
+const CommitSystemPrompt = "You are an expert software engineer who writes clear, accurate git commit messages following the Conventional Commits specification."
+
+// BuildCommitPrompt constructs a prompt asking the LLM for a commit message
+// describing a staged diff. recent holds subjects of earlier commits, so the
+// message can follow the repository's habits, and hint is the user's own
+// description of the change, if any.
+func BuildCommitPrompt(stat, diff string, truncated bool, recent []string, hint string) string {
+	var b strings.Builder
+	b.WriteString("Write a commit message for the staged changes below.\n" +
+		"Requirements:\n" +
+		"- The first line is `type(scope): summary`, where type is one of feat, fix, docs, style, refactor, perf, test, build, ci or chore, the scope is optional, and the whole line is at most 72 characters, in the imperative mood and without a trailing period.\n" +
+		"- Add `!` after the type or scope if the change breaks compatibility.\n" +
+		"- If the summary does not say everything important, add a blank line and a short body, wrapped at 72 characters, explaining what changed and why.\n" +
+		"Return ONLY the commit message, without markdown code blocks or any text before or after it.\n\n")
+	if hint != "" {
+		fmt.Fprintf(&b, "The author describes the change as: %s\n\n", hint)
+	}
+	if len(recent) > 0 {
+		fmt.Fprintf(&b, "Recent commit messages in this repository:\n%s\n\n", strings.Join(recent, "\n"))
+	}
+	fmt.Fprintf(&b, "Changed files:\n%s\n\n", stat)
+	if truncated {
+		b.WriteString("Staged diff (truncated, describe the changes from the file list as well):\n")
+	} else {
+		b.WriteString("Staged diff:\n")
+	}
+	b.WriteString(diff)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// BuildCommitRefinePrompt asks the LLM to revise its previous commit message according to the user's feedback.
+func BuildCommitRefinePrompt(refinement string) string {
+	return fmt.Sprintf(
+		"Revise the previous commit message according to this request: %s\n"+
+			"Keep following the same format. Return ONLY the commit message, nothing else.",
+		refinement,
+	)
+}
@@ -0,0 +1,55 @@
+// Package prompt provides the guidance for generating commands in shells
+// other than bash, whose syntax the model would otherwise get wrong.
+package prompt
+
+import "strings"
+
+// dialects holds the guidance for the non-POSIX target shells, keyed by the
+// shell's name. Unlike prompt packs it goes in the system prompt, so it
+// applies to follow-ups and corrections too.
+var dialects = map[string]*Pack{
+	"fish": {
+		Name: "fish",
+		Instructions: "The command runs in fish, not bash: write fish syntax. " +
+			"Use `set -x NAME value` instead of `export NAME=value` and `set name value` instead of `name=value`; " +
+			"`(cmd)` for command substitution, not `$(cmd)` or backticks; `; and`/`; or` or `&&`/`||`, and `not` instead of `!`; " +
+			"`for x in ...; ...; end`, `if ...; ...; end` and `while ...; ...; end` instead of do/done, then/fi. " +
+			"There are no heredocs, `[[ ]]` or `$((...))`: use `printf`, `test` and `math`. " +
+			"Variables are lists and are not word-split, so they need no quoting. Translate the bash in the guidance for other tools into fish.",
+		Examples: []Example{
+			{"set EDITOR to vim for this session", "set -x EDITOR vim"},
+			{"rename every .txt file here to .md", "for f in *.txt; mv $f (basename $f .txt).md; end"},
+			{"run the tests and print ok if they pass", "make test; and echo ok"},
+			{"add 40 and 2", "math 40 + 2"},
+		},
+	},
+	"nu": {
+		Name: "nushell",
+		Instructions: "The command runs in nushell (nu), not bash: write nu syntax. " +
+			"Builtins such as `ls`, `ps`, `open` and `sys` return tables, so filter and shape them with `where`, `select`, `sort-by`, `get` and `first` rather than grep, awk, cut or head. " +
+			"Set environment variables with `$env.NAME = value`, substitute commands with `(cmd)`, and separate commands with `;`, or `and`/`or` for conditions: there is no `&&`. " +
+			"Prefix a program with `^` to run it instead of the builtin of the same name, such as `^ls`. " +
+			"There are no heredocs, `$(...)`, `[[ ]]` or `export`. Translate the bash in the guidance for other tools into nu.",
+		Examples: []Example{
+			{"list files larger than 10 MB, biggest first", "ls | where size > 10mb | sort-by size --reverse"},
+			{"show the 5 processes using the most memory", "ps | sort-by mem --reverse | first 5"},
+			{"set the API_URL environment variable to localhost:8080", "$env.API_URL = 'localhost:8080'"},
+			{"count the lines in main.go", "open main.go --raw | lines | length"},
+		},
+	},
+}
+
+// dialectRule returns the system prompt section for the target shell, or ""
+// for bash and the other POSIX shells, which need none.
+func dialectRule(shellName string) string {
+	d, ok := dialects[shellName]
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Target shell: " + d.Name + "\n" + d.Instructions + "\nExamples:\n")
+	for _, ex := range d.Examples {
+		b.WriteString("- Request: " + ex.Request + "\n  Command: " + ex.Command + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,27 @@
+// Package prompt provides the prompt used to explain existing shell commands.
+package prompt
+
+import (
+	"fmt"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// ExplainSystemPrompt is the system prompt used when explaining a command.
+const ExplainSystemPrompt = "You are an expert terminal assistant who explains shell commands precisely and concisely in plain English."
+
+// BuildExplainPrompt constructs a prompt asking the LLM to explain an existing shell command.
+func BuildExplainPrompt(ctx *context.Context, command string) string {
+	return fmt.Sprintf(
+		"Explain the following shell command in plain English for someone who has not seen it before.\n\n"+
+			"Use exactly this structure and plain text (no markdown headers, no code blocks):\n"+
+			"Summary: <one sentence describing what the whole command does>\n"+
+			"Parts:\n"+
+			"- <part> : <what this program, flag, argument, pipe or redirection does>\n"+
+			"Effects: <files or system state that are read, modified or deleted, or 'None' if read-only>\n"+
+			"Risk: <low, medium or high, with a short reason>\n\n"+
+			"Working Directory: %s\n"+
+			"Command: %s\n",
+		ctx.WorkingDir, command,
+	)
+}
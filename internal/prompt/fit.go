@@ -0,0 +1,112 @@
+// Package prompt fits prompts into the context window of the model they are sent to.
+package prompt
+
+import (
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/models"
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
+)
+
+// Section is a part of the context that may be left out of a prompt to fit it
+// into a model's context window.
+type Section string
+
+// Context sections, in the order they are left out: lowest priority first.
+const (
+	SectionPlugins        Section = "plugin context"
+	SectionLessons        Section = "feedback on past requests"
+	SectionEarlier        Section = "earlier session requests"
+	SectionPacks          Section = "prompt packs"
+	SectionFiles          Section = "file list"
+	SectionGitStatus      Section = "git status"
+	SectionPreviousOutput Section = "previous output"
+	SectionInstructions   Section = "project instructions"
+)
+
+var sectionPriority = []Section{
+	SectionPlugins,
+	SectionLessons,
+	SectionEarlier,
+	SectionPacks,
+	SectionFiles,
+	SectionGitStatus,
+	SectionPreviousOutput,
+	SectionInstructions,
+}
+
+// Fitted is a system prompt and prompt that fit into a model's context window.
+type Fitted struct {
+	System  string
+	Prompt  string
+	Tokens  int       // estimated tokens of the system prompt and prompt together
+	Window  int       // the model's context window, or 0 when there is no model to fit
+	Dropped []Section // sections left out to make them fit, in the order they were dropped
+}
+
+// Fits reports whether the prompts and a reply of up to reply tokens fit into the context window.
+func (f Fitted) Fits(reply int) bool {
+	return f.Window == 0 || f.Tokens+reply <= f.Window
+}
+
+// Fit builds the system prompt and prompt for a request, leaving out the
+// lowest-priority context sections one at a time until they fit into the
+// model's context window along with a reply of up to reply tokens. The
+// request itself, the hard constraints and the answer format are never left
+// out, so the result may still not fit. Without a model there is no window
+// to fit, and nothing is left out.
+func Fit(ctx *context.Context, userInput string, opts Options, reply int) Fitted {
+	omit := map[Section]bool{}
+	build := func() Fitted {
+		sysOpts := opts
+		if omit[SectionInstructions] {
+			sysOpts.Instructions = ""
+		}
+		f := Fitted{
+			System: BuildSystemPrompt(sysOpts),
+			Prompt: buildPrompt(ctx, userInput, opts, omit),
+		}
+		if opts.Model != "" {
+			f.Window = models.For(opts.Model).ContextWindow
+		}
+		f.Tokens = tokens.Estimate(opts.Model, f.System+"\n"+f.Prompt)
+		return f
+	}
+	f := build()
+	var dropped []Section
+	for _, s := range sectionPriority {
+		if f.Fits(reply) {
+			break
+		}
+		if !hasSection(ctx, userInput, opts, s) {
+			continue
+		}
+		omit[s] = true
+		dropped = append(dropped, s)
+		f = build()
+	}
+	f.Dropped = dropped
+	return f
+}
+
+// hasSection reports whether the prompt for a request includes a section.
+func hasSection(ctx *context.Context, userInput string, opts Options, s Section) bool {
+	switch s {
+	case SectionPlugins:
+		return len(ctx.Extra) > 0
+	case SectionLessons:
+		return len(opts.Lessons) > 0
+	case SectionEarlier:
+		return len(opts.Earlier) > 0
+	case SectionPacks:
+		return len(ActivePacks(ctx, userInput, opts.Packs, opts.DisabledPacks)) > 0
+	case SectionFiles:
+		return len(ctx.Files) > 0
+	case SectionGitStatus:
+		return ctx.GitInfo["status"] != ""
+	case SectionPreviousOutput:
+		return opts.Previous != nil && opts.Previous.Executed && opts.Previous.Output != ""
+	case SectionInstructions:
+		return opts.Instructions != ""
+	}
+	return false
+}
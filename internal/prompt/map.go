@@ -0,0 +1,47 @@
+// Package prompt provides the prompt used to build stdin filters.
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// MapSystemPrompt is the system prompt used when generating a filter for `nlch map`.
+const MapSystemPrompt = "You are an expert at processing text streams with standard Unix tools."
+
+// MapSystem returns the system prompt for a filter run by the target shell.
+func MapSystem(shellName string) string {
+	if rule := dialectRule(shellName); rule != "" {
+		return MapSystemPrompt + "\n\n" + strings.TrimRight(rule, "\n")
+	}
+	return MapSystemPrompt
+}
+
+// Maximum length of a sample line shown to the LLM.
+const maxSampleLine = 200
+
+// BuildMapPrompt asks the LLM for a one-line filter that transforms the records
+// read from stdin, showing it the first few records.
+func BuildMapPrompt(ctx *context.Context, description string, sample []string) string {
+	var lines strings.Builder
+	for _, line := range sample {
+		if len(line) > maxSampleLine {
+			line = line[:maxSampleLine] + "..."
+		}
+		lines.WriteString(line + "\n")
+	}
+	return fmt.Sprintf(
+		"Write a single shell command that reads records from standard input and writes the transformed records to standard output.\n"+
+			"Requirements:\n"+
+			"- Prefer sed, awk, jq, cut, tr, sort or xargs; use jq if the input is JSON.\n"+
+			"- Process every record of the input, not only the sample.\n"+
+			"- Do not modify any files; only read stdin and write stdout.\n"+
+			"Return ONLY the command on one line, without markdown code blocks.\n\n"+
+			"Working Directory: %s\n"+
+			"Sample input (the first %d records):\n%s\n"+
+			"Transformation: %s\n",
+		ctx.WorkingDir, len(sample), lines.String(), description,
+	)
+}
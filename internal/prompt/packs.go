@@ -0,0 +1,233 @@
+// Package prompt provides domain prompt packs that add curated guidance for common tools.
+package prompt
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// Example is a curated request/command pair shown to the model.
+type Example struct {
+	Request string
+	Command string
+}
+
+// Pack is a domain-specific prompt add-on.
+// A pack is activated when one of its Files matches an entry in the working
+// directory, when Detect reports the tool is in use, or when the user's
+// request mentions one of its Keywords.
+type Pack struct {
+	Name         string
+	Files        []string // glob patterns matched against the directory listing
+	Keywords     []string // words that activate the pack when found in the request
+	Detect       func(ctx *context.Context) bool
+	Instructions string
+	Examples     []Example
+	Facts        func(ctx *context.Context, request string) string // facts about the request gathered when the pack is active
+}
+
+// packs holds the built-in prompt packs, keyed by name.
+var packs = map[string]*Pack{
+	"git": {
+		Name:     "git",
+		Files:    []string{".git", ".gitignore"},
+		Keywords: []string{"git", "commit", "branch", "rebase", "stash", "merge", "cherry-pick"},
+		Detect: func(ctx *context.Context) bool {
+			return ctx.GitInfo["branch"] != ""
+		},
+		Instructions: "Prefer porcelain git commands. Never rewrite published history (force push, reset --hard) unless explicitly asked, and mark such commands as dangerous. Use `git switch`/`git restore` over the overloaded `git checkout`.",
+		Examples: []Example{
+			{"undo my last commit but keep the changes", "git reset --soft HEAD~1"},
+			{"show what changed in the last 3 commits", "git log -3 --stat --oneline"},
+			{"delete local branches already merged into main", "git branch --merged main | grep -v '^[ *]*main$' | xargs -r git branch -d"},
+		},
+	},
+	"archive": {
+		Name:     "archive",
+		Files:    []string{"*.tar", "*.tar.*", "*.tgz", "*.zip", "*.7z", "*.zst", "*.gz", "*.xz"},
+		Keywords: []string{"archive", "archives", "tar", "tarball", "zip", "unzip", "untar", "compress", "compressed", "decompress", "unpack", "gzip", "zstd", "xz", "bzip2", "pigz", "7z", "backup"},
+		Instructions: "Pick the compressor for the size of the data and the tools installed, as the facts below say. Under about 100 MiB any is fine, so use gzip (`tar -czf`) for the widest compatibility. For more, prefer zstd with all cores (`tar -I 'zstd -T0' -cf x.tar.zst`), else pigz (`tar -I pigz -cf x.tar.gz`); use xz (`-J`, or pixz) only when the smallest archive matters more than time. " +
+			"Leave out what can be rebuilt, such as .git, node_modules and build output, when archiving a project, unless asked to keep it. Use `tar -C <dir>` rather than cd, so paths in the archive are relative. To extract, let tar detect the compression (`tar -xf`); extracting over existing files is dangerous unless into a new directory (`-C <new dir>` after `mkdir -p`).",
+		Examples: []Example{
+			{"compress the logs folder (3.2 GiB, zstd installed)", "tar -I 'zstd -T0' -cf logs.tar.zst logs"},
+			{"zip up this project to send it", "zip -r ../project.zip . -x '.git/*' 'node_modules/*'"},
+			{"extract backup.tar.zst into restore", "mkdir -p restore && tar -xf backup.tar.zst -C restore"},
+		},
+		Facts: archiveFacts,
+	},
+	"docker": {
+		Name:         "docker",
+		Files:        []string{"Dockerfile", "Dockerfile.*", "*.Dockerfile", "docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml", ".dockerignore"},
+		Keywords:     []string{"docker", "container", "containers", "compose", "dockerfile"},
+		Instructions: "Use `docker compose` (v2) rather than `docker-compose`. Prefer `--format` with Go templates for readable listings. Pruning volumes or images is destructive and must be marked dangerous.",
+		Examples: []Example{
+			{"show running containers with their ports", "docker ps --format 'table {{.Names}}\\t{{.Status}}\\t{{.Ports}}'"},
+			{"rebuild and restart the compose stack", "docker compose up -d --build"},
+			{"remove all stopped containers", "danger: docker container prune -f"},
+		},
+	},
+	"forge": {
+		Name:         "forge",
+		Keywords:     []string{"pr", "prs", "pull", "mr", "issue", "issues", "gh", "glab", "github", "gitlab", "release", "workflow"},
+		Instructions: "Use `gh` for repositories hosted on GitHub and `glab` for GitLab, as the hosting in the context says. Target the default branch (`--base`, `--target-branch`) and give a title derived from the branch's commits: the subject of a single commit, or a short summary of several. Pass every value the CLI would otherwise ask for. Push a branch without an upstream first (`git push -u`), and start with `gh auth login` or `glab auth login` when the CLI is not logged in.",
+		Examples: []Example{
+			{"open a PR for this branch", "git push -u origin fix-upload-retry && gh pr create --base main --head fix-upload-retry --title 'Retry failed uploads' --body 'Retry uploads that fail with a 5xx response up to three times.'"},
+			{"open a merge request for this branch", "glab mr create --target-branch main --title 'Retry failed uploads' --description 'Retry uploads that fail with a 5xx response up to three times.' --yes"},
+			{"show the checks on my PR", "gh pr checks"},
+		},
+	},
+	"kubernetes": {
+		Name:         "kubernetes",
+		Files:        []string{"kustomization.yaml", "kustomization.yml", "Chart.yaml", "skaffold.yaml", "helmfile.yaml"},
+		Keywords:     []string{"kubectl", "kubernetes", "k8s", "pod", "pods", "deployment", "namespace", "helm", "cluster"},
+		Instructions: "Use kubectl with an explicit `-n <namespace>` when the request names one. Prefer `kubectl get` with `-o wide` or `-o jsonpath` for focused output. Deleting resources or scaling to zero must be marked dangerous.",
+		Examples: []Example{
+			{"list pods that are not running", "kubectl get pods -A --field-selector=status.phase!=Running"},
+			{"tail logs of the api deployment", "kubectl logs -f deployment/api --tail=100"},
+			{"restart the web deployment", "kubectl rollout restart deployment/web"},
+		},
+	},
+	"ffmpeg": {
+		Name:         "ffmpeg",
+		Files:        []string{"*.mp4", "*.mkv", "*.mov", "*.avi", "*.webm", "*.mp3", "*.wav", "*.flac", "*.gif"},
+		Keywords:     []string{"ffmpeg", "ffprobe", "video", "videos", "audio", "transcode", "mp4", "mkv", "mp3", "gif", "subtitle", "subtitles", "codec", "bitrate"},
+		Instructions: "Use ffmpeg with `-hide_banner`. Never overwrite the input file; write to a new output name and avoid `-y` unless asked. Prefer `-c copy` when no re-encoding is needed.",
+		Examples: []Example{
+			{"convert input.mov to mp4", "ffmpeg -hide_banner -i input.mov -c:v libx264 -crf 23 -c:a aac output.mp4"},
+			{"extract the audio from talk.mp4", "ffmpeg -hide_banner -i talk.mp4 -vn -c:a copy talk.m4a"},
+			{"cut the first 30 seconds of clip.mp4", "ffmpeg -hide_banner -ss 0 -t 30 -i clip.mp4 -c copy clip-30s.mp4"},
+		},
+	},
+	"text": {
+		Name:         "text",
+		Files:        []string{"*.csv", "*.tsv", "*.log", "*.jsonl", "*.ndjson"},
+		Keywords:     []string{"grep", "rg", "sed", "awk", "jq", "yq", "sort", "uniq", "csv", "tsv", "json", "jsonl", "regex"},
+		Instructions: "Prefer standard POSIX tools (grep, sed, awk, sort, uniq, cut) and jq for JSON. Quote patterns with single quotes. Never edit files in place unless asked; when asked, keep a backup (e.g. `sed -i.bak`).",
+		Examples: []Example{
+			{"count unique IPs in access.log", "awk '{print $1}' access.log | sort | uniq -c | sort -rn | head"},
+			{"print the second column of data.csv", "cut -d, -f2 data.csv"},
+			{"list the names from users.json", "jq -r '.[].name' users.json"},
+		},
+	},
+	"watch": {
+		Name:         "watch",
+		Keywords:     []string{"watch", "monitor", "follow", "tail", "journalctl"},
+		Instructions: "For requests to watch or monitor something, give one command that keeps running: the tool's own option where it has one (`kubectl get -w`, `tail -f`, `journalctl -f`, `docker stats`), otherwise `watch -n <seconds>` when watch is installed, or a `while true; do ...; sleep <seconds>; done` loop. Don't add a count or time limit unless asked; nlch stops the command at its watch limit or on Ctrl-C.",
+		Examples: []Example{
+			{"watch the pod count every 5s", "watch -n 5 'kubectl get pods --no-headers | wc -l'"},
+			{"follow the nginx error log", "tail -f /var/log/nginx/error.log"},
+			{"show disk usage of /data every minute", "while true; do df -h /data; sleep 60; done"},
+		},
+	},
+}
+
+// GetPack returns a built-in pack by name.
+func GetPack(name string) (*Pack, bool) {
+	p, ok := packs[name]
+	return p, ok
+}
+
+// PackNames returns the names of all built-in packs in sorted order.
+func PackNames() []string {
+	names := make([]string, 0, len(packs))
+	for name := range packs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckPacks returns an error naming the first pack in enabled or disabled,
+// the packs and disabled_packs of the config, that is not a built-in pack.
+func CheckPacks(enabled, disabled []string) error {
+	for _, list := range []struct {
+		key   string
+		names []string
+	}{{"packs", enabled}, {"disabled_packs", disabled}} {
+		for _, name := range list.names {
+			if _, ok := packs[name]; !ok {
+				return fmt.Errorf("unknown prompt pack %q in %s, use one of %s", name, list.key, strings.Join(PackNames(), ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// ActivePacks returns the packs that apply to the given context and request.
+// Packs listed in enabled are always included; packs listed in disabled are never included.
+func ActivePacks(ctx *context.Context, userInput string, enabled, disabled []string) []*Pack {
+	skip := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		skip[name] = true
+	}
+	force := make(map[string]bool, len(enabled))
+	for _, name := range enabled {
+		force[name] = true
+	}
+
+	words := requestWords(userInput)
+	var active []*Pack
+	for _, name := range PackNames() {
+		p := packs[name]
+		if skip[name] {
+			continue
+		}
+		if force[name] || p.matches(ctx, words) {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// matches reports whether the pack should be activated automatically.
+func (p *Pack) matches(ctx *context.Context, words map[string]bool) bool {
+	for _, kw := range p.Keywords {
+		if words[kw] {
+			return true
+		}
+	}
+	if ctx == nil {
+		return false
+	}
+	if p.Detect != nil && p.Detect(ctx) {
+		return true
+	}
+	for _, file := range ctx.Files {
+		for _, pattern := range p.Files {
+			if ok, _ := filepath.Match(pattern, file); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// format renders the pack as a prompt section.
+func (p *Pack) format() string {
+	var b strings.Builder
+	b.WriteString("Guidance for " + p.Name + ":\n")
+	b.WriteString(p.Instructions + "\n")
+	if len(p.Examples) > 0 {
+		b.WriteString("Examples:\n")
+		for _, ex := range p.Examples {
+			b.WriteString("- Request: " + ex.Request + "\n  Command: " + ex.Command + "\n")
+		}
+	}
+	return b.String()
+}
+
+// requestWords splits the request into a set of lower-case words.
+func requestWords(userInput string) map[string]bool {
+	words := map[string]bool{}
+	fields := strings.FieldsFunc(strings.ToLower(userInput), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-')
+	})
+	for _, f := range fields {
+		words[f] = true
+	}
+	return words
+}
@@ -0,0 +1,43 @@
+package prompt
+
+import "testing"
+
+func TestActivePacksByKeyword(t *testing.T) {
+	tests := []struct {
+		request string
+		want    string // the only pack expected, or "" for none
+	}{
+		{"count the lines in main.go", ""},
+		{"replace foo with bar in notes.txt", ""},
+		{"convert this image to png", ""},
+		{"show the last 20 lines of the log", ""},
+		{"print the free space every hour", ""},
+		{"list running docker containers", "docker"},
+		{"extract the audio track with ffmpeg", "ffmpeg"},
+		{"pretty print data.json with jq", "text"},
+		{"tail the nginx access log", "watch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.request, func(t *testing.T) {
+			var names []string
+			for _, p := range ActivePacks(nil, tt.request, nil, nil) {
+				names = append(names, p.Name)
+			}
+			if tt.want == "" && len(names) > 0 || tt.want != "" && (len(names) != 1 || names[0] != tt.want) {
+				t.Errorf("ActivePacks(%q) = %v, want %q", tt.request, names, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPacks(t *testing.T) {
+	if err := CheckPacks([]string{"git", "text"}, []string{"ffmpeg"}); err != nil {
+		t.Errorf("CheckPacks of built-in packs: %v", err)
+	}
+	if err := CheckPacks([]string{"kubernetes", "kubernets"}, nil); err == nil {
+		t.Error("CheckPacks accepted an unknown pack in packs")
+	}
+	if err := CheckPacks(nil, []string{"images"}); err == nil {
+		t.Error("CheckPacks accepted an unknown pack in disabled_packs")
+	}
+}
@@ -0,0 +1,46 @@
+// Package prompt provides the prompt that describes each stage of a pipeline.
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PipelineSystemPrompt is the system prompt used when describing pipeline stages.
+const PipelineSystemPrompt = "You are an expert terminal assistant who explains shell pipelines stage by stage, briefly and precisely."
+
+// BuildPipelinePrompt asks for a one-line description of each stage of a
+// pipeline, in the context of the whole command.
+func BuildPipelinePrompt(command string, stages []string) string {
+	var list strings.Builder
+	for i, stage := range stages {
+		fmt.Fprintf(&list, "%d. %s\n", i+1, stage)
+	}
+	return fmt.Sprintf(
+		"The shell command below is a pipeline. For each numbered stage, describe in at most ten words what it does with its input, "+
+			"in the context of the whole command.\n\n"+
+			"Reply with exactly one line per stage, in order, as `<number>. <description>`, and nothing else.\n\n"+
+			"Command: %s\n\nStages:\n%s",
+		command, list.String(),
+	)
+}
+
+var stageLine = regexp.MustCompile(`^\s*(\d+)[.):]\s*(.+)$`)
+
+// ParseStageDescriptions reads the reply to BuildPipelinePrompt into one
+// description per stage. Stages the reply leaves out get an empty description.
+func ParseStageDescriptions(reply string, stages int) []string {
+	descriptions := make([]string, stages)
+	for _, line := range strings.Split(reply, "\n") {
+		m := stageLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if n, _ := strconv.Atoi(m[1]); n >= 1 && n <= stages {
+			descriptions[n-1] = strings.Trim(strings.TrimSpace(m[2]), "`")
+		}
+	}
+	return descriptions
+}
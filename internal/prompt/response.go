@@ -0,0 +1,179 @@
+// Package prompt provides helpers for interpreting provider responses.
+package prompt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DangerPrefix marks commands the LLM considers dangerous.
+const DangerPrefix = "danger: "
+
+var (
+	// dangerMarker matches a danger: marker at the start of a line, also in bold or italics.
+	dangerMarker = regexp.MustCompile(`(?i)^[*_]*danger[*_]*:[*_]*\s*`)
+	// dangerMention matches a danger: marker anywhere in prose.
+	dangerMention = regexp.MustCompile(`(?i)(^|\s)[*_]*danger[*_]*:`)
+	// inlineCode matches a markdown code span.
+	inlineCode = regexp.MustCompile("`([^`]+)`")
+	// shellPrompt matches the $ prompt some models put in front of commands.
+	shellPrompt = regexp.MustCompile(`^\$\s+`)
+)
+
+// CleanCommand extracts the command from a provider response. Providers
+// format replies differently: the command may be in a fenced code block with
+// or without a language tag, in inline backticks, after a line of prose or
+// behind a $ prompt. A danger: marker before the command, inside or outside
+// the code block, is kept as the DangerPrefix. Lines continued with a
+// trailing backslash are joined. A reply with no line that passes
+// ValidateCommand comes back as its first line, for the caller to reject.
+func CleanCommand(reply string) string {
+	reply = strings.TrimSpace(strings.ReplaceAll(reply, "\r\n", "\n"))
+	if reply == "" {
+		return ""
+	}
+	danger := false
+	body := reply
+	if before, block, ok := fencedBlock(reply); ok {
+		danger = dangerMention.MatchString(before)
+		body = block
+	}
+
+	lines := strings.Split(body, "\n")
+	first := ""
+	for i := 0; i < len(lines); i++ {
+		line, marked := cleanLine(lines[i])
+		if line == "" {
+			// A marker on a line of its own applies to the command after it
+			danger = danger || marked
+			continue
+		}
+		if first == "" {
+			first = markDanger(line, danger || marked)
+		}
+		if ValidateCommand(line) != nil {
+			continue
+		}
+		for strings.HasSuffix(line, `\`) && i+1 < len(lines) {
+			i++
+			line = strings.TrimSpace(strings.TrimSuffix(line, `\`)) + " " + strings.TrimSpace(lines[i])
+		}
+		return markDanger(line, danger || marked)
+	}
+	return first
+}
+
+// fencedBlock finds the first fenced code block of a reply, opened by ``` or
+// ~~~ and an optional language tag, and returns the text before it and the
+// block's contents. An unclosed block runs to the end of the reply.
+func fencedBlock(reply string) (before, block string, ok bool) {
+	lines := strings.Split(reply, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		start := strings.Index(trimmed, "```")
+		if start < 0 && strings.HasPrefix(trimmed, "~~~") {
+			start = 0
+		}
+		if start < 0 {
+			continue
+		}
+		fence := trimmed[start : start+3]
+		prefix := strings.Join(lines[:i], "\n") + "\n" + trimmed[:start]
+		rest := trimmed[start+3:]
+
+		// A block on one line, such as ```ls -la```
+		if inner, _, closed := strings.Cut(rest, fence); closed {
+			return prefix, inner, true
+		}
+		var content []string
+		if tag := strings.TrimSpace(rest); strings.ContainsAny(tag, " \t") {
+			// Text after the fence is the command itself, not a language tag
+			content = append(content, tag)
+		}
+		for _, l := range lines[i+1:] {
+			if strings.HasPrefix(strings.TrimSpace(l), fence) {
+				break
+			}
+			content = append(content, l)
+		}
+		return prefix, strings.Join(content, "\n"), true
+	}
+	return "", "", false
+}
+
+// cleanLine removes danger markers, code span backticks and $ prompts from a
+// line, in whatever order they are nested, and reports whether it was marked
+// dangerous.
+func cleanLine(line string) (string, bool) {
+	danger := false
+	line = strings.TrimSpace(line)
+	for {
+		before := line
+		if loc := dangerMarker.FindStringIndex(line); loc != nil {
+			danger = true
+			line = line[loc[1]:]
+		}
+		line = strings.TrimSpace(shellPrompt.ReplaceAllString(unquoteInline(line), ""))
+		if line == before {
+			return line, danger
+		}
+	}
+}
+
+// unquoteInline returns the command in a line made of a code span, or in a
+// line of prose that quotes it, such as "Run `ls -la` to list them." When
+// the prose quotes several spans, the longest is the command. Commands that
+// use backticks for command substitution are left alone.
+func unquoteInline(line string) string {
+	if len(line) > 2 && line[0] == '`' && line[len(line)-1] == '`' && !strings.Contains(line[1:len(line)-1], "`") {
+		return line[1 : len(line)-1]
+	}
+	if !quotesCommand(line) {
+		return line
+	}
+	longest := ""
+	for _, m := range inlineCode.FindAllStringSubmatch(line, -1) {
+		if len(m[1]) > len(longest) {
+			longest = m[1]
+		}
+	}
+	if longest == "" {
+		return line
+	}
+	return longest
+}
+
+// quotesCommand reports whether a line is prose around code spans rather
+// than a command: it is not a valid command, or it starts with a capitalised
+// word, such as "Use".
+func quotesCommand(line string) bool {
+	if !strings.Contains(line, "`") {
+		return false
+	}
+	if ValidateCommand(line) != nil {
+		return true
+	}
+	return isCapitalised(strings.Fields(line)[0])
+}
+
+// markDanger puts the DangerPrefix in front of a dangerous command.
+func markDanger(cmd string, danger bool) string {
+	if danger {
+		return DangerPrefix + cmd
+	}
+	return cmd
+}
+
+// firstLine returns the first non-empty line of a reply, from inside its
+// fenced code block if it has one, for replies that are not commands.
+func firstLine(reply string) string {
+	if _, block, ok := fencedBlock(reply); ok {
+		reply = block
+	}
+	for _, line := range strings.Split(reply, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package prompt
+
+import "testing"
+
+func TestCleanCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{"plain", "ls -la", "ls -la"},
+		{"surrounding whitespace", "\n  ls -la  \n", "ls -la"},
+		{"crlf", "ls -la\r\n", "ls -la"},
+		{"empty", "  \n ", ""},
+
+		{"fenced", "```\nls -la\n```", "ls -la"},
+		{"fenced with language tag", "```bash\nls -la\n```", "ls -la"},
+		{"fenced with sh tag", "```sh\nfind . -name '*.go'\n```", "find . -name '*.go'"},
+		{"tilde fence", "~~~bash\nls -la\n~~~", "ls -la"},
+		{"fence on one line", "```ls -la```", "ls -la"},
+		{"command after opening fence", "``` ls -la\n```", "ls -la"},
+		{"unclosed fence", "```bash\nls -la", "ls -la"},
+		{"prose around fence", "Here is the command:\n```bash\ndu -sh *\n```\nIt shows the size of each entry.", "du -sh *"},
+		{"continued lines in fence", "```bash\nfind . \\\n  -name '*.log' \\\n  -delete\n```", "find . -name '*.log' -delete"},
+
+		{"inline code", "`ls -la`", "ls -la"},
+		{"prose quoting inline code", "Run `ls -la` to list them.", "ls -la"},
+		{"longest of several spans", "Use `du -sh *` or `ls`.", "du -sh *"},
+		{"command substitution kept", "echo `date`", "echo `date`"},
+		{"command substitution around args", "cp `which ls` /tmp", "cp `which ls` /tmp"},
+
+		{"leading prose", "Sure! Here's the command:\nls -la", "ls -la"},
+		{"leading sentence", "This lists every file in the directory.\nls -la", "ls -la"},
+
+		{"shell prompt", "$ ls -la", "ls -la"},
+		{"shell prompt in fence", "```bash\n$ ls -la\n```", "ls -la"},
+		{"shell prompt in inline code", "`$ ls -la`", "ls -la"},
+		{"variable not a prompt", "$EDITOR notes.txt", "$EDITOR notes.txt"},
+
+		{"danger", "danger: rm -rf build", "danger: rm -rf build"},
+		{"bold danger", "**danger:** rm -rf build", "danger: rm -rf build"},
+		{"danger inside fence", "```bash\ndanger: rm -rf build\n```", "danger: rm -rf build"},
+		{"danger before fence", "danger:\n```bash\nrm -rf build\n```", "danger: rm -rf build"},
+		{"danger in prose before fence", "This is destructive, danger:\n```\nrm -rf build\n```", "danger: rm -rf build"},
+		{"danger on its own line", "danger:\nrm -rf build", "danger: rm -rf build"},
+		{"danger with inline code", "danger: `rm -rf build`", "danger: rm -rf build"},
+		{"danger with prompt", "danger: $ rm -rf build", "danger: rm -rf build"},
+
+		{"no command", "I'm sorry, I can't help with that.", "I'm sorry, I can't help with that."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanCommand(tt.reply); got != tt.want {
+				t.Errorf("CleanCommand(%q) = %q, want %q", tt.reply, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	tests := []struct {
+		cmd   string
+		valid bool
+	}{
+		{"ls -la", true},
+		{"git commit -m \"Fix the build.\"", true},
+		{"Rscript analysis.R", true},
+		{"danger: rm -rf build", true},
+		{"", false},
+		{"# List files", false},
+		{"- ls -la", false},
+		{"Sure, here you go", false},
+		{"echo \"Sorry, I can't do that\"", false},
+		{"This command lists all files.", false},
+		{"Here is the command:", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateCommand(tt.cmd); (err == nil) != tt.valid {
+			t.Errorf("ValidateCommand(%q) = %v, want valid %v", tt.cmd, err, tt.valid)
+		}
+	}
+}
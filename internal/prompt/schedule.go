@@ -0,0 +1,31 @@
+// Package prompt provides the prompt used to generate scheduled commands.
+package prompt
+
+import (
+	"fmt"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// ScheduleSystemPrompt is the system prompt used when generating a job for `nlch schedule`.
+const ScheduleSystemPrompt = "You are an expert at automating recurring tasks with cron and shell commands."
+
+// BuildSchedulePrompt asks the LLM for a command and the cron expression of
+// when to run it, each on a labelled line.
+func BuildSchedulePrompt(ctx *context.Context, description string) string {
+	return fmt.Sprintf(
+		"Turn the description below into a recurring job. The command runs unattended from a scheduler, so:\n"+
+			"- it must not prompt for input or need a terminal;\n"+
+			"- use absolute paths, or paths under $HOME, since the working directory and PATH are minimal;\n"+
+			"- keep it to a single line.\n"+
+			"If the command is potentially dangerous and destructive, write 'danger: ' before it on the COMMAND line.\n"+
+			"Reply with exactly these four lines and nothing else, without markdown code blocks:\n"+
+			"NAME: <short lower-case name with dashes, e.g. backup-notes>\n"+
+			"CRON: <standard five-field cron expression, e.g. 0 2 * * *>\n"+
+			"COMMAND: <the shell command>\n"+
+			"DESCRIPTION: <one short sentence describing the job>\n\n"+
+			"Working Directory: %s\n"+
+			"Description: %s\n",
+		ctx.WorkingDir, description,
+	)
+}
@@ -0,0 +1,40 @@
+// Package prompt provides the prompt used to generate complete shell scripts.
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// ScriptSystemPrompt is the system prompt used when generating a script file.
+const ScriptSystemPrompt = "You are an expert shell programmer who writes robust, well-commented, shellcheck-clean scripts."
+
+// BuildScriptPrompt constructs a prompt asking the LLM for a complete shell script.
+func BuildScriptPrompt(ctx *context.Context, description, shellName string) string {
+	return fmt.Sprintf(
+		"Write a complete %[1]s script for the task below.\n"+
+			"Requirements:\n"+
+			"- Start with a `#!/usr/bin/env %[1]s` shebang and a comment block describing the purpose and usage.\n"+
+			"- Enable strict error handling (e.g. `set -euo pipefail` for bash) and check the result of important steps.\n"+
+			"- Quote every variable expansion and keep the script shellcheck-clean.\n"+
+			"- Put configurable values in clearly named variables near the top.\n"+
+			"- Comment each logical step.\n"+
+			"Return ONLY the script, without markdown code blocks or any text before or after it.\n\n"+
+			"Working Directory: %[2]s\n"+
+			"Task: %[3]s\n",
+		shellName, ctx.WorkingDir, description,
+	)
+}
+
+// BuildScriptFixPrompt asks the LLM to fix the problems shellcheck found in a script.
+func BuildScriptFixPrompt(script string, findings []string) string {
+	return fmt.Sprintf(
+		"shellcheck reported these problems in the script below:\n%s\n\n"+
+			"Fix them without changing what the script does. Keep its comments and structure.\n"+
+			"Return ONLY the complete fixed script, without markdown code blocks or any text before or after it.\n\n"+
+			"Script:\n%s\n",
+		"- "+strings.Join(findings, "\n- "), script,
+	)
+}
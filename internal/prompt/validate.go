@@ -0,0 +1,82 @@
+// Package prompt recognises provider responses that are not commands, such
+// as apologies, explanations or markdown, so they can be asked for again.
+package prompt
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// preamble matches the start of a reply that talks about a command instead of being one.
+var preamble = regexp.MustCompile(`(?i)^(sure|certainly|of course|okay|here('s| is| are)|i('m| am) sorry|sorry|apologies|i (can't|cannot|won't|am unable|'m unable)|unfortunately|as an ai|to (do|accomplish|achieve) (this|that)|you can|the (following )?command)\b`)
+
+// echoed matches a command that only prints text, and the text it prints.
+var echoed = regexp.MustCompile(`^(?:echo|printf)\s+(?:-\w+\s+)*["']?(.*)`)
+
+// markdownLine matches list items and emphasis, which commands never start with.
+var markdownLine = regexp.MustCompile(`^(\*\*|[-*+]\s|\d+\.\s)`)
+
+// ValidateCommand reports why a cleaned-up response is not a shell command:
+// it is empty, prose such as an apology or an introduction, an echo of such
+// prose, or markdown. Commands that look unusual but plausible pass.
+func ValidateCommand(cmd string) error {
+	cmd = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(cmd), DangerPrefix))
+	if cmd == "" {
+		return errors.New("is empty")
+	}
+	if strings.HasPrefix(cmd, "#") {
+		return errors.New("is a comment or markdown heading")
+	}
+	if markdownLine.MatchString(cmd) {
+		return errors.New("is markdown")
+	}
+	if preamble.MatchString(cmd) {
+		return errors.New("is prose, not a command")
+	}
+	if m := echoed.FindStringSubmatch(cmd); m != nil && preamble.MatchString(m[1]) {
+		return errors.New("prints prose instead of doing what was asked")
+	}
+	if isSentence(cmd) {
+		return errors.New("is prose, not a command")
+	}
+	return nil
+}
+
+// isSentence reports whether a line reads like an English sentence: it
+// introduces something or ends like a sentence, starts with a capitalised
+// word, and is several words long.
+func isSentence(line string) bool {
+	words := strings.Fields(line)
+	if strings.HasSuffix(line, ":") && len(words) > 2 {
+		return true
+	}
+	if len(words) < 4 || !strings.ContainsAny(line[len(line)-1:], ".!") {
+		return false
+	}
+	return isCapitalised(words[0])
+}
+
+// isCapitalised reports whether a word is written like the first word of a
+// sentence, such as "Use" or "Here's": a capital letter followed only by
+// lowercase ones. It deliberately doesn't look at the PATH, so that what a
+// reply is taken to mean doesn't depend on what is installed; the few
+// programs named this way, such as Rscript, are rare at the start of a reply.
+func isCapitalised(word string) bool {
+	if word == "" || word[0] < 'A' || word[0] > 'Z' {
+		return false
+	}
+	for _, c := range word[1:] {
+		if (c < 'a' || c > 'z') && c != '\'' {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildRetryPrompt asks again for the command after a reply that was not
+// one, saying what was wrong with it.
+func BuildRetryPrompt(promptStr, reply string, problem error) string {
+	return promptStr + "\n\nYour previous reply " + problem.Error() + ":\n" + strings.TrimSpace(reply) + "\n" +
+		"Return ONLY the shell command, on a single line: no explanations, apologies, markdown or code blocks, and never echo text in place of the command.\n"
+}
@@ -0,0 +1,37 @@
+// Package prompt provides the prompt used to diagnose failed shell commands.
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// WhySystemPrompt is the system prompt used when diagnosing a failed command.
+const WhySystemPrompt = "You are an expert terminal assistant who diagnoses failed shell commands and suggests concrete fixes."
+
+// BuildWhyPrompt constructs a prompt asking the LLM why a command failed. The
+// command may be empty when only its output is known, and an exit code below
+// zero means it is unknown.
+func BuildWhyPrompt(ctx *context.Context, command string, exitCode int, output string) string {
+	var b strings.Builder
+	b.WriteString("Diagnose why the following shell command failed and suggest how to fix it.\n\n" +
+		"Use exactly this structure and plain text (no markdown headers, no code blocks):\n" +
+		"Cause: <one or two sentences on the most likely cause>\n" +
+		"Fixes:\n" +
+		"- <a concrete fix, with the corrected command when there is one>\n\n")
+	fmt.Fprintf(&b, "Working Directory: %s\n", ctx.WorkingDir)
+	if command == "" {
+		command = "(unknown)"
+	}
+	fmt.Fprintf(&b, "Command: %s\n", command)
+	if exitCode >= 0 {
+		fmt.Fprintf(&b, "Exit Status: %d\n", exitCode)
+	}
+	if output = strings.TrimSpace(output); output == "" {
+		output = "(not recorded)"
+	}
+	fmt.Fprintf(&b, "Output:\n%s\n", output)
+	return b.String()
+}
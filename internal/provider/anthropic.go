@@ -12,7 +12,7 @@ type AnthropicProvider struct {
 func (a *AnthropicProvider) Name() string { return "anthropic" }
 
 func (a *AnthropicProvider) GetEndpoint() string {
-	return "https://api.anthropic.com/v1/messages"
+	return a.endpoint("https://api.anthropic.com/v1", "/messages")
 }
 
 func (a *AnthropicProvider) GetHeaders(apiKey string) map[string]string {
@@ -23,8 +23,8 @@ func (a *AnthropicProvider) GetHeaders(apiKey string) map[string]string {
 	}
 }
 
-func (a *AnthropicProvider) BuildRequestBody(model, prompt string) ([]byte, error) {
-	return BuildAnthropicRequestBody(model, prompt)
+func (a *AnthropicProvider) BuildRequestBody(req Request) ([]byte, error) {
+	return BuildAnthropicRequestBody(req)
 }
 
 func (a *AnthropicProvider) ParseResponse(body []byte) (string, error) {
@@ -32,10 +32,5 @@ func (a *AnthropicProvider) ParseResponse(body []byte) (string, error) {
 }
 
 func (a *AnthropicProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
-	model := a.Model
-	if opts.Model != "" {
-		model = opts.Model
-	}
-
-	return a.MakeHTTPRequest(a, model, promptStr)
+	return a.MakeHTTPRequest(a, NewRequest(a.Model, promptStr, opts))
 }
@@ -0,0 +1,287 @@
+// Package provider finds AWS credentials and regions the way the AWS CLI and
+// SDKs do, for the Bedrock provider.
+package provider
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+)
+
+// awsCredentials are the keys requests to AWS are signed with.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string    // set for temporary credentials
+	Expires         time.Time // zero for credentials that don't expire
+	Source          string    // where they were found, for messages
+}
+
+// expiring reports whether temporary credentials expire within five minutes,
+// so that a request signed with them may not arrive in time.
+func (c awsCredentials) expiring(now time.Time) bool {
+	return !c.Expires.IsZero() && now.Add(5*time.Minute).After(c.Expires)
+}
+
+// metadataTimeout bounds each request to the container or instance metadata
+// endpoints, which do not answer at all outside of AWS.
+const metadataTimeout = 2 * time.Second
+
+// AWSCredentialSource tells where the bedrock provider finds AWS credentials
+// for profile, or why it finds none.
+func AWSCredentialSource(profile string) (string, error) {
+	creds, err := loadAWSCredentials(profile)
+	return creds.Source, err
+}
+
+// loadAWSCredentials finds AWS credentials in the standard places, in the
+// order the AWS CLI looks: the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+// environment variables, unless a profile is given; the profile's keys or
+// credential_process in ~/.aws/credentials and ~/.aws/config, where the
+// profile is profile, AWS_PROFILE or "default"; the ECS container endpoint;
+// and the EC2 instance metadata service.
+func loadAWSCredentials(profile string) (awsCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); profile == "" && id != "" && secret != "" {
+		return awsCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN"), Source: "environment"}, nil
+	}
+	profile = awsProfile(profile)
+
+	credentials, config := awsSharedFiles()
+	for _, section := range []map[string]string{readINI(credentials)[profile], awsConfigSection(config, profile)} {
+		if section["aws_access_key_id"] != "" && section["aws_secret_access_key"] != "" {
+			return awsCredentials{
+				AccessKeyID:     section["aws_access_key_id"],
+				SecretAccessKey: section["aws_secret_access_key"],
+				SessionToken:    section["aws_session_token"],
+				Source:          "profile " + profile,
+			}, nil
+		}
+		if process := section["credential_process"]; process != "" {
+			return processCredentials(process, profile)
+		}
+	}
+
+	if creds, err := containerCredentials(); err != nil || creds.AccessKeyID != "" {
+		return creds, err
+	}
+	if os.Getenv("AWS_EC2_METADATA_DISABLED") != "true" {
+		if creds, err := instanceCredentials(); err == nil {
+			return creds, nil
+		}
+	}
+	return awsCredentials{}, fmt.Errorf("no AWS credentials found: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or configure profile %q with aws configure", profile)
+}
+
+// AWSRegion returns the region to use when none is configured: AWS_REGION,
+// AWS_DEFAULT_REGION or the profile's region in ~/.aws/config.
+func AWSRegion(profile string) string {
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(name); region != "" {
+			return region
+		}
+	}
+	_, config := awsSharedFiles()
+	return awsConfigSection(config, awsProfile(profile))["region"]
+}
+
+// awsProfile returns the profile to use: the given one, AWS_PROFILE or "default".
+func awsProfile(profile string) string {
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	return profile
+}
+
+// awsSharedFiles returns the paths of the shared credentials and config files.
+func awsSharedFiles() (credentials, config string) {
+	home, _ := os.UserHomeDir()
+	credentials = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentials == "" {
+		credentials = filepath.Join(home, ".aws", "credentials")
+	}
+	config = os.Getenv("AWS_CONFIG_FILE")
+	if config == "" {
+		config = filepath.Join(home, ".aws", "config")
+	}
+	return credentials, config
+}
+
+// awsConfigSection returns a profile's settings in the config file, where
+// profiles other than the default are named "profile <name>".
+func awsConfigSection(path, profile string) map[string]string {
+	sections := readINI(path)
+	if profile == "default" {
+		return sections["default"]
+	}
+	return sections["profile "+profile]
+}
+
+// readINI reads the sections of an INI file such as ~/.aws/credentials, or
+// none if it can't be read.
+func readINI(path string) map[string]map[string]string {
+	sections := map[string]map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return sections
+	}
+	defer f.Close()
+	var section map[string]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[' && strings.HasSuffix(line, "]"):
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			if sections[name] == nil {
+				sections[name] = map[string]string{}
+			}
+			section = sections[name]
+		case section != nil:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				section[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return sections
+}
+
+// credentialsJSON is the format of credentials from a credential process and,
+// with Token for SessionToken, from the metadata endpoints.
+type credentialsJSON struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"SessionToken"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// credentials checks the parsed credentials and converts them.
+func (c credentialsJSON) credentials(source string) (awsCredentials, error) {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("no AWS credentials from %s", source)
+	}
+	token := c.SessionToken
+	if token == "" {
+		token = c.Token
+	}
+	return awsCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: token, Expires: c.Expiration, Source: source}, nil
+}
+
+// processCredentials runs a profile's credential_process, such as
+// "aws configure export-credentials --format process" for SSO logins.
+func processCredentials(process, profile string) (awsCredentials, error) {
+	source := "credential_process of profile " + profile
+	cmd := exec.Command("sh", "-c", process)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("%s failed: %v", source, err)
+	}
+	var c credentialsJSON
+	if err := json.Unmarshal(out, &c); err != nil {
+		return awsCredentials{}, fmt.Errorf("%s printed invalid JSON: %v", source, err)
+	}
+	return c.credentials(source)
+}
+
+// metadataClient connects to the metadata endpoints directly, never through
+// a proxy, as they are only reachable from the container or instance itself,
+// and not at all in offline mode.
+var metadataClient = httpclient.NewDirect(metadataTimeout)
+
+// containerCredentials fetches the credentials of an ECS task's role, or
+// returns none outside of a task.
+func containerCredentials() (awsCredentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = "http://169.254.170.2" + relative
+	}
+	if endpoint == "" {
+		return awsCredentials{}, nil
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	var c credentialsJSON
+	if err := getMetadata(req, &c); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to get container credentials: %v", err)
+	}
+	return c.credentials("container role")
+}
+
+// instanceCredentials fetches the credentials of an EC2 instance's role with
+// IMDSv2.
+func instanceCredentials() (awsCredentials, error) {
+	const imds = "http://169.254.169.254/latest"
+	req, _ := http.NewRequest("PUT", imds+"/api/token", nil)
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	var token string
+	if err := getMetadata(req, &token); err != nil {
+		return awsCredentials{}, err
+	}
+	req, _ = http.NewRequest("GET", imds+"/meta-data/iam/security-credentials/", nil)
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	var role string
+	if err := getMetadata(req, &role); err != nil {
+		return awsCredentials{}, err
+	}
+	role, _, _ = strings.Cut(strings.TrimSpace(role), "\n")
+	if role == "" {
+		return awsCredentials{}, errors.New("the instance has no role")
+	}
+	req, _ = http.NewRequest("GET", imds+"/meta-data/iam/security-credentials/"+role, nil)
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	var c credentialsJSON
+	if err := getMetadata(req, &c); err != nil {
+		return awsCredentials{}, err
+	}
+	return c.credentials("instance role " + role)
+}
+
+// getMetadata sends a request to a metadata endpoint and decodes the JSON
+// response into v, or stores it in v as is when v is a *string.
+func getMetadata(req *http.Request, v any) error {
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	if s, ok := v.(*string); ok {
+		*s = string(body)
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,169 @@
+// Package provider implements the Amazon Bedrock provider, which reaches
+// Claude, Titan and the other models of Bedrock through its Converse API.
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+)
+
+type BedrockProvider struct {
+	Region   string
+	Model    string       // model or inference profile ID, e.g. anthropic.claude-3-5-haiku-20241022-v1:0
+	Profile  string       // AWS profile to take credentials from; the standard chain when empty
+	Endpoint string       // base URL replacing the regional bedrock-runtime endpoint, such as a VPC endpoint
+	Client   *http.Client // HTTP client to use; the shared client when nil
+
+	mu    sync.Mutex
+	creds awsCredentials // found on the first request, and again when they expire
+}
+
+func (b *BedrockProvider) Name() string { return "bedrock" }
+
+// GetEndpoint returns the URL requests for the default model go to.
+func (b *BedrockProvider) GetEndpoint() string {
+	return b.endpoint(b.Model)
+}
+
+// endpoint returns the Converse API URL of a model, in the provider's region
+// or at its configured endpoint.
+func (b *BedrockProvider) endpoint(model string) string {
+	base := b.Endpoint
+	if base == "" {
+		base = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", b.Region)
+	}
+	return strings.TrimSuffix(base, "/") + "/model/" + awsEscape(model) + "/converse"
+}
+
+func (b *BedrockProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
+	// Build request body
+	request := NewRequest(b.Model, promptStr, opts)
+	reqBody, err := BuildBedrockRequestBody(request)
+	if err != nil {
+		return "", err
+	}
+	creds, err := b.credentials()
+	if err != nil {
+		return "", err
+	}
+
+	// Make request, retrying while throttled, signing each attempt anew;
+	// a signal cancels it rather than killing the process mid-request
+	defer interrupt.Busy()()
+	client := b.Client
+	if client == nil {
+		client = httpclient.Default()
+	}
+	url := b.endpoint(request.Model)
+	resp, err := sendWithRetry(client, b.Name(), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(interrupt.Context(), "POST", url, bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		signV4(req, reqBody, creds, b.Region, "bedrock", time.Now())
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// Parse response
+	content, err := ParseBedrockResponse(body)
+	if err != nil {
+		return "", err
+	}
+
+	if content == "" {
+		return "", errors.New("no content returned from Bedrock")
+	}
+
+	return extractResult(content, request.Raw), nil
+}
+
+// credentials returns the credentials to sign with, looking them up on first
+// use and again when temporary ones are about to expire.
+func (b *BedrockProvider) credentials() (awsCredentials, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.creds.AccessKeyID == "" || b.creds.expiring(time.Now()) {
+		creds, err := loadAWSCredentials(b.Profile)
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		b.creds = creds
+	}
+	return b.creds, nil
+}
+
+// BuildBedrockRequestBody creates a Converse API request body. Titan text
+// models take no system prompt, so it goes before the prompt instead.
+func BuildBedrockRequestBody(req Request) ([]byte, error) {
+	text := req.Prompt
+	reqBody := map[string]any{
+		"inferenceConfig": map[string]any{"maxTokens": req.MaxTokens, "temperature": 0.2},
+	}
+	if strings.Contains(req.Model, "amazon.titan") {
+		text = req.System + "\n\n" + text
+	} else {
+		reqBody["system"] = []map[string]string{{"text": req.System}}
+	}
+
+	content := []map[string]any{{"text": text}}
+	for _, img := range req.Images {
+		content = append(content, map[string]any{
+			"image": map[string]any{
+				"format": strings.TrimPrefix(img.MediaType, "image/"),
+				"source": map[string]string{"bytes": img.Base64()},
+			},
+		})
+	}
+	messages := make([]map[string]any, 0, len(req.History)+1)
+	for _, m := range req.History {
+		messages = append(messages, map[string]any{"role": m.Role, "content": []map[string]string{{"text": m.Content}}})
+	}
+	reqBody["messages"] = append(messages, map[string]any{"role": "user", "content": content})
+	return json.Marshal(reqBody)
+}
+
+// ParseBedrockResponse parses a Converse API response
+func ParseBedrockResponse(body []byte) (string, error) {
+	var res struct {
+		Output struct {
+			Message struct {
+				Content []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"message"`
+		} `json:"output"`
+	}
+
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, c := range res.Output.Message.Content {
+		text.WriteString(c.Text)
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,115 @@
+// Package provider implements the Cohere provider, which reaches the Command
+// models through Cohere's v2 chat API.
+package provider
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+type CohereProvider struct {
+	BaseHTTPProvider
+}
+
+func (c *CohereProvider) Name() string { return "cohere" }
+
+func (c *CohereProvider) GetEndpoint() string {
+	return c.endpoint("https://api.cohere.com", "/v2/chat")
+}
+
+func (c *CohereProvider) GetHeaders(apiKey string) map[string]string {
+	return map[string]string{
+		"Authorization": "Bearer " + apiKey,
+		"Content-Type":  "application/json",
+	}
+}
+
+func (c *CohereProvider) BuildRequestBody(req Request) ([]byte, error) {
+	return BuildCohereRequestBody(req)
+}
+
+func (c *CohereProvider) ParseResponse(body []byte) (string, error) {
+	return ParseCohereResponse(body)
+}
+
+func (c *CohereProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
+	return c.MakeHTTPRequest(c, NewRequest(c.Model, promptStr, opts))
+}
+
+func (c *CohereProvider) ListModels() ([]string, error) {
+	var res struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := getModels(c.Name(), c.endpoint("https://api.cohere.com", "/v1/models?endpoint=chat&page_size=1000"), c.GetHeaders(c.APIKey), &res); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(res.Models))
+	for i, m := range res.Models {
+		names[i] = m.Name
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// BuildCohereRequestBody creates a Cohere v2 chat request body. The system
+// prompt is the first message, as with OpenAI, but a schema goes inside a
+// json_object response format, and images are image_url parts.
+func BuildCohereRequestBody(req Request) ([]byte, error) {
+	var content any = req.Prompt
+	if len(req.Images) > 0 {
+		parts := []map[string]any{{"type": "text", "text": req.Prompt}}
+		for _, img := range req.Images {
+			parts = append(parts, map[string]any{
+				"type":      "image_url",
+				"image_url": map[string]string{"url": "data:" + img.MediaType + ";base64," + img.Base64()},
+			})
+		}
+		content = parts
+	}
+	reqBody := map[string]any{
+		"model": req.Model,
+		"messages": append([]map[string]any{
+			{"role": "system", "content": req.System},
+		}, chatMessages(req, content)...),
+		"max_tokens":  req.MaxTokens,
+		"temperature": 0.2,
+	}
+	if req.JSON {
+		format := map[string]any{"type": "json_object"}
+		if req.Schema != nil {
+			format["json_schema"] = req.Schema
+		}
+		reqBody["response_format"] = format
+	}
+	return json.Marshal(reqBody)
+}
+
+// ParseCohereResponse parses a Cohere v2 chat response, whose message holds
+// a list of content blocks.
+func ParseCohereResponse(body []byte) (string, error) {
+	var res struct {
+		Message struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, c := range res.Message.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,163 @@
+// Package provider implements recording and replaying provider interactions.
+package provider
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// Environment variables that switch the selected provider into record or replay mode.
+const (
+	RecordEnv = "NLCH_RECORD"
+	ReplayEnv = "NLCH_REPLAY"
+)
+
+// Fixture is one recorded provider interaction, stored as a line of JSON.
+type Fixture struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	System   string `json:"system,omitempty"`
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+	Error    string `json:"error,omitempty"`
+}
+
+// WithFixtures wraps p in a Recorder or Replayer when NLCH_RECORD or
+// NLCH_REPLAY names a fixture file, and returns it unchanged otherwise.
+func WithFixtures(p Provider) (Provider, error) {
+	record, replay := os.Getenv(RecordEnv), os.Getenv(ReplayEnv)
+	switch {
+	case record != "" && replay != "":
+		return nil, fmt.Errorf("%s and %s can't be used together", RecordEnv, ReplayEnv)
+	case record != "":
+		return &Recorder{Provider: p, Path: record}, nil
+	case replay != "":
+		return NewReplayer(p.Name(), replay)
+	}
+	return p, nil
+}
+
+// Recorder passes requests to a provider and appends each interaction to a fixture file.
+type Recorder struct {
+	Provider Provider
+	Path     string
+
+	mu sync.Mutex
+}
+
+func (r *Recorder) Name() string { return r.Provider.Name() }
+
+func (r *Recorder) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
+	response, err := r.Provider.GenerateCommand(ctx, promptStr, opts)
+	fixture := Fixture{
+		Provider: r.Provider.Name(),
+		Model:    opts.Model,
+		System:   opts.System,
+		Prompt:   promptStr,
+		Response: response,
+	}
+	if err != nil {
+		fixture.Error = err.Error()
+	}
+	if werr := r.append(fixture); werr != nil {
+		return "", fmt.Errorf("failed to record fixture: %v", werr)
+	}
+	return response, err
+}
+
+// append adds a fixture to the end of the file.
+func (r *Recorder) append(fixture Fixture) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	line, err := json.Marshal(fixture)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Replayer answers requests from a fixture file instead of contacting a provider.
+// A request gets the response recorded for the same prompt and system prompt;
+// if there is none, because the context differs from the recording, it gets the
+// next unused response in recording order.
+type Replayer struct {
+	name     string
+	path     string
+	fixtures []Fixture
+
+	mu   sync.Mutex
+	used []bool
+}
+
+// NewReplayer loads the fixtures in path for replay under the given provider name.
+func NewReplayer(name, path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixtures: %v", err)
+	}
+	defer f.Close()
+
+	r := &Replayer{name: name, path: path}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var fixture Fixture
+		if err := json.Unmarshal(scanner.Bytes(), &fixture); err != nil {
+			return nil, fmt.Errorf("invalid fixture in %s: %v", path, err)
+		}
+		r.fixtures = append(r.fixtures, fixture)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %v", err)
+	}
+	r.used = make([]bool, len(r.fixtures))
+	return r, nil
+}
+
+func (r *Replayer) Name() string { return r.name }
+
+func (r *Replayer) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for i, f := range r.fixtures {
+		if !r.used[i] && f.Prompt == promptStr && f.System == opts.System {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		for i := range r.fixtures {
+			if !r.used[i] {
+				match = i
+				break
+			}
+		}
+	}
+	if match < 0 {
+		return "", fmt.Errorf("no recorded responses left in %s", r.path)
+	}
+
+	r.used[match] = true
+	fixture := r.fixtures[match]
+	if fixture.Error != "" {
+		return "", errors.New(fixture.Error)
+	}
+	return fixture.Response, nil
+}
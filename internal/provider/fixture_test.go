@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+func TestMockProviderCyclesResponses(t *testing.T) {
+	p, err := New("mock", config.ProviderConfig{Responses: []string{"ls -la\nextra line", "pwd"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := p.(*MockProvider)
+	want := []string{"ls -la", "pwd", "ls -la"}
+	for i, w := range want {
+		got, err := mock.GenerateCommand(context.Context{}, "User Request: anything", ProviderOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("response %d = %q, want %q", i+1, got, w)
+		}
+	}
+	if mock.Calls() != len(want) {
+		t.Errorf("Calls() = %d, want %d", mock.Calls(), len(want))
+	}
+
+	raw, _ := mock.GenerateCommand(context.Context{}, "", ProviderOptions{Raw: true})
+	if raw != "pwd" {
+		t.Errorf("raw response = %q, want %q", raw, "pwd")
+	}
+}
+
+func TestMockProviderEchoesRequest(t *testing.T) {
+	mock := &MockProvider{}
+	got, err := mock.GenerateCommand(context.Context{}, "Context:\nUser Request: list files\nCommand:", ProviderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `echo "list files"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.jsonl")
+	recorder := &Recorder{Provider: &MockProvider{Responses: []string{"ls", "pwd"}}, Path: path}
+	opts := ProviderOptions{Model: "m", System: "sys"}
+	for _, prompt := range []string{"first", "second"} {
+		if _, err := recorder.GenerateCommand(context.Context{}, prompt, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replayer, err := NewReplayer("mock", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayer.Name() != "mock" {
+		t.Errorf("Name() = %q, want mock", replayer.Name())
+	}
+	// Prompts are matched whatever the order they are asked in
+	for _, tt := range []struct{ prompt, want string }{{"second", "pwd"}, {"first", "ls"}} {
+		got, err := replayer.GenerateCommand(context.Context{}, tt.prompt, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("replay of %q = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+	if _, err := replayer.GenerateCommand(context.Context{}, "first", opts); err == nil || !strings.Contains(err.Error(), "no recorded responses left") {
+		t.Errorf("replay past the end: err = %v", err)
+	}
+}
+
+func TestReplayFallsBackToRecordingOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.jsonl")
+	lines := `{"provider":"mock","prompt":"a","response":"one"}
+
+{"provider":"mock","prompt":"b","system":"other","response":"two"}
+{"provider":"mock","prompt":"c","error":"rate limited"}
+`
+	if err := os.WriteFile(path, []byte(lines), 0600); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := NewReplayer("mock", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "b" was recorded with another system prompt, so the next unused response is used
+	got, _ := replayer.GenerateCommand(context.Context{}, "b", ProviderOptions{})
+	if got != "one" {
+		t.Errorf("unmatched prompt got %q, want the first unused response %q", got, "one")
+	}
+	got, _ = replayer.GenerateCommand(context.Context{}, "b", ProviderOptions{System: "other"})
+	if got != "two" {
+		t.Errorf("matched prompt got %q, want %q", got, "two")
+	}
+	if _, err := replayer.GenerateCommand(context.Context{}, "c", ProviderOptions{}); err == nil || err.Error() != "rate limited" {
+		t.Errorf("recorded error replayed as %v", err)
+	}
+}
+
+func TestRecorderRecordsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.jsonl")
+	recorder := &Recorder{Provider: failingProvider{}, Path: path}
+	if _, err := recorder.GenerateCommand(context.Context{}, "p", ProviderOptions{}); err == nil {
+		t.Fatal("expected the provider's error")
+	}
+	replayer, err := NewReplayer("failing", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replayer.GenerateCommand(context.Context{}, "p", ProviderOptions{}); err == nil || err.Error() != "service unavailable" {
+		t.Errorf("replayed error = %v, want service unavailable", err)
+	}
+}
+
+func TestNewReplayerRejectsInvalidFixtures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.jsonl")
+	if err := os.WriteFile(path, []byte("not json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReplayer("mock", path); err == nil {
+		t.Error("expected an error for an invalid fixture")
+	}
+	if _, err := NewReplayer("mock", filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("expected an error for a missing fixture file")
+	}
+}
+
+func TestWithFixtures(t *testing.T) {
+	mock := &MockProvider{}
+	path := filepath.Join(t.TempDir(), "fixtures.jsonl")
+
+	t.Setenv(RecordEnv, "")
+	t.Setenv(ReplayEnv, "")
+	if p, err := WithFixtures(mock); err != nil || p != Provider(mock) {
+		t.Errorf("without fixtures got %T, %v; want the provider unchanged", p, err)
+	}
+
+	t.Setenv(RecordEnv, path)
+	if p, err := WithFixtures(mock); err != nil {
+		t.Fatal(err)
+	} else if _, ok := p.(*Recorder); !ok {
+		t.Errorf("with %s got %T, want *Recorder", RecordEnv, p)
+	}
+
+	t.Setenv(ReplayEnv, path)
+	if _, err := WithFixtures(mock); err == nil {
+		t.Errorf("expected an error with both %s and %s", RecordEnv, ReplayEnv)
+	}
+
+	t.Setenv(RecordEnv, "")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := WithFixtures(mock); err != nil {
+		t.Fatal(err)
+	} else if _, ok := p.(*Replayer); !ok {
+		t.Errorf("with %s got %T, want *Replayer", ReplayEnv, p)
+	}
+}
+
+// failingProvider answers every request with an error.
+type failingProvider struct{}
+
+func (failingProvider) Name() string { return "failing" }
+
+func (failingProvider) GenerateCommand(context.Context, string, ProviderOptions) (string, error) {
+	return "", errors.New("service unavailable")
+}
@@ -0,0 +1,259 @@
+// Package provider gets Google Cloud access tokens from Application Default
+// Credentials, the way the Google Cloud SDKs do, for the Vertex AI provider.
+package provider
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+)
+
+// cloudPlatformScope is the OAuth scope Vertex AI requests need.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// googleToken is an OAuth access token for Google Cloud APIs.
+type googleToken struct {
+	AccessToken  string
+	Expires      time.Time
+	QuotaProject string // project billed for the requests of user credentials, if set
+	Source       string // where the credentials were found, for messages
+}
+
+// expiring reports whether the token expires within a minute.
+func (t googleToken) expiring(now time.Time) bool {
+	return now.Add(time.Minute).After(t.Expires)
+}
+
+// googleCredentialsFile is a credentials file of Application Default
+// Credentials: a service account key or a gcloud user login.
+type googleCredentialsFile struct {
+	Type           string `json:"type"` // "service_account" or "authorized_user"
+	ProjectID      string `json:"project_id"`
+	QuotaProjectID string `json:"quota_project_id"`
+	// Service accounts
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	// Users
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// GoogleCredentialSource tells where the vertex provider finds Application
+// Default Credentials, or why it finds none. Finding them gets a token.
+func GoogleCredentialSource() (string, error) {
+	token, err := googleAccessToken()
+	return token.Source, err
+}
+
+// googleAccessToken gets an access token from Application Default
+// Credentials: the file GOOGLE_APPLICATION_CREDENTIALS names, the login of
+// gcloud auth application-default login, or the service account of the
+// Compute Engine, Cloud Run or GKE metadata server.
+func googleAccessToken() (googleToken, error) {
+	path, creds, err := googleCredentials()
+	if err != nil {
+		return googleToken{}, err
+	}
+	if creds == nil {
+		token, err := metadataToken()
+		if err != nil {
+			return googleToken{}, errors.New("no Application Default Credentials found: run gcloud auth application-default login, or set GOOGLE_APPLICATION_CREDENTIALS to a service account key")
+		}
+		return token, nil
+	}
+	var token googleToken
+	switch creds.Type {
+	case "service_account":
+		token, err = serviceAccountToken(creds)
+	case "authorized_user":
+		token, err = userToken(creds)
+	default:
+		return googleToken{}, fmt.Errorf("%s: unsupported credentials type %q, use a service account key or a gcloud login", path, creds.Type)
+	}
+	if err != nil {
+		return googleToken{}, fmt.Errorf("%s: %v", path, err)
+	}
+	token.QuotaProject = creds.QuotaProjectID
+	token.Source = path
+	return token, nil
+}
+
+// googleCredentials reads the credentials file of Application Default
+// Credentials, returning none when there is no such file.
+func googleCredentials() (string, *googleCredentialsFile, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		dir := os.Getenv("CLOUDSDK_CONFIG")
+		if dir == "" {
+			home, _ := os.UserHomeDir()
+			dir = filepath.Join(home, ".config", "gcloud")
+		}
+		path = filepath.Join(dir, "application_default_credentials.json")
+		if _, err := os.Stat(path); err != nil {
+			return "", nil, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read credentials: %v", err)
+	}
+	var creds googleCredentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", nil, fmt.Errorf("%s is not a credentials file: %v", path, err)
+	}
+	return path, &creds, nil
+}
+
+// GoogleProject returns the project to use when none is configured:
+// GOOGLE_CLOUD_PROJECT, the project of the credentials file or, on Google
+// Cloud, the project the metadata server names.
+func GoogleProject() string {
+	for _, name := range []string{"GOOGLE_CLOUD_PROJECT", "CLOUDSDK_CORE_PROJECT"} {
+		if project := os.Getenv(name); project != "" {
+			return project
+		}
+	}
+	if _, creds, err := googleCredentials(); err == nil && creds != nil {
+		if creds.ProjectID != "" {
+			return creds.ProjectID
+		}
+		return creds.QuotaProjectID
+	}
+	req, _ := http.NewRequest("GET", metadataHost()+"/computeMetadata/v1/project/project-id", nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	var project string
+	if getMetadata(req, &project) != nil {
+		return ""
+	}
+	return strings.TrimSpace(project)
+}
+
+// serviceAccountToken exchanges a JWT signed with a service account's key
+// for an access token.
+func serviceAccountToken(creds *googleCredentialsFile) (googleToken, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return googleToken{}, errors.New("no private key in the service account key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if err != nil || !ok {
+		return googleToken{}, errors.New("the service account's private key is not an RSA key")
+	}
+	tokenURI := creds.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   creds.ClientEmail,
+		"scope": cloudPlatformScope,
+		"aud":   tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return googleToken{}, err
+	}
+	return requestToken(tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+}
+
+// userToken exchanges the refresh token of a gcloud login for an access token.
+func userToken(creds *googleCredentialsFile) (googleToken, error) {
+	return requestToken("https://oauth2.googleapis.com/token", url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {creds.ClientID},
+		"client_secret": {creds.ClientSecret},
+		"refresh_token": {creds.RefreshToken},
+	})
+}
+
+// tokenResponse is the response of the OAuth token endpoints and the
+// metadata server.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// token checks the response and converts it.
+func (r tokenResponse) token() (googleToken, error) {
+	if r.AccessToken == "" {
+		if r.Description != "" {
+			return googleToken{}, fmt.Errorf("no access token: %s", r.Description)
+		}
+		return googleToken{}, fmt.Errorf("no access token: %s", r.Error)
+	}
+	return googleToken{AccessToken: r.AccessToken, Expires: time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)}, nil
+}
+
+// requestToken posts a token request to an OAuth token endpoint.
+func requestToken(endpoint string, form url.Values) (googleToken, error) {
+	resp, err := httpclient.Default().PostForm(endpoint, form)
+	if err != nil {
+		return googleToken{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return googleToken{}, err
+	}
+	var res tokenResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return googleToken{}, fmt.Errorf("token request failed: %s", resp.Status)
+	}
+	return res.token()
+}
+
+// metadataHost returns the URL of the metadata server, which GCE_METADATA_HOST
+// can change.
+func metadataHost() string {
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		return "http://" + host
+	}
+	return "http://metadata.google.internal"
+}
+
+// metadataToken gets an access token for the service account of the machine
+// nlch runs on from the metadata server.
+func metadataToken() (googleToken, error) {
+	req, _ := http.NewRequest("GET", metadataHost()+"/computeMetadata/v1/instance/service-accounts/default/token?scopes="+url.QueryEscape(cloudPlatformScope), nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	var res tokenResponse
+	if err := getMetadata(req, &res); err != nil {
+		return googleToken{}, err
+	}
+	token, err := res.token()
+	token.Source = "the metadata server"
+	return token, err
+}
@@ -14,7 +14,7 @@ type GeminiProvider struct {
 func (g *GeminiProvider) Name() string { return "gemini" }
 
 func (g *GeminiProvider) GetEndpoint() string {
-	return fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", g.Model, g.APIKey)
+	return g.endpoint("https://generativelanguage.googleapis.com/v1beta", fmt.Sprintf("/models/%s:generateContent?key=%s", g.Model, g.APIKey))
 }
 
 func (g *GeminiProvider) GetHeaders(apiKey string) map[string]string {
@@ -23,8 +23,8 @@ func (g *GeminiProvider) GetHeaders(apiKey string) map[string]string {
 	}
 }
 
-func (g *GeminiProvider) BuildRequestBody(model, prompt string) ([]byte, error) {
-	return BuildGeminiRequestBody(model, prompt)
+func (g *GeminiProvider) BuildRequestBody(req Request) ([]byte, error) {
+	return BuildGeminiRequestBody(req)
 }
 
 func (g *GeminiProvider) ParseResponse(body []byte) (string, error) {
@@ -32,10 +32,5 @@ func (g *GeminiProvider) ParseResponse(body []byte) (string, error) {
 }
 
 func (g *GeminiProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
-	model := g.Model
-	if opts.Model != "" {
-		model = opts.Model
-	}
-
-	return g.MakeHTTPRequest(g, model, promptStr)
+	return g.MakeHTTPRequest(g, NewRequest(g.Model, promptStr, opts))
 }
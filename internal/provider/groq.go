@@ -0,0 +1,38 @@
+// Package provider implements the Groq provider, whose API is compatible with
+// OpenAI's chat completions.
+package provider
+
+import (
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+type GroqProvider struct {
+	BaseHTTPProvider
+}
+
+func (g *GroqProvider) Name() string { return "groq" }
+
+func (g *GroqProvider) GetEndpoint() string {
+	return g.endpoint("https://api.groq.com/openai/v1", "/chat/completions")
+}
+
+func (g *GroqProvider) GetHeaders(apiKey string) map[string]string {
+	return map[string]string{
+		"Authorization": "Bearer " + apiKey,
+		"Content-Type":  "application/json",
+	}
+}
+
+func (g *GroqProvider) BuildRequestBody(req Request) ([]byte, error) {
+	// Only some of Groq's models take a schema, but all with a JSON mode take a JSON object
+	req.Schema = nil
+	return BuildOpenAIStyleRequestBody(req)
+}
+
+func (g *GroqProvider) ParseResponse(body []byte) (string, error) {
+	return ParseOpenAIStyleResponse(body)
+}
+
+func (g *GroqProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
+	return g.MakeHTTPRequest(g, NewRequest(g.Model, promptStr, opts))
+}
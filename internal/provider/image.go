@@ -0,0 +1,49 @@
+// Package provider loads images attached to requests for vision-capable models.
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+)
+
+// Largest image that may be attached. Providers reject larger ones, and
+// they would cost a lot of tokens anyway.
+const maxImageSize = 20 << 20
+
+// imageTypes are the image formats accepted by all vision-capable providers.
+var imageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// Image is a picture attached to a request, such as a screenshot of an error.
+type Image struct {
+	MediaType string // e.g. "image/png"
+	Data      []byte
+}
+
+// Base64 returns the image data encoded as standard base64.
+func (i Image) Base64() string {
+	return base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// LoadImage reads an image file to attach to a request. The format is
+// detected from the file's contents rather than its name.
+func LoadImage(path string) (Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read image: %v", err)
+	}
+	if info.Size() > maxImageSize {
+		return Image{}, fmt.Errorf("image %s is too large (%d MB, at most %d MB)", path, info.Size()>>20, maxImageSize>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read image: %v", err)
+	}
+	mediaType := http.DetectContentType(data)
+	if !slices.Contains(imageTypes, mediaType) {
+		return Image{}, fmt.Errorf("%s is not a PNG, JPEG, GIF or WebP image", path)
+	}
+	return Image{MediaType: mediaType, Data: data}, nil
+}
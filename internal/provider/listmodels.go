@@ -0,0 +1,135 @@
+// Package provider lists the models providers offer, so that a request for a
+// model that doesn't exist can go to one that does.
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+)
+
+// ModelLister is implemented by providers that can list the models they offer.
+type ModelLister interface {
+	ListModels() ([]string, error)
+}
+
+// ModelNotFound reports whether err is a provider saying it doesn't know the
+// requested model, or doesn't offer it to the account.
+func ModelNotFound(err error) bool {
+	var e interface{ ModelNotFound() bool }
+	return errors.As(err, &e) && e.ModelNotFound()
+}
+
+// ollamaListTimeout bounds listing the models of Ollama, which answers at once when running.
+const ollamaListTimeout = 5 * time.Second
+
+func (o *OpenAIProvider) ListModels() ([]string, error) {
+	return openAIStyleModels(o.Name(), o.endpoint("https://api.openai.com/v1", "/models"), o.GetHeaders(o.APIKey))
+}
+
+func (g *GroqProvider) ListModels() ([]string, error) {
+	return openAIStyleModels(g.Name(), g.endpoint("https://api.groq.com/openai/v1", "/models"), g.GetHeaders(g.APIKey))
+}
+
+func (o *OpenRouterProvider) ListModels() ([]string, error) {
+	return openAIStyleModels(o.Name(), o.endpoint("https://openrouter.ai/api/v1", "/models"), o.GetHeaders(o.APIKey))
+}
+
+func (a *AnthropicProvider) ListModels() ([]string, error) {
+	return openAIStyleModels(a.Name(), a.endpoint("https://api.anthropic.com/v1", "/models?limit=1000"), a.GetHeaders(a.APIKey))
+}
+
+func (g *GeminiProvider) ListModels() ([]string, error) {
+	var res struct {
+		Models []struct {
+			Name    string   `json:"name"`
+			Methods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	url := g.endpoint("https://generativelanguage.googleapis.com/v1beta", fmt.Sprintf("/models?pageSize=1000&key=%s", g.APIKey))
+	if err := getModels(g.Name(), url, nil, &res); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range res.Models {
+		if slices.Contains(m.Methods, "generateContent") {
+			names = append(names, strings.TrimPrefix(m.Name, "models/"))
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (o *OllamaProvider) ListModels() ([]string, error) {
+	names, err := o.InstalledModels(ollamaListTimeout)
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(name, ":latest")
+	}
+	slices.Sort(names)
+	return names, err
+}
+
+// openAIStyleModels lists the chat models of an API with OpenAI's list
+// format, which Anthropic's follows too, leaving out models for embeddings,
+// speech, images and moderation.
+func openAIStyleModels(name, url string, headers map[string]string) ([]string, error) {
+	var res struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getModels(name, url, headers, &res); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, m := range res.Data {
+		if !nonChatModel(m.ID) {
+			ids = append(ids, m.ID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// nonChatModels are parts of the names of models that don't answer chat
+// requests, such as OpenAI's text-embedding-3-small or Groq's whisper-large-v3.
+var nonChatModels = []string{"embed", "whisper", "tts", "dall-e", "moderation", "transcribe", "realtime", "audio", "image", "guard", "davinci", "babbage"}
+
+// nonChatModel reports whether a model's name says it doesn't answer chat requests.
+func nonChatModel(id string) bool {
+	id = strings.ToLower(id)
+	for _, part := range nonChatModels {
+		if strings.Contains(id, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// getModels sends a GET request for a list of models and decodes the JSON
+// response into v.
+func getModels(name, url string, headers map[string]string, v any) error {
+	defer interrupt.Busy()()
+	resp, err := sendWithRetry(httpclient.Default(), name, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(interrupt.Context(), "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
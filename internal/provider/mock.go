@@ -0,0 +1,53 @@
+// Package provider implements the mock provider used for testing.
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+)
+
+// MockProvider returns canned responses without contacting any service. It
+// cycles through Responses in order; with none configured it echoes the user's request.
+type MockProvider struct {
+	Responses []string
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (m *MockProvider) Name() string { return "mock" }
+
+func (m *MockProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if len(m.Responses) == 0 {
+		return fmt.Sprintf("echo %q", userRequest(promptStr)), nil
+	}
+	response := m.Responses[(m.calls-1)%len(m.Responses)]
+	if opts.Raw {
+		return response, nil
+	}
+	return extractResult(response, false), nil
+}
+
+// Calls returns how many requests the provider has answered.
+func (m *MockProvider) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+// userRequest extracts the user's request from a prompt, falling back to its last line.
+func userRequest(promptStr string) string {
+	lines := strings.Split(strings.TrimSpace(promptStr), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if request, ok := strings.CutPrefix(lines[i], "User Request: "); ok {
+			return strings.TrimSpace(request)
+		}
+	}
+	return strings.TrimSpace(lines[len(lines)-1])
+}
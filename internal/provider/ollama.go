@@ -3,56 +3,56 @@ package provider
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
 )
 
 type OllamaProvider struct {
-	URL   string
-	Model string
+	URL    string
+	Model  string
+	Client *http.Client // HTTP client to use; the shared client when nil
 }
 
 func (o *OllamaProvider) Name() string { return "ollama" }
 
 func (o *OllamaProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
-	model := o.Model
-	if opts.Model != "" {
-		model = opts.Model
-	}
-
 	// Build request body
-	reqBody, err := BuildOllamaRequestBody(model, promptStr)
+	request := NewRequest(o.Model, promptStr, opts)
+	reqBody, err := BuildOllamaRequestBody(request)
 	if err != nil {
 		return "", err
 	}
 
-	// Create HTTP request
-	url := fmt.Sprintf("%s/api/chat", strings.TrimSuffix(o.URL, "/"))
-	req, err := http.NewRequest("POST", url, bytes.NewReader(reqBody))
-	if err != nil {
-		return "", err
+	// Make request, retrying while overloaded; a signal cancels it rather
+	// than killing the process mid-request
+	defer interrupt.Busy()()
+	client := o.Client
+	if client == nil {
+		client = httpclient.Default()
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	// Make request
-	resp, err := http.DefaultClient.Do(req)
+	url := fmt.Sprintf("%s/api/chat", strings.TrimSuffix(o.URL, "/"))
+	resp, err := sendWithRetry(client, o.Name(), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(interrupt.Context(), "POST", url, bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	// Check status code
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ollama API error (%d): %s", resp.StatusCode, string(body))
-	}
-
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -69,8 +69,32 @@ func (o *OllamaProvider) GenerateCommand(ctx context.Context, promptStr string,
 		return "", errors.New("no content returned from Ollama")
 	}
 
-	// Extract the first line as the shell command
-	content = strings.TrimSpace(content)
-	cmd := strings.SplitN(content, "\n", 2)[0]
-	return cmd, nil
+	return extractResult(content, request.Raw), nil
+}
+
+// InstalledModels lists the models pulled into the Ollama instance, failing
+// if it doesn't answer within timeout.
+func (o *OllamaProvider) InstalledModels(timeout time.Duration) ([]string, error) {
+	client := &http.Client{Timeout: timeout, Transport: httpclient.Default().Transport}
+	resp, err := client.Get(strings.TrimSuffix(o.URL, "/") + "/api/tags")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Ollama answered %s", resp.Status)
+	}
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(tags.Models))
+	for i, m := range tags.Models {
+		names[i] = m.Name
+	}
+	return names, nil
 }
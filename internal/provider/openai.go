@@ -12,7 +12,7 @@ type OpenAIProvider struct {
 func (o *OpenAIProvider) Name() string { return "openai" }
 
 func (o *OpenAIProvider) GetEndpoint() string {
-	return "https://api.openai.com/v1/chat/completions"
+	return o.endpoint("https://api.openai.com/v1", "/chat/completions")
 }
 
 func (o *OpenAIProvider) GetHeaders(apiKey string) map[string]string {
@@ -22,8 +22,8 @@ func (o *OpenAIProvider) GetHeaders(apiKey string) map[string]string {
 	}
 }
 
-func (o *OpenAIProvider) BuildRequestBody(model, prompt string) ([]byte, error) {
-	return BuildOpenAIStyleRequestBody(model, prompt)
+func (o *OpenAIProvider) BuildRequestBody(req Request) ([]byte, error) {
+	return BuildOpenAIStyleRequestBody(req)
 }
 
 func (o *OpenAIProvider) ParseResponse(body []byte) (string, error) {
@@ -31,10 +31,5 @@ func (o *OpenAIProvider) ParseResponse(body []byte) (string, error) {
 }
 
 func (o *OpenAIProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
-	model := o.Model
-	if opts.Model != "" {
-		model = opts.Model
-	}
-
-	return o.MakeHTTPRequest(o, model, promptStr)
+	return o.MakeHTTPRequest(o, NewRequest(o.Model, promptStr, opts))
 }
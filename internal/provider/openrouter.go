@@ -12,7 +12,7 @@ type OpenRouterProvider struct {
 func (o *OpenRouterProvider) Name() string { return "openrouter" }
 
 func (o *OpenRouterProvider) GetEndpoint() string {
-	return "https://openrouter.ai/api/v1/chat/completions"
+	return o.endpoint("https://openrouter.ai/api/v1", "/chat/completions")
 }
 
 func (o *OpenRouterProvider) GetHeaders(apiKey string) map[string]string {
@@ -23,8 +23,10 @@ func (o *OpenRouterProvider) GetHeaders(apiKey string) map[string]string {
 	}
 }
 
-func (o *OpenRouterProvider) BuildRequestBody(model, prompt string) ([]byte, error) {
-	return BuildOpenAIStyleRequestBody(model, prompt)
+func (o *OpenRouterProvider) BuildRequestBody(req Request) ([]byte, error) {
+	// Not every routed model takes a schema, so ask for any JSON object
+	req.Schema = nil
+	return BuildOpenAIStyleRequestBody(req)
 }
 
 func (o *OpenRouterProvider) ParseResponse(body []byte) (string, error) {
@@ -32,10 +34,5 @@ func (o *OpenRouterProvider) ParseResponse(body []byte) (string, error) {
 }
 
 func (o *OpenRouterProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
-	model := o.Model
-	if opts.Model != "" {
-		model = opts.Model
-	}
-
-	return o.MakeHTTPRequest(o, model, promptStr)
+	return o.MakeHTTPRequest(o, NewRequest(o.Model, promptStr, opts))
 }
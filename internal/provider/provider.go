@@ -8,16 +8,87 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
 
 	"github.com/kanishka-sahoo/nlch/internal/config"
 	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
 )
 
 // ProviderOptions holds options for provider calls (e.g., model override).
 type ProviderOptions struct {
-	Model    string
-	Provider string
+	Model     string
+	Provider  string
+	System    string         // Overrides the default system prompt
+	MaxTokens int            // Overrides the default response token limit
+	Raw       bool           // Return the full response instead of only its first line
+	History   []Message      // Earlier turns of the conversation, oldest first
+	Images    []Image        // Images attached to the prompt, for vision-capable models
+	JSON      bool           // Constrain the reply to a JSON object, for models with a JSON mode
+	Schema    map[string]any // JSON schema of the reply with JSON, for APIs that take one
+}
+
+// Message is a single earlier turn in a conversation with the model.
+type Message struct {
+	Role    string // "user" or "assistant"
+	Content string
+}
+
+// DefaultMaxTokens is the maximum number of tokens in a provider response, unless overridden.
+const DefaultMaxTokens = 128
+
+// Request holds everything needed to build a single provider API request.
+type Request struct {
+	Model     string
+	System    string
+	Prompt    string
+	MaxTokens int
+	Raw       bool
+	History   []Message
+	Images    []Image
+	JSON      bool
+	Schema    map[string]any
+}
+
+// NewRequest builds a Request for the given model and prompt, applying provider options.
+func NewRequest(model, promptStr string, opts ProviderOptions) Request {
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	system := opts.System
+	if system == "" {
+		system = prompt.DefaultSystemPrompt
+	}
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+	return Request{
+		Model:     model,
+		System:    system,
+		Prompt:    promptStr,
+		MaxTokens: maxTokens,
+		Raw:       opts.Raw,
+		History:   opts.History,
+		Images:    opts.Images,
+		JSON:      opts.JSON,
+		Schema:    opts.Schema,
+	}
+}
+
+// chatMessages returns the conversation history followed by the prompt as
+// role/content maps. The prompt's content is given in the provider's format,
+// since that is where any images are attached.
+func chatMessages(req Request, content any) []map[string]any {
+	messages := make([]map[string]any, 0, len(req.History)+1)
+	for _, m := range req.History {
+		messages = append(messages, map[string]any{"role": m.Role, "content": m.Content})
+	}
+	return append(messages, map[string]any{"role": "user", "content": content})
 }
 
 // Provider is the interface for LLM backends.
@@ -30,49 +101,67 @@ type Provider interface {
 type HTTPProvider interface {
 	GetEndpoint() string
 	GetHeaders(apiKey string) map[string]string
-	BuildRequestBody(model, prompt string) ([]byte, error)
+	BuildRequestBody(req Request) ([]byte, error)
 	ParseResponse(body []byte) (string, error)
 }
 
 // BaseHTTPProvider provides common HTTP functionality for API-based providers
 type BaseHTTPProvider struct {
-	APIKey string
-	Model  string
+	APIKey   string
+	Model    string
+	Endpoint string       // base URL of a compatible gateway that replaces the provider's own; "" for the provider's API
+	Client   *http.Client // HTTP client to use; the shared client when nil
 }
 
-// MakeHTTPRequest performs the common HTTP request logic
-func (b *BaseHTTPProvider) MakeHTTPRequest(httpProvider HTTPProvider, model, prompt string) (string, error) {
-	// Build request body
-	reqBody, err := httpProvider.BuildRequestBody(model, prompt)
-	if err != nil {
-		return "", err
+// endpoint joins path to the configured gateway's base URL, or to base, the
+// provider's own, when there is none.
+func (b *BaseHTTPProvider) endpoint(base, path string) string {
+	if b.Endpoint != "" {
+		base = strings.TrimSuffix(b.Endpoint, "/")
 	}
+	return base + path
+}
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", httpProvider.GetEndpoint(), bytes.NewReader(reqBody))
+// httpClient returns the injected client, or the shared one.
+func (b *BaseHTTPProvider) httpClient() *http.Client {
+	if b.Client != nil {
+		return b.Client
+	}
+	return httpclient.Default()
+}
+
+// MakeHTTPRequest performs the common HTTP request logic
+func (b *BaseHTTPProvider) MakeHTTPRequest(httpProvider HTTPProvider, request Request) (string, error) {
+	// Build request body
+	reqBody, err := httpProvider.BuildRequestBody(request)
 	if err != nil {
 		return "", err
 	}
 
-	// Set headers
-	headers := httpProvider.GetHeaders(b.APIKey)
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	// Name the provider in errors and retry notices
+	name := httpProvider.GetEndpoint()
+	if p, ok := httpProvider.(Provider); ok {
+		name = p.Name()
 	}
 
-	// Make request
-	resp, err := http.DefaultClient.Do(req)
+	// Make request, retrying while rate limited; a signal cancels it rather
+	// than killing the process mid-request
+	defer interrupt.Busy()()
+	resp, err := sendWithRetry(b.httpClient(), name, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(interrupt.Context(), "POST", httpProvider.GetEndpoint(), bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range httpProvider.GetHeaders(b.APIKey) {
+			req.Header.Set(key, value)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	// Check status code
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
-	}
-
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -89,26 +178,57 @@ func (b *BaseHTTPProvider) MakeHTTPRequest(httpProvider HTTPProvider, model, pro
 		return "", errors.New("no content returned from API")
 	}
 
-	// Extract the first line as the shell command
+	return extractResult(content, request.Raw), nil
+}
+
+// extractResult trims the response and, unless raw output was requested,
+// keeps only the first line as the shell command.
+func extractResult(content string, raw bool) string {
 	content = strings.TrimSpace(content)
-	cmd := strings.SplitN(content, "\n", 2)[0]
-	return cmd, nil
+	if raw {
+		return content
+	}
+	return strings.SplitN(content, "\n", 2)[0]
 }
 
 // BuildOpenAIStyleRequestBody creates an OpenAI-compatible request body
-func BuildOpenAIStyleRequestBody(model, prompt string) ([]byte, error) {
+func BuildOpenAIStyleRequestBody(req Request) ([]byte, error) {
 	reqBody := map[string]any{
-		"model": model,
-		"messages": []map[string]string{
-			{"role": "system", "content": "You are a helpful assistant that generates safe, concise shell commands for the user's request."},
-			{"role": "user", "content": prompt},
-		},
-		"max_tokens":  128,
+		"model": req.Model,
+		"messages": append([]map[string]any{
+			{"role": "system", "content": req.System},
+		}, chatMessages(req, openAIStyleContent(req))...),
+		"max_tokens":  req.MaxTokens,
 		"temperature": 0.2,
 	}
+	switch {
+	case req.JSON && req.Schema != nil:
+		reqBody["response_format"] = map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "reply", "schema": req.Schema, "strict": true},
+		}
+	case req.JSON:
+		reqBody["response_format"] = map[string]string{"type": "json_object"}
+	}
 	return json.Marshal(reqBody)
 }
 
+// openAIStyleContent returns the content of the prompt message: plain text, or
+// text and image parts when images are attached.
+func openAIStyleContent(req Request) any {
+	if len(req.Images) == 0 {
+		return req.Prompt
+	}
+	parts := []map[string]any{{"type": "text", "text": req.Prompt}}
+	for _, img := range req.Images {
+		parts = append(parts, map[string]any{
+			"type":      "image_url",
+			"image_url": map[string]string{"url": "data:" + img.MediaType + ";base64," + img.Base64()},
+		})
+	}
+	return parts
+}
+
 // ParseOpenAIStyleResponse parses an OpenAI-compatible response
 func ParseOpenAIStyleResponse(body []byte) (string, error) {
 	var res struct {
@@ -131,23 +251,46 @@ func ParseOpenAIStyleResponse(body []byte) (string, error) {
 }
 
 // BuildAnthropicRequestBody creates an Anthropic-specific request body
-func BuildAnthropicRequestBody(model, prompt string) ([]byte, error) {
+func BuildAnthropicRequestBody(req Request) ([]byte, error) {
 	reqBody := map[string]any{
-		"model": model,
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
-		"max_tokens": 128,
-		"system":     "You are a helpful assistant that generates safe, concise shell commands for the user's request.",
+		"model":      req.Model,
+		"messages":   chatMessages(req, anthropicContent(req)),
+		"max_tokens": req.MaxTokens,
+		"system":     req.System,
+	}
+	if req.JSON {
+		anthropicTools(reqBody, req.Schema)
 	}
 	return json.Marshal(reqBody)
 }
 
+// anthropicContent returns the content of the prompt message: plain text, or
+// image blocks followed by the text when images are attached.
+func anthropicContent(req Request) any {
+	if len(req.Images) == 0 {
+		return req.Prompt
+	}
+	blocks := []map[string]any{}
+	for _, img := range req.Images {
+		blocks = append(blocks, map[string]any{
+			"type": "image",
+			"source": map[string]string{
+				"type":       "base64",
+				"media_type": img.MediaType,
+				"data":       img.Base64(),
+			},
+		})
+	}
+	return append(blocks, map[string]any{"type": "text", "text": req.Prompt})
+}
+
 // ParseAnthropicResponse parses an Anthropic-specific response
 func ParseAnthropicResponse(body []byte) (string, error) {
 	var res struct {
 		Content []struct {
-			Text string `json:"text"`
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			Input json.RawMessage `json:"input"`
 		} `json:"content"`
 	}
 
@@ -159,23 +302,56 @@ func ParseAnthropicResponse(body []byte) (string, error) {
 		return "", errors.New("no content returned from API")
 	}
 
+	// A reply held to a schema is the input of the tool call
+	for _, block := range res.Content {
+		if block.Type == "tool_use" {
+			return string(block.Input), nil
+		}
+	}
 	return res.Content[0].Text, nil
 }
 
 // BuildGeminiRequestBody creates a Gemini-specific request body
-func BuildGeminiRequestBody(model, prompt string) ([]byte, error) {
+func BuildGeminiRequestBody(req Request) ([]byte, error) {
+	// Gemini names the assistant role "model" and takes the system prompt separately
+	contents := make([]map[string]any, 0, len(req.History)+1)
+	for _, m := range req.History {
+		role := m.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, map[string]any{
+			"role":  role,
+			"parts": []map[string]string{{"text": m.Content}},
+		})
+	}
+	parts := []map[string]any{{"text": req.Prompt}}
+	for _, img := range req.Images {
+		parts = append(parts, map[string]any{
+			"inlineData": map[string]string{"mimeType": img.MediaType, "data": img.Base64()},
+		})
+	}
+	contents = append(contents, map[string]any{
+		"role":  "user",
+		"parts": parts,
+	})
+
+	generationConfig := map[string]any{
+		"maxOutputTokens": req.MaxTokens,
+		"temperature":     0.2,
+	}
+	if req.JSON {
+		generationConfig["responseMimeType"] = "application/json"
+		if req.Schema != nil {
+			generationConfig["responseSchema"] = geminiSchema(req.Schema)
+		}
+	}
 	reqBody := map[string]any{
-		"contents": []map[string]any{
-			{
-				"parts": []map[string]string{
-					{"text": "You are a helpful assistant that generates safe, concise shell commands for the user's request.\n\n" + prompt},
-				},
-			},
-		},
-		"generationConfig": map[string]any{
-			"maxOutputTokens": 128,
-			"temperature":     0.2,
+		"systemInstruction": map[string]any{
+			"parts": []map[string]string{{"text": req.System}},
 		},
+		"contents":         contents,
+		"generationConfig": generationConfig,
 	}
 	return json.Marshal(reqBody)
 }
@@ -204,19 +380,30 @@ func ParseGeminiResponse(body []byte) (string, error) {
 }
 
 // BuildOllamaRequestBody creates an Ollama-specific request body
-func BuildOllamaRequestBody(model, prompt string) ([]byte, error) {
+func BuildOllamaRequestBody(req Request) ([]byte, error) {
+	// Ollama takes images as a list of base64 strings beside the message text
+	messages := append([]map[string]any{
+		{"role": "system", "content": req.System},
+	}, chatMessages(req, req.Prompt)...)
+	if len(req.Images) > 0 {
+		images := make([]string, len(req.Images))
+		for i, img := range req.Images {
+			images[i] = img.Base64()
+		}
+		messages[len(messages)-1]["images"] = images
+	}
 	reqBody := map[string]any{
-		"model": model,
-		"messages": []map[string]string{
-			{"role": "system", "content": "You are a helpful assistant that generates safe, concise shell commands for the user's request."},
-			{"role": "user", "content": prompt},
-		},
-		"stream": false,
+		"model":    req.Model,
+		"messages": messages,
+		"stream":   false,
 		"options": map[string]any{
-			"num_predict": 128,
+			"num_predict": req.MaxTokens,
 			"temperature": 0.2,
 		},
 	}
+	if req.JSON {
+		reqBody["format"] = "json"
+	}
 	return json.Marshal(reqBody)
 }
 
@@ -239,78 +426,106 @@ func ParseOllamaResponse(body []byte) (string, error) {
 	return res.Message.Content, nil
 }
 
-// Registry holds registered providers.
-var registry = make(map[string]Provider)
-
-// Register adds a provider to the registry.
-func Register(p Provider) {
-	registry[p.Name()] = p
-}
-
-// Get returns a provider by name.
-func Get(name string) (Provider, bool) {
-	p, ok := registry[name]
-	return p, ok
+// validate checks that a built-in provider can be created from its configuration.
+func validate(name string, providerConfig config.ProviderConfig) error {
+	switch name {
+	case "openrouter", "anthropic", "openai", "gemini", "groq", "cohere":
+		if providerConfig.Key == "" {
+			return fmt.Errorf("provider '%s' needs an API key", name)
+		}
+	case "bedrock":
+		if providerConfig.Region == "" && AWSRegion(providerConfig.Profile) == "" {
+			return fmt.Errorf("provider '%s' needs a region", name)
+		}
+	case "mock", "ollama", "vertex":
+	default:
+		return fmt.Errorf("unknown provider '%s'", name)
+	}
+	if providerConfig.Endpoint != "" {
+		if u, err := url.Parse(providerConfig.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("provider '%s' has an invalid endpoint %q, use an http:// or https:// URL", name, providerConfig.Endpoint)
+		}
+	}
+	return nil
 }
 
-// List returns all registered providers.
-func List() []Provider {
-	providers := make([]Provider, 0, len(registry))
-	for _, p := range registry {
-		providers = append(providers, p)
+// Local reports whether requests to a configured provider stay on this
+// machine: the mock provider, and Ollama at a local URL.
+func Local(name string, providerConfig config.ProviderConfig) bool {
+	switch name {
+	case "mock":
+		return true
+	case "ollama":
+		return httpclient.IsLocal(OllamaURL(providerConfig))
 	}
-	return providers
+	return false
 }
 
-// RegisterProvidersFromConfig registers all configured providers
-func RegisterProvidersFromConfig(configProviders map[string]config.ProviderConfig) {
-	for name, providerConfig := range configProviders {
-		switch name {
-		case "openrouter":
-			if providerConfig.Key != "" {
-				Register(&OpenRouterProvider{
-					BaseHTTPProvider: BaseHTTPProvider{
-						APIKey: providerConfig.Key,
-						Model:  providerConfig.DefaultModel,
-					},
-				})
-			}
-		case "anthropic":
-			if providerConfig.Key != "" {
-				Register(&AnthropicProvider{
-					BaseHTTPProvider: BaseHTTPProvider{
-						APIKey: providerConfig.Key,
-						Model:  providerConfig.DefaultModel,
-					},
-				})
-			}
-		case "openai":
-			if providerConfig.Key != "" {
-				Register(&OpenAIProvider{
-					BaseHTTPProvider: BaseHTTPProvider{
-						APIKey: providerConfig.Key,
-						Model:  providerConfig.DefaultModel,
-					},
-				})
-			}
-		case "gemini":
-			if providerConfig.Key != "" {
-				Register(&GeminiProvider{
-					BaseHTTPProvider: BaseHTTPProvider{
-						APIKey: providerConfig.Key,
-						Model:  providerConfig.DefaultModel,
-					},
-				})
-			}
-		case "ollama":
-			url := providerConfig.URL
-			if url == "" {
-				url = "http://localhost:11434"
+// New creates a built-in provider from its configuration.
+func New(name string, providerConfig config.ProviderConfig) (Provider, error) {
+	if err := validate(name, providerConfig); err != nil {
+		return nil, err
+	}
+	base := BaseHTTPProvider{
+		APIKey:   providerConfig.Key,
+		Model:    providerConfig.DefaultModel,
+		Endpoint: providerConfig.Endpoint,
+	}
+	switch name {
+	case "openrouter":
+		return &OpenRouterProvider{BaseHTTPProvider: base}, nil
+	case "anthropic":
+		return &AnthropicProvider{BaseHTTPProvider: base}, nil
+	case "openai":
+		return &OpenAIProvider{BaseHTTPProvider: base}, nil
+	case "gemini":
+		return &GeminiProvider{BaseHTTPProvider: base}, nil
+	case "groq":
+		return &GroqProvider{BaseHTTPProvider: base}, nil
+	case "cohere":
+		return &CohereProvider{BaseHTTPProvider: base}, nil
+	case "mock":
+		return &MockProvider{Responses: providerConfig.Responses}, nil
+	case "bedrock":
+		region := providerConfig.Region
+		if region == "" {
+			region = AWSRegion(providerConfig.Profile)
+		}
+		model := providerConfig.DefaultModel
+		if model == "" {
+			model = config.DefaultModels[name]
+		}
+		return &BedrockProvider{Region: region, Model: model, Profile: providerConfig.Profile, Endpoint: providerConfig.Endpoint}, nil
+	case "vertex":
+		region := providerConfig.Region
+		for _, name := range []string{"GOOGLE_CLOUD_LOCATION", "CLOUD_ML_REGION"} {
+			if region == "" {
+				region = os.Getenv(name)
 			}
-			Register(&OllamaProvider{
-				URL:   url,
-				Model: providerConfig.DefaultModel,
-			})
 		}
+		if region == "" {
+			region = "us-central1"
+		}
+		model := providerConfig.DefaultModel
+		if model == "" {
+			model = config.DefaultModels[name]
+		}
+		return &VertexProvider{Project: providerConfig.Project, Region: region, Model: model, Endpoint: providerConfig.Endpoint}, nil
+	}
+	return &OllamaProvider{
+		URL:   OllamaURL(providerConfig),
+		Model: providerConfig.DefaultModel,
+	}, nil
+}
+
+// OllamaURL returns the URL of Ollama in its configuration, given as url or
+// endpoint, or the default of a local install.
+func OllamaURL(providerConfig config.ProviderConfig) string {
+	switch {
+	case providerConfig.Endpoint != "":
+		return providerConfig.Endpoint
+	case providerConfig.URL != "":
+		return providerConfig.URL
 	}
+	return "http://localhost:11434"
 }
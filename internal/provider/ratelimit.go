@@ -0,0 +1,263 @@
+// Package provider retries rate-limited requests and turns provider API
+// errors into readable messages.
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+)
+
+// Retry bounds: a rate-limited request is retried at most maxRetries times,
+// and not at all when the provider asks to wait longer than maxRetryWait.
+const (
+	maxRetries   = 3
+	maxRetryWait = 60 * time.Second
+)
+
+// Maximum length of a raw error body quoted in an error message.
+const maxErrorBody = 300
+
+// RetryNotice reports a request that is about to be retried. It prints to stderr by default.
+var RetryNotice = func(message string) {
+	fmt.Fprintf(os.Stderr, "nlch: %s\n", message)
+}
+
+// displayNames are the names providers are called by in messages.
+var displayNames = map[string]string{
+	"openai":     "OpenAI",
+	"anthropic":  "Anthropic",
+	"openrouter": "OpenRouter",
+	"gemini":     "Gemini",
+	"ollama":     "Ollama",
+	"bedrock":    "Bedrock",
+	"vertex":     "Vertex AI",
+	"groq":       "Groq",
+	"cohere":     "Cohere",
+}
+
+// displayName returns the name of a provider for use in messages.
+func displayName(name string) string {
+	if display, ok := displayNames[name]; ok {
+		return display
+	}
+	return name
+}
+
+// APIError is an error response from a provider API.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string        // the provider's own explanation, or the raw body
+	RetryAfter time.Duration // how long the provider asked to wait, if it did
+	Quota      bool          // the account is out of credit, so waiting will not help
+}
+
+func (e *APIError) Error() string {
+	name := displayName(e.Provider)
+	switch {
+	case e.Quota:
+		return fmt.Sprintf("%s quota exceeded: %s", name, e.Message)
+	case e.StatusCode == http.StatusTooManyRequests && e.RetryAfter > 0:
+		return fmt.Sprintf("%s rate limited the request, try again in %s: %s", name, formatWait(e.RetryAfter), e.Message)
+	case e.StatusCode == http.StatusTooManyRequests:
+		return fmt.Sprintf("%s rate limited the request: %s", name, e.Message)
+	}
+	return fmt.Sprintf("%s API error (%d): %s", name, e.StatusCode, e.Message)
+}
+
+// RateLimited reports whether the provider refused the request because of a rate limit.
+func (e *APIError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests && !e.Quota
+}
+
+// ModelNotFound reports whether the provider doesn't know the requested model
+// or doesn't offer it to the account. Most answer 404 Not Found; OpenRouter
+// and Bedrock answer 400 Bad Request, saying the model ID is invalid.
+func (e *APIError) ModelNotFound() bool {
+	msg := strings.ToLower(e.Message)
+	if !strings.Contains(msg, "model") {
+		return false
+	}
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return true
+	case http.StatusBadRequest:
+		for _, phrase := range []string{"not a valid model", "model identifier is invalid", "does not exist", "not found"} {
+			if strings.Contains(msg, phrase) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryable reports whether the request may succeed if sent again after a while:
+// it was rate limited, or the service was temporarily overloaded.
+func (e *APIError) retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, 529: // 529: Anthropic is overloaded
+		return !e.Quota
+	}
+	return false
+}
+
+// sendWithRetry sends the request built by newRequest, retrying it while the
+// provider reports a rate limit or overload and asks for a bounded wait. Any
+// response other than 200 OK is returned as an *APIError.
+func sendWithRetry(client *http.Client, name string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if interrupt.Interrupted() {
+				return nil, interrupt.ErrInterrupted
+			}
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		apiErr := newAPIError(name, resp, body)
+
+		if !apiErr.retryable() || attempt == maxRetries || apiErr.RetryAfter > maxRetryWait {
+			return nil, apiErr
+		}
+
+		// Without a hint from the provider, back off exponentially
+		wait := apiErr.RetryAfter
+		if wait <= 0 {
+			wait = time.Second << attempt
+		}
+		reason := "rate limited"
+		if apiErr.StatusCode != http.StatusTooManyRequests {
+			reason = "overloaded"
+		}
+		RetryNotice(fmt.Sprintf("%s %s, retrying in %s", displayName(name), reason, formatWait(wait)))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-interrupt.Context().Done():
+			timer.Stop()
+			return nil, interrupt.ErrInterrupted
+		}
+	}
+}
+
+// newAPIError builds the error for a failed response from its status, headers and body.
+func newAPIError(name string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{Provider: name, StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+
+	// OpenAI, Anthropic, OpenRouter and Gemini wrap an object in "error";
+	// Ollama uses a plain string, and Bedrock a message at the top level
+	var res struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	var detail struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    any    `json:"code"`
+		Details []struct {
+			RetryDelay string `json:"retryDelay"`
+		} `json:"details"`
+	}
+	if json.Unmarshal(body, &res) == nil && len(res.Error) > 0 {
+		if json.Unmarshal(res.Error, &apiErr.Message) != nil && json.Unmarshal(res.Error, &detail) == nil {
+			apiErr.Message = detail.Message
+			apiErr.Quota = detail.Type == "insufficient_quota" || detail.Code == "insufficient_quota"
+			// Gemini puts the wait in the body rather than a header
+			for _, d := range detail.Details {
+				if delay, err := time.ParseDuration(d.RetryDelay); err == nil && apiErr.RetryAfter == 0 {
+					apiErr.RetryAfter = delay
+				}
+			}
+		}
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = res.Message
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+		if len(apiErr.Message) > maxErrorBody {
+			apiErr.Message = apiErr.Message[:maxErrorBody] + "..."
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// retryAfter returns how long the response headers ask to wait before
+// retrying, or 0 if they don't say.
+func retryAfter(h http.Header) time.Duration {
+	// Retry-After holds seconds or an HTTP date; OpenAI also sends milliseconds
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(secs * float64(time.Second))
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return time.Until(at)
+		}
+	}
+
+	// Otherwise use the reset time of whichever limit is exhausted. OpenAI
+	// sends durations such as "6m0s", Anthropic RFC 3339 timestamps
+	var wait time.Duration
+	for _, prefix := range []string{"X-Ratelimit-", "Anthropic-Ratelimit-"} {
+		for _, limit := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+			if h.Get(prefix+"Remaining-"+limit) != "0" && h.Get(prefix+limit+"-Remaining") != "0" {
+				continue
+			}
+			reset := h.Get(prefix + "Reset-" + limit)
+			if reset == "" {
+				reset = h.Get(prefix + limit + "-Reset")
+			}
+			if d := parseReset(reset); d > wait {
+				wait = d
+			}
+		}
+	}
+
+	// OpenRouter sends the reset time of its limit in Unix milliseconds
+	if wait == 0 {
+		if ms, err := strconv.ParseInt(h.Get("X-Ratelimit-Reset"), 10, 64); err == nil {
+			wait = time.Until(time.UnixMilli(ms))
+		}
+	}
+	return max(wait, 0)
+}
+
+// parseReset parses a rate limit reset given as a duration or a timestamp.
+func parseReset(v string) time.Duration {
+	if d, err := time.ParseDuration(v); err == nil {
+		return d
+	}
+	if at, err := time.Parse(time.RFC3339, v); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// formatWait formats a wait for display, rounded up to whole seconds.
+func formatWait(d time.Duration) string {
+	return (d + time.Second - 1).Truncate(time.Second).String()
+}
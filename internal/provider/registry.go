@@ -0,0 +1,182 @@
+// Package provider keeps the registry of providers nlch can use.
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+)
+
+// Registry holds registered providers, and the factories of providers that
+// are only constructed when first requested. It is safe for concurrent use,
+// as the daemon re-registers providers while serving requests.
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Provider)
+	factories  = make(map[string]func() Provider)
+	configured = make(map[string]bool)  // names registered by RegisterProvidersFromConfig
+	invalid    = make(map[string]error) // why configured providers that failed validation were left out
+)
+
+// Register adds a provider to the registry, replacing any with the same name.
+func Register(p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[p.Name()] = p
+	delete(factories, p.Name())
+	delete(configured, p.Name())
+}
+
+// RegisterFactory adds a provider that is constructed by factory the first
+// time it is requested, replacing any with the same name.
+func RegisterFactory(name string, factory func() Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	factories[name] = factory
+	delete(registry, name)
+	delete(configured, name)
+}
+
+// Unregister removes a provider, reporting whether there was one.
+func Unregister(name string) bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return unregister(name)
+}
+
+// unregister removes a provider; registryMu must be held.
+func unregister(name string) bool {
+	_, registered := registry[name]
+	_, pending := factories[name]
+	delete(registry, name)
+	delete(factories, name)
+	delete(configured, name)
+	return registered || pending
+}
+
+// Swap registers p in place of the provider with the same name and returns a
+// function that puts the previous one, or none, back. Tests use it to
+// substitute a provider for their duration.
+func Swap(p Provider) (restore func()) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name := p.Name()
+	previous, hadPrevious := registry[name]
+	factory, hadFactory := factories[name]
+	wasConfigured := configured[name]
+	registry[name] = p
+	delete(factories, name)
+	delete(configured, name)
+	return func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		unregister(name)
+		switch {
+		case hadPrevious:
+			registry[name] = previous
+		case hadFactory:
+			factories[name] = factory
+		}
+		if wasConfigured {
+			configured[name] = true
+		}
+	}
+}
+
+// Get returns a provider by name, constructing it if needed.
+func Get(name string) (Provider, bool) {
+	registryMu.RLock()
+	p, ok := registry[name]
+	registryMu.RUnlock()
+	if ok {
+		return p, true
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	// Another caller may have constructed it meanwhile
+	if p, ok := registry[name]; ok {
+		return p, true
+	}
+	factory, ok := factories[name]
+	if !ok {
+		return nil, false
+	}
+	p = factory()
+	registry[name] = p
+	delete(factories, name)
+	return p, true
+}
+
+// Names returns the names of all registered providers, sorted, without constructing any.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry)+len(factories))
+	for name := range registry {
+		names = append(names, name)
+	}
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List returns all registered providers, constructing any that haven't been yet.
+func List() []Provider {
+	providers := []Provider{}
+	for _, name := range Names() {
+		if p, ok := Get(name); ok {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// RegisterProvidersFromConfig registers all configured providers. They are
+// only constructed when selected. Providers registered by an earlier call
+// that are no longer configured, or no longer valid, are removed, so a
+// reloaded config takes full effect. Invalid providers are left out, and
+// NotFound reports why when one of them is selected.
+func RegisterProvidersFromConfig(configProviders map[string]config.ProviderConfig) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for name := range configured {
+		if _, ok := configProviders[name]; !ok || validate(name, configProviders[name]) != nil {
+			unregister(name)
+		}
+	}
+	invalid = make(map[string]error)
+	for name, providerConfig := range configProviders {
+		if err := validate(name, providerConfig); err != nil {
+			invalid[name] = err
+			continue
+		}
+		factories[name] = func() Provider {
+			p, _ := New(name, providerConfig)
+			return p
+		}
+		delete(registry, name)
+		configured[name] = true
+	}
+}
+
+// Invalid returns why the configured provider of that name was left out by
+// RegisterProvidersFromConfig, or nil if it wasn't.
+func Invalid(name string) error {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return invalid[name]
+}
+
+// NotFound explains why Get found no provider of that name: the configured
+// provider failed validation, or no such provider is registered.
+func NotFound(name string) error {
+	if err := Invalid(name); err != nil {
+		return err
+	}
+	return fmt.Errorf("provider '%s' not found. Available: %v", name, Names())
+}
@@ -0,0 +1,96 @@
+// Package provider negotiates native structured output: how, if at all, a
+// provider's API holds a reply to a JSON schema for the model in use.
+package provider
+
+import (
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/models"
+)
+
+// JSONMode returns the feature a built-in provider uses to hold replies of
+// the model to JSON, such as "tool use" for Anthropic, or "" when the model
+// has none and the prompt alone asks for the format.
+func JSONMode(providerName, model string) string {
+	caps := models.For(model)
+	switch providerName {
+	case "openai", "cohere":
+		if caps.JSONMode {
+			return "response_format json_schema"
+		}
+	case "openrouter", "groq":
+		// Not every routed or Groq model takes a schema, but those with a JSON mode take a JSON object
+		if caps.JSONMode {
+			return "response_format json_object"
+		}
+	case "gemini", "vertex":
+		if caps.JSONMode {
+			return "responseSchema"
+		}
+	case "anthropic":
+		// Claude has no JSON mode, but is held to a tool's input schema when made to call it
+		if caps.FunctionCalling {
+			return "tool use"
+		}
+	case "ollama":
+		if caps.JSONMode {
+			return "format json"
+		}
+	}
+	return ""
+}
+
+// replyTool is the name of the tool Anthropic models are made to call with
+// the reply as its input.
+const replyTool = "reply"
+
+// anthropicTools makes the model call a tool whose input is the reply, the
+// way to hold Claude to a schema.
+func anthropicTools(reqBody map[string]any, schema map[string]any) {
+	if schema == nil {
+		schema = map[string]any{"type": "object"}
+	}
+	reqBody["tools"] = []map[string]any{{
+		"name":         replyTool,
+		"description":  "Give the reply as a JSON object in the requested format.",
+		"input_schema": schema,
+	}}
+	reqBody["tool_choice"] = map[string]string{"type": "tool", "name": replyTool}
+}
+
+// geminiSchema converts a JSON schema to the OpenAPI subset Gemini accepts:
+// upper case types and no additionalProperties.
+func geminiSchema(schema any) any {
+	switch s := schema.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(s))
+		for k, v := range s {
+			switch k {
+			case "additionalProperties":
+			case "properties":
+				// Keys are property names, not keywords
+				if props, ok := v.(map[string]any); ok {
+					converted := make(map[string]any, len(props))
+					for name, prop := range props {
+						converted[name] = geminiSchema(prop)
+					}
+					out[k] = converted
+				}
+			case "type":
+				if t, ok := v.(string); ok {
+					out[k] = strings.ToUpper(t)
+				}
+			default:
+				out[k] = geminiSchema(v)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(s))
+		for i, v := range s {
+			out[i] = geminiSchema(v)
+		}
+		return out
+	}
+	return schema
+}
@@ -0,0 +1,128 @@
+// Package provider implements the Google Vertex AI provider, which reaches
+// Gemini models through a Google Cloud project rather than an AI Studio key.
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+)
+
+type VertexProvider struct {
+	Project  string       // Google Cloud project; from the environment or credentials when empty
+	Region   string       // e.g. us-central1, or global
+	Model    string       // e.g. gemini-1.5-flash
+	Endpoint string       // base URL replacing the regional aiplatform endpoint, such as a Private Service Connect one
+	Client   *http.Client // HTTP client to use; the shared client when nil
+
+	mu    sync.Mutex
+	token googleToken // got on the first request, and again when it expires
+}
+
+func (v *VertexProvider) Name() string { return "vertex" }
+
+// GetEndpoint returns the URL requests for the default model go to.
+func (v *VertexProvider) GetEndpoint() string {
+	return v.endpoint(v.Project, v.Model)
+}
+
+// endpoint returns the generateContent URL of a Gemini model in a project,
+// in the provider's region or at its configured endpoint.
+func (v *VertexProvider) endpoint(project, model string) string {
+	base := v.Endpoint
+	switch {
+	case base != "":
+	case v.Region == "global":
+		base = "https://aiplatform.googleapis.com"
+	default:
+		base = fmt.Sprintf("https://%s-aiplatform.googleapis.com", v.Region)
+	}
+	return fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/google/models/%s:generateContent", strings.TrimSuffix(base, "/"), project, v.Region, model)
+}
+
+func (v *VertexProvider) GenerateCommand(ctx context.Context, promptStr string, opts ProviderOptions) (string, error) {
+	// Build request body, which has the Gemini API's format
+	request := NewRequest(v.Model, promptStr, opts)
+	reqBody, err := BuildGeminiRequestBody(request)
+	if err != nil {
+		return "", err
+	}
+	token, project, err := v.credentials()
+	if err != nil {
+		return "", err
+	}
+
+	// Make request, retrying while rate limited; a signal cancels it rather
+	// than killing the process mid-request
+	defer interrupt.Busy()()
+	client := v.Client
+	if client == nil {
+		client = httpclient.Default()
+	}
+	url := v.endpoint(project, request.Model)
+	resp, err := sendWithRetry(client, v.Name(), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(interrupt.Context(), "POST", url, bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		if token.QuotaProject != "" {
+			req.Header.Set("X-Goog-User-Project", token.QuotaProject)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// Parse response
+	content, err := ParseGeminiResponse(body)
+	if err != nil {
+		return "", err
+	}
+
+	if content == "" {
+		return "", errors.New("no content returned from Vertex AI")
+	}
+
+	return extractResult(content, request.Raw), nil
+}
+
+// credentials returns the access token to send and the project to send
+// requests to, getting a token on first use and again when it is about to
+// expire.
+func (v *VertexProvider) credentials() (googleToken, string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.token.AccessToken == "" || v.token.expiring(time.Now()) {
+		token, err := googleAccessToken()
+		if err != nil {
+			return googleToken{}, "", err
+		}
+		v.token = token
+	}
+	if v.Project == "" {
+		v.Project = GoogleProject()
+	}
+	if v.Project == "" {
+		return googleToken{}, "", errors.New("provider 'vertex' needs a project: set project in its config, or GOOGLE_CLOUD_PROJECT")
+	}
+	return v.token, v.Project, nil
+}
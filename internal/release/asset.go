@@ -0,0 +1,66 @@
+// Package release finds the binaries of a release and the platforms they are built for.
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Binary is a release binary for one platform.
+type Binary struct {
+	OS, Arch string // as in asset names, e.g. linux and armv7
+	Asset    string // file name, e.g. nlch-linux-armv7
+	Path     string
+	SHA256   string
+	Size     int64
+}
+
+// findBinary returns the binary for an os/arch platform in dir, named like the
+// release assets: <name>-<os>-<arch>, with .exe on Windows.
+func findBinary(dir, name, platform string) (*Binary, error) {
+	goos, arch, ok := strings.Cut(platform, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid platform %q, expected os/arch", platform)
+	}
+	asset := name + "-" + goos + "-" + arch
+	if goos == "windows" {
+		asset += ".exe"
+	}
+	path := filepath.Join(dir, asset)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("no binary for %s: %v", platform, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return &Binary{
+		OS:     goos,
+		Arch:   arch,
+		Asset:  asset,
+		Path:   path,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+		Size:   size,
+	}, nil
+}
+
+// findBinaries returns the binaries for each of the platforms.
+func findBinaries(dir, name string, platforms []string) ([]*Binary, error) {
+	binaries := make([]*Binary, 0, len(platforms))
+	for _, platform := range platforms {
+		b, err := findBinary(dir, name, platform)
+		if err != nil {
+			return nil, err
+		}
+		binaries = append(binaries, b)
+	}
+	return binaries, nil
+}
@@ -0,0 +1,116 @@
+// Package release writes the Homebrew formula of a release.
+package release
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+var formulaTemplate = template.Must(template.New("formula").Parse(`# Homebrew formula for {{.Name}} {{.Tag}}, generated by nlch-release from packaging.yaml.
+# Publish it as Formula/{{.Name}}.rb in a tap, then: brew install <owner>/<tap>/{{.Name}}
+class {{.Class}} < Formula
+  desc "{{.Summary}}"
+  homepage "{{.Homepage}}"
+  version "{{.Version}}"
+{{- if .License}}
+  license "{{.License}}"
+{{- end}}
+{{range .Systems}}
+  on_{{.Block}} do
+{{- range .Binaries}}
+    on_{{.CPU}} do
+      url "{{.URL}}"
+      sha256 "{{.SHA256}}"
+    end
+{{- end}}
+  end
+{{end}}
+  def install
+    bin.install Dir["{{.Name}}-*"].first => "{{.Name}}"
+  end
+
+  test do
+    assert_match "{{.Name}} version", shell_output("#{bin}/{{.Name}} version")
+  end
+end
+`))
+
+type formulaBinary struct {
+	CPU, URL, SHA256 string
+}
+
+type formulaSystem struct {
+	Block    string // macos or linux
+	Binaries []formulaBinary
+}
+
+// Formula returns the Homebrew formula that installs the release binaries.
+// Homebrew only tells Intel from ARM, so each OS may have one binary of each.
+func Formula(cfg *Config, tag, dist string) (string, error) {
+	binaries, err := findBinaries(dist, cfg.Name, cfg.Brew.Platforms)
+	if err != nil {
+		return "", err
+	}
+	var systems []formulaSystem
+	for _, goos := range []string{"darwin", "linux"} {
+		system := formulaSystem{Block: goos}
+		if goos == "darwin" {
+			system.Block = "macos"
+		}
+		for _, b := range binaries {
+			if b.OS != goos {
+				continue
+			}
+			var cpu string
+			switch b.Arch {
+			case "amd64":
+				cpu = "intel"
+			case "arm64":
+				cpu = "arm"
+			default:
+				return "", fmt.Errorf("homebrew does not support %s/%s", b.OS, b.Arch)
+			}
+			system.Binaries = append(system.Binaries, formulaBinary{CPU: cpu, URL: cfg.assetURL(tag, b.Asset), SHA256: b.SHA256})
+		}
+		if len(system.Binaries) > 0 {
+			systems = append(systems, system)
+		}
+	}
+	if len(systems) == 0 {
+		return "", fmt.Errorf("no macOS or Linux binaries for the Homebrew formula")
+	}
+
+	var b strings.Builder
+	err = formulaTemplate.Execute(&b, map[string]any{
+		"Name":     cfg.Name,
+		"Class":    formulaClass(cfg.Name),
+		"Tag":      tag,
+		"Version":  strings.TrimPrefix(tag, "v"),
+		"Summary":  strings.ReplaceAll(cfg.Summary, `"`, `\"`),
+		"Homepage": cfg.Homepage,
+		"License":  cfg.License,
+		"Systems":  systems,
+	})
+	return b.String(), err
+}
+
+// formulaClass returns the Ruby class name Homebrew expects for a formula,
+// e.g. "Nlch" for nlch and "FooBar" for foo-bar.
+func formulaClass(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '-' || r == '_' || r == '.' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+// Package release builds the package manager metadata and packages published
+// alongside each release's binaries: a Homebrew formula, a Scoop manifest and
+// .deb and .rpm packages.
+package release
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes how a release is packaged. It is read from packaging.yaml.
+type Config struct {
+	Name        string `yaml:"name"`
+	Summary     string `yaml:"summary"`
+	Description string `yaml:"description"`
+	Homepage    string `yaml:"homepage"`
+	License     string `yaml:"license"`
+	Maintainer  string `yaml:"maintainer"`
+	// DownloadURL is where a release asset can be downloaded from, with
+	// {tag}, {version} and {asset} replaced.
+	DownloadURL string `yaml:"download_url"`
+
+	Brew  BrewConfig  `yaml:"brew"`
+	Scoop ScoopConfig `yaml:"scoop"`
+	Linux LinuxConfig `yaml:"linux"`
+}
+
+// BrewConfig lists the platforms the Homebrew formula installs on.
+type BrewConfig struct {
+	Platforms []string `yaml:"platforms"` // os/arch, e.g. darwin/arm64
+}
+
+// ScoopConfig lists the platforms the Scoop manifest installs on.
+type ScoopConfig struct {
+	Platforms []string `yaml:"platforms"`
+}
+
+// LinuxConfig describes the .deb and .rpm packages.
+type LinuxConfig struct {
+	Formats   []string `yaml:"formats"`   // deb and/or rpm
+	Platforms []string `yaml:"platforms"` // linux/arch, e.g. linux/armv7
+	BinDir    string   `yaml:"bindir"`    // where the binary is installed, /usr/bin by default
+	Section   string   `yaml:"section"`   // Debian section
+	Group     string   `yaml:"group"`     // RPM group
+}
+
+// LoadConfig reads and checks a packaging config.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	switch {
+	case cfg.Name == "":
+		return nil, fmt.Errorf("%s: name is required", path)
+	case cfg.DownloadURL == "":
+		return nil, fmt.Errorf("%s: download_url is required", path)
+	}
+	for _, format := range cfg.Linux.Formats {
+		if format != "deb" && format != "rpm" {
+			return nil, fmt.Errorf("%s: unknown package format %q (use deb or rpm)", path, format)
+		}
+	}
+	if cfg.Linux.BinDir == "" {
+		cfg.Linux.BinDir = "/usr/bin"
+	}
+	cfg.Description = strings.TrimSpace(cfg.Description)
+	if cfg.Description == "" {
+		cfg.Description = cfg.Summary
+	}
+	return &cfg, nil
+}
+
+// assetURL returns the download URL of a release asset.
+func (c *Config) assetURL(tag, asset string) string {
+	return strings.NewReplacer("{tag}", tag, "{version}", strings.TrimPrefix(tag, "v"), "{asset}", asset).Replace(c.DownloadURL)
+}
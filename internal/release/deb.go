@@ -0,0 +1,180 @@
+// Package release builds Debian packages of a release binary.
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// debArchs maps release asset architectures to Debian's names for them.
+var debArchs = map[string]string{
+	"amd64":   "amd64",
+	"arm64":   "arm64",
+	"armv7":   "armhf",
+	"386":     "i386",
+	"riscv64": "riscv64",
+}
+
+// packageVersion converts a release version to one that sorts correctly in
+// dpkg and rpm, where "~" sorts before anything, so 1.0.0~beta1 < 1.0.0.
+func packageVersion(tag string) string {
+	return strings.Replace(strings.TrimPrefix(tag, "v"), "-", "~", 1)
+}
+
+// DebName returns the file name of the Debian package for a binary. It keeps
+// the tag's version, as GitHub renames assets with a "~" in their name.
+func DebName(cfg *Config, tag string, b *Binary) string {
+	return fmt.Sprintf("%s_%s_%s.deb", cfg.Name, strings.TrimPrefix(tag, "v"), debArchs[b.Arch])
+}
+
+// WriteDeb writes a Debian package that installs the binary into the configured bindir.
+func WriteDeb(w io.Writer, cfg *Config, tag string, b *Binary, modTime time.Time) error {
+	arch, ok := debArchs[b.Arch]
+	if b.OS != "linux" || !ok {
+		return fmt.Errorf("no Debian architecture for %s/%s", b.OS, b.Arch)
+	}
+	binary, err := os.ReadFile(b.Path)
+	if err != nil {
+		return err
+	}
+	target := path.Join(strings.TrimPrefix(cfg.Linux.BinDir, "/"), cfg.Name)
+
+	// data.tar.gz holds the installed files, with their parent directories
+	var data bytes.Buffer
+	var dirs []string
+	for dir := path.Dir(target); dir != "."; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	err = writeTarGz(&data, modTime, func(tw *tar.Writer) error {
+		for _, dir := range dirs {
+			if err := writeTarDir(tw, "./"+dir+"/", modTime); err != nil {
+				return err
+			}
+		}
+		return writeTarFile(tw, "./"+target, binary, 0755, modTime)
+	})
+	if err != nil {
+		return err
+	}
+
+	section := cfg.Linux.Section
+	if section == "" {
+		section = "utils"
+	}
+	var control strings.Builder
+	fmt.Fprintf(&control, "Package: %s\n", cfg.Name)
+	fmt.Fprintf(&control, "Version: %s\n", packageVersion(tag))
+	fmt.Fprintf(&control, "Architecture: %s\n", arch)
+	fmt.Fprintf(&control, "Maintainer: %s\n", cfg.Maintainer)
+	fmt.Fprintf(&control, "Installed-Size: %d\n", (len(binary)+1023)/1024)
+	fmt.Fprintf(&control, "Section: %s\n", section)
+	fmt.Fprintf(&control, "Priority: optional\n")
+	if cfg.Homepage != "" {
+		fmt.Fprintf(&control, "Homepage: %s\n", cfg.Homepage)
+	}
+	fmt.Fprintf(&control, "Description: %s\n", cfg.Summary)
+	// Continuation lines start with a space, and a lone "." stands for an empty line
+	for _, line := range strings.Split(cfg.Description, "\n") {
+		if line = strings.TrimRight(line, " "); line == "" {
+			line = "."
+		}
+		fmt.Fprintf(&control, " %s\n", line)
+	}
+	sum := md5.Sum(binary)
+	md5sums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), target)
+
+	var ctrl bytes.Buffer
+	err = writeTarGz(&ctrl, modTime, func(tw *tar.Writer) error {
+		if err := writeTarDir(tw, "./", modTime); err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, "./control", []byte(control.String()), 0644, modTime); err != nil {
+			return err
+		}
+		return writeTarFile(tw, "./md5sums", []byte(md5sums), 0644, modTime)
+	})
+	if err != nil {
+		return err
+	}
+
+	// A .deb is an ar archive of the format version, the control files and the data
+	if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+		return err
+	}
+	members := []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", ctrl.Bytes()},
+		{"data.tar.gz", data.Bytes()},
+	}
+	for _, m := range members {
+		if err := writeArMember(w, m.name, m.data, modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArMember writes a file to an ar archive, padded to an even length.
+func writeArMember(w io.Writer, name string, data []byte, modTime time.Time) error {
+	header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, modTime.Unix(), 0, 0, "100644", len(data))
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data)%2 == 1 {
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+	return nil
+}
+
+// writeTarGz writes a gzipped tarball whose entries are added by add.
+func writeTarGz(w io.Writer, modTime time.Time, add func(*tar.Writer) error) error {
+	gz := gzip.NewWriter(w)
+	gz.ModTime = modTime
+	tw := tar.NewWriter(gz)
+	if err := add(tw); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeTarFile adds a regular file owned by root to a tarball.
+func writeTarFile(tw *tar.Writer, name string, data []byte, mode int64, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     mode,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Uname:    "root",
+		Gname:    "root",
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// writeTarDir adds a directory owned by root to a tarball.
+func writeTarDir(tw *tar.Writer, name string, modTime time.Time) error {
+	return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755, ModTime: modTime, Uname: "root", Gname: "root"})
+}
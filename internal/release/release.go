@@ -0,0 +1,82 @@
+// Package release writes every package and manifest of a release.
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Build writes the Homebrew formula, Scoop manifest and Linux packages for the
+// release tag into dist, which holds the release binaries, and returns the
+// names of the files it wrote. modTime is recorded as the time of every file
+// in the packages, so building the same release twice gives the same packages.
+func Build(cfg *Config, tag, dist string, modTime time.Time) ([]string, error) {
+	var written []string
+	write := func(name string, fill func(io.Writer) error) error {
+		var buf bytes.Buffer
+		if err := fill(&buf); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dist, name), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		written = append(written, name)
+		return nil
+	}
+
+	if len(cfg.Brew.Platforms) > 0 {
+		err := write(cfg.Name+".rb", func(w io.Writer) error {
+			formula, err := Formula(cfg, tag, dist)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, formula)
+			return err
+		})
+		if err != nil {
+			return written, err
+		}
+	}
+
+	if len(cfg.Scoop.Platforms) > 0 {
+		err := write(cfg.Name+".json", func(w io.Writer) error {
+			manifest, err := ScoopManifest(cfg, tag, dist)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(manifest)
+			return err
+		})
+		if err != nil {
+			return written, err
+		}
+	}
+
+	binaries, err := findBinaries(dist, cfg.Name, cfg.Linux.Platforms)
+	if err != nil {
+		return written, err
+	}
+	for _, format := range cfg.Linux.Formats {
+		for _, b := range binaries {
+			var name string
+			var writePackage func(io.Writer, *Config, string, *Binary, time.Time) error
+			switch format {
+			case "deb":
+				name, writePackage = DebName(cfg, tag, b), WriteDeb
+			case "rpm":
+				name, writePackage = RPMName(cfg, tag, b), WriteRPM
+			}
+			err := write(name, func(w io.Writer) error {
+				return writePackage(w, cfg, tag, b, modTime)
+			})
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,343 @@
+// Package release builds RPM packages of a release binary.
+package release
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rpmArchs maps release asset architectures to RPM's names for them.
+var rpmArchs = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"armv7":   "armv7hl",
+	"386":     "i686",
+	"riscv64": "riscv64",
+}
+
+// The release of every package; a new package means a new nlch release.
+const rpmRelease = "1"
+
+// RPMName returns the file name of the RPM package for a binary.
+func RPMName(cfg *Config, tag string, b *Binary) string {
+	return fmt.Sprintf("%s-%s-%s.%s.rpm", cfg.Name, strings.TrimPrefix(tag, "v"), rpmRelease, rpmArchs[b.Arch])
+}
+
+// Header data types
+const (
+	rpmInt16       = 3
+	rpmInt32       = 4
+	rpmString      = 6
+	rpmBin         = 7
+	rpmStringArray = 8
+	rpmI18NString  = 9
+)
+
+// Header tags
+const (
+	tagHeaderSignatures  = 62
+	tagHeaderImmutable   = 63
+	tagI18NTable         = 100
+	tagSigSHA1           = 269
+	tagSigSHA256         = 273
+	tagSigSize           = 1000
+	tagSigMD5            = 1004
+	tagSigPayloadSize    = 1007
+	tagName              = 1000
+	tagVersion           = 1001
+	tagRelease           = 1002
+	tagSummary           = 1004
+	tagDescription       = 1005
+	tagBuildTime         = 1006
+	tagBuildHost         = 1007
+	tagSize              = 1009
+	tagLicense           = 1014
+	tagPackager          = 1015
+	tagGroup             = 1016
+	tagURL               = 1020
+	tagOS                = 1021
+	tagArch              = 1022
+	tagFileSizes         = 1028
+	tagFileModes         = 1030
+	tagFileRdevs         = 1033
+	tagFileMtimes        = 1034
+	tagFileDigests       = 1035
+	tagFileLinkTos       = 1036
+	tagFileFlags         = 1037
+	tagFileUserName      = 1039
+	tagFileGroupName     = 1040
+	tagSourceRPM         = 1044
+	tagFileVerifyFlags   = 1045
+	tagProvideName       = 1047
+	tagRequireFlags      = 1048
+	tagRequireName       = 1049
+	tagRequireVersion    = 1050
+	tagRPMVersion        = 1064
+	tagFileDevices       = 1095
+	tagFileInodes        = 1096
+	tagFileLangs         = 1097
+	tagProvideFlags      = 1112
+	tagProvideVersion    = 1113
+	tagDirIndexes        = 1116
+	tagBaseNames         = 1117
+	tagDirNames          = 1118
+	tagPayloadFormat     = 1124
+	tagPayloadCompressor = 1125
+	tagPayloadFlags      = 1126
+	tagFileDigestAlgo    = 5011
+	tagPayloadDigest     = 5092
+	tagPayloadDigestAlgo = 5093
+)
+
+// Dependency flags
+const (
+	senseLess   = 1 << 1
+	senseEqual  = 1 << 3
+	senseRPMLib = 1 << 24
+)
+
+// The digest algorithm of file and payload digests, SHA-256.
+const digestSHA256 = 8
+
+// rpmEntry is a tag and its value in an RPM header.
+type rpmEntry struct {
+	tag, typ, count int32
+	data            []byte
+}
+
+// rpmHeader builds the binary header structure used for both the signature and the package header.
+type rpmHeader []rpmEntry
+
+func (h *rpmHeader) addString(tag int32, s string) {
+	*h = append(*h, rpmEntry{tag, rpmString, 1, []byte(s + "\x00")})
+}
+
+func (h *rpmHeader) addI18NString(tag int32, s string) {
+	*h = append(*h, rpmEntry{tag, rpmI18NString, 1, []byte(s + "\x00")})
+}
+
+func (h *rpmHeader) addStrings(tag int32, ss ...string) {
+	var data []byte
+	for _, s := range ss {
+		data = append(append(data, s...), 0)
+	}
+	*h = append(*h, rpmEntry{tag, rpmStringArray, int32(len(ss)), data})
+}
+
+func (h *rpmHeader) addInt32(tag int32, values ...int32) {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(data[4*i:], uint32(v))
+	}
+	*h = append(*h, rpmEntry{tag, rpmInt32, int32(len(values)), data})
+}
+
+func (h *rpmHeader) addInt16(tag int32, values ...uint16) {
+	data := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(data[2*i:], v)
+	}
+	*h = append(*h, rpmEntry{tag, rpmInt16, int32(len(values)), data})
+}
+
+func (h *rpmHeader) addBin(tag int32, data []byte) {
+	*h = append(*h, rpmEntry{tag, rpmBin, int32(len(data)), data})
+}
+
+// marshal encodes the header with an immutable region covering all of its
+// entries, as rpm expects of headers in package files: the first index entry
+// names regionTag and points at a copy of itself at the end of the data,
+// whose negative offset gives the size of the index.
+func (h rpmHeader) marshal(regionTag int32) []byte {
+	entries := append(rpmHeader(nil), h...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	count := int32(len(entries) + 1)
+	var index, store bytes.Buffer
+	putEntry := func(buf *bytes.Buffer, tag, typ, offset, count int32) {
+		for _, v := range []int32{tag, typ, offset, count} {
+			binary.Write(buf, binary.BigEndian, v)
+		}
+	}
+	for _, e := range entries {
+		// Numbers are aligned to their size within the data
+		align := map[int32]int{rpmInt16: 2, rpmInt32: 4}[e.typ]
+		for align > 0 && store.Len()%align != 0 {
+			store.WriteByte(0)
+		}
+		putEntry(&index, e.tag, e.typ, int32(store.Len()), e.count)
+		store.Write(e.data)
+	}
+	var region bytes.Buffer
+	putEntry(&region, regionTag, rpmBin, int32(store.Len()), 16)
+	putEntry(&store, regionTag, rpmBin, -16*count, 16)
+
+	var out bytes.Buffer
+	out.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	binary.Write(&out, binary.BigEndian, count)
+	binary.Write(&out, binary.BigEndian, int32(store.Len()))
+	out.Write(region.Bytes())
+	out.Write(index.Bytes())
+	out.Write(store.Bytes())
+	return out.Bytes()
+}
+
+// WriteRPM writes an RPM package that installs the binary into the configured bindir.
+func WriteRPM(w io.Writer, cfg *Config, tag string, b *Binary, modTime time.Time) error {
+	arch, ok := rpmArchs[b.Arch]
+	if b.OS != "linux" || !ok {
+		return fmt.Errorf("no RPM architecture for %s/%s", b.OS, b.Arch)
+	}
+	binaryData, err := os.ReadFile(b.Path)
+	if err != nil {
+		return err
+	}
+	version := packageVersion(tag)
+	target := path.Join(cfg.Linux.BinDir, cfg.Name)
+	const mode = 0100755 // a regular file, rwxr-xr-x
+
+	// The payload is a gzipped cpio archive of the files, with paths relative to /
+	var cpio bytes.Buffer
+	writeCPIOEntry(&cpio, "."+target, mode, binaryData, modTime)
+	writeCPIOEntry(&cpio, "TRAILER!!!", 0, nil, time.Unix(0, 0))
+	var payload bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&payload, gzip.BestCompression)
+	gz.Write(cpio.Bytes())
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	group := cfg.Linux.Group
+	if group == "" {
+		group = "Applications/System"
+	}
+	license := cfg.License
+	if license == "" {
+		license = "Unspecified"
+	}
+	fileDigest := sha256.Sum256(binaryData)
+	payloadDigest := sha256.Sum256(payload.Bytes())
+
+	var h rpmHeader
+	h.addStrings(tagI18NTable, "C")
+	h.addString(tagName, cfg.Name)
+	h.addString(tagVersion, version)
+	h.addString(tagRelease, rpmRelease)
+	h.addI18NString(tagSummary, cfg.Summary)
+	h.addI18NString(tagDescription, cfg.Description)
+	h.addInt32(tagBuildTime, int32(modTime.Unix()))
+	h.addString(tagBuildHost, "localhost")
+	h.addInt32(tagSize, int32(len(binaryData)))
+	h.addString(tagLicense, license)
+	if cfg.Maintainer != "" {
+		h.addString(tagPackager, cfg.Maintainer)
+	}
+	h.addI18NString(tagGroup, group)
+	if cfg.Homepage != "" {
+		h.addString(tagURL, cfg.Homepage)
+	}
+	h.addString(tagOS, "linux")
+	h.addString(tagArch, arch)
+	h.addString(tagSourceRPM, fmt.Sprintf("%s-%s-%s.src.rpm", cfg.Name, version, rpmRelease))
+	h.addString(tagRPMVersion, "4.16.0")
+
+	// The files, with their directories and names stored separately
+	h.addInt32(tagFileSizes, int32(len(binaryData)))
+	h.addInt16(tagFileModes, mode)
+	h.addInt16(tagFileRdevs, 0)
+	h.addInt32(tagFileMtimes, int32(modTime.Unix()))
+	h.addStrings(tagFileDigests, hex.EncodeToString(fileDigest[:]))
+	h.addStrings(tagFileLinkTos, "")
+	h.addInt32(tagFileFlags, 0)
+	h.addStrings(tagFileUserName, "root")
+	h.addStrings(tagFileGroupName, "root")
+	h.addInt32(tagFileVerifyFlags, -1)
+	h.addInt32(tagFileDevices, 1)
+	h.addInt32(tagFileInodes, 1)
+	h.addStrings(tagFileLangs, "")
+	h.addInt32(tagDirIndexes, 0)
+	h.addStrings(tagBaseNames, path.Base(target))
+	h.addStrings(tagDirNames, path.Dir(target)+"/")
+	h.addInt32(tagFileDigestAlgo, digestSHA256)
+
+	// The package provides itself and needs the rpm features it is built with
+	h.addStrings(tagProvideName, cfg.Name)
+	h.addInt32(tagProvideFlags, senseEqual)
+	h.addStrings(tagProvideVersion, version+"-"+rpmRelease)
+	h.addStrings(tagRequireName, "rpmlib(CompressedFileNames)", "rpmlib(FileDigests)", "rpmlib(PayloadFilesHavePrefix)")
+	h.addInt32(tagRequireFlags, senseRPMLib|senseLess|senseEqual, senseRPMLib|senseLess|senseEqual, senseRPMLib|senseLess|senseEqual)
+	h.addStrings(tagRequireVersion, "3.0.4-1", "4.6.0-1", "4.0-1")
+
+	h.addString(tagPayloadFormat, "cpio")
+	h.addString(tagPayloadCompressor, "gzip")
+	h.addString(tagPayloadFlags, "9")
+	h.addStrings(tagPayloadDigest, hex.EncodeToString(payloadDigest[:]))
+	h.addInt32(tagPayloadDigestAlgo, digestSHA256)
+	header := h.marshal(tagHeaderImmutable)
+
+	// The signature header holds digests of the package header and payload
+	headerSHA1 := sha1.Sum(header)
+	headerSHA256 := sha256.Sum256(header)
+	all := md5.New()
+	all.Write(header)
+	all.Write(payload.Bytes())
+	var sig rpmHeader
+	sig.addString(tagSigSHA1, hex.EncodeToString(headerSHA1[:]))
+	sig.addString(tagSigSHA256, hex.EncodeToString(headerSHA256[:]))
+	sig.addInt32(tagSigSize, int32(len(header)+payload.Len()))
+	sig.addBin(tagSigMD5, all.Sum(nil))
+	sig.addInt32(tagSigPayloadSize, int32(cpio.Len()))
+	signature := sig.marshal(tagHeaderSignatures)
+
+	// The lead is a fixed-size legacy header that rpm only checks for its magic
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	binary.BigEndian.PutUint16(lead[6:], 0) // a binary package
+	binary.BigEndian.PutUint16(lead[8:], 1)
+	copy(lead[10:75], fmt.Sprintf("%s-%s-%s", cfg.Name, version, rpmRelease))
+	binary.BigEndian.PutUint16(lead[76:], 1) // Linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // a signature header follows
+
+	// The signature is padded to a multiple of 8 bytes
+	for _, part := range [][]byte{lead, signature, make([]byte, (8-len(signature)%8)%8), header, payload.Bytes()} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCPIOEntry writes a file to a cpio archive in the "newc" format.
+func writeCPIOEntry(w *bytes.Buffer, name string, mode int, data []byte, modTime time.Time) {
+	ino, nlink := 1, 1
+	if name == "TRAILER!!!" {
+		ino, nlink = 0, 0
+	}
+	fields := []int{ino, mode, 0, 0, nlink, int(modTime.Unix()), len(data), 0, 0, 0, 0, len(name) + 1, 0}
+	w.WriteString("070701")
+	for _, f := range fields {
+		fmt.Fprintf(w, "%08X", f)
+	}
+	w.WriteString(name)
+	w.WriteByte(0)
+	pad(w)
+	w.Write(data)
+	pad(w)
+}
+
+// pad pads a cpio archive to a multiple of 4 bytes.
+func pad(w *bytes.Buffer) {
+	w.WriteString(strings.Repeat("\x00", (4-w.Len()%4)%4))
+}
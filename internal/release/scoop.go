@@ -0,0 +1,70 @@
+// Package release writes the Scoop manifest of a release.
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// scoopArchs maps release asset architectures to Scoop's names for them.
+var scoopArchs = map[string]string{
+	"amd64": "64bit",
+	"386":   "32bit",
+	"arm64": "arm64",
+}
+
+// ScoopManifest returns the Scoop manifest that installs the Windows binaries,
+// with checkver and autoupdate entries so a bucket can follow new releases.
+func ScoopManifest(cfg *Config, tag, dist string) ([]byte, error) {
+	binaries, err := findBinaries(dist, cfg.Name, cfg.Scoop.Platforms)
+	if err != nil {
+		return nil, err
+	}
+	type archEntry struct {
+		URL  string     `json:"url"`
+		Hash string     `json:"hash,omitempty"`
+		Bin  [][]string `json:"bin,omitempty"`
+	}
+	architecture := map[string]archEntry{}
+	autoupdate := map[string]archEntry{}
+	for _, b := range binaries {
+		arch, ok := scoopArchs[b.Arch]
+		if b.OS != "windows" || !ok {
+			return nil, fmt.Errorf("scoop does not support %s/%s", b.OS, b.Arch)
+		}
+		// The binary keeps its asset name and Scoop adds a shim under the command's name
+		architecture[arch] = archEntry{
+			URL:  cfg.assetURL(tag, b.Asset),
+			Hash: b.SHA256,
+			Bin:  [][]string{{b.Asset, cfg.Name}},
+		}
+		autoupdate[arch] = archEntry{URL: cfg.assetURL("v$version", b.Asset)}
+	}
+	if len(architecture) == 0 {
+		return nil, fmt.Errorf("no Windows binaries for the Scoop manifest")
+	}
+
+	manifest := struct {
+		Version      string               `json:"version"`
+		Description  string               `json:"description"`
+		Homepage     string               `json:"homepage"`
+		License      string               `json:"license,omitempty"`
+		Architecture map[string]archEntry `json:"architecture"`
+		Checkver     string               `json:"checkver"`
+		Autoupdate   map[string]any       `json:"autoupdate"`
+	}{
+		Version:      strings.TrimPrefix(tag, "v"),
+		Description:  cfg.Summary,
+		Homepage:     cfg.Homepage,
+		License:      cfg.License,
+		Architecture: architecture,
+		Checkver:     "github",
+		Autoupdate:   map[string]any{"architecture": autoupdate},
+	}
+	data, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
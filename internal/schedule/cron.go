@@ -0,0 +1,196 @@
+// Package schedule parses cron expressions so they can be translated for other schedulers.
+package schedule
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// field describes one of the five fields of a cron expression.
+type field struct {
+	name     string
+	min, max int
+	names    []string // names accepted instead of numbers, starting at min
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// Shorthand expressions understood by cron.
+var macros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// Cron is a parsed cron expression. Each field holds the values it matches,
+// or nil if it matches every value.
+type Cron struct {
+	Expr                                     string
+	Minute, Hour, DayOfMonth, Month, Weekday []int
+}
+
+// ParseCron parses a five-field cron expression or one of the @daily style shorthands.
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	spec := expr
+	if expanded, ok := macros[strings.ToLower(expr)]; ok {
+		spec = expanded
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("invalid cron expression %q: want 5 fields", expr)
+	}
+
+	values := make([][]int, len(fields))
+	for i, part := range parts {
+		v, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+		values[i] = v
+	}
+	// Sunday may be written as 0 or 7
+	if values[4] != nil {
+		for i, d := range values[4] {
+			if d == 7 {
+				values[4][i] = 0
+			}
+		}
+		slices.Sort(values[4])
+		values[4] = slices.Compact(values[4])
+	}
+	return &Cron{Expr: expr, Minute: values[0], Hour: values[1], DayOfMonth: values[2], Month: values[3], Weekday: values[4]}, nil
+}
+
+// parseField returns the sorted values matched by one field, or nil for "*".
+func parseField(part string, f field) ([]int, error) {
+	if part == "*" {
+		return nil, nil
+	}
+	var values []int
+	for _, item := range strings.Split(part, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return nil, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(to); err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if hi < lo {
+				return nil, fmt.Errorf("invalid range %q in %s", rangePart, f.name)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			values = append(values, v)
+		}
+	}
+	slices.Sort(values)
+	return slices.Compact(values), nil
+}
+
+// value parses a single number or name of the field.
+func (f field) value(s string) (int, error) {
+	if i := slices.Index(f.names, strings.ToLower(s)); i >= 0 {
+		return f.min + i, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	return n, nil
+}
+
+// weekdayNames are the day names systemd uses, starting on Sunday.
+var weekdayNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// OnCalendar returns the expression as a systemd calendar event, such as
+// "Mon,Fri *-*-* 02:00:00". systemd requires every field to match, while cron
+// runs a job when either the day of month or the day of week matches, so
+// expressions restricting both have no equivalent.
+func (c *Cron) OnCalendar() (string, error) {
+	if c.DayOfMonth != nil && c.Weekday != nil {
+		return "", fmt.Errorf("the schedule %q restricts both the day of month and the day of week, which systemd cannot express; use cron", c.Expr)
+	}
+	list := func(values []int, width int) string {
+		if values == nil {
+			return "*"
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = fmt.Sprintf("%0*d", width, v)
+		}
+		return strings.Join(parts, ",")
+	}
+	event := fmt.Sprintf("*-%s-%s %s:%s:00", list(c.Month, 2), list(c.DayOfMonth, 2), list(c.Hour, 2), list(c.Minute, 2))
+	if c.Weekday != nil {
+		days := make([]string, len(c.Weekday))
+		for i, d := range c.Weekday {
+			days[i] = weekdayNames[d]
+		}
+		event = strings.Join(days, ",") + " " + event
+	}
+	return event, nil
+}
+
+// calendarKeys are the launchd StartCalendarInterval keys, in cron field order.
+var calendarKeys = []string{"Minute", "Hour", "Day", "Month", "Weekday"}
+
+// maxCalendarIntervals bounds the number of launchd intervals an expression may expand to.
+const maxCalendarIntervals = 256
+
+// CalendarIntervals returns the expression as launchd StartCalendarInterval
+// entries. launchd has no lists or steps, so every combination of the listed
+// values becomes an entry of its own.
+func (c *Cron) CalendarIntervals() ([]map[string]int, error) {
+	intervals := []map[string]int{{}}
+	for i, values := range [][]int{c.Minute, c.Hour, c.DayOfMonth, c.Month, c.Weekday} {
+		if values == nil {
+			continue
+		}
+		var next []map[string]int
+		for _, interval := range intervals {
+			for _, v := range values {
+				entry := map[string]int{calendarKeys[i]: v}
+				for k, old := range interval {
+					entry[k] = old
+				}
+				next = append(next, entry)
+			}
+		}
+		if len(next) > maxCalendarIntervals {
+			return nil, fmt.Errorf("the schedule %q is too fine-grained for launchd", c.Expr)
+		}
+		intervals = next
+	}
+	return intervals, nil
+}
@@ -0,0 +1,290 @@
+// Package schedule turns a generated command and cron expression into a
+// crontab entry, systemd user timer or launchd agent, and installs it.
+package schedule
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Supported schedulers.
+const (
+	BackendCron    = "cron"
+	BackendSystemd = "systemd"
+	BackendLaunchd = "launchd"
+)
+
+// Backends lists the supported schedulers.
+var Backends = []string{BackendCron, BackendSystemd, BackendLaunchd}
+
+// Schedule is a command to run at the times described by a cron expression.
+type Schedule struct {
+	Name        string // identifies the job, e.g. "backup-notes"
+	Description string
+	Command     string
+	Cron        *Cron
+}
+
+// validName matches job names that are safe to use in file and unit names.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Parse reads the NAME, CRON, COMMAND and DESCRIPTION lines of an LLM reply.
+func Parse(reply string) (*Schedule, error) {
+	values := map[string]string{}
+	for _, line := range strings.Split(reply, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok {
+			values[strings.ToUpper(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), "`")
+		}
+	}
+	if values["COMMAND"] == "" || values["CRON"] == "" {
+		return nil, errors.New("LLM did not return a command and a schedule")
+	}
+	cron, err := ParseCron(values["CRON"])
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(values["NAME"])
+	if !validName.MatchString(name) {
+		// Name the job after its command, so that it only replaces the same job
+		sum := sha256.Sum256([]byte(values["COMMAND"]))
+		name = "job-" + hex.EncodeToString(sum[:4])
+	}
+	return &Schedule{Name: name, Description: values["DESCRIPTION"], Command: values["COMMAND"], Cron: cron}, nil
+}
+
+// DefaultBackend returns the usual scheduler of this system: launchd on macOS,
+// cron where crontab is installed, and systemd timers otherwise.
+func DefaultBackend() string {
+	if runtime.GOOS == "darwin" {
+		return BackendLaunchd
+	}
+	if _, err := exec.LookPath("crontab"); err == nil {
+		return BackendCron
+	}
+	return BackendSystemd
+}
+
+// Render returns the files or entries that Install would create, for review.
+func (s *Schedule) Render(backend string) (string, error) {
+	switch backend {
+	case BackendCron:
+		return s.cronEntry(), nil
+	case BackendSystemd:
+		service, timer, err := s.systemdUnits()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("# %s\n%s\n# %s\n%s", s.unitName()+".service", service, s.unitName()+".timer", timer), nil
+	case BackendLaunchd:
+		plist, err := s.launchdPlist()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("# %s\n%s", s.plistPath(), plist), nil
+	}
+	return "", fmt.Errorf("unknown scheduler %q, use one of %s", backend, strings.Join(Backends, ", "))
+}
+
+// Install adds the schedule to the scheduler, replacing an earlier job of the same name.
+func (s *Schedule) Install(backend string) error {
+	switch backend {
+	case BackendCron:
+		return s.installCron()
+	case BackendSystemd:
+		return s.installSystemd()
+	case BackendLaunchd:
+		return s.installLaunchd()
+	}
+	return fmt.Errorf("unknown scheduler %q, use one of %s", backend, strings.Join(Backends, ", "))
+}
+
+// Installed reports whether a job of the same name is already installed,
+// which Install would replace.
+func (s *Schedule) Installed(backend string) bool {
+	switch backend {
+	case BackendCron:
+		current, _ := exec.Command("crontab", "-l").Output()
+		for _, line := range strings.Split(string(current), "\n") {
+			if s.isMarker(line) {
+				return true
+			}
+		}
+	case BackendSystemd:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false
+		}
+		_, err = os.Stat(filepath.Join(home, ".config", "systemd", "user", s.unitName()+".timer"))
+		return err == nil
+	case BackendLaunchd:
+		_, err := os.Stat(s.plistPath())
+		return err == nil
+	}
+	return false
+}
+
+// cronMarker starts the comment line that precedes each nlch job in the crontab.
+const cronMarker = "# nlch: "
+
+// cronEntry returns the crontab lines of the job. A % ends the command in a
+// crontab line, so it is escaped.
+func (s *Schedule) cronEntry() string {
+	comment := cronMarker + s.Name
+	if s.Description != "" {
+		comment += " - " + s.Description
+	}
+	return fmt.Sprintf("%s\n%s %s\n", comment, s.Cron.Expr, strings.ReplaceAll(s.Command, "%", `\%`))
+}
+
+// isMarker reports whether a crontab line is the comment that precedes this job.
+func (s *Schedule) isMarker(line string) bool {
+	marker, ok := strings.CutPrefix(line, cronMarker)
+	return ok && (marker == s.Name || strings.HasPrefix(marker, s.Name+" - "))
+}
+
+func (s *Schedule) installCron() error {
+	// crontab -l fails when the user has no crontab yet, which is an empty one
+	current, _ := exec.Command("crontab", "-l").Output()
+
+	// Drop an earlier job of the same name: its marker and the line after it
+	var kept []string
+	lines := strings.Split(strings.TrimRight(string(current), "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		if s.isMarker(lines[i]) {
+			i++
+			continue
+		}
+		if lines[i] != "" || len(kept) > 0 {
+			kept = append(kept, lines[i])
+		}
+	}
+	crontab := strings.Join(kept, "\n")
+	if crontab != "" {
+		crontab += "\n"
+	}
+	crontab += s.cronEntry()
+
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(crontab)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to install crontab: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (s *Schedule) unitName() string {
+	return "nlch-" + s.Name
+}
+
+// systemdUnits returns the service and timer units of the job.
+func (s *Schedule) systemdUnits() (service, timer string, err error) {
+	calendar, err := s.Cron.OnCalendar()
+	if err != nil {
+		return "", "", err
+	}
+	description := s.Description
+	if description == "" {
+		description = s.Name
+	}
+	// systemd expands % specifiers and $ variables in ExecStart, so both are doubled
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(s.Command)
+	service = fmt.Sprintf("[Unit]\nDescription=%s (nlch)\n\n[Service]\nType=oneshot\nExecStart=/bin/sh -c \"%s\"\n", description, escaped)
+	timer = fmt.Sprintf("[Unit]\nDescription=%s (nlch)\n\n[Timer]\nOnCalendar=%s\nPersistent=true\n\n[Install]\nWantedBy=timers.target\n", description, calendar)
+	return service, timer, nil
+}
+
+func (s *Schedule) installSystemd() error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(home, ".config", "systemd", "user")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	service, timer, err := s.systemdUnits()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, s.unitName()+".service"), []byte(service), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, s.unitName()+".timer"), []byte(timer), 0644); err != nil {
+		return err
+	}
+	for _, args := range [][]string{
+		{"--user", "daemon-reload"},
+		{"--user", "enable", "--now", s.unitName() + ".timer"},
+	} {
+		if out, err := exec.Command("systemctl", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("systemctl %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// plistPath returns where the launchd agent of the job is stored.
+func (s *Schedule) plistPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "LaunchAgents", "com.nlch."+s.Name+".plist")
+}
+
+// launchdPlist returns the property list of a launchd agent running the job.
+func (s *Schedule) launchdPlist() (string, error) {
+	intervals, err := s.Cron.CalendarIntervals()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	fmt.Fprintf(&b, "  <key>Label</key>\n  <string>com.nlch.%s</string>\n", s.Name)
+	fmt.Fprintf(&b, "  <key>ProgramArguments</key>\n  <array>\n    <string>/bin/sh</string>\n    <string>-c</string>\n    <string>%s</string>\n  </array>\n", xmlEscape(s.Command))
+	b.WriteString("  <key>StartCalendarInterval</key>\n  <array>\n")
+	for _, interval := range intervals {
+		b.WriteString("    <dict>\n")
+		for _, key := range calendarKeys {
+			if v, ok := interval[key]; ok {
+				fmt.Fprintf(&b, "      <key>%s</key><integer>%d</integer>\n", key, v)
+			}
+		}
+		b.WriteString("    </dict>\n")
+	}
+	b.WriteString("  </array>\n</dict>\n</plist>\n")
+	return b.String(), nil
+}
+
+func (s *Schedule) installLaunchd() error {
+	plist, err := s.launchdPlist()
+	if err != nil {
+		return err
+	}
+	path := s.plistPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Unload an earlier version of the job so the new one replaces it
+	_ = exec.Command("launchctl", "unload", path).Run()
+	if err := os.WriteFile(path, []byte(plist), 0644); err != nil {
+		return err
+	}
+	if out, err := exec.Command("launchctl", "load", "-w", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl load failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// xmlEscape escapes text for use in an XML element.
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
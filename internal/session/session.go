@@ -0,0 +1,171 @@
+// Package session stores named interactive sessions, so that a conversation
+// with nlch can be resumed later with the requests it was made of.
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+)
+
+// MaxTurns is how many of the most recent turns a session keeps.
+const MaxTurns = 50
+
+// Turn is one request of a session and its outcome.
+type Turn struct {
+	Time      time.Time `json:"time"`
+	Request   string    `json:"request"`
+	Command   string    `json:"command,omitempty"`
+	Executed  bool      `json:"executed,omitempty"`
+	ExitCode  int       `json:"exit_code,omitempty"`
+	Output    string    `json:"output,omitempty"` // as the history keeps it: truncated, or empty when outputs are hashed
+	HistoryID int       `json:"history_id,omitempty"`
+}
+
+// Session is a named conversation.
+type Session struct {
+	Name    string    `json:"name"`
+	Dir     string    `json:"dir"` // where it was started
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	Turns   []Turn    `json:"turns"`
+}
+
+// Add appends a turn, keeping at most MaxTurns.
+func (s *Session) Add(t Turn) {
+	if t.Time.IsZero() {
+		t.Time = time.Now()
+	}
+	s.Turns = append(s.Turns, t)
+	if len(s.Turns) > MaxTurns {
+		s.Turns = s.Turns[len(s.Turns)-MaxTurns:]
+	}
+	s.Updated = t.Time
+}
+
+// Store is a directory holding one JSON file per session.
+type Store struct {
+	Dir string
+}
+
+// Open returns the store at the default location in the nlch config directory.
+func Open() (*Store, error) {
+	dir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Dir: filepath.Join(dir, "sessions")}, nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.Dir, name+".json")
+}
+
+// ErrNotFound is returned for a session that has not been saved.
+var ErrNotFound = errors.New("no such session")
+
+// Load returns the saved session with the given name.
+func (s *Store) Load(name string) (*Session, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.path(name), err)
+	}
+	sess.Name = name
+	return &sess, nil
+}
+
+// Get returns the session with the given name, or a new, empty one started
+// in dir if there is none yet.
+func (s *Store) Get(name, dir string) (*Session, error) {
+	sess, err := s.Load(name)
+	if errors.Is(err, ErrNotFound) {
+		now := time.Now()
+		return &Session{Name: name, Dir: dir, Created: now, Updated: now}, nil
+	}
+	return sess, err
+}
+
+// Save writes the session, replacing its earlier state.
+func (s *Store) Save(sess *Session) error {
+	if err := ValidateName(sess.Name); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	tmp := s.path(sess.Name) + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(sess.Name))
+}
+
+// List returns every saved session, most recently used first.
+func (s *Store) List() ([]*Session, error) {
+	files, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || ValidateName(name) != nil {
+			continue
+		}
+		sess, err := s.Load(name)
+		if err != nil {
+			// Skip a corrupt session rather than hiding all the others
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Updated.After(sessions[j].Updated) })
+	return sessions, nil
+}
+
+// Delete removes the session with the given name.
+func (s *Store) Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	err := os.Remove(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateName checks that a session name is usable on the command line and as a file name.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package shell compares generated commands.
+package shell
+
+import (
+	"slices"
+	"strings"
+)
+
+// Equivalent reports whether two commands only differ in ways that don't
+// change what they do: whitespace, the quoting of plain words, and the order
+// of bundled or adjacent short flags ("ls -la" and "ls -a -l").
+func Equivalent(a, b string) bool {
+	return slices.Equal(normalizeWords(a), normalizeWords(b))
+}
+
+// normalizeWords splits a command into words in a canonical form.
+func normalizeWords(cmd string) []string {
+	var words, flags []string
+	flush := func() {
+		slices.Sort(flags)
+		words = append(words, flags...)
+		flags = nil
+	}
+	for _, word := range strings.Fields(cmd) {
+		word = unquotePlain(word)
+		if len(word) > 1 && word[0] == '-' && word[1] != '-' && isLetters(word[1:]) {
+			// Split bundled short flags into single letters so their order doesn't matter
+			for _, r := range word[1:] {
+				flags = append(flags, "-"+string(r))
+			}
+			continue
+		}
+		flush()
+		words = append(words, word)
+	}
+	flush()
+	return words
+}
+
+// unquotePlain removes quotes around a word that needs none.
+func unquotePlain(word string) string {
+	if len(word) >= 2 && (word[0] == '\'' || word[0] == '"') && word[len(word)-1] == word[0] {
+		inner := word[1 : len(word)-1]
+		if inner != "" && !strings.ContainsAny(inner, " \t'\"$`\\*?[]{}()<>|&;!~#") {
+			return inner
+		}
+	}
+	return word
+}
+
+// isLetters reports whether s consists of ASCII letters only.
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
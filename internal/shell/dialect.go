@@ -0,0 +1,51 @@
+// Package shell chooses the shell commands are generated for and run with.
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+)
+
+// TargetShells are the shells commands can be generated for. Commands for the
+// POSIX shells run with Interpreter(); fish and nu commands run with fish and nu.
+var TargetShells = []string{"bash", "sh", "zsh", "fish", "nu"}
+
+// Target returns the shell to generate commands for: name when it is set,
+// from a flag or the config, otherwise fish or nu when $SHELL is one of
+// them, and bash otherwise. "nushell" is accepted for nu.
+func Target(name string) (string, error) {
+	if name == "" {
+		if login := filepath.Base(os.Getenv("SHELL")); login == "fish" || login == "nu" {
+			return login, nil
+		}
+		return "bash", nil
+	}
+	if name == "nushell" {
+		name = "nu"
+	}
+	if !slices.Contains(TargetShells, name) {
+		return "", fmt.Errorf("unsupported shell %q (supported: bash, sh, zsh, fish, nu)", name)
+	}
+	return name, nil
+}
+
+// POSIX reports whether commands for the target shell are POSIX shell
+// commands, run with Interpreter().
+func POSIX(target string) bool {
+	return target != "fish" && target != "nu"
+}
+
+// Program returns the path of the shell that runs commands for the target.
+func Program(target string) (string, error) {
+	if POSIX(target) {
+		return Interpreter(), nil
+	}
+	path, err := exec.LookPath(target)
+	if err != nil {
+		return "", fmt.Errorf("%s is not installed, so %s commands can't run; choose another shell with --shell", target, target)
+	}
+	return path, nil
+}
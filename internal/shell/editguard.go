@@ -0,0 +1,269 @@
+// Package shell previews the changes a command makes to files tracked by git,
+// such as sed -i or a redirection over a file, by running it against copies
+// of those files before it runs for real.
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+// previewTimeout bounds how long a command may run for a preview.
+const previewTimeout = 10 * time.Second
+
+// PreviewableEdits returns the files tracked by git in dir that cmd changes
+// in place, with sed -i, perl -i or an output redirection, relative to dir.
+// It returns nil unless the change can be previewed safely: every file the
+// command writes is such a file, and everything else it runs only reads.
+func PreviewableEdits(cmd, dir string) []string {
+	line := parseLine(cmd)
+	var writes []string
+	for _, stage := range line.stages {
+		name := filepath.Base(stage[0])
+		if name == "cd" || name == "pushd" {
+			// Relative paths would then point outside the copy
+			return nil
+		}
+		if name == "sed" || name == "perl" {
+			scriptOptions := "ef"
+			if name == "perl" {
+				scriptOptions = "eE"
+			}
+			if files, inPlace := inPlaceTargets(stage[1:], scriptOptions); inPlace {
+				writes = append(writes, files...)
+				continue
+			}
+		}
+		if risk, _ := stageRisk(stage); risk != RiskLow {
+			return nil
+		}
+	}
+	for _, target := range line.redirects {
+		if target != "/dev/null" && target != "/dev/stdout" && target != "/dev/stderr" {
+			writes = append(writes, target)
+		}
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	var files []string
+	for _, target := range writes {
+		rel, ok := trackedFile(dir, target)
+		if !ok {
+			return nil
+		}
+		if !slices.Contains(files, rel) {
+			files = append(files, rel)
+		}
+	}
+	return files
+}
+
+// inPlaceTargets returns the files sed or perl edits in place, given its
+// arguments, and whether it edits in place at all. scriptOptions are the
+// short options that take the script, such as sed's -e and -f.
+func inPlaceTargets(args []string, scriptOptions string) ([]string, bool) {
+	inPlace, script := false, false
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+		case a == "-i" && i+1 < len(args) && args[i+1] == "":
+			// BSD sed takes the backup suffix as a separate, here empty, argument
+			inPlace = true
+			i++
+		case a == "--in-place" || strings.HasPrefix(a, "--in-place="):
+			inPlace = true
+		case a == "--expression" || a == "--file":
+			script = true
+			i++
+		case strings.HasPrefix(a, "--expression=") || strings.HasPrefix(a, "--file="):
+			script = true
+		case strings.HasPrefix(a, "--"):
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			// A cluster of short options; -i ends it with an optional backup
+			// suffix, and a script option with the script or, at the end, the
+			// next argument
+			for j := 1; j < len(a); j++ {
+				if a[j] == 'i' {
+					inPlace = true
+					break
+				}
+				if strings.IndexByte(scriptOptions, a[j]) >= 0 {
+					script = true
+					if j == len(a)-1 {
+						i++
+					}
+					break
+				}
+			}
+		default:
+			operands = append(operands, a)
+		}
+	}
+	if !script && len(operands) > 0 {
+		operands = operands[1:] // the script
+	}
+	return operands, inPlace
+}
+
+// trackedFile returns target, cleaned, if it is a relative path to an
+// existing regular file inside dir that git tracks. Absolute paths and ..
+// would reach the original file from the preview's copy of dir.
+func trackedFile(dir, target string) (string, bool) {
+	if filepath.IsAbs(target) || slices.Contains(strings.Split(filepath.ToSlash(target), "/"), "..") {
+		return "", false
+	}
+	rel := filepath.Clean(target)
+	path := filepath.Join(dir, rel)
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	git := exec.Command("git", "ls-files", "--error-unmatch", "--", rel)
+	git.Dir = dir
+	if git.Run() != nil {
+		return "", false
+	}
+	return rel, true
+}
+
+// PreviewEdits runs cmd with program, or Interpreter() if empty, in a mirror
+// of dir where files are copies and everything else links to the original,
+// and returns a unified diff of the changes it made to files.
+func PreviewEdits(cmd, dir, program string, files []string) (string, error) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		return "", errors.New("diff is not installed")
+	}
+	mirror, err := os.MkdirTemp("", "nlch-preview-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(mirror)
+	if err := linkEntries(dir, mirror); err != nil {
+		return "", err
+	}
+	for _, rel := range files {
+		if err := mirrorFile(dir, mirror, rel); err != nil {
+			return "", fmt.Errorf("failed to copy %s: %v", rel, err)
+		}
+	}
+
+	if program == "" {
+		program = Interpreter()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+	defer cancel()
+	run := exec.CommandContext(ctx, program, "-c", cmd)
+	run.Dir = mirror
+	run.Env = append(os.Environ(), "PWD="+mirror)
+	var stderr bytes.Buffer
+	run.Stderr = &stderr
+	if err := run.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, rel := range files {
+		diff := exec.Command("diff", "-u", "--label", "a/"+filepath.ToSlash(rel), "--label", "b/"+filepath.ToSlash(rel), filepath.Join(dir, rel), filepath.Join(mirror, rel))
+		text, err := diff.Output()
+		// diff exits with 1 when the files differ
+		if err != nil && ExitCode(err) != 1 {
+			return "", fmt.Errorf("diff failed: %v", err)
+		}
+		if len(text) == 0 {
+			fmt.Fprintf(&out, "%s\n", ui.Dim("no change to "+rel))
+			continue
+		}
+		for _, l := range strings.SplitAfter(strings.TrimRight(string(text), "\n"), "\n") {
+			l = ui.WrapLine(strings.TrimRight(l, "\n"), 1)
+			switch {
+			case strings.HasPrefix(l, "+++") || strings.HasPrefix(l, "---") || strings.HasPrefix(l, "@@"):
+				l = ui.Dim(l)
+			case strings.HasPrefix(l, "+"):
+				l = ui.Success(l)
+			case strings.HasPrefix(l, "-"):
+				l = ui.Error(l)
+			}
+			out.WriteString(l + "\n")
+		}
+	}
+	return out.String(), nil
+}
+
+// mirrorFile copies the file rel of dir into mirror, which already links to
+// everything in dir. The directories on its path are made real in the
+// mirror, with links to everything else in them, so the command can still
+// read the files around it.
+func mirrorFile(dir, mirror, rel string) error {
+	sub := ""
+	for _, part := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		sub = filepath.Join(sub, part)
+		target := filepath.Join(mirror, sub)
+		if info, err := os.Lstat(target); err == nil && info.IsDir() {
+			continue
+		}
+		os.Remove(target) // the link made for the directory above
+		if err := os.Mkdir(target, 0700); err != nil {
+			return err
+		}
+		if err := linkEntries(filepath.Join(dir, sub), target); err != nil {
+			return err
+		}
+	}
+
+	dst := filepath.Join(mirror, rel)
+	os.Remove(dst)
+	src, err := os.Open(filepath.Join(dir, rel))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	copied, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(copied, src); err != nil {
+		copied.Close()
+		return err
+	}
+	return copied.Close()
+}
+
+// linkEntries links everything in the directory from into the directory to.
+func linkEntries(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Symlink(filepath.Join(from, entry.Name()), filepath.Join(to, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
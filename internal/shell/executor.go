@@ -4,54 +4,397 @@ package shell
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/container"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+// ErrAborted is returned by Run when the user declines to run the command.
+var ErrAborted = errors.New("aborted by user")
+
+// ErrRefine is returned by Run when the user asks to refine the command instead of running it.
+var ErrRefine = errors.New("refinement requested")
+
+// ErrBlocked is returned by Run for commands that may not run at all.
+var ErrBlocked = errors.New("blocked by the confirmation policy")
+
+// Confirmation is what the user has to do before a command runs.
+type Confirmation string
+
+const (
+	ConfirmNone  Confirmation = "run"     // nothing, the command runs immediately
+	ConfirmYesNo Confirmation = "confirm" // answer a Y/n question
+	ConfirmTyped Confirmation = "type"    // type "yes" in full
+	ConfirmBlock Confirmation = "block"   // the command may not run
 )
 
+// Confirmations lists the valid confirmations, from the least to the most strict.
+var Confirmations = []Confirmation{ConfirmNone, ConfirmYesNo, ConfirmTyped, ConfirmBlock}
+
+// AtLeast returns the stricter of the two confirmations.
+func (c Confirmation) AtLeast(other Confirmation) Confirmation {
+	if slices.Index(Confirmations, other) > slices.Index(Confirmations, c) {
+		return other
+	}
+	return c
+}
+
+// stdin is shared by every prompt so that buffered input is never lost between reads.
+var stdin = bufio.NewReader(os.Stdin)
+
+// ReadLine prints the prompt and returns the next line of user input without the trailing newline.
+func ReadLine(prompt string) string {
+	fmt.Print(prompt)
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+// CommandExecutor runs generated commands after confirming them with the
+// user. *Executor is the implementation; callers depend on this interface so
+// the confirmation and retry flows can be exercised without real commands.
+type CommandExecutor interface {
+	// Run confirms and executes cmd, returning its output.
+	Run(cmd string, confirm Confirmation) (stdout, stderr string, err error)
+	// ReadLine asks the user a question and returns the answer.
+	ReadLine(prompt string) string
+}
+
+// Runner starts a command line and waits for it to finish, writing what it
+// prints to stdout and stderr.
+type Runner interface {
+	Run(cmd string, stdout, stderr io.Writer) error
+}
+
+// SystemRunner runs commands with the system shell, or inside a container.
+type SystemRunner struct {
+	Container string        // Run commands inside this container instead of on the host
+	Shell     string        // Program that runs commands, such as fish, Interpreter() if empty
+	Limit     time.Duration // Interrupt commands that run longer than this, 0 for no limit
+	Stdin     io.Reader     // What the command reads, the terminal's stdin if nil
+}
+
+// How long a command interrupted for running past its limit has to exit
+// before it is killed.
+const limitGrace = 5 * time.Second
+
+// Run runs cmd in its own process group with the terminal's stdin, passing
+// signals sent to nlch on to it.
+func (r SystemRunner) Run(cmd string, stdout, stderr io.Writer) error {
+	program := r.Shell
+	if program == "" {
+		program = Interpreter()
+	}
+	command := exec.Command(program, "-c", cmd)
+	if r.Container != "" {
+		command = container.Command(r.Container, cmd)
+	}
+	restore := configureProcess(command)
+	defer restore()
+
+	command.Stdout = stdout
+	command.Stderr = stderr
+	command.Stdin = os.Stdin
+	if r.Stdin != nil {
+		command.Stdin = r.Stdin
+	}
+	if err := command.Start(); err != nil {
+		return err
+	}
+	stop := interrupt.Forward(func(sig os.Signal) { signalProcess(command, sig) })
+	defer stop()
+	if r.Limit > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-done:
+				return
+			case <-time.After(r.Limit):
+			}
+			signalProcess(command, os.Interrupt)
+			select {
+			case <-done:
+			case <-time.After(limitGrace):
+				signalProcess(command, os.Kill)
+			}
+		}()
+	}
+	return command.Wait()
+}
+
 // Executor handles command execution with dry-run and confirmation support.
+// The zero value talks to the terminal and runs commands with SystemRunner.
 type Executor struct {
-	DryRun bool
+	DryRun      bool
+	AllowRefine bool   // Offer a "refine" choice at the confirmation prompt
+	Container   string // Run commands inside this container instead of on the host
+	Capture     string // Also write the command's output to this file as it runs
+	Shell       string // Program that runs commands, such as fish, Interpreter() if empty
+
+	// How long commands that run until stopped, such as tail -f, may run; 0 for no limit
+	WatchLimit time.Duration
+
+	Stdin  io.Reader // where answers are read from, os.Stdin if nil
+	Stdout io.Writer // where messages, prompts and the command's output go, os.Stdout if nil
+	Stderr io.Writer // where the command's error output goes, os.Stderr if nil
+	Runner Runner    // what runs the command, SystemRunner if nil
+
+	// For filters such as nlch map's: what the command reads instead of the
+	// terminal, and where its output goes, unbuffered, instead of Stdout
+	Input  io.Reader
+	Output io.Writer
+
+	in *bufio.Reader // buffers Stdin across questions
 }
 
-// Run executes the given shell command, optionally as a dry-run.
-// Returns the command output and error for potential retry logic.
-func (e *Executor) Run(cmd string, requireConfirm bool) (stdout, stderr string, err error) {
-	fmt.Printf("> Running command `%s`...\n", cmd)
+// ReadLine prints the prompt and returns the next line of the user's answer
+// without the trailing newline.
+func (e *Executor) ReadLine(prompt string) string {
+	in := stdin
+	if e.Stdin != nil {
+		if e.in == nil {
+			e.in = bufio.NewReader(e.Stdin)
+		}
+		in = e.in
+	}
+	fmt.Fprint(e.out(), prompt)
+	line, _ := in.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func (e *Executor) out() io.Writer {
+	if e.Stdout == nil {
+		return os.Stdout
+	}
+	return e.Stdout
+}
+
+func (e *Executor) errOut() io.Writer {
+	if e.Stderr == nil {
+		return os.Stderr
+	}
+	return e.Stderr
+}
+
+// Run executes the given shell command, optionally as a dry-run, after the
+// confirmation the caller requires. Returns the command output and error for
+// potential retry logic.
+func (e *Executor) Run(cmd string, confirm Confirmation) (stdout, stderr string, err error) {
+	out := e.out()
+	if e.Container != "" {
+		fmt.Fprintf(out, "> Running command `%s` in container %s...\n", ui.Highlight(ui.WrapCommand(cmd, len("> Running command `"))), e.Container)
+	} else {
+		fmt.Fprintf(out, "> Running command `%s`...\n", ui.Highlight(ui.WrapCommand(cmd, len("> Running command `"))))
+	}
 	if e.DryRun {
-		fmt.Println("> This was a dry-run, thus no action was taken.")
+		fmt.Fprintln(out, "> This was a dry-run, thus no action was taken.")
 		return "", "", nil
 	}
-	if requireConfirm {
-		fmt.Print("> Confirm? [Y/n]: ")
-		scanner := bufio.NewScanner(os.Stdin)
-		scanner.Scan()
-		resp := scanner.Text()
-		if resp != "" && (resp[0] == 'n' || resp[0] == 'N') {
-			fmt.Println("> Aborted by user.")
-			return "", "", nil
+	// Commands that change tracked files in place can be tried on copies first
+	var edits []string
+	wd, _ := os.Getwd()
+	if confirm != ConfirmNone && confirm != ConfirmBlock && e.Runner == nil && e.Container == "" {
+		if edits = PreviewableEdits(cmd, wd); len(edits) > 0 {
+			fmt.Fprintf(out, "> The command changes %s in place; answer d to preview the change first.\n", strings.Join(edits, ", "))
 		}
 	}
+	previewing := func(resp string) bool {
+		if len(edits) == 0 || !strings.EqualFold(strings.TrimSpace(resp), "d") {
+			return false
+		}
+		diff, err := PreviewEdits(cmd, wd, e.Shell, edits)
+		if err != nil {
+			fmt.Fprintf(e.errOut(), "nlch: warning: could not preview the change: %v\n", err)
+		} else {
+			fmt.Fprint(out, diff)
+		}
+		return true
+	}
 
-	command := exec.Command("bash", "-c", cmd)
+	switch confirm {
+	case ConfirmNone:
+	case ConfirmBlock:
+		return "", "", ErrBlocked
+	case ConfirmTyped:
+		question := "> Type 'yes' to run it: "
+		switch {
+		case e.AllowRefine && len(edits) > 0:
+			question = "> Type 'yes' to run it, d to preview the change, or r to refine: "
+		case e.AllowRefine:
+			question = "> Type 'yes' to run it, or r to refine: "
+		case len(edits) > 0:
+			question = "> Type 'yes' to run it, or d to preview the change: "
+		}
+		resp := strings.TrimSpace(e.ReadLine(question))
+		for previewing(resp) {
+			resp = strings.TrimSpace(e.ReadLine(question))
+		}
+		if e.AllowRefine && (resp == "r" || resp == "R") {
+			return "", "", ErrRefine
+		}
+		if !strings.EqualFold(resp, "yes") {
+			fmt.Fprintln(out, "> Aborted by user.")
+			return "", "", ErrAborted
+		}
+	default:
+		choices := "Y/n"
+		if e.AllowRefine {
+			choices += "/r(efine)"
+		}
+		if len(edits) > 0 {
+			choices += "/d(iff)"
+		}
+		question := "> Confirm? [" + choices + "]: "
+		resp := e.ReadLine(question)
+		for previewing(resp) {
+			resp = e.ReadLine(question)
+		}
+		if resp != "" && (resp[0] == 'n' || resp[0] == 'N') {
+			fmt.Fprintln(out, "> Aborted by user.")
+			return "", "", ErrAborted
+		}
+		if e.AllowRefine && resp != "" && (resp[0] == 'r' || resp[0] == 'R') {
+			return "", "", ErrRefine
+		}
+	}
 
+	watching, _ := Watching(cmd)
+	runner := e.Runner
+	if runner == nil {
+		runner = SystemRunner{Container: e.Container, Shell: e.Shell, Stdin: e.Input}
+		if watching {
+			runner = SystemRunner{Container: e.Container, Shell: e.Shell, Limit: e.WatchLimit, Stdin: e.Input}
+		}
+	}
+	if e.Output != nil {
+		// The output is data, such as a whole transformed file, so it is streamed rather than kept
+		return "", "", runner.Run(cmd, e.Output, e.errOut())
+	}
+	if watching {
+		return e.watch(cmd, runner)
+	}
 	var stdoutBuf, stderrBuf bytes.Buffer
-	command.Stdout = &stdoutBuf
-	command.Stderr = &stderrBuf
-	command.Stdin = os.Stdin
-
-	err = command.Run()
-
+	interactivity, _ := Interaction(cmd)
+	if interactivity == FullScreen {
+		// The command needs the terminal itself, so its output can't be kept
+		if e.Capture != "" {
+			fmt.Fprintf(e.errOut(), "nlch: warning: %s is not captured, the command takes over the terminal\n", e.Capture)
+		}
+		err = runner.Run(cmd, out, e.errOut())
+		return "", "", err
+	}
+	keepOut, keepErr := io.Writer(&stdoutBuf), io.Writer(&stderrBuf)
+	if e.Capture != "" {
+		capture, err := os.Create(e.Capture)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create capture file: %v", err)
+		}
+		defer capture.Close()
+		keepOut, keepErr = io.MultiWriter(keepOut, capture), io.MultiWriter(keepErr, capture)
+	}
+	if interactivity == Prompts {
+		// Questions must be seen as they are asked, so output goes straight through as well
+		err = runner.Run(cmd, io.MultiWriter(out, keepOut), io.MultiWriter(e.errOut(), keepErr))
+		return stdoutBuf.String(), stderrBuf.String(), err
+	}
+	err = runner.Run(cmd, keepOut, keepErr)
 	stdout = stdoutBuf.String()
 	stderr = stderrBuf.String()
 
 	// Still print to console for user visibility
 	if stdout != "" {
-		fmt.Print(stdout)
+		fmt.Fprint(out, stdout)
 	}
 	if stderr != "" {
-		fmt.Fprint(os.Stderr, stderr)
+		fmt.Fprint(e.errOut(), stderr)
 	}
 
 	return stdout, stderr, err
 }
+
+// Most output of a watch-style command kept, from its end.
+const watchKeepBytes = 64 * 1024
+
+// watch runs a command that keeps running until it is stopped. Its output is
+// shown as it is written, and stopping it with Ctrl-C or at the time limit
+// counts as success rather than an interruption.
+func (e *Executor) watch(cmd string, runner Runner) (stdout, stderr string, err error) {
+	out := e.out()
+	if e.WatchLimit > 0 {
+		fmt.Fprintf(out, "> Watching for up to %s, press Ctrl-C to stop.\n", FormatLimit(e.WatchLimit))
+	} else {
+		fmt.Fprintln(out, "> Watching until you press Ctrl-C.")
+	}
+	keepOut, keepErr := &tailBuffer{max: watchKeepBytes}, &tailBuffer{max: watchKeepBytes}
+	shownOut, shownErr := io.Writer(out), io.Writer(e.errOut())
+	if interactivity, _ := Interaction(cmd); interactivity != FullScreen {
+		shownOut, shownErr = io.MultiWriter(out, keepOut), io.MultiWriter(shownErr, keepErr)
+	}
+	if e.Capture != "" {
+		capture, err := os.Create(e.Capture)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create capture file: %v", err)
+		}
+		defer capture.Close()
+		shownOut, shownErr = io.MultiWriter(shownOut, capture), io.MultiWriter(shownErr, capture)
+	}
+	start := time.Now()
+	err = runner.Run(cmd, shownOut, shownErr)
+	elapsed := time.Since(start).Round(time.Second)
+	if err != nil && Interrupted(err) {
+		if e.WatchLimit > 0 && elapsed >= e.WatchLimit {
+			fmt.Fprintf(out, "\n> Stopped watching at the %s limit.\n", FormatLimit(e.WatchLimit))
+		} else {
+			fmt.Fprintf(out, "\n> Stopped watching after %s.\n", elapsed)
+		}
+		err = nil
+	}
+	return keepOut.String(), keepErr.String(), err
+}
+
+// Interpreter returns the shell that runs commands: bash where it is installed,
+// which is not the case by default on FreeBSD or minimal ARM images, and the
+// POSIX sh otherwise.
+func Interpreter() string {
+	if bash, err := exec.LookPath("bash"); err == nil {
+		return bash
+	}
+	return "/bin/sh"
+}
+
+// ExitCode returns the exit status for an error returned by Run.
+// It is 0 for a nil error and -1 when the command could not be started.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// Interrupted reports whether err comes from a command stopped by SIGINT or SIGTERM.
+func Interrupted(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal() == syscall.SIGINT || status.Signal() == syscall.SIGTERM
+	}
+	// Shells report a child killed by a signal as 128 plus the signal number
+	code := exitErr.ExitCode()
+	return code == 128+int(syscall.SIGINT) || code == 128+int(syscall.SIGTERM)
+}
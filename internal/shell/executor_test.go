@@ -0,0 +1,131 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records the commands it is asked to run instead of running them.
+type fakeRunner struct {
+	ran            []string
+	stdout, stderr string
+	err            error
+}
+
+func (f *fakeRunner) Run(cmd string, stdout, stderr io.Writer) error {
+	f.ran = append(f.ran, cmd)
+	io.WriteString(stdout, f.stdout)
+	io.WriteString(stderr, f.stderr)
+	return f.err
+}
+
+func TestExecutorConfirmation(t *testing.T) {
+	tests := []struct {
+		name    string
+		confirm Confirmation
+		refine  bool
+		answers string
+		wantErr error
+		wantRan bool
+	}{
+		{"none runs without asking", ConfirmNone, false, "", nil, true},
+		{"Y/n accepts enter", ConfirmYesNo, false, "\n", nil, true},
+		{"Y/n accepts y", ConfirmYesNo, false, "y\n", nil, true},
+		{"Y/n declines n", ConfirmYesNo, false, "n\n", ErrAborted, false},
+		{"Y/n declines No", ConfirmYesNo, false, "No\n", ErrAborted, false},
+		{"Y/n ignores r without refine", ConfirmYesNo, false, "r\n", nil, true},
+		{"Y/n refines", ConfirmYesNo, true, "r\n", ErrRefine, false},
+		{"typed accepts yes", ConfirmTyped, false, "yes\n", nil, true},
+		{"typed accepts YES", ConfirmTyped, false, "YES\n", nil, true},
+		{"typed declines y", ConfirmTyped, false, "y\n", ErrAborted, false},
+		{"typed declines enter", ConfirmTyped, false, "\n", ErrAborted, false},
+		{"typed declines at end of input", ConfirmTyped, false, "", ErrAborted, false},
+		{"typed refines", ConfirmTyped, true, "r\n", ErrRefine, false},
+		{"block never runs", ConfirmBlock, false, "yes\n", ErrBlocked, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{}
+			var out bytes.Buffer
+			e := &Executor{AllowRefine: tt.refine, Stdin: strings.NewReader(tt.answers), Stdout: &out, Stderr: &out, Runner: runner}
+			_, _, err := e.Run("ls -la", tt.confirm)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if ran := len(runner.ran) > 0; ran != tt.wantRan {
+				t.Errorf("ran = %v, want %v; output:\n%s", ran, tt.wantRan, out.String())
+			}
+		})
+	}
+}
+
+func TestExecutorDryRun(t *testing.T) {
+	runner := &fakeRunner{}
+	var out bytes.Buffer
+	e := &Executor{DryRun: true, Stdout: &out, Runner: runner}
+	if _, _, err := e.Run("rm -rf build", ConfirmTyped); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.ran) > 0 {
+		t.Error("a dry run ran the command")
+	}
+	if !strings.Contains(out.String(), "dry-run") {
+		t.Errorf("output doesn't mention the dry run:\n%s", out.String())
+	}
+}
+
+func TestExecutorReturnsOutput(t *testing.T) {
+	failure := errors.New("exit status 1")
+	runner := &fakeRunner{stdout: "out\n", stderr: "warning\n", err: failure}
+	var out, errOut bytes.Buffer
+	e := &Executor{Stdout: &out, Stderr: &errOut, Runner: runner}
+	stdout, stderr, err := e.Run("make", ConfirmNone)
+	if err != failure {
+		t.Errorf("err = %v, want the runner's error", err)
+	}
+	if stdout != "out\n" || stderr != "warning\n" {
+		t.Errorf("output = %q, %q", stdout, stderr)
+	}
+	// The output is shown as well as returned, for the user and for corrections
+	if !strings.Contains(out.String(), "out\n") || errOut.String() != "warning\n" {
+		t.Errorf("shown output = %q, %q", out.String(), errOut.String())
+	}
+	if len(runner.ran) != 1 || runner.ran[0] != "make" {
+		t.Errorf("ran %q, want [make]", runner.ran)
+	}
+}
+
+func TestExecutorKeepsAnswersAcrossQuestions(t *testing.T) {
+	runner := &fakeRunner{}
+	var out bytes.Buffer
+	e := &Executor{AllowRefine: true, Stdin: strings.NewReader("r\nsort by size\ny\n"), Stdout: &out, Runner: runner}
+	if _, _, err := e.Run("ls", ConfirmYesNo); !errors.Is(err, ErrRefine) {
+		t.Fatalf("err = %v, want ErrRefine", err)
+	}
+	if got := e.ReadLine("> Refine: "); got != "sort by size" {
+		t.Errorf("refinement = %q", got)
+	}
+	if _, _, err := e.Run("ls -S", ConfirmYesNo); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.ran) != 1 || runner.ran[0] != "ls -S" {
+		t.Errorf("ran %q, want [ls -S]", runner.ran)
+	}
+}
+
+func TestConfirmationAtLeast(t *testing.T) {
+	tests := []struct{ c, other, want Confirmation }{
+		{ConfirmNone, ConfirmYesNo, ConfirmYesNo},
+		{ConfirmTyped, ConfirmYesNo, ConfirmTyped},
+		{ConfirmBlock, ConfirmNone, ConfirmBlock},
+		{ConfirmYesNo, ConfirmYesNo, ConfirmYesNo},
+	}
+	for _, tt := range tests {
+		if got := tt.c.AtLeast(tt.other); got != tt.want {
+			t.Errorf("%s.AtLeast(%s) = %s, want %s", tt.c, tt.other, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Package shell provides keybinding integration scripts for interactive shells.
+package shell
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed scripts/*
+var scripts embed.FS
+
+// Shells for which an integration script is available, mapped to the script file.
+var integrationScripts = map[string]string{
+	"zsh":  "scripts/nlch.zsh",
+	"bash": "scripts/nlch.bash",
+	"fish": "scripts/nlch.fish",
+}
+
+// IntegrationShells returns the names of shells that have an integration script.
+func IntegrationShells() []string {
+	names := make([]string, 0, len(integrationScripts))
+	for name := range integrationScripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IntegrationScript returns the keybinding integration script for the given shell.
+func IntegrationScript(shellName string) (string, error) {
+	path, ok := integrationScripts[shellName]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell %q (supported: %s)", shellName, strings.Join(IntegrationShells(), ", "))
+	}
+	data, err := scripts.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
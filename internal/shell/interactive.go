@@ -0,0 +1,277 @@
+// Package shell detects commands that ask the user for input while they run.
+package shell
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Interactivity is how a command interacts with the user while it runs.
+type Interactivity int
+
+const (
+	NotInteractive Interactivity = iota
+	Prompts                      // asks questions, e.g. apt-get install or rm -i
+	FullScreen                   // takes over the terminal, e.g. vim, top or an interactive ssh session
+)
+
+// Interactive policies: how commands that ask for input are handled.
+const (
+	InteractiveFlags    = "flags"    // add options that answer the questions where they are known
+	InteractiveTerminal = "terminal" // leave the command as it is
+)
+
+// fullScreenPrograms take over the terminal whatever their arguments.
+var fullScreenPrograms = []string{
+	"vi", "vim", "nvim", "nano", "pico", "micro", "emacs", "less", "more", "most", "man",
+	"top", "htop", "btop", "atop", "watch", "tmux", "screen", "fzf", "mc", "ranger", "nnn", "visudo",
+}
+
+// replPrograms start an interactive session when given nothing to run.
+var replPrograms = []string{"python", "python3", "node", "irb", "lua", "ghci", "bash", "sh", "zsh", "fish", "redis-cli", "mongosh"}
+
+// promptingPrograms always ask for something, such as a password.
+var promptingPrograms = []string{"passwd", "ssh-copy-id", "adduser", "scp", "sftp"}
+
+// Options of ssh that take a value.
+const sshValueOptions = "BbcDEeFIiJLlmOopQRSWw"
+
+// Interaction tells whether a command will wait for the user's input, and
+// why. Output of such commands has to reach the user while they run, since
+// the questions they ask are part of it.
+func Interaction(cmd string) (Interactivity, string) {
+	line := parseLine(cmd)
+	result, reason := NotInteractive, ""
+	for i, stage := range line.stages {
+		// Input piped into a stage answers it, but pagers still take over the terminal
+		if line.piped[i] && !slices.Contains(fullScreenPrograms, program(stage)) {
+			continue
+		}
+		if r, why := stageInteraction(stage); r > result {
+			result, reason = r, why
+		}
+	}
+	return result, reason
+}
+
+// stageInteraction rates a single simple command, given as its words.
+func stageInteraction(stage []string) (Interactivity, string) {
+	name, args := programArgs(stage)
+	has := func(options ...string) bool {
+		for _, a := range args {
+			if slices.Contains(options, a) {
+				return true
+			}
+		}
+		return false
+	}
+	operands := nonOptions(args)
+	switch {
+	case slices.Contains(fullScreenPrograms, name):
+		return FullScreen, name + " takes over the terminal"
+	case slices.Contains(replPrograms, name) && len(operands) == 0 && !has("-c", "-e", "-m", "--eval", "--version", "-V"):
+		return FullScreen, name + " starts an interactive session"
+	case name == "psql" && !has("-c", "--command", "-f", "--file", "-l", "--list"),
+		name == "mysql" && !has("-e", "--execute"),
+		name == "sqlite3" && len(operands) < 2:
+		return FullScreen, name + " starts an interactive session"
+	case name == "ssh" && len(sshOperands(args)) < 2:
+		return FullScreen, "ssh starts an interactive session"
+	case name == "git" && gitOpensEditor(args):
+		return FullScreen, "git opens an editor"
+	case name == "crontab" && has("-e"):
+		return FullScreen, "crontab opens an editor"
+	case (name == "docker" || name == "podman" || name == "kubectl") && (subcommand(name, args) == "exec" || subcommand(name, args) == "run") && has("-it", "-ti"):
+		return FullScreen, name + " attaches a terminal"
+	case slices.Contains(promptingPrograms, name), name == "ssh":
+		return Prompts, name + " may ask for a password or to confirm the host"
+	case name == "ssh-keygen" && !has("-N", "-l", "-F", "-R", "-y"):
+		return Prompts, "ssh-keygen asks for a passphrase"
+	case (name == "rm" || name == "cp" || name == "mv") && has("-i", "--interactive"):
+		return Prompts, name + " -i asks before each file"
+	case (name == "docker" || name == "podman") && subcommand(name, args) == "login" && !has("--password-stdin", "-p", "--password"):
+		return Prompts, name + " login asks for a password"
+	case (name == "gh" || name == "glab") && subcommand(name, args) == "auth" && slices.Contains(args, "login"):
+		return Prompts, name + " auth login asks questions"
+	}
+	for _, rule := range answerRules {
+		if slices.Contains(rule.programs, name) && rule.asks(args) && !has(rule.answered...) {
+			return Prompts, name + " asks for confirmation"
+		}
+	}
+	return NotInteractive, ""
+}
+
+// programArgs returns the name of the program a stage runs, skipping
+// wrappers, and the words after it.
+func programArgs(stage []string) (string, []string) {
+	for i, w := range stage {
+		if strings.Contains(w, "=") || slices.Contains(wrappers, w) || strings.HasPrefix(w, "-") {
+			continue
+		}
+		name := w
+		if j := strings.LastIndex(name, "/"); j >= 0 {
+			name = name[j+1:]
+		}
+		return name, stage[i+1:]
+	}
+	return "", nil
+}
+
+// nonOptions returns the arguments that are neither options nor the values
+// of the options in takesValue.
+func nonOptions(args []string, takesValue ...string) []string {
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case slices.Contains(takesValue, a):
+			i++
+		case !strings.HasPrefix(a, "-"):
+			operands = append(operands, a)
+		}
+	}
+	return operands
+}
+
+// sshOperands returns the destination and remote command words of an ssh invocation.
+func sshOperands(args []string) []string {
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if len(operands) == 0 && strings.HasPrefix(a, "-") && len(a) > 1 {
+			if len(a) == 2 && strings.ContainsRune(sshValueOptions, rune(a[1])) {
+				i++
+			}
+			continue
+		}
+		operands = append(operands, a)
+	}
+	return operands
+}
+
+// gitOpensEditor reports whether a git invocation opens an editor or asks
+// about each change.
+func gitOpensEditor(args []string) bool {
+	sub := subcommand("git", args)
+	switch sub {
+	case "commit":
+		for _, a := range args {
+			if a == "-m" || a == "-F" || a == "-C" || a == "--no-edit" || strings.HasPrefix(a, "--message") || strings.HasPrefix(a, "--file") || strings.HasPrefix(a, "-m") {
+				return false
+			}
+		}
+		return true
+	case "rebase":
+		return slices.Contains(args, "-i") || slices.Contains(args, "--interactive")
+	case "add", "checkout", "reset", "stash":
+		return slices.Contains(args, "-p") || slices.Contains(args, "--patch") || (sub == "add" && slices.Contains(args, "-i"))
+	}
+	return false
+}
+
+// answerRule is an option that answers the confirmation a program asks for.
+type answerRule struct {
+	programs   []string
+	subcommand *regexp.Regexp // subcommands that ask
+	answered   []string       // options that already answer
+	flag       string         // the option to add
+	operation  bool           // the subcommand is an option, as pacman's -S
+}
+
+var answerRules = []answerRule{
+	{
+		programs:   []string{"apt", "apt-get"},
+		subcommand: regexp.MustCompile(`^(install|reinstall|remove|purge|upgrade|full-upgrade|dist-upgrade|autoremove)$`),
+		answered:   []string{"-y", "--yes", "--assume-yes", "-s", "--simulate", "--dry-run"},
+		flag:       "-y",
+	},
+	{
+		programs:   []string{"dnf", "yum"},
+		subcommand: regexp.MustCompile(`^(install|reinstall|remove|erase|upgrade|update|autoremove|downgrade)$`),
+		answered:   []string{"-y", "--assumeyes"},
+		flag:       "-y",
+	},
+	{
+		programs:   []string{"npm", "yarn", "pnpm"},
+		subcommand: regexp.MustCompile(`^init$`),
+		answered:   []string{"-y", "--yes"},
+		flag:       "-y",
+	},
+	{
+		programs:   []string{"pip", "pip3"},
+		subcommand: regexp.MustCompile(`^uninstall$`),
+		answered:   []string{"-y", "--yes"},
+		flag:       "-y",
+	},
+	{
+		programs:   []string{"conda", "mamba"},
+		subcommand: regexp.MustCompile(`^(install|remove|uninstall|create|update)$`),
+		answered:   []string{"-y", "--yes"},
+		flag:       "-y",
+	},
+	{
+		programs:   []string{"pacman"},
+		subcommand: regexp.MustCompile(`^-[SRU][A-Za-z]*$`),
+		answered:   []string{"--noconfirm"},
+		flag:       "--noconfirm",
+		operation:  true,
+	},
+}
+
+// asks reports whether a program of the rule asks for confirmation with these arguments.
+func (r answerRule) asks(args []string) bool {
+	if !r.operation {
+		return r.subcommand.MatchString(firstArg(args))
+	}
+	return slices.ContainsFunc(args, r.subcommand.MatchString)
+}
+
+// pattern matches a program of the rule, its leading options and the
+// subcommand, at the start of a simple command or after a wrapper.
+func (r answerRule) pattern() *regexp.Regexp {
+	names := make([]string, len(r.programs))
+	for i, p := range r.programs {
+		names[i] = regexp.QuoteMeta(p)
+	}
+	sub := strings.TrimSuffix(strings.TrimPrefix(r.subcommand.String(), "^"), "$")
+	if r.operation {
+		return regexp.MustCompile(`(?:^|[\s;&|(/])(?:` + strings.Join(names, "|") + `)\s+(` + sub + `)(?:\s|$)`)
+	}
+	return regexp.MustCompile(`(?:^|[\s;&|(/])(?:` + strings.Join(names, "|") + `)(?:\s+-[^\s;&|]+)*\s+(` + sub + `)(?:\s|$)`)
+}
+
+// NonInteractive adds options that answer the confirmations a command would
+// ask for, such as -y for apt-get install, where the command doesn't already
+// answer them. It returns the command and a description of each option added.
+func NonInteractive(cmd string) (string, []string) {
+	var added []string
+	for _, rule := range answerRules {
+		matches := rule.pattern().FindAllStringSubmatchIndex(cmd, -1)
+		// Insert from the end so earlier offsets stay valid
+		for i := len(matches) - 1; i >= 0; i-- {
+			end := matches[i][3]
+			stage := cmd[matches[i][0]:]
+			if j := strings.IndexAny(stage[1:], ";&|\n"); j >= 0 {
+				stage = stage[:j+1]
+			}
+			stage = strings.TrimLeft(stage, " \t;&|(/")
+			if answered(stage, rule.answered) {
+				continue
+			}
+			cmd = cmd[:end] + " " + rule.flag + cmd[end:]
+			added = append(added, rule.flag+" for "+strings.Fields(stage)[0])
+		}
+	}
+	return cmd, added
+}
+
+// answered reports whether a simple command has one of the options.
+func answered(stage string, options []string) bool {
+	for _, w := range strings.Fields(stage) {
+		if slices.Contains(options, w) {
+			return true
+		}
+	}
+	return false
+}
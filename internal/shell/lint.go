@@ -0,0 +1,103 @@
+// Package shell lints generated scripts with shellcheck, or with a few
+// built-in checks when shellcheck is not installed.
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Finding is a problem found in a script.
+type Finding struct {
+	Line    int
+	Level   string // "error" or "warning"
+	Code    string // shellcheck code, e.g. SC2086
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", f.Line, f.Message, f.Code)
+}
+
+// LintShells are the shells scripts can be linted for.
+var LintShells = []string{"sh", "bash", "dash", "ksh"}
+
+// Lint checks a script written for the shell with shellcheck, reporting
+// errors and warnings but not style suggestions. Without shellcheck on PATH
+// it applies a small built-in subset of its checks. Scripts for shells
+// shellcheck doesn't support are not checked.
+func Lint(script, shellName string) ([]Finding, error) {
+	if !slices.Contains(LintShells, shellName) {
+		return nil, nil
+	}
+	path, err := exec.LookPath("shellcheck")
+	if err != nil {
+		return lintBuiltin(script), nil
+	}
+	var stdout, stderr bytes.Buffer
+	check := exec.Command(path, "--format=json1", "--severity=warning", "--shell="+shellName, "-")
+	check.Stdin = strings.NewReader(script)
+	check.Stdout = &stdout
+	check.Stderr = &stderr
+	// shellcheck exits with 1 when it finds something
+	if err := check.Run(); err != nil && stdout.Len() == 0 {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("shellcheck: %s", msg)
+		}
+		return nil, fmt.Errorf("shellcheck: %v", err)
+	}
+	var report struct {
+		Comments []struct {
+			Line    int    `json:"line"`
+			Level   string `json:"level"`
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"comments"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		return nil, errors.New("shellcheck: unexpected output")
+	}
+	findings := make([]Finding, 0, len(report.Comments))
+	for _, c := range report.Comments {
+		findings = append(findings, Finding{Line: c.Line, Level: c.Level, Code: fmt.Sprintf("SC%d", c.Code), Message: c.Message})
+	}
+	return findings, nil
+}
+
+// builtinCheck is one of the shellcheck checks nlch applies itself.
+type builtinCheck struct {
+	code    string
+	pattern *regexp.Regexp
+	message string
+}
+
+var builtinChecks = []builtinCheck{
+	{"SC2164", regexp.MustCompile(`(?:^|[;&|]\s*)cd\s+[^;&|]+$`), "Use 'cd ... || exit' in case cd fails."},
+	{"SC2045", regexp.MustCompile(`\bfor\s+\w+\s+in\s+\$\(ls\b`), "Iterating over ls output is fragile. Use globs."},
+	{"SC2115", regexp.MustCompile(`\brm\s+-\w*r\w*\s+(?:-\S+\s+)*"?\$\{?\w+\}?"?/`), "Use \"${var:?}\" to ensure this never expands to /* ."},
+	{"SC2162", regexp.MustCompile(`(?:^|[;&|]\s*)read\s+(?:-[^r\s]+\s+)*[A-Za-z_]\w*(?:\s|$)`), "read without -r will mangle backslashes."},
+	{"SC2068", regexp.MustCompile(`(?:^|\s)\$@(?:\s|$)`), "Double quote array expansions to avoid re-splitting elements."},
+}
+
+// lintBuiltin applies the built-in checks line by line, skipping comments.
+func lintBuiltin(script string) []Finding {
+	var findings []Finding
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, c := range builtinChecks {
+			if c.pattern.MatchString(line) {
+				findings = append(findings, Finding{Line: i + 1, Level: "warning", Code: c.code, Message: c.message})
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,49 @@
+// Package shell splits command lines into the stages of their pipelines.
+package shell
+
+import "strings"
+
+// PipelineStages returns the text of each stage of a command line's
+// pipelines, split at every | outside quotes, command substitutions and
+// subshells. A line without a pipe gives nil. Stages keep any && or ; lists
+// they contain, so every part of the line is in some stage.
+func PipelineStages(cmd string) []string {
+	var stages []string
+	depth, start := 0, 0
+	for i := 0; i < len(cmd); i++ {
+		switch c := cmd[i]; {
+		case c == '\\':
+			i++
+		case c == '\'':
+			if end := strings.IndexByte(cmd[i+1:], '\''); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(cmd)
+			}
+		case c == '"':
+			for i++; i < len(cmd) && cmd[i] != '"'; i++ {
+				if cmd[i] == '\\' {
+					i++
+				}
+			}
+		case c == '(' || c == '{':
+			depth++
+		case (c == ')' || c == '}') && depth > 0:
+			depth--
+		case c == '|' && depth == 0:
+			if i+1 < len(cmd) && cmd[i+1] == '|' {
+				i++ // || is a list, not a pipe
+				continue
+			}
+			stages = append(stages, strings.TrimSpace(cmd[start:i]))
+			if i+1 < len(cmd) && cmd[i+1] == '&' {
+				i++ // |& pipes stderr too
+			}
+			start = i + 1
+		}
+	}
+	if len(stages) == 0 {
+		return nil
+	}
+	return append(stages, strings.TrimSpace(cmd[start:]))
+}
@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || freebsd)
+
+// Package shell manages executed commands on platforms without Unix process groups.
+package shell
+
+import (
+	"os"
+	"os/exec"
+)
+
+// configureProcess leaves cmd unchanged; the console delivers Ctrl-C to the command itself.
+func configureProcess(cmd *exec.Cmd) (restore func()) {
+	return func() {}
+}
+
+// signalProcess stops the command. Other platforms can't deliver arbitrary signals.
+func signalProcess(cmd *exec.Cmd, sig os.Signal) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
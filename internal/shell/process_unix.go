@@ -0,0 +1,54 @@
+//go:build linux || darwin || freebsd
+
+// Package shell manages the process group of executed commands on Unix.
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// configureProcess runs cmd in its own process group. When nlch owns the
+// terminal, that group becomes the terminal's foreground group so Ctrl-C and
+// job control reach the command directly. The returned function gives the
+// terminal back to nlch once the command has exited.
+func configureProcess(cmd *exec.Cmd) (restore func()) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	tty := int(os.Stdin.Fd())
+	if fg, err := foregroundGroup(tty); err != nil || fg != syscall.Getpgrp() {
+		return func() {}
+	}
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = tty
+	return func() {
+		// Taking the terminal back from the background raises SIGTTOU unless it is ignored
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		setForegroundGroup(tty, syscall.Getpgrp())
+	}
+}
+
+// signalProcess sends sig to the command's whole process group.
+func signalProcess(cmd *exec.Cmd, sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok && cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, s)
+	}
+}
+
+// foregroundGroup returns the foreground process group of the terminal fd.
+func foregroundGroup(fd int) (int, error) {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+// setForegroundGroup makes pgrp the foreground process group of the terminal fd.
+func setForegroundGroup(fd, pgrp int) {
+	id := int32(pgrp)
+	_, _, _ = syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&id)))
+}
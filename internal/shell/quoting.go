@@ -0,0 +1,46 @@
+// Package shell finds names, such as file names from the context, that a
+// command leaves unquoted although the shell would split or expand them.
+package shell
+
+import (
+	"slices"
+	"strings"
+)
+
+// specialChars are the characters that make the shell split or expand a name
+// unless it is quoted: whitespace, quotes, and operators, globs and
+// expansions.
+const specialChars = " \t\n'\"`$\\;&|<>()*?[]{}!"
+
+// NeedsQuoting reports whether a name has to be quoted in a command to reach
+// the program as one word, unchanged.
+func NeedsQuoting(name string) bool {
+	return strings.ContainsAny(name, specialChars) || strings.HasPrefix(name, "~") || strings.HasPrefix(name, "#")
+}
+
+// UnquotedNames returns the names that need quoting and appear in cmd
+// without it: the text of the name is in the command, but no word of the
+// command contains it once quotes are removed, because the shell would split
+// the name or take its quotes away. Globs and $ that would be expanded in a
+// name that stays one word are not found.
+func UnquotedNames(cmd string, names []string) []string {
+	var words []string
+	var unquoted []string
+	for _, name := range names {
+		if !NeedsQuoting(name) || !strings.Contains(cmd, name) || slices.Contains(unquoted, name) {
+			continue
+		}
+		if words == nil {
+			line := parseLine(cmd)
+			for _, stage := range line.stages {
+				words = append(words, stage...)
+			}
+			words = append(words, line.redirects...)
+			words = append(words, line.inputs...)
+		}
+		if !slices.ContainsFunc(words, func(w string) bool { return strings.Contains(w, name) }) {
+			unquoted = append(unquoted, name)
+		}
+	}
+	return unquoted
+}
@@ -0,0 +1,111 @@
+// Package shell provides management of nlch's block in shell startup files.
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Markers delimiting the block of the rc file that nlch manages.
+const (
+	managedBlockStart = "# >>> nlch aliases >>>"
+	managedBlockEnd   = "# <<< nlch aliases <<<"
+	managedEntry      = "# nlch: "
+)
+
+// RCFile returns the startup file for the given shell.
+func RCFile(shellName string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch shellName {
+	case "zsh":
+		return filepath.Join(home, ".zshrc"), nil
+	case "bash":
+		return filepath.Join(home, ".bashrc"), nil
+	case "fish":
+		return filepath.Join(home, ".config", "fish", "config.fish"), nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (supported: bash, fish, zsh)", shellName)
+}
+
+// AddManagedDefinition adds a named alias or function definition to nlch's managed
+// block in the rc file, replacing an earlier definition with the same name.
+// The block is created at the end of the file if it doesn't exist yet.
+func AddManagedDefinition(path, name, definition string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := string(data)
+
+	entry := managedEntry + name + "\n" + strings.TrimRight(definition, "\n") + "\n"
+
+	start := strings.Index(content, managedBlockStart)
+	end := strings.Index(content, managedBlockEnd)
+	if start < 0 || end < start {
+		// Append a new managed block
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += "\n" + managedBlockStart + "\n" +
+			"# Managed by nlch. Entries are added with `nlch alias`.\n" +
+			entry +
+			managedBlockEnd + "\n"
+	} else {
+		block := content[start+len(managedBlockStart) : end]
+		block = removeManagedEntry(block, name)
+		block = strings.TrimRight(block, "\n") + "\n" + entry
+		content = content[:start] + managedBlockStart + block + content[end:]
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// removeManagedEntry removes the entry with the given name from the block.
+// An entry spans from its marker comment to the next marker comment.
+func removeManagedEntry(block, name string) string {
+	lines := strings.Split(block, "\n")
+	var kept []string
+	skipping := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, managedEntry) {
+			skipping = strings.TrimPrefix(line, managedEntry) == name
+		}
+		if !skipping {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// DefinitionName returns the name defined by an alias or function definition.
+func DefinitionName(definition string) string {
+	for _, line := range strings.Split(definition, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "alias "):
+			rest := strings.TrimSpace(strings.TrimPrefix(line, "alias "))
+			// bash/zsh use name=value, fish uses name value
+			if i := strings.IndexAny(rest, "= "); i > 0 {
+				return rest[:i]
+			}
+		case strings.HasPrefix(line, "function "):
+			fields := strings.Fields(strings.TrimPrefix(line, "function "))
+			if len(fields) > 0 {
+				return strings.TrimSuffix(strings.TrimSuffix(fields[0], "{"), "()")
+			}
+		case strings.Contains(line, "()"):
+			return strings.TrimSpace(line[:strings.Index(line, "()")])
+		}
+	}
+	return ""
+}
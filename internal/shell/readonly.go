@@ -0,0 +1,90 @@
+// Package shell decides whether a command line only reads, for read-only
+// mode, which refuses anything it does not recognise.
+package shell
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// readOnlyWrappers are the wrappers that may run a read-only program.
+// nohup writes nohup.out, and sudo and doas are refused outright in
+// read-only mode, so they are not among them.
+var readOnlyWrappers = []string{"env", "nice", "time", "timeout", "xargs", "command", "exec", "watch"}
+
+// settingBuiltins set shell variables, which can change what later programs
+// run, through PAGER or GIT_EXTERNAL_DIFF for example.
+var settingBuiltins = []string{"export", "local", "read", "declare", "typeset", "readonly", "set"}
+
+// ReadOnly reports whether a command line only reads files and system state
+// and, if not, why. Unlike Classify, which rates what it does not know as
+// medium, it refuses everything that is not a known read-only program with
+// arguments known not to change anything.
+func ReadOnly(cmd string) (bool, string) {
+	line := parseLine(cmd)
+	for _, target := range line.redirects {
+		if target != "/dev/null" && target != "/dev/stdout" && target != "/dev/stderr" {
+			return false, "writes to " + target
+		}
+	}
+	for _, stage := range line.stages {
+		if why := stageWrites(stage); why != "" {
+			return false, why
+		}
+	}
+	return true, ""
+}
+
+// stageWrites returns why a simple command, given as its words, is not known
+// to only read, or "" if it is.
+func stageWrites(stage []string) string {
+	// Command substitutions in double quotes are left in their words by parseLine
+	if slices.ContainsFunc(stage, func(w string) bool { return strings.Contains(w, "$(") || strings.Contains(w, "`") }) {
+		return "runs a command substitution"
+	}
+	words, assignments, wrapped := simpleCommand(stage)
+	for _, a := range assignments {
+		if name, _, _ := strings.Cut(a, "="); !isLocaleVariable(name) {
+			return "sets " + name
+		}
+	}
+	for _, w := range wrapped {
+		if !slices.Contains(readOnlyWrappers, w) {
+			return "runs " + w
+		}
+	}
+	if len(words) == 0 {
+		return ""
+	}
+	name, args := filepath.Base(words[0]), words[1:]
+
+	switch {
+	case slices.Contains(settingBuiltins, name):
+		return "sets shell variables"
+	case name == "printf" && slices.Contains(args, "-v"):
+		return "sets a shell variable"
+	case name == "sed" && slices.ContainsFunc(args, func(a string) bool { return strings.HasPrefix(a, "-i") || a == "--in-place" }):
+		return "edits files in place"
+	case name == "git" && slices.ContainsFunc(args, func(a string) bool {
+		return a == "-c" || a == "--config-env" || strings.HasPrefix(a, "--exec-path")
+	}):
+		return "changes the git configuration, which can run commands"
+	}
+	if why := writesWith(name, args); why != "" {
+		return why
+	}
+	if slices.Contains(readOnlyPrograms, name) {
+		return ""
+	}
+	if subs, ok := readOnlySubcommands[name]; ok && slices.Contains(subs, subcommand(name, args)) {
+		return ""
+	}
+	return "runs " + name + ", which is not known to only read"
+}
+
+// isLocaleVariable reports whether an environment variable only changes how
+// output is formatted, such as LC_ALL in LC_ALL=C sort.
+func isLocaleVariable(name string) bool {
+	return strings.HasPrefix(name, "LC_") || slices.Contains([]string{"LANG", "LANGUAGE", "TZ", "COLUMNS", "NO_COLOR"}, name)
+}
@@ -0,0 +1,445 @@
+// Package shell rates how risky a command is to run.
+package shell
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Risk is how much harm a command can do if it is not what the user wanted.
+type Risk int
+
+const (
+	RiskLow      Risk = iota // only reads: listing, searching, inspecting
+	RiskMedium               // changes something, or is not known to be harmless
+	RiskHigh                 // deletes or overwrites data, or affects the whole system
+	RiskCritical             // can destroy the system, a disk or a home directory
+)
+
+var riskNames = []string{"low", "medium", "high", "critical"}
+
+func (r Risk) String() string {
+	if r < RiskLow || r > RiskCritical {
+		return fmt.Sprintf("Risk(%d)", int(r))
+	}
+	return riskNames[r]
+}
+
+// ParseRisk parses a risk level name.
+func ParseRisk(name string) (Risk, error) {
+	if i := slices.Index(riskNames, strings.ToLower(strings.TrimSpace(name))); i >= 0 {
+		return Risk(i), nil
+	}
+	return RiskLow, fmt.Errorf("unknown risk level %q, use one of %s", name, strings.Join(riskNames, ", "))
+}
+
+// readOnlyPrograms only read files or system state, apart from the exceptions
+// handled in stageRisk and the arguments writesWith recognises.
+var readOnlyPrograms = []string{
+	"ls", "ll", "la", "dir", "tree", "pwd", "cat", "tac", "less", "more", "head", "tail", "bat",
+	"grep", "egrep", "fgrep", "rg", "ag", "ack", "wc", "sort", "uniq", "cut", "tr", "column", "nl",
+	"fold", "paste", "join", "comm", "diff", "cmp", "awk", "gawk", "sed", "jq", "yq", "find", "fd",
+	"locate", "which", "whereis", "type", "file", "stat", "du", "df", "free", "ps", "pgrep", "top",
+	"htop", "uptime", "whoami", "id", "groups", "uname", "hostname", "date", "cal", "echo",
+	"printf", "printenv", "basename", "dirname", "realpath", "readlink", "md5sum",
+	"sha1sum", "sha256sum", "shasum", "cksum", "xxd", "hexdump", "od", "strings", "lsblk",
+	"lsof", "lscpu", "lsusb", "lspci", "ip", "ifconfig", "netstat", "ss", "ping", "dig",
+	"nslookup", "host", "man", "seq", "true", "false", "test", "[", "history", "tldr",
+	"cd", "read", "export", "local", "sleep", "exit", "return", "break", "continue", "shift", "wait",
+}
+
+// readOnlySubcommands are the read-only subcommands of tools that can also change things.
+var readOnlySubcommands = map[string][]string{
+	"git":       {"status", "log", "diff", "show", "blame", "shortlog", "describe", "rev-parse", "ls-files", "grep", "reflog", "whatchanged"},
+	"docker":    {"ps", "images", "logs", "inspect", "stats", "top", "version", "info"},
+	"podman":    {"ps", "images", "logs", "inspect", "stats", "top", "version", "info"},
+	"kubectl":   {"get", "describe", "logs", "top", "explain", "version"},
+	"systemctl": {"status", "list-units", "list-timers", "is-active", "is-enabled", "show", "cat"},
+	"go":        {"version", "env", "list", "doc", "vet"},
+	"npm":       {"ls", "list", "view", "outdated", "search"},
+	"brew":      {"list", "info", "search", "outdated"},
+}
+
+// wrappers run the command that follows them, after their own options.
+var wrappers = []string{"sudo", "doas", "env", "nice", "nohup", "time", "timeout", "xargs", "command", "exec", "watch"}
+
+// Shell keywords that precede a command, and those that start or end a
+// compound command without running anything themselves.
+var (
+	commandKeywords  = []string{"if", "then", "else", "elif", "while", "until", "do", "!", "{"}
+	structureKeyword = []string{"for", "select", "case", "in", "done", "fi", "esac", "}", "function"}
+)
+
+// systemPaths are targets whose recursive deletion or overwriting destroys
+// the system or the user's files.
+var systemPaths = []string{
+	"/", "/*", "~", "~/", "~/*", "$HOME", "$HOME/", "$HOME/*", "${HOME}", "/home", "/root", "/etc",
+	"/usr", "/var", "/bin", "/sbin", "/lib", "/lib64", "/boot", "/opt", "/System", "/Users",
+}
+
+// Classify rates the risk of a command line with local rules, and gives the
+// reason for any risk above low. Unknown programs are rated medium: a command
+// is only low risk if every part of it is known to only read.
+func Classify(cmd string) (Risk, string) {
+	line := parseLine(cmd)
+	risk, reason := RiskLow, ""
+	raise := func(r Risk, why string) {
+		if r > risk {
+			risk, reason = r, why
+		}
+	}
+
+	if strings.Contains(strings.ReplaceAll(cmd, " ", ""), ":(){:|:&};:") {
+		raise(RiskCritical, "fork bomb")
+	}
+	for _, target := range line.redirects {
+		switch {
+		case isDevice(target):
+			raise(RiskCritical, "writes directly to a disk device")
+		case target != "/dev/null" && target != "/dev/stdout" && target != "/dev/stderr":
+			raise(RiskMedium, "writes to "+target)
+		}
+	}
+	for i, stage := range line.stages {
+		r, why := stageRisk(stage)
+		raise(r, why)
+		// Downloading a script straight into a shell runs code nobody has reviewed
+		if i > 0 && slices.Contains([]string{"sh", "bash", "zsh", "fish", "dash"}, program(stage)) && line.piped[i] {
+			if prev := program(line.stages[i-1]); prev == "curl" || prev == "wget" {
+				raise(RiskHigh, "pipes a download into a shell")
+			}
+		}
+	}
+	return risk, reason
+}
+
+// IsDangerousCommand returns true if the command is considered dangerous.
+func IsDangerousCommand(cmd string) bool {
+	risk, _ := Classify(cmd)
+	return risk >= RiskHigh
+}
+
+// stageRisk rates a single simple command, given as its words.
+func stageRisk(words []string) (Risk, string) {
+	words, _, wrapped := simpleCommand(words)
+	if len(words) == 0 {
+		return RiskLow, ""
+	}
+	name, args := filepath.Base(words[0]), words[1:]
+	asRoot := slices.Contains(wrapped, "sudo") || slices.Contains(wrapped, "doas")
+
+	switch {
+	case strings.HasPrefix(name, "mkfs") || name == "wipefs":
+		return RiskCritical, "formats a disk"
+	case name == "rm" || name == "rmdir" || name == "shred" || name == "unlink":
+		recursive := slices.ContainsFunc(args, func(a string) bool {
+			return a == "--recursive" || strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && strings.ContainsAny(a, "rR")
+		})
+		if recursive && slices.ContainsFunc(args, isSystemPath) {
+			return RiskCritical, "recursively deletes a system or home directory"
+		}
+		return RiskHigh, "deletes files"
+	case name == "dd":
+		if slices.ContainsFunc(args, func(a string) bool { return strings.HasPrefix(a, "of=") && isDevice(a[3:]) }) {
+			return RiskCritical, "writes directly to a disk device"
+		}
+		return RiskHigh, "overwrites data"
+	case name == "chmod" || name == "chown" || name == "chgrp":
+		if slices.ContainsFunc(args, func(a string) bool { return a == "-R" || a == "--recursive" }) {
+			if slices.ContainsFunc(args, isSystemPath) {
+				return RiskCritical, "recursively changes permissions of a system or home directory"
+			}
+			return RiskHigh, "recursively changes permissions"
+		}
+		return RiskMedium, "changes permissions"
+	case slices.Contains([]string{"shutdown", "reboot", "halt", "poweroff"}, name),
+		name == "init" && len(args) > 0 && (args[0] == "0" || args[0] == "6"):
+		return RiskHigh, "shuts down or restarts the system"
+	case name == "truncate" || name == "fdisk" || name == "parted" || name == "sfdisk":
+		return RiskHigh, "overwrites data"
+	case name == "kill" || name == "killall" || name == "pkill":
+		return RiskHigh, "terminates processes"
+	case name == "crontab" && slices.Contains(args, "-r"):
+		return RiskHigh, "deletes the crontab"
+	case name == "find":
+		if slices.ContainsFunc(args, func(a string) bool { return a == "-delete" }) {
+			return RiskHigh, "deletes the files it finds"
+		}
+		// Rate the command -exec runs on each file, which ends at ; or +
+		for i, a := range args {
+			if a != "-exec" && a != "-execdir" && a != "-ok" && a != "-okdir" {
+				continue
+			}
+			end := i + 1
+			for end < len(args) && args[end] != ";" && args[end] != "+" {
+				end++
+			}
+			r, why := stageRisk(args[i+1 : end])
+			if r >= RiskHigh {
+				return r, why
+			}
+			return RiskMedium, "runs a command on the files it finds"
+		}
+	case name == "sed" || name == "perl":
+		if slices.ContainsFunc(args, func(a string) bool { return strings.HasPrefix(a, "-i") || a == "--in-place" }) {
+			return RiskMedium, "edits files in place"
+		}
+	case name == "git":
+		sub := subcommand(name, args)
+		switch {
+		case sub == "push" && slices.ContainsFunc(args, func(a string) bool { return a == "-f" || strings.HasPrefix(a, "--force") }):
+			return RiskHigh, "force-pushes, which can discard commits on the remote"
+		case sub == "reset" && slices.Contains(args, "--hard"),
+			sub == "clean" && slices.ContainsFunc(args, func(a string) bool { return strings.HasPrefix(a, "-") && strings.Contains(a, "f") }),
+			sub == "checkout" && slices.Contains(args, "--") && slices.Contains(args, "."),
+			sub == "branch" && slices.ContainsFunc(args, func(a string) bool { return a == "-D" }):
+			return RiskHigh, "discards uncommitted or unmerged work"
+		}
+	case name == "docker" || name == "podman":
+		if sub := subcommand(name, args); sub == "rm" || sub == "rmi" || sub == "prune" || slices.Contains(args, "prune") {
+			return RiskHigh, "deletes containers, images or volumes"
+		}
+	case name == "kubectl":
+		if subcommand(name, args) == "delete" {
+			return RiskHigh, "deletes cluster resources"
+		}
+	}
+
+	if asRoot {
+		return RiskMedium, "runs " + name + " as root"
+	}
+	if why := writesWith(name, args); why != "" {
+		return RiskMedium, why
+	}
+	if slices.Contains(readOnlyPrograms, name) {
+		return RiskLow, ""
+	}
+	if subs, ok := readOnlySubcommands[name]; ok && slices.Contains(subs, subcommand(name, args)) {
+		return RiskLow, ""
+	}
+	return RiskMedium, "runs " + name
+}
+
+// simpleCommand strips the keywords, environment assignments and wrappers
+// before the program a stage runs, and returns the program's words with the
+// assignments and wrappers it skipped. The words of a compound command's
+// structure, such as done, run no program.
+func simpleCommand(words []string) (cmd, assignments, wrapped []string) {
+	for len(words) > 0 {
+		w := words[0]
+		switch {
+		case slices.Contains(structureKeyword, w):
+			return nil, assignments, wrapped
+		case slices.Contains(commandKeywords, w):
+			words = words[1:]
+			continue
+		case strings.Contains(w, "=") && !strings.HasPrefix(w, "-"):
+			assignments = append(assignments, w)
+			words = words[1:]
+			continue
+		case slices.Contains(wrappers, filepath.Base(w)):
+			wrapped = append(wrapped, filepath.Base(w))
+			words = words[1:]
+			for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+				words = words[1:]
+			}
+			if w == "timeout" && len(words) > 0 {
+				words = words[1:] // the duration
+			}
+			continue
+		}
+		break
+	}
+	return words, assignments, wrapped
+}
+
+// program returns the name of the program a stage runs, skipping wrappers.
+func program(stage []string) string {
+	for _, w := range stage {
+		if strings.Contains(w, "=") || slices.Contains(wrappers, w) || strings.HasPrefix(w, "-") {
+			continue
+		}
+		return filepath.Base(w)
+	}
+	return ""
+}
+
+// firstArg returns the first argument that is not an option, such as a subcommand.
+func firstArg(args []string) string {
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			return a
+		}
+	}
+	return ""
+}
+
+// valueOptions are the global options of tools with subcommands that take a
+// value as the next word, which is not the subcommand.
+var valueOptions = map[string][]string{
+	"git":       {"-C", "-c", "--git-dir", "--work-tree", "--namespace", "--super-prefix", "--config-env", "--exec-path"},
+	"docker":    {"-c", "--context", "-H", "--host", "--config", "-l", "--log-level", "--tlscacert", "--tlscert", "--tlskey"},
+	"podman":    {"-c", "--connection", "--url", "--root", "--runroot", "--log-level", "--storage-driver", "--cgroup-manager"},
+	"kubectl":   {"-n", "--namespace", "--context", "--kubeconfig", "--cluster", "--user", "-s", "--server", "--as", "--as-group", "--token", "--request-timeout", "-v"},
+	"systemctl": {"-H", "--host", "-M", "--machine", "-t", "--type", "-p", "--property", "--state", "--root", "-o", "--output"},
+	"go":        {"-C"},
+	"npm":       {"--prefix", "-w", "--workspace"},
+	"ip":        {"-n", "-netns", "-b", "-batch", "-rc", "-rcvbuf", "-l", "-loops"},
+}
+
+// subcommand returns the subcommand of a tool's arguments: the first word
+// that is neither an option nor the value of one of its valueOptions, as
+// the /repo of git -C /repo push.
+func subcommand(name string, args []string) string {
+	takesValue := valueOptions[name]
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") {
+			return a
+		}
+		if slices.Contains(takesValue, a) {
+			i++
+		}
+	}
+	return ""
+}
+
+// isSystemPath reports whether a target is the root, a home or a system directory.
+func isSystemPath(target string) bool {
+	return slices.Contains(systemPaths, strings.TrimRight(target, "/")) || slices.Contains(systemPaths, target)
+}
+
+// isDevice reports whether a path is a block device of a disk.
+func isDevice(target string) bool {
+	for _, prefix := range []string{"/dev/sd", "/dev/hd", "/dev/vd", "/dev/xvd", "/dev/nvme", "/dev/disk", "/dev/mmcblk", "/dev/rdisk"} {
+		if strings.HasPrefix(target, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandLine is a command line split into simple commands.
+type commandLine struct {
+	stages    [][]string // words of each simple command, unquoted
+	piped     []bool     // whether each stage reads the output of the one before
+	redirects []string   // targets of output redirections
+	inputs    []string   // files read with input redirections
+}
+
+// parseLine splits a command line into simple commands at pipes, lists and
+// command substitutions, honouring quotes, and collects redirections.
+// It is not a full shell parser, but enough to tell which programs run.
+func parseLine(cmd string) commandLine {
+	var line commandLine
+	var words []string
+	var word strings.Builder
+	inWord, piped, redirect, input := false, false, false, false
+
+	endWord := func() {
+		if !inWord {
+			return
+		}
+		if redirect {
+			line.redirects = append(line.redirects, word.String())
+			redirect = false
+		} else if input {
+			line.inputs = append(line.inputs, word.String())
+			input = false
+		} else {
+			words = append(words, word.String())
+		}
+		word.Reset()
+		inWord = false
+	}
+	endStage := func(nextPiped bool) {
+		endWord()
+		if len(words) > 0 {
+			line.stages = append(line.stages, words)
+			line.piped = append(line.piped, piped)
+		}
+		words, piped = nil, nextPiped
+	}
+
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case c == '\'':
+			end := strings.IndexByte(cmd[i+1:], '\'')
+			if end < 0 {
+				end = len(cmd) - i - 1
+			}
+			word.WriteString(cmd[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == '"':
+			j := i + 1
+			for j < len(cmd) && cmd[j] != '"' {
+				if cmd[j] == '\\' && j+1 < len(cmd) {
+					j++
+				}
+				word.WriteByte(cmd[j])
+				j++
+			}
+			inWord = true
+			i = j
+		case c == '\\' && i+1 < len(cmd):
+			word.WriteByte(cmd[i+1])
+			inWord = true
+			i++
+		case c == ' ' || c == '\t':
+			endWord()
+		case c == '|':
+			if i+1 < len(cmd) && cmd[i+1] == '|' {
+				i++
+				endStage(false)
+			} else {
+				endStage(true)
+			}
+		case c == ';' || c == '&' || c == '\n' || c == '`' || c == '(' || c == ')':
+			if c == '&' && i+1 < len(cmd) && cmd[i+1] == '>' {
+				continue // &> redirects both stdout and stderr
+			}
+			if c == '(' && word.Len() > 0 && strings.HasSuffix(word.String(), "$") {
+				// $( starts a command substitution
+				s := word.String()
+				word.Reset()
+				word.WriteString(s[:len(s)-1])
+			}
+			endStage(false)
+		case c == '>':
+			endWord()
+			if i+1 < len(cmd) && cmd[i+1] == '>' {
+				i++
+			}
+			// Redirecting to a file descriptor, as in 2>&1, writes nothing
+			if i+1 < len(cmd) && cmd[i+1] == '&' {
+				i++
+				for i+1 < len(cmd) && (cmd[i+1] >= '0' && cmd[i+1] <= '9' || cmd[i+1] == '-') {
+					i++
+				}
+				continue
+			}
+			// A file descriptor number before > is not a word
+			if n := len(words); n > 0 && isNumber(words[n-1]) && i > 0 && cmd[i-1] != ' ' {
+				words = words[:n-1]
+			}
+			redirect = true
+		case c == '<':
+			endWord()
+			input = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endStage(false)
+	return line
+}
+
+// isNumber reports whether s consists of digits only.
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
@@ -0,0 +1,113 @@
+package shell
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want Risk
+	}{
+		// Reading
+		{"ls -la", RiskLow},
+		{"awk '{print $1}' data.txt", RiskLow},
+		{"awk '$3 > max {max = $3} END {print max}' data.txt", RiskLow},
+		{"awk '/foo|bar/' log", RiskLow},
+		{"sed -n 1p notes.txt", RiskLow},
+		{"sed 's/foo/bar/g' notes.txt", RiskLow},
+		{"sed -e '/^#/d' -e 's/a/b/' conf", RiskLow},
+		{"find . -name '*.go'", RiskLow},
+		{"ip addr", RiskLow},
+		{"ip -br addr show dev eth0", RiskLow},
+		{"ip route get 1.1.1.1", RiskLow},
+		{"ifconfig -a", RiskLow},
+		{"ifconfig eth0", RiskLow},
+		{"hostname", RiskLow},
+		{"hostname -I", RiskLow},
+		{"date +%F", RiskLow},
+		{"date -d yesterday +%F", RiskLow},
+		{"history 20", RiskLow},
+		{"xxd -l 64 image.png", RiskLow},
+		{"git -C /repo status", RiskLow},
+		{"kubectl -n prod get pods", RiskLow},
+
+		// Arguments that make reading programs change things
+		{`awk 'BEGIN{system("rm -rf ~")}'`, RiskMedium},
+		{`awk '{print > "out.txt"}' data.txt`, RiskMedium},
+		{`awk '{print | "sh"}' data.txt`, RiskMedium},
+		{`awk 'BEGIN{"date" | getline d}'`, RiskMedium},
+		{"awk -f prog.awk data.txt", RiskMedium},
+		{"sed -n 'w /etc/x' notes.txt", RiskMedium},
+		{"sed 'e id' notes.txt", RiskMedium},
+		{"sed '/x/W out' notes.txt", RiskMedium},
+		{"sed 's/x/y/w out' notes.txt", RiskMedium},
+		{"sed -e 1d -e 's/a/b/e' notes.txt", RiskMedium},
+		{"find . -fprint0 list", RiskMedium},
+		{"find . -fls list", RiskMedium},
+		{"ip link set eth0 down", RiskMedium},
+		{"ip route add default via 10.0.0.1", RiskMedium},
+		{"ifconfig eth0 down", RiskMedium},
+		{"hostname foo", RiskMedium},
+		{"date -s '2020-01-01'", RiskMedium},
+		{"date 010112002020", RiskMedium},
+		{"history -c", RiskMedium},
+		{"sort -o data.txt data.txt", RiskMedium},
+		{"uniq in.txt out.txt", RiskMedium},
+		{"xxd -r dump.hex", RiskMedium},
+		{"go env -w GOFLAGS=-mod=mod", RiskMedium},
+
+		// Deleting
+		{"find / -delete", RiskHigh},
+		{"git -C /repo push -f", RiskHigh},
+		{"git -C x reset --hard", RiskHigh},
+		{"git -C x branch -D y", RiskHigh},
+		{"git -c core.pager=cat push --force origin main", RiskHigh},
+		{"docker --context prod rm web", RiskHigh},
+		{"kubectl -n prod delete pod web", RiskHigh},
+		{"kubectl --context prod delete ns app", RiskHigh},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd, func(t *testing.T) {
+			if got, why := Classify(tt.cmd); got != tt.want {
+				t.Errorf("Classify(%q) = %s (%s), want %s", tt.cmd, got, why, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want bool
+	}{
+		{"ls -la", true},
+		{"grep -rn TODO . | sort | uniq -c", true},
+		{"LC_ALL=C sort names.txt", true},
+		{"git -C /repo log --oneline", true},
+		{"find . -name '*.go' 2>/dev/null", true},
+		{"for f in *.txt; do wc -l $f; done", true},
+
+		{"touch file", false},
+		{"ls > files.txt", false},
+		{"mkdir out", false},
+		{"git commit -m x", false},
+		{"git -c core.pager='rm -rf ~' log", false},
+		{"PAGER='sh -c id' git log", false},
+		{"export GIT_PAGER=id; git log", false},
+		{`echo "$(touch x)"`, false},
+		{"find . -exec cat {} ;", false},
+		{"find / -delete", false},
+		{`awk 'BEGIN{system("id")}'`, false},
+		{"sed -i s/a/b/ notes.txt", false},
+		{"nohup ls", false},
+		{"sudo cat /etc/shadow", false},
+		{"less -o copy.txt notes.txt", false},
+		{"mystery-tool --list", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd, func(t *testing.T) {
+			if got, why := ReadOnly(tt.cmd); got != tt.want {
+				t.Errorf("ReadOnly(%q) = %v (%s), want %v", tt.cmd, got, why, tt.want)
+			}
+		})
+	}
+}
@@ -2,21 +2,120 @@
 package shell
 
 import (
+	"path/filepath"
+	"regexp"
 	"strings"
 )
 
-// List of dangerous commands requiring extra confirmation.
-var dangerousCommands = []string{
-	"rm", "dd", "mkfs", "shutdown", "reboot", "init 0", "halt",
+// Leading words stripped from a "never" rule before it is matched against a command.
+var constraintVerbs = []string{"never", "suggest", "use", "run", "call", "invoke", "execute", "pipe"}
+
+// ViolatedConstraints returns the rules from a `never:` list that the command violates.
+// A rule such as "never suggest curl | sh" matches any pipeline where a curl stage
+// is directly followed by an sh stage; "never use sudo" matches any stage containing sudo.
+// Rules written as /regexp/ are matched as regular expressions against the whole command.
+func ViolatedConstraints(cmd string, rules []string) []string {
+	var violated []string
+	pipelines := splitPipelines(cmd)
+	for _, rule := range rules {
+		pattern := constraintPattern(rule)
+		if pattern == "" {
+			continue
+		}
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err == nil && re.MatchString(cmd) {
+				violated = append(violated, rule)
+			}
+			continue
+		}
+		if matchesPipelines(pipelines, splitStages(pattern)) {
+			violated = append(violated, rule)
+		}
+	}
+	return violated
+}
+
+// constraintPattern strips the natural-language prefix from a rule, leaving the command fragment.
+func constraintPattern(rule string) string {
+	pattern := strings.TrimSpace(rule)
+	for {
+		lower := strings.ToLower(pattern)
+		trimmed := false
+		for _, verb := range constraintVerbs {
+			if strings.HasPrefix(lower, verb+" ") {
+				pattern = strings.TrimSpace(pattern[len(verb):])
+				trimmed = true
+				break
+			}
+		}
+		if !trimmed {
+			break
+		}
+	}
+	return strings.Trim(pattern, "\"'` ")
+}
+
+// splitPipelines splits a command line into pipelines, each being a list of stages,
+// each stage being a list of words.
+func splitPipelines(cmd string) [][][]string {
+	separators := strings.NewReplacer("&&", "\x00", "||", "\x00", ";", "\x00", "\n", "\x00")
+	var pipelines [][][]string
+	for _, part := range strings.Split(separators.Replace(cmd), "\x00") {
+		if stages := splitStages(part); len(stages) > 0 {
+			pipelines = append(pipelines, stages)
+		}
+	}
+	return pipelines
+}
+
+// splitStages splits a single pipeline on `|` into word lists.
+func splitStages(pipeline string) [][]string {
+	var stages [][]string
+	for _, stage := range strings.Split(pipeline, "|") {
+		var words []string
+		for _, w := range strings.Fields(stage) {
+			w = strings.Trim(w, "\"'`$()")
+			if w != "" {
+				words = append(words, w)
+			}
+		}
+		if len(words) > 0 {
+			stages = append(stages, words)
+		}
+	}
+	return stages
 }
 
-// IsDangerousCommand returns true if the command is considered dangerous.
-func IsDangerousCommand(cmd string) bool {
-	lower := strings.ToLower(cmd)
-	for _, danger := range dangerousCommands {
-		if strings.Contains(lower, danger) {
-			return true
+// matchesPipelines reports whether any pipeline contains consecutive stages matching the pattern.
+func matchesPipelines(pipelines [][][]string, pattern [][]string) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+	for _, stages := range pipelines {
+		for i := 0; i+len(pattern) <= len(stages); i++ {
+			ok := true
+			for j, want := range pattern {
+				if !containsWords(stages[i+j], want) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				return true
+			}
 		}
 	}
 	return false
 }
+
+// containsWords reports whether stage contains the wanted words in order.
+func containsWords(stage, want []string) bool {
+	i := 0
+	for _, w := range stage {
+		if i < len(want) && (w == want[i] || filepath.Base(w) == want[i]) {
+			i++
+		}
+	}
+	return i == len(want)
+}
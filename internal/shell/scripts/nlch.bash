@@ -0,0 +1,54 @@
+# nlch bash integration.
+# Add the following to ~/.bashrc:
+#   eval "$(nlch shell-init bash)"
+# Type a description on the command line and press Alt-g (or $NLCH_BINDKEY)
+# to replace it with the generated command. Press Alt-h (or $NLCH_HISTORY_BINDKEY)
+# to search past nlch commands with fzf and insert the one you pick. A failed
+# command line is remembered so `nlch why` can diagnose it.
+
+_nlch_widget() {
+  [[ -z "$READLINE_LINE" ]] && return
+  local cmd
+  cmd=$(command nlch run --print -- "$READLINE_LINE" </dev/tty) || return
+  if [[ -n "$cmd" ]]; then
+    READLINE_LINE=$cmd
+    READLINE_POINT=${#READLINE_LINE}
+  fi
+}
+
+bind -x "\"${NLCH_BINDKEY:-\\eg}\": _nlch_widget"
+
+_nlch_history_widget() {
+  local cmd
+  cmd=$(command nlch history pick "$READLINE_LINE" </dev/tty) || return
+  if [[ -n "$cmd" ]]; then
+    READLINE_LINE=$cmd
+    READLINE_POINT=${#READLINE_LINE}
+  fi
+}
+
+bind -x "\"${NLCH_HISTORY_BINDKEY:-\\eh}\": _nlch_history_widget"
+
+# Remember the last failed command line for `nlch why`. Running nlch itself
+# forgets it, so `nlch why` then falls back to the last command nlch ran.
+_nlch_record_status() {
+  local exit_status=$? last
+  last=$(HISTTIMEFORMAT= builtin history 1)
+  last=${last#*[0-9]  }
+  case $last in
+    "nlch why"*) ;;
+    nlch*) unset NLCH_LAST_COMMAND NLCH_LAST_STATUS ;;
+    *)
+      if (( exit_status != 0 )); then
+        export NLCH_LAST_COMMAND=$last NLCH_LAST_STATUS=$exit_status
+      else
+        unset NLCH_LAST_COMMAND NLCH_LAST_STATUS
+      fi
+      ;;
+  esac
+  return $exit_status
+}
+
+if [[ $PROMPT_COMMAND != *_nlch_record_status* ]]; then
+  PROMPT_COMMAND="_nlch_record_status${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
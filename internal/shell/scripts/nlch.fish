@@ -0,0 +1,57 @@
+# nlch fish integration.
+# Add the following to ~/.config/fish/config.fish:
+#   nlch shell-init fish | source
+# Type a description on the command line and press Alt-g (or $NLCH_BINDKEY)
+# to replace it with the generated command. Press Alt-h (or $NLCH_HISTORY_BINDKEY)
+# to search past nlch commands with fzf and insert the one you pick. A failed
+# command line is remembered so `nlch why` can diagnose it.
+
+function _nlch_widget
+    set -l buf (commandline)
+    if test -z "$buf"
+        return
+    end
+    set -l cmd (command nlch run --print -- "$buf" </dev/tty | string collect)
+    if test $status -eq 0; and test -n "$cmd"
+        commandline -r -- $cmd
+    end
+    commandline -f repaint
+end
+
+if set -q NLCH_BINDKEY
+    bind $NLCH_BINDKEY _nlch_widget
+else
+    bind \eg _nlch_widget
+end
+
+function _nlch_history_widget
+    set -l cmd (command nlch history pick (commandline) </dev/tty | string collect)
+    if test $status -eq 0; and test -n "$cmd"
+        commandline -r -- $cmd
+    end
+    commandline -f repaint
+end
+
+if set -q NLCH_HISTORY_BINDKEY
+    bind $NLCH_HISTORY_BINDKEY _nlch_history_widget
+else
+    bind \eh _nlch_history_widget
+end
+
+# Remember the last failed command line for `nlch why`. Running nlch itself
+# forgets it, so `nlch why` then falls back to the last command nlch ran.
+function _nlch_record_status --on-event fish_postexec
+    set -l exit_status $status
+    switch $argv[1]
+        case 'nlch why*'
+        case 'nlch*'
+            set -e NLCH_LAST_COMMAND NLCH_LAST_STATUS
+        case '*'
+            if test $exit_status -ne 0
+                set -gx NLCH_LAST_COMMAND $argv[1]
+                set -gx NLCH_LAST_STATUS $exit_status
+            else
+                set -e NLCH_LAST_COMMAND NLCH_LAST_STATUS
+            end
+    end
+end
@@ -0,0 +1,30 @@
+// Package shell provides syntax checking of generated commands.
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// bashLocation matches the "bash: -c: line 1: " prefix of the shell's error messages.
+var bashLocation = regexp.MustCompile(`(?m)^.*?-c: line \d+: `)
+
+// CheckSyntax parses cmd with the shell that would run it, without running it,
+// and returns the parse error, if any.
+func CheckSyntax(cmd string) error {
+	var stderr bytes.Buffer
+	check := exec.Command(Interpreter(), "-n", "-c", cmd)
+	check.Stderr = &stderr
+	if err := check.Run(); err != nil {
+		msg := strings.TrimSpace(bashLocation.ReplaceAllString(stderr.String(), ""))
+		msg = strings.ReplaceAll(msg, "\n", "; ")
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("syntax error: %s", msg)
+	}
+	return nil
+}
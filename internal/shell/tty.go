@@ -0,0 +1,35 @@
+// Package shell reads answers from the terminal when stdin carries data.
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ErrNoTerminal is returned by ReadTerminalLine when there is no terminal to ask.
+var ErrNoTerminal = errors.New("no terminal to ask for confirmation")
+
+// ReadTerminalLine prints the prompt to stderr and reads a line from the
+// controlling terminal rather than stdin, for commands whose stdin is data.
+func ReadTerminalLine(prompt string) (string, error) {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+	tty, err := os.Open(name)
+	if err != nil {
+		return "", ErrNoTerminal
+	}
+	defer tty.Close()
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
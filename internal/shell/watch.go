@@ -0,0 +1,99 @@
+// Package shell recognises commands that keep running and printing updates
+// until they are stopped, such as watch or tail -f.
+package shell
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultWatchLimit is how long a watch-style command runs unless the config
+// or the caller sets another limit.
+const DefaultWatchLimit = 10 * time.Minute
+
+// Watching tells whether a command keeps running until it is stopped, such
+// as watch, tail -f, kubectl get -w or a while true loop, and why.
+func Watching(cmd string) (bool, string) {
+	for _, stage := range parseLine(cmd).stages {
+		if why := stageWatches(stage); why != "" {
+			return true, why
+		}
+	}
+	return false, ""
+}
+
+// stageWatches returns why a simple command runs until stopped, or "" if it doesn't.
+func stageWatches(stage []string) string {
+	if len(stage) >= 2 && (stage[0] == "while" && (stage[1] == "true" || stage[1] == ":") || stage[0] == "until" && stage[1] == "false") {
+		return "the loop runs until stopped"
+	}
+	name, args := programArgs(stage)
+	has := func(options ...string) bool {
+		return slices.ContainsFunc(args, func(a string) bool { return slices.Contains(options, a) })
+	}
+	sub := subcommand(name, args)
+	switch {
+	case name == "watch":
+		return "watch reruns the command until stopped"
+	case name == "tail" && (has("--follow") || slices.ContainsFunc(args, isFollowOption)):
+		return "tail follows the file"
+	case name == "journalctl" && has("-f", "--follow"):
+		return "journalctl follows the journal"
+	case name == "kubectl" && sub == "logs" && has("-f", "--follow"):
+		return "kubectl logs follows the log"
+	case name == "kubectl" && has("-w", "--watch", "--watch-only"):
+		return "kubectl watches for changes"
+	case (name == "docker" || name == "podman") && sub == "logs" && has("-f", "--follow"):
+		return name + " logs follows the log"
+	case (name == "docker" || name == "podman") && (sub == "stats" && !has("--no-stream") || sub == "events"):
+		return name + " " + sub + " streams until stopped"
+	case name == "ping" && !slices.ContainsFunc(args, hasCountOption):
+		return "ping runs until stopped"
+	case name == "vmstat" || name == "iostat" || name == "mpstat":
+		if n := nonOptions(args); len(n) == 1 {
+			return name + " reports until stopped"
+		}
+	}
+	return ""
+}
+
+// isFollowOption matches tail's options that follow, such as -f or -fn 50.
+func isFollowOption(arg string) bool {
+	return strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.ContainsAny(arg, "fF")
+}
+
+// hasCountOption matches ping's options that make it stop, -c and -w, with
+// or without the value attached.
+func hasCountOption(arg string) bool {
+	return strings.HasPrefix(arg, "-c") || strings.HasPrefix(arg, "-w")
+}
+
+// tailBuffer keeps the last max bytes written to it, for the output of
+// commands that may run for a long time.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string { return string(t.buf) }
+
+// FormatLimit formats a watch limit without trailing zero units, e.g. 10m.
+func FormatLimit(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
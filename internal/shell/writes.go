@@ -0,0 +1,233 @@
+// Package shell recognises the arguments that make otherwise read-only
+// programs write files, run other commands or change system settings.
+package shell
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// awkEffects matches awk program text that runs commands or writes files:
+// system(), pipes to and from commands, and print output redirected to a
+// file, as opposed to a comparison such as $3 > max.
+var awkEffects = regexp.MustCompile(`\bsystem\s*\(|\|\s*getline\b|(^|[^|])\|\s*"|\|&|\bprintf?\b[^;{}]*(>|\|)`)
+
+// writesWith returns why a program from readOnlyPrograms or a read-only
+// subcommand changes something with these arguments, or "" if it only reads.
+// Only the arguments known to have effects are recognised; the program is
+// otherwise assumed to be what readOnlyPrograms says it is.
+func writesWith(name string, args []string) string {
+	has := func(options ...string) bool {
+		return slices.ContainsFunc(args, func(a string) bool {
+			return slices.ContainsFunc(options, func(o string) bool {
+				return a == o || strings.HasPrefix(o, "--") && strings.HasPrefix(a, o+"=")
+			})
+		})
+	}
+	switch name {
+	case "awk", "gawk", "mawk", "nawk":
+		if has("-f", "--file", "-i", "--include", "-l", "--load") {
+			return "runs an awk program from a file"
+		}
+		if slices.ContainsFunc(args, awkEffects.MatchString) {
+			return "the awk program runs commands or writes files"
+		}
+	case "sed":
+		if has("-f", "--file") {
+			return "runs a sed script from a file"
+		}
+		for _, script := range sedScripts(args) {
+			if sedWrites(script) {
+				return "the sed script runs commands or writes files"
+			}
+		}
+	case "find":
+		if has("-delete") {
+			return "deletes the files it finds"
+		}
+		if has("-fprint", "-fprint0", "-fprintf", "-fls") {
+			return "writes to a file"
+		}
+		if has("-exec", "-execdir", "-ok", "-okdir") {
+			return "runs a command on the files it finds"
+		}
+	case "fd":
+		if has("-x", "--exec", "-X", "--exec-batch") {
+			return "runs a command on the files it finds"
+		}
+	case "rg":
+		if has("--pre") {
+			return "runs a preprocessor command"
+		}
+	case "sort":
+		if has("-o", "--output") || slices.ContainsFunc(args, func(a string) bool { return strings.HasPrefix(a, "-o") && len(a) > 2 }) {
+			return "writes to a file"
+		}
+	case "uniq":
+		// The second operand is the output file
+		if operands := nonOptions(args, "-f", "-s", "-w"); len(operands) > 1 {
+			return "writes to " + operands[1]
+		}
+	case "xxd":
+		if has("-r", "-revert") {
+			return "converts a dump back to binary"
+		}
+		if operands := nonOptions(args, "-c", "-cols", "-g", "-groupsize", "-l", "-len", "-s", "-seek", "-o", "-n", "-name"); len(operands) > 1 {
+			return "writes to " + operands[1]
+		}
+	case "less":
+		if has("-o", "-O", "--log-file", "--LOG-FILE") {
+			return "writes to a log file"
+		}
+	case "file":
+		if has("-C", "--compile") {
+			return "writes a compiled magic file"
+		}
+	case "tree":
+		if has("-o") {
+			return "writes to a file"
+		}
+	case "yq":
+		if has("-i", "--inplace") {
+			return "edits files in place"
+		}
+	case "ip":
+		operands := nonOptions(args, valueOptions["ip"]...)
+		if len(operands) > 1 && !slices.Contains([]string{"show", "list", "ls", "lst", "get", "help"}, operands[1]) {
+			return "changes the network configuration"
+		}
+	case "ifconfig":
+		// ifconfig, ifconfig -a and ifconfig eth0 show; anything more configures
+		if len(nonOptions(args)) > 1 {
+			return "changes the network configuration"
+		}
+	case "hostname":
+		if len(nonOptions(args)) > 0 || has("-F", "--file", "-b", "--boot") {
+			return "sets the host name"
+		}
+	case "date":
+		if has("-s", "--set") {
+			return "sets the system clock"
+		}
+		// date MMDDhhmm sets the clock; formats start with +
+		for i := 0; i < len(args); i++ {
+			switch a := args[i]; {
+			case slices.Contains([]string{"-d", "--date", "-r", "--reference", "-f", "--file"}, a):
+				i++
+			case !strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "+"):
+				return "sets the system clock"
+			}
+		}
+	case "history":
+		if slices.ContainsFunc(args, func(a string) bool { return strings.HasPrefix(a, "-") }) {
+			return "changes the shell history"
+		}
+	case "git":
+		if has("--output", "-O", "--open-files-in-pager") {
+			return "writes to a file or runs a command"
+		}
+	case "go":
+		if subcommand(name, args) == "env" && has("-w", "-u") {
+			return "changes the go environment"
+		}
+	}
+	return ""
+}
+
+// sedScripts returns the scripts of a sed invocation: the values of -e, or
+// the first operand when there are none.
+func sedScripts(args []string) []string {
+	var scripts []string
+	first := ""
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "-e" || a == "--expression":
+			if i+1 < len(args) {
+				scripts = append(scripts, args[i+1])
+			}
+			i++
+		case strings.HasPrefix(a, "--expression="):
+			scripts = append(scripts, strings.TrimPrefix(a, "--expression="))
+		case strings.HasPrefix(a, "-e") && len(a) > 2:
+			scripts = append(scripts, a[2:])
+		case a == "-l" || a == "--line-length":
+			i++
+		case first == "" && !strings.HasPrefix(a, "-"):
+			first = a
+		}
+	}
+	if len(scripts) == 0 && first != "" {
+		scripts = append(scripts, first)
+	}
+	return scripts
+}
+
+// sedWrites reports whether a sed script writes files or runs commands: the
+// w, W and e commands, and the w and e flags of s.
+func sedWrites(script string) bool {
+	i := 0
+	skipUntil := func(stops string) {
+		for i < len(script) && !strings.ContainsRune(stops, rune(script[i])) {
+			i++
+		}
+	}
+	// skipDelimited skips text up to an unescaped delimiter, and the delimiter
+	skipDelimited := func(delim byte) {
+		for i < len(script) && script[i] != delim {
+			if script[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		i++
+	}
+	for i < len(script) {
+		c := script[i]
+		switch {
+		case c == ' ' || c == '\t' || c == ';' || c == '\n' || c == '{' || c == '}' || c == '!' || c == ',':
+			i++
+		case c >= '0' && c <= '9' || c == '$' || c == '~' || c == '+':
+			i++ // part of an address
+		case c == '/':
+			i++
+			skipDelimited('/')
+			for i < len(script) && (script[i] == 'I' || script[i] == 'M') {
+				i++
+			}
+		case c == '\\' && i+1 < len(script):
+			delim := script[i+1]
+			i += 2
+			skipDelimited(delim)
+		case c == 'w' || c == 'W' || c == 'e':
+			return true
+		case c == 's' || c == 'y':
+			if i+1 >= len(script) {
+				return false
+			}
+			delim := script[i+1]
+			i += 2
+			skipDelimited(delim)
+			skipDelimited(delim)
+			if c == 's' {
+				start := i
+				skipUntil(";\n}")
+				if strings.ContainsAny(script[start:i], "we") {
+					return true
+				}
+			}
+		case c == 'a' || c == 'i' || c == 'c' || c == 'r' || c == 'R' || c == 'b' || c == 't' || c == 'T' || c == ':':
+			// Text, file names and labels run to the end of the line; labels also to ;
+			i++
+			if c == 'b' || c == 't' || c == 'T' || c == ':' {
+				skipUntil(";\n")
+			} else {
+				skipUntil("\n")
+			}
+		default:
+			i++
+		}
+	}
+	return false
+}
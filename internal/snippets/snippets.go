@@ -0,0 +1,249 @@
+// Package snippets stores named commands that can be recalled and run later.
+package snippets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Snippet is a saved command.
+type Snippet struct {
+	Command string    `yaml:"command"`
+	Request string    `yaml:"request,omitempty"` // natural-language request the command was generated from
+	Shell   string    `yaml:"shell,omitempty"`   // shell the command is written for, such as fish; empty for POSIX shells
+	Created time.Time `yaml:"created"`
+}
+
+// Store is a YAML file holding snippets keyed by name.
+type Store struct {
+	Path string
+}
+
+// Open returns the store at the default location in the nlch config directory.
+func Open() (*Store, error) {
+	dir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Path: filepath.Join(dir, "snippets.yaml")}, nil
+}
+
+// Load returns all saved snippets.
+func (s *Store) Load() (map[string]Snippet, error) {
+	snippets := map[string]Snippet{}
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return snippets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &snippets); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	return snippets, nil
+}
+
+// Names returns the names of the snippets in sorted order.
+func Names(snippets map[string]Snippet) []string {
+	names := make([]string, 0, len(snippets))
+	for name := range snippets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the snippet with the given name.
+func (s *Store) Get(name string) (Snippet, error) {
+	snippets, err := s.Load()
+	if err != nil {
+		return Snippet{}, err
+	}
+	snippet, ok := snippets[name]
+	if !ok {
+		return Snippet{}, fmt.Errorf("no saved command named %q", name)
+	}
+	return snippet, nil
+}
+
+// Save stores the snippet under the given name, replacing any existing one.
+func (s *Store) Save(name string, snippet Snippet) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	snippets, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if snippet.Created.IsZero() {
+		snippet.Created = time.Now()
+	}
+	snippets[name] = snippet
+	return s.write(snippets)
+}
+
+// Delete removes the snippet with the given name.
+func (s *Store) Delete(name string) error {
+	snippets, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if _, ok := snippets[name]; !ok {
+		return fmt.Errorf("no saved command named %q", name)
+	}
+	delete(snippets, name)
+	return s.write(snippets)
+}
+
+// write replaces the store's contents with the given snippets.
+func (s *Store) write(snippets map[string]Snippet) error {
+	data, err := yaml.Marshal(snippets)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, data, 0600)
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateName checks that a snippet name is usable on the command line.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// Positional placeholders: {{1}}, {{2}}, ... and {{@}} for all arguments.
+var positional = regexp.MustCompile(`\{\{\s*(\d+|@)\s*\}\}`)
+
+// Named placeholders: {{bucket}}, or {{env=staging}} with a default value.
+var named = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*(?:=([^}]*))?\}\}`)
+
+// Variable is a named placeholder in a saved command.
+type Variable struct {
+	Name       string
+	Default    string
+	HasDefault bool
+}
+
+// Variables returns the named placeholders of a command in the order they
+// first appear. A default given at any occurrence applies to all of them.
+func Variables(command string) []Variable {
+	var vars []Variable
+	index := map[string]int{}
+	for _, m := range named.FindAllStringSubmatchIndex(command, -1) {
+		name := command[m[2]:m[3]]
+		i, seen := index[name]
+		if !seen {
+			i = len(vars)
+			index[name] = i
+			vars = append(vars, Variable{Name: name})
+		}
+		if m[4] >= 0 && !vars[i].HasDefault {
+			vars[i].Default, vars[i].HasDefault = strings.TrimSpace(command[m[4]:m[5]]), true
+		}
+	}
+	return vars
+}
+
+// placeholder matches either kind of placeholder, so both are substituted
+// in one pass and values are never themselves expanded.
+var placeholder = regexp.MustCompile(positional.String() + "|" + named.String())
+
+// Expand substitutes positional placeholders in the command with the given
+// arguments and named placeholders with the given values, quoting each one
+// for the shell the command is written for. Named placeholders without a
+// value take their default. It fails if a placeholder has nothing to substitute.
+func Expand(command string, args []string, values map[string]string, shellName string) (string, error) {
+	quote := func(s string) string { return QuoteFor(shellName, s) }
+	defaults := map[string]string{}
+	for _, v := range Variables(command) {
+		if v.HasDefault {
+			defaults[v.Name] = v.Default
+		}
+	}
+	var missing []string
+	expanded := placeholder.ReplaceAllStringFunc(command, func(match string) string {
+		if m := named.FindStringSubmatch(match); m != nil {
+			if value, ok := values[m[1]]; ok {
+				return quote(value)
+			}
+			if value, ok := defaults[m[1]]; ok {
+				return quote(value)
+			}
+			missing = append(missing, match)
+			return match
+		}
+		key := positional.FindStringSubmatch(match)[1]
+		if key == "@" {
+			quoted := make([]string, len(args))
+			for i, a := range args {
+				quoted[i] = quote(a)
+			}
+			return strings.Join(quoted, " ")
+		}
+		n, _ := strconv.Atoi(key)
+		if n < 1 || n > len(args) {
+			missing = append(missing, match)
+			return match
+		}
+		return quote(args[n-1])
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing values for placeholders: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// Quote returns s quoted for safe use as a single POSIX shell word.
+func Quote(s string) string {
+	if isPlainWord(s, "-_./=:,@%+") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// QuoteFor returns s quoted as a single word for the named shell: fish and nu
+// have quoting rules of their own, other shells are POSIX.
+func QuoteFor(shellName, s string) string {
+	switch shellName {
+	case "fish":
+		// In single quotes fish only unescapes \' and \\
+		if isPlainWord(s, "-_./=:,@+") {
+			return s
+		}
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+	case "nu", "nushell":
+		// nu's single quotes have no escapes, so text with one goes in double quotes
+		if isPlainWord(s, "-_./") {
+			return s
+		}
+		if !strings.Contains(s, "'") {
+			return "'" + s + "'"
+		}
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	return Quote(s)
+}
+
+// isPlainWord reports whether s is not empty and has only letters, digits
+// and the given punctuation, so that it needs no quotes.
+func isPlainWord(s, punctuation string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(punctuation, r))
+	}) < 0
+}
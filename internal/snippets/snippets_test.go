@@ -0,0 +1,29 @@
+package snippets
+
+import "testing"
+
+func TestExpandQuotesForTheShell(t *testing.T) {
+	tests := []struct {
+		shell string
+		value string
+		want  string
+	}{
+		{"", "app.log", "tail app.log"},
+		{"", "it's here", `tail 'it'\''s here'`},
+		{"fish", "my file", "tail 'my file'"},
+		{"fish", `it's a \ path`, `tail 'it\'s a \\ path'`},
+		{"nu", "my file", "tail 'my file'"},
+		{"nu", `it's "here"`, `tail "it's \"here\""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell+" "+tt.value, func(t *testing.T) {
+			got, err := Expand("tail {{1}}", []string{tt.value}, nil, tt.shell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Expand = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
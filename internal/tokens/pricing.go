@@ -0,0 +1,74 @@
+// Package tokens provides approximate per-model pricing for estimating spend.
+package tokens
+
+import "strings"
+
+// Price is the cost of a model in US dollars per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// prices maps model name prefixes to list prices. More specific prefixes must
+// come before the prefixes they extend.
+var prices = []struct {
+	prefix string
+	price  Price
+}{
+	{"gpt-4o-mini", Price{0.15, 0.60}},
+	{"gpt-4o", Price{2.50, 10.00}},
+	{"gpt-4.1-nano", Price{0.10, 0.40}},
+	{"gpt-4.1-mini", Price{0.40, 1.60}},
+	{"gpt-4.1", Price{2.00, 8.00}},
+	{"gpt-4-turbo", Price{10.00, 30.00}},
+	{"gpt-3.5-turbo", Price{0.50, 1.50}},
+	{"o1-mini", Price{1.10, 4.40}},
+	{"o1", Price{15.00, 60.00}},
+	{"o3-mini", Price{1.10, 4.40}},
+	{"o4-mini", Price{1.10, 4.40}},
+	{"o3", Price{2.00, 8.00}},
+	{"claude-3-haiku", Price{0.25, 1.25}},
+	{"claude-3-5-haiku", Price{0.80, 4.00}},
+	{"claude-haiku", Price{0.80, 4.00}},
+	{"claude-3-opus", Price{15.00, 75.00}},
+	{"claude-opus", Price{15.00, 75.00}},
+	{"claude-3", Price{3.00, 15.00}},
+	{"claude-sonnet", Price{3.00, 15.00}},
+	{"gemini-1.5-flash-8b", Price{0.0375, 0.15}},
+	{"gemini-1.5-flash", Price{0.075, 0.30}},
+	{"gemini-1.5-pro", Price{1.25, 5.00}},
+	{"gemini-2.0-flash-lite", Price{0.075, 0.30}},
+	{"gemini-2.0-flash", Price{0.10, 0.40}},
+	{"gemini-2.5-flash", Price{0.30, 2.50}},
+	{"gemini-2.5-pro", Price{1.25, 10.00}},
+	{"command-r7b", Price{0.0375, 0.15}},
+	{"command-r-plus", Price{2.50, 10.00}},
+	{"command-r", Price{0.15, 0.60}},
+	{"command-a", Price{2.50, 10.00}},
+	{"llama-3.1-8b-instant", Price{0.05, 0.08}},
+	{"llama-3.3-70b-versatile", Price{0.59, 0.79}},
+}
+
+// PriceFor returns the list price of the model. Router prefixes such as
+// "openai/" are ignored. It reports false for unknown and local models.
+func PriceFor(model string) (Price, bool) {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, p := range prices {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.price, true
+		}
+	}
+	return Price{}, false
+}
+
+// Cost returns the estimated cost in US dollars of a request to the model.
+func Cost(model string, inputTokens, outputTokens int) float64 {
+	p, ok := PriceFor(model)
+	if !ok {
+		return 0
+	}
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
@@ -0,0 +1,93 @@
+// Package tokens provides token count estimation for prompts sent to LLM providers.
+package tokens
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// pretokenizer approximates the cl100k/o200k pre-tokenization pattern used by
+// OpenAI-compatible models. RE2 has no lookahead, so trailing whitespace runs
+// are kept whole instead of leaving the last space for the following word.
+// It is compiled on first use so commands that never estimate tokens don't pay for it.
+var pretokenizer = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+})
+
+// Prefixes of model names that use a tiktoken-style BPE vocabulary.
+var bpeModelPrefixes = []string{
+	"gpt-", "o1", "o3", "o4", "chatgpt", "text-embedding", "openai/", "davinci", "babbage",
+}
+
+// IsBPEModel reports whether the model uses an OpenAI-style BPE tokenizer.
+func IsBPEModel(model string) bool {
+	model = strings.ToLower(model)
+	for _, prefix := range bpeModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Estimate returns the estimated number of tokens the text occupies for the given model.
+// OpenAI-compatible models use a BPE-style estimate over pre-tokenized pieces;
+// all other models use a character/word heuristic.
+func Estimate(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	if IsBPEModel(model) {
+		return estimateBPE(text)
+	}
+	return estimateHeuristic(text)
+}
+
+// estimateBPE splits text with the tiktoken pre-tokenizer and estimates how many
+// BPE tokens each piece merges into. Short common pieces are almost always a
+// single token; longer pieces split roughly every four characters.
+func estimateBPE(text string) int {
+	count := 0
+	for _, piece := range pretokenizer().FindAllString(text, -1) {
+		n := utf8.RuneCountInString(piece)
+		switch {
+		case n != len(piece):
+			// Non-ASCII text is split much more aggressively.
+			count += (len(piece) + 1) / 2
+		case n <= 6:
+			count++
+		default:
+			count += (n + 3) / 4
+		}
+	}
+	return count
+}
+
+// estimateHeuristic estimates tokens as the larger of chars/4 and words*4/3.
+func estimateHeuristic(text string) int {
+	byChars := (utf8.RuneCountInString(text) + 3) / 4
+	byWords := (len(strings.Fields(text))*4 + 2) / 3
+	if byWords > byChars {
+		return byWords
+	}
+	return byChars
+}
+
+// Truncate shortens text line by line so that it fits within max tokens for the model.
+// It returns the (possibly shortened) text and whether anything was removed.
+func Truncate(model, text string, max int) (string, bool) {
+	if max <= 0 || Estimate(model, text) <= max {
+		return text, false
+	}
+	lines := strings.Split(text, "\n")
+	used := 0
+	for i, line := range lines {
+		used += Estimate(model, line+"\n")
+		if used > max {
+			return strings.Join(lines[:i], "\n"), true
+		}
+	}
+	return text, false
+}
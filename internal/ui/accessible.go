@@ -0,0 +1,39 @@
+// Package ui provides a screen-reader-friendly output mode.
+package ui
+
+import (
+	"os"
+	"strings"
+)
+
+// accessible is set when output should avoid color, emoji and decorative symbols.
+var accessible = envAccessible()
+
+// envAccessible reports whether NLCH_ACCESSIBLE requests accessible output.
+func envAccessible() bool {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv("NLCH_ACCESSIBLE")))
+	return value != "" && value != "0" && value != "false" && value != "no"
+}
+
+// SetAccessible enables or disables accessible output. The NLCH_ACCESSIBLE
+// environment variable always takes precedence when it enables the mode.
+func SetAccessible(on bool) {
+	accessible = on || envAccessible()
+	if accessible {
+		enabled = false
+	}
+}
+
+// Accessible reports whether accessible output is enabled.
+func Accessible() bool {
+	return accessible
+}
+
+// Icon returns the decorative symbol, or its plain-text alternative in accessible mode.
+// The plain alternative may be empty to drop the symbol entirely.
+func Icon(symbol, plain string) string {
+	if accessible {
+		return plain
+	}
+	return symbol
+}
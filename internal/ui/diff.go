@@ -0,0 +1,59 @@
+// Package ui renders word-level differences between two commands.
+package ui
+
+import "strings"
+
+// WordDiff renders the words of a and b with the words only in a marked as
+// removed and the words only in b marked as added. Without color, or in
+// accessible mode, the markers are textual: [-removed-] and {+added+}.
+func WordDiff(a, b string) (before, after string) {
+	x, y := strings.Fields(a), strings.Fields(b)
+
+	// Longest common subsequence of words, filled from the end
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var left, right []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			left, right = append(left, x[i]), append(right, y[j])
+			i, j = i+1, j+1
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			left = append(left, removed(x[i]))
+			i++
+		default:
+			right = append(right, added(y[j]))
+			j++
+		}
+	}
+	return strings.Join(left, " "), strings.Join(right, " ")
+}
+
+// removed marks a word that only the first command has.
+func removed(word string) string {
+	if !enabled || accessible {
+		return "[-" + word + "-]"
+	}
+	return style(current.Danger, word)
+}
+
+// added marks a word that only the second command has.
+func added(word string) string {
+	if !enabled || accessible {
+		return "{+" + word + "+}"
+	}
+	return style(current.Success, word)
+}
@@ -0,0 +1,103 @@
+// Package ui provides shell syntax highlighting for generated commands.
+package ui
+
+import (
+	"strings"
+)
+
+// Operators that separate or connect commands, longest first.
+var operators = []string{"&&", "||", ">>", "2>", "&>", "|", ";", ">", "<", "&"}
+
+// Operators after which the next word is a program name.
+var commandStarters = map[string]bool{"&&": true, "||": true, "|": true, ";": true, "&": true}
+
+// Highlight renders a shell command with syntax highlighting using the active theme.
+// The returned text is unchanged apart from the inserted escape sequences.
+func Highlight(cmd string) string {
+	if !enabled {
+		return cmd
+	}
+
+	var b strings.Builder
+	expectCommand := true
+	for i := 0; i < len(cmd); {
+		c := cmd[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			b.WriteByte(c)
+			i++
+		case c == '\'' || c == '"':
+			end := closingQuote(cmd, i)
+			b.WriteString(style(current.String, cmd[i:end]))
+			i = end
+			expectCommand = false
+		default:
+			if op := operatorAt(cmd, i); op != "" {
+				b.WriteString(style(current.Operator, op))
+				i += len(op)
+				expectCommand = commandStarters[op]
+				continue
+			}
+			end := wordEnd(cmd, i)
+			word := cmd[i:end]
+			switch {
+			case expectCommand && !strings.Contains(word, "="):
+				b.WriteString(style(current.Command, word))
+				expectCommand = false
+			case strings.HasPrefix(word, "-"):
+				b.WriteString(style(current.Flag, word))
+			default:
+				b.WriteString(word)
+			}
+			i = end
+		}
+	}
+	return b.String()
+}
+
+// closingQuote returns the index just past the quoted string starting at i.
+func closingQuote(cmd string, i int) int {
+	quote := cmd[i]
+	for j := i + 1; j < len(cmd); j++ {
+		if cmd[j] == '\\' && quote == '"' {
+			j++
+			continue
+		}
+		if cmd[j] == quote {
+			return j + 1
+		}
+	}
+	return len(cmd)
+}
+
+// operatorAt returns the operator starting at index i, if any.
+func operatorAt(cmd string, i int) string {
+	for _, op := range operators {
+		if strings.HasPrefix(cmd[i:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// wordEnd returns the index just past the unquoted word starting at i.
+func wordEnd(cmd string, i int) int {
+	j := i
+	for j < len(cmd) {
+		c := cmd[j]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\'' || c == '"' || operatorAt(cmd, j) != "" {
+			if j == i {
+				return j + 1
+			}
+			return j
+		}
+		if c == '\\' {
+			j++
+		}
+		j++
+	}
+	if j > len(cmd) {
+		j = len(cmd)
+	}
+	return j
+}
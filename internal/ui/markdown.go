@@ -0,0 +1,90 @@
+// Package ui renders the markdown models write in explanations, so bold
+// text, lists and code show as such rather than as asterisks and backticks.
+package ui
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	heading    = regexp.MustCompile(`^\s*#{1,6}\s+(.*?)\s*#*\s*$`)
+	listItem   = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	codeSpan   = regexp.MustCompile("`+([^`]+)`+")
+	strong     = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	emphasis   = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*[^*\s])?)\*($|[^\w*])`)
+	link       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	ansiEscape = regexp.MustCompile("\033\\[[0-9;]*m")
+)
+
+// Markdown renders markdown text, such as an explanation, for the terminal
+// and wraps it as WrapText does. Headings and bold text are shown in bold,
+// list items with bullets, code spans highlighted as commands and fenced code
+// blocks indented. Without color, code spans keep their backticks.
+func Markdown(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	fenced := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			out = append(out, "    "+Highlight(line))
+			continue
+		}
+		if m := heading.FindStringSubmatch(line); m != nil {
+			out = append(out, style(current.Strong, inline(m[1])))
+			continue
+		}
+		if m := listItem.FindStringSubmatch(line); m != nil {
+			line = m[1] + Icon("•", "-") + " " + inline(line[len(m[0]):])
+		} else {
+			line = inline(line)
+		}
+		out = append(out, line)
+	}
+	return WrapText(strings.Join(out, "\n"))
+}
+
+// inline renders the code spans, bold and italic text and links of a line.
+// Text inside code spans is left as written.
+func inline(line string) string {
+	var b strings.Builder
+	for {
+		loc := codeSpan.FindStringSubmatchIndex(line)
+		if loc == nil {
+			break
+		}
+		b.WriteString(emphasize(line[:loc[0]]))
+		code := line[loc[2]:loc[3]]
+		if enabled {
+			b.WriteString(Highlight(code))
+		} else {
+			b.WriteString("`" + code + "`")
+		}
+		line = line[loc[1]:]
+	}
+	b.WriteString(emphasize(line))
+	return b.String()
+}
+
+// emphasize renders the bold and italic text and links of text without code.
+func emphasize(text string) string {
+	text = link.ReplaceAllString(text, "$1 ($2)")
+	text = strong.ReplaceAllStringFunc(text, func(s string) string {
+		return style(current.Strong, strings.Trim(s, "*_"))
+	})
+	return emphasis.ReplaceAllStringFunc(text, func(s string) string {
+		m := emphasis.FindStringSubmatch(s)
+		return m[1] + style(current.Emphasis, m[2]) + m[3]
+	})
+}
+
+// visibleWidth returns the number of columns s takes on the terminal, not
+// counting the ANSI escape sequences that style it.
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(s, ""))
+}
@@ -0,0 +1,111 @@
+// Package ui provides themed, colorized terminal output for nlch.
+package ui
+
+import (
+	"os"
+	"strings"
+)
+
+// ANSI escape sequences used by themes.
+const (
+	reset     = "\033[0m"
+	bold      = "\033[1m"
+	dim       = "\033[2m"
+	italic    = "\033[3m"
+	red       = "\033[31m"
+	green     = "\033[32m"
+	yellow    = "\033[33m"
+	blue      = "\033[34m"
+	magenta   = "\033[35m"
+	cyan      = "\033[36m"
+	boldRed   = "\033[1;31m"
+	boldGreen = "\033[1;32m"
+	boldBlue  = "\033[1;34m"
+)
+
+// Theme maps output roles to ANSI styles. An empty style leaves text unstyled.
+type Theme struct {
+	Command  string // program names in a command
+	Flag     string // options such as -l or --recursive
+	String   string // quoted arguments
+	Operator string // pipes, redirections and command separators
+	Danger   string // warnings about dangerous commands
+	Success  string // positive status messages
+	Dim      string // explanations and secondary information
+	Strong   string // bold text and headings in explanations
+	Emphasis string // italic text in explanations
+}
+
+// Built-in themes, selectable with the `theme` config setting.
+var themes = map[string]Theme{
+	"default": {Command: boldGreen, Flag: cyan, String: yellow, Operator: magenta, Danger: boldRed, Success: green, Dim: dim, Strong: bold, Emphasis: italic},
+	"dark":    {Command: boldBlue, Flag: cyan, String: green, Operator: magenta, Danger: boldRed, Success: green, Dim: dim, Strong: bold, Emphasis: italic},
+	"light":   {Command: bold + blue, Flag: magenta, String: red, Operator: blue, Danger: boldRed, Success: green, Dim: dim, Strong: bold, Emphasis: italic},
+	"none":    {},
+}
+
+// Active theme and whether color output is enabled.
+var (
+	current = themes["default"]
+	enabled = colorSupported() && !envAccessible()
+)
+
+// SetTheme selects the theme by name. Unknown or empty names select the default theme.
+func SetTheme(name string) {
+	theme, ok := themes[strings.ToLower(name)]
+	if !ok {
+		theme = themes["default"]
+	}
+	current = theme
+}
+
+// Enabled reports whether color output is enabled.
+func Enabled() bool {
+	return enabled
+}
+
+// colorSupported reports whether stdout is a terminal and NO_COLOR is not set.
+func colorSupported() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// style wraps text in the given ANSI style when color is enabled.
+func style(s, text string) string {
+	if !enabled || s == "" || text == "" {
+		return text
+	}
+	return s + text + reset
+}
+
+// Danger renders a warning about a dangerous command.
+// Accessible mode adds a textual prefix so the warning isn't signalled by color alone.
+func Danger(text string) string {
+	if accessible && !strings.HasPrefix(text, "Warning") {
+		text = "Warning: " + text
+	}
+	return style(current.Danger, text)
+}
+
+// Error renders an error message.
+func Error(text string) string {
+	if accessible {
+		text = "Error: " + text
+	}
+	return style(current.Danger, text)
+}
+
+// Success renders a positive status message.
+func Success(text string) string { return style(current.Success, text) }
+
+// Dim renders explanations and other secondary information.
+func Dim(text string) string { return style(current.Dim, text) }
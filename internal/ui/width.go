@@ -0,0 +1,193 @@
+// Package ui fits output to the width of the terminal, so that long commands,
+// explanations and diffs wrap at word boundaries with an indent instead of
+// wrapping raggedly in the middle of a word.
+package ui
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// continuationIndent indents the continuation lines of a wrapped command.
+const continuationIndent = "    "
+
+// Width returns the width of the terminal in columns, from $COLUMNS or the
+// terminal stdout or stderr writes to. It returns 0, and nothing is wrapped,
+// when output doesn't go to a terminal.
+func Width() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if n := terminalWidth(f); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// WrapCommand breaks a command that would not fit on the terminal, shown
+// after column columns of other text, into lines ending with " \". The shell
+// joins such lines again, so the wrapped command still runs when copied and
+// pasted. Lines break between words outside quotes, and before |, && and ||
+// where possible; commands already on several lines are left alone.
+func WrapCommand(cmd string, column int) string {
+	width := Width()
+	if width <= 0 || column+utf8.RuneCountInString(cmd) <= width || strings.Contains(cmd, "\n") {
+		return cmd
+	}
+	// Each segment starts with an operator, so it can start a line of its own
+	var segments [][]string
+	for _, word := range shellWords(cmd) {
+		if len(segments) == 0 || word == "|" || word == "&&" || word == "||" {
+			segments = append(segments, nil)
+		}
+		segments[len(segments)-1] = append(segments[len(segments)-1], word)
+	}
+
+	var b strings.Builder
+	used, empty := column, true // columns used on the current line, and whether it has no words yet
+	fits := func(text string) bool {
+		// Leave room for the continuation marker
+		return used+1+utf8.RuneCountInString(text)+2 <= width
+	}
+	comment := false
+	for _, segment := range segments {
+		for i, word := range segment {
+			text := word
+			if i == 0 {
+				text = strings.Join(segment, " ")
+			}
+			if !empty && !comment && !fits(text) {
+				b.WriteString(" \\\n" + continuationIndent)
+				used, empty = len(continuationIndent), true
+			}
+			if !empty {
+				b.WriteByte(' ')
+				used++
+			}
+			b.WriteString(word)
+			used += utf8.RuneCountInString(word)
+			empty = false
+			// A continuation marker would be part of a comment
+			comment = comment || strings.HasPrefix(word, "#")
+		}
+	}
+	return b.String()
+}
+
+// shellWords splits a command at spaces outside quotes, parentheses and
+// backticks, keeping each word as written.
+func shellWords(cmd string) []string {
+	var words []string
+	start, depth := -1, 0
+	var quote byte
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		if (c == ' ' || c == '\t') && quote == 0 && depth == 0 {
+			if start >= 0 {
+				words = append(words, cmd[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		}
+	}
+	if start >= 0 {
+		words = append(words, cmd[start:])
+	}
+	return words
+}
+
+// hangingIndent matches the indentation and list marker at the start of a
+// line, such as "- ", "2. " or "  -l, --long  ", which the line's
+// continuation lines are indented past.
+var hangingIndent = regexp.MustCompile(`^\s*(?:[-*•]\s+|\d+[.)]\s+|--?[\w-]+(?:,\s*--?[\w-]+)*\s{2,})?`)
+
+// WrapText wraps each line of prose, such as an explanation, at word
+// boundaries to fit the terminal, indenting continuation lines past the
+// line's indentation and list marker. Styling doesn't count towards the width.
+func WrapText(text string) string {
+	width := Width()
+	if width <= 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = wrapWords(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapWords wraps a line at spaces to width, keeping its indentation and
+// list marker as written. Words longer than a line are left whole.
+func wrapWords(line string, width int) string {
+	if visibleWidth(line) <= width {
+		return line
+	}
+	marker := hangingIndent.FindString(line)
+	indent := utf8.RuneCountInString(marker)
+	if indent > width/2 {
+		marker, indent = "", 0
+	}
+	var b strings.Builder
+	b.WriteString(marker)
+	used := indent
+	first := true
+	for _, word := range strings.Fields(line[len(marker):]) {
+		n := visibleWidth(word)
+		switch {
+		case first:
+		case used+1+n > width:
+			b.WriteString("\n" + strings.Repeat(" ", indent))
+			used = indent
+		default:
+			b.WriteByte(' ')
+			used++
+		}
+		b.WriteString(word)
+		used += n
+		first = false
+	}
+	return b.String()
+}
+
+// WrapLine breaks a line of fixed text, such as a line of a diff, into
+// pieces that fit the terminal, indenting the pieces after the first by
+// indent columns. Unlike WrapText it keeps every character, spaces included.
+func WrapLine(line string, indent int) string {
+	width := Width()
+	runes := []rune(line)
+	if width <= indent+1 || len(runes) <= width {
+		return line
+	}
+	var b strings.Builder
+	b.WriteString(string(runes[:width]))
+	for rest := runes[width:]; len(rest) > 0; {
+		n := min(len(rest), width-indent)
+		b.WriteString("\n" + strings.Repeat(" ", indent) + string(rest[:n]))
+		rest = rest[n:]
+	}
+	return b.String()
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd)
+
+// Package ui leaves output unwrapped where the terminal size can't be read.
+package ui
+
+import "os"
+
+// terminalWidth returns 0: without a way to read the terminal's size, set
+// $COLUMNS to wrap output.
+func terminalWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+
+// Package ui reads the size of the terminal on Unix.
+package ui
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalWidth returns the width of the terminal f writes to, or 0 if it is not a terminal.
+func terminalWidth(f *os.File) int {
+	var size struct{ rows, cols, x, y uint16 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0
+	}
+	return int(size.cols)
+}
@@ -0,0 +1,133 @@
+// Package update extracts the nlch binary from compressed release archives,
+// such as those produced by GoReleaser.
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// Archive formats recognised as release assets.
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".zip"}
+
+// archNames normalises alternative spellings of architectures used by common release tooling.
+var archNames = strings.NewReplacer("x86_64", "amd64", "aarch64", "arm64", "i386", "386", "armv7l", "armv7", "armv6l", "armv6", "armhf", "armv7")
+
+// findArchiveAsset returns the name of an archive asset for this platform, or "".
+// Both "nlch-linux-amd64.tar.gz" and GoReleaser's "nlch_1.2.0_Linux_x86_64.tar.gz" match.
+func findArchiveAsset(release *Release) string {
+	for _, asset := range release.Assets {
+		name := strings.ToLower(asset.Name)
+		if !strings.HasPrefix(name, "nlch") || !hasArchiveSuffix(name) {
+			continue
+		}
+		fields := strings.FieldsFunc(archNames.Replace(trimArchiveSuffix(name)), func(r rune) bool { return r == '-' || r == '_' })
+		if matchesPlatform(fields) {
+			return asset.Name
+		}
+	}
+	return ""
+}
+
+// extractBinary extracts the nlch executable from the archive to dst.
+func extractBinary(archive, dst string) (string, error) {
+	binary := "nlch"
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+
+	var err error
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		err = extractZip(archive, binary, dst)
+	} else {
+		err = extractTarGz(archive, binary, dst)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to extract %s: %v", path.Base(archive), err)
+	}
+	return dst, nil
+}
+
+// extractTarGz copies the file named binary out of a gzipped tarball.
+func extractTarGz(archive, binary, dst string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("no %s in archive", binary)
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeReg && path.Base(header.Name) == binary {
+			return writeFile(dst, tr)
+		}
+	}
+}
+
+// extractZip copies the file named binary out of a zip archive.
+func extractZip(archive, binary, dst string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || path.Base(f.Name) != binary {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return writeFile(dst, rc)
+	}
+	return fmt.Errorf("no %s in archive", binary)
+}
+
+// writeFile writes the contents of r to a new file at dst.
+func writeFile(dst string, r io.Reader) error {
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func hasArchiveSuffix(name string) bool {
+	return trimArchiveSuffix(name) != name
+}
+
+func trimArchiveSuffix(name string) string {
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
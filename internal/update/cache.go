@@ -0,0 +1,72 @@
+// Package update caches release API responses with their ETags, so repeated
+// update checks are answered with 304 Not Modified and don't use up rate limits.
+package update
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cachedResponse is the last successful response for a URL.
+type cachedResponse struct {
+	ETag string          `json:"etag"`
+	Body json.RawMessage `json:"body"`
+}
+
+// cacheMu serialises access to the cache file between concurrent checks.
+var cacheMu sync.Mutex
+
+// cachePath returns the file the response cache is stored in.
+func cachePath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "update-cache.json"), nil
+}
+
+// loadCache returns the cached responses keyed by URL. A missing or broken cache is empty.
+func loadCache() map[string]cachedResponse {
+	cache := map[string]cachedResponse{}
+	path, err := cachePath()
+	if err != nil {
+		return cache
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// cachedBody returns the cached response for the URL, if there is one.
+func cachedBody(url string) (cachedResponse, bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	entry, ok := loadCache()[url]
+	return entry, ok && entry.ETag != ""
+}
+
+// storeResponse caches the response for the URL. Caching is best-effort.
+func storeResponse(url, etag string, body []byte) {
+	if etag == "" || !json.Valid(body) {
+		return
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	path, err := cachePath()
+	if err != nil {
+		return
+	}
+	cache := loadCache()
+	cache[url] = cachedResponse{ETag: etag, Body: body}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,69 @@
+// Package update collects and prints the release notes of pending updates.
+package update
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ReleasesBetween returns the releases in the configured channel that are newer
+// than the current version, up to and including latest, oldest first.
+func ReleasesBetween(latest *Release) ([]Release, error) {
+	upper, err := ParseVersion(latest.TagName)
+	if err != nil {
+		return nil, err
+	}
+	current, currentErr := ParseVersion(GetCurrentVersion())
+
+	releases, err := fetchReleases(100)
+	if err != nil {
+		return nil, err
+	}
+
+	type versioned struct {
+		release Release
+		version Version
+	}
+	var pending []versioned
+	for _, r := range releases {
+		if !inChannel(&r, Channel()) {
+			continue
+		}
+		v, err := ParseVersion(r.TagName)
+		if err != nil || v.Compare(upper) > 0 {
+			continue
+		}
+		if currentErr == nil && v.Compare(current) <= 0 {
+			continue
+		}
+		pending = append(pending, versioned{r, v})
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].version.Compare(pending[j].version) < 0
+	})
+
+	between := make([]Release, len(pending))
+	for i, p := range pending {
+		between[i] = p.release
+	}
+	return between, nil
+}
+
+// PrintChangelog writes the release notes of each release.
+func PrintChangelog(w io.Writer, releases []Release) {
+	for _, r := range releases {
+		title := r.TagName
+		if r.Name != "" && r.Name != r.TagName {
+			title += " - " + r.Name
+		}
+		fmt.Fprintf(w, "\n## %s\n", title)
+		notes := strings.TrimSpace(strings.ReplaceAll(r.Body, "\r\n", "\n"))
+		if notes == "" {
+			notes = "(no release notes)"
+		}
+		fmt.Fprintln(w, notes)
+	}
+	fmt.Fprintln(w)
+}
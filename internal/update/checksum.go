@@ -0,0 +1,69 @@
+// Package update verifies downloaded release assets against published SHA-256 checksums.
+package update
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ChecksumsAssetName is the release asset listing the SHA-256 sum of every other asset.
+// GoReleaser prefixes it with the project and version, as in
+// "nlch_1.2.0_checksums.txt", so any asset ending in this name is accepted.
+const ChecksumsAssetName = "checksums.txt"
+
+// findAsset returns the download URL of the named release asset, or "" if there is none.
+func findAsset(release *Release, name string) string {
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			return asset.BrowserDownloadURL
+		}
+	}
+	return ""
+}
+
+// findChecksumsAsset returns the name and download URL of the release's
+// checksums file, preferring an exact ChecksumsAssetName over a prefixed one.
+func findChecksumsAsset(release *Release) (string, string) {
+	if url := findAsset(release, ChecksumsAssetName); url != "" {
+		return ChecksumsAssetName, url
+	}
+	for _, asset := range release.Assets {
+		if strings.HasSuffix(asset.Name, ChecksumsAssetName) {
+			return asset.Name, asset.BrowserDownloadURL
+		}
+	}
+	return "", ""
+}
+
+// fetchChecksum downloads the release's checksums file and returns the
+// expected SHA-256 sum of the named asset.
+func fetchChecksum(release *Release, assetName string) (string, error) {
+	checksumsName, url := findChecksumsAsset(release)
+	if url == "" {
+		return "", fmt.Errorf("release %s has no %s, refusing to install an unverified binary", release.TagName, ChecksumsAssetName)
+	}
+
+	resp, err := httpGet(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("checksums download failed with status: %d", resp.StatusCode)
+	}
+
+	// Lines have the sha256sum format: "<hex sum>  <name>", binary mode names start with '*'
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == assetName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read checksums: %v", err)
+	}
+	return "", fmt.Errorf("%s has no entry for %s, refusing to install an unverified binary", checksumsName, assetName)
+}
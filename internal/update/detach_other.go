@@ -0,0 +1,15 @@
+//go:build !windows
+
+// Package update starts the background stager in its own session outside
+// Windows, so it is not stopped with the terminal's process group when nlch exits.
+package update
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach lets cmd keep running after nlch exits.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
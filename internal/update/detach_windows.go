@@ -0,0 +1,10 @@
+//go:build windows
+
+// Package update starts the background stager on Windows, where a child
+// process outlives its parent without further setup.
+package update
+
+import "os/exec"
+
+// detach lets cmd keep running after nlch exits.
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,139 @@
+// Package update applies bsdiff binary patches so that updates only need to
+// download the difference from the installed version.
+package update
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// patchAssetName returns the name of the patch from the installed version to
+// a release for this platform, e.g. "nlch-linux-amd64-from-v0.1.0.bsdiff".
+func patchAssetName() string {
+	return strings.TrimSuffix(GetPlatformAssetName(), ".exe") + "-from-v" + GetCurrentVersion() + ".bsdiff"
+}
+
+// downloadPatched downloads the patch, applies it to the running binary and
+// returns the path of the patched binary. The result is checked against the
+// release's checksum of the full binary, since a patch applied to a local or
+// modified build of the same version produces a corrupt one.
+func downloadPatched(release *Release, patchName string) (string, error) {
+	assetName := GetPlatformAssetName()
+	expected, err := fetchChecksum(release, assetName)
+	if err != nil {
+		return "", err
+	}
+
+	patchPath, err := downloadVerified(release, patchName)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(patchPath)
+
+	exe, err := executablePath()
+	if err != nil {
+		return "", err
+	}
+	old, err := os.ReadFile(exe)
+	if err != nil {
+		return "", err
+	}
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		return "", err
+	}
+	patched, err := applyBSDiff(old, patch)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply patch: %v", err)
+	}
+	sum := sha256.Sum256(patched)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return "", fmt.Errorf("checksum mismatch for patched %s (expected %s, got %s)", assetName, expected, actual)
+	}
+
+	dst := filepath.Join(os.TempDir(), assetName)
+	if err := os.WriteFile(dst, patched, 0755); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+var errCorruptPatch = errors.New("corrupt patch")
+
+// applyBSDiff applies a patch in the BSDIFF40 format produced by bsdiff.
+func applyBSDiff(old, patch []byte) ([]byte, error) {
+	// Header: magic, length of the control and diff blocks, size of the new file
+	if len(patch) < 32 || string(patch[:8]) != "BSDIFF40" {
+		return nil, errCorruptPatch
+	}
+	ctrlLen := offtin(patch[8:16])
+	diffLen := offtin(patch[16:24])
+	newSize := offtin(patch[24:32])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || 32+ctrlLen+diffLen > int64(len(patch)) {
+		return nil, errCorruptPatch
+	}
+
+	// The three blocks are compressed separately with bzip2
+	body := patch[32:]
+	ctrl := bzip2.NewReader(bytes.NewReader(body[:ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+
+	newFile := make([]byte, newSize)
+	var oldPos, newPos int64
+	buf := make([]byte, 8)
+	for newPos < newSize {
+		// Each control triple: bytes to add from diff, bytes to copy from extra, seek in old
+		var triple [3]int64
+		for i := range triple {
+			if _, err := io.ReadFull(ctrl, buf); err != nil {
+				return nil, errCorruptPatch
+			}
+			triple[i] = offtin(buf)
+		}
+		add, copyLen, seek := triple[0], triple[1], triple[2]
+
+		if add < 0 || newPos+add > newSize {
+			return nil, errCorruptPatch
+		}
+		if _, err := io.ReadFull(diff, newFile[newPos:newPos+add]); err != nil {
+			return nil, errCorruptPatch
+		}
+		for i := int64(0); i < add; i++ {
+			if oldPos+i >= 0 && oldPos+i < int64(len(old)) {
+				newFile[newPos+i] += old[oldPos+i]
+			}
+		}
+		newPos += add
+		oldPos += add
+
+		if copyLen < 0 || newPos+copyLen > newSize {
+			return nil, errCorruptPatch
+		}
+		if _, err := io.ReadFull(extra, newFile[newPos:newPos+copyLen]); err != nil {
+			return nil, errCorruptPatch
+		}
+		newPos += copyLen
+		oldPos += seek
+	}
+	return newFile, nil
+}
+
+// offtin decodes bsdiff's sign-magnitude little-endian 64-bit integer.
+func offtin(b []byte) int64 {
+	v := binary.LittleEndian.Uint64(b)
+	n := int64(v &^ (1 << 63))
+	if v&(1<<63) != 0 {
+		n = -n
+	}
+	return n
+}
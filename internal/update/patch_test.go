@@ -0,0 +1,105 @@
+package update
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testPatch is a bsdiff patch that produces "patched nlch" whatever binary it
+// is applied to: its only control triple copies all of it from the extra block.
+const testPatch = "425344494646343029000000000000000e000000000000000c00000000000000425a6839314159265359c3f19b4a0000004000440c200030cd34121a6700f177245385090c3f19b4a0425a683917724538509000000000425a68393141592653592779a754000005118040002e45440020003100302003d2508e06452f1772453850902779a754"
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// testRelease serves a release with a patch, the full binary and a checksums
+// file under checksumsName, which lists binarySum for the full binary.
+func testRelease(t *testing.T, checksumsName, binarySum string) *Release {
+	t.Helper()
+	patch, err := hex.DecodeString(testPatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binaryName, patchName := GetPlatformAssetName(), patchAssetName()
+	files := map[string][]byte{
+		patchName:  patch,
+		binaryName: []byte("full nlch"),
+	}
+	files[checksumsName] = []byte(fmt.Sprintf("%s  %s\n%s *%s\n", sha256Hex(patch), patchName, binarySum, binaryName))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	var assets []map[string]string
+	for name := range files {
+		assets = append(assets, map[string]string{"name": name, "browser_download_url": server.URL + "/" + name})
+	}
+	data, _ := json.Marshal(map[string]any{"tag_name": "v9.9.9", "assets": assets})
+	var release Release
+	if err := json.Unmarshal(data, &release); err != nil {
+		t.Fatal(err)
+	}
+	return &release
+}
+
+func TestDownloadUpdateVerifiesPatchedBinary(t *testing.T) {
+	tests := []struct {
+		name      string
+		binarySum string
+		want      string
+	}{
+		{"patched binary matches", sha256Hex([]byte("patched nlch")), "patched nlch"},
+		// The patch was made against another build, so the full binary is downloaded instead
+		{"patched binary differs", sha256Hex([]byte("full nlch")), "full nlch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := DownloadUpdate(testRelease(t, ChecksumsAssetName, tt.binarySum))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(path)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("installed %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchChecksumFindsGoReleaserChecksums(t *testing.T) {
+	sum := sha256Hex([]byte("full nlch"))
+	release := testRelease(t, "nlch_9.9.9_checksums.txt", sum)
+	got, err := fetchChecksum(release, GetPlatformAssetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sum {
+		t.Errorf("checksum = %s, want %s", got, sum)
+	}
+}
+
+func TestFetchChecksumRequiresChecksums(t *testing.T) {
+	release := &Release{TagName: "v9.9.9"}
+	if _, err := fetchChecksum(release, GetPlatformAssetName()); err == nil {
+		t.Error("expected a release without checksums to be refused")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+// Package update leaves PATH to the shell's startup files outside Windows.
+package update
+
+import "errors"
+
+// AddToUserPath returns errors.ErrUnsupported: outside Windows PATH is set in
+// shell startup files, which nlch leaves to the user.
+func AddToUserPath(dir string) error {
+	return errors.ErrUnsupported
+}
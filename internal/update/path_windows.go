@@ -0,0 +1,31 @@
+//go:build windows
+
+// Package update adds directories to the user's PATH on Windows.
+package update
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// addToPathScript appends $env:NLCH_PATH_DIR to the user's PATH unless it is
+// there already. .NET broadcasts the change, so new terminals pick it up.
+const addToPathScript = `$dir = $env:NLCH_PATH_DIR
+$path = [Environment]::GetEnvironmentVariable('Path', 'User')
+$entries = @($path -split ';' | Where-Object { $_ -ne '' })
+if ($entries -notcontains $dir) {
+    [Environment]::SetEnvironmentVariable('Path', (($entries + $dir) -join ';'), 'User')
+}`
+
+// AddToUserPath adds dir to the PATH stored in the user's environment. It
+// takes effect in terminals opened afterwards.
+func AddToUserPath(dir string) error {
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", addToPathScript)
+	cmd.Env = append(os.Environ(), "NLCH_PATH_DIR="+dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update the user PATH: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
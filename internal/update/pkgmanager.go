@@ -0,0 +1,86 @@
+// Package update detects installations managed by a package manager, which
+// must be upgraded through that package manager rather than replaced in place.
+package update
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PackageManager is a package manager that installed nlch.
+type PackageManager struct {
+	Name    string
+	Upgrade string // command that upgrades nlch
+}
+
+// DetectPackageManager returns the package manager that owns the executable at exe, or nil.
+func DetectPackageManager(exe string) *PackageManager {
+	lower := strings.ToLower(filepath.ToSlash(exe))
+	switch {
+	case strings.Contains(lower, "/cellar/") || strings.Contains(lower, "/homebrew/") || strings.Contains(lower, "/linuxbrew/"):
+		return &PackageManager{Name: "Homebrew", Upgrade: "brew upgrade nlch"}
+	case strings.Contains(lower, "/scoop/apps/"):
+		return &PackageManager{Name: "Scoop", Upgrade: "scoop update nlch"}
+	}
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+
+	// dpkg lists the files of each package in /var/lib/dpkg/info/<package>.list
+	if listed, _ := filepath.Glob("/var/lib/dpkg/info/nlch*.list"); fileListsContain(listed, exe) {
+		return &PackageManager{Name: "apt", Upgrade: "sudo apt update && sudo apt install --only-upgrade nlch"}
+	}
+	// pacman lists them in /var/lib/pacman/local/<package>-<version>/files, without the leading slash
+	if listed, _ := filepath.Glob("/var/lib/pacman/local/nlch*/files"); fileListsContain(listed, strings.TrimPrefix(exe, "/")) {
+		return &PackageManager{Name: "pacman/AUR", Upgrade: "yay -Syu nlch (or your AUR helper)"}
+	}
+	// rpm can tell which package owns a file
+	if out, err := exec.Command("rpm", "-qf", "--queryformat", "%{NAME}", exe).Output(); err == nil && string(out) == "nlch" {
+		return &PackageManager{Name: "rpm", Upgrade: "sudo dnf upgrade nlch"}
+	}
+	return nil
+}
+
+// fileListsContain reports whether any of the package file lists has a line equal to path.
+func fileListsContain(lists []string, path string) bool {
+	for _, list := range lists {
+		file, err := os.Open(list)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		found := false
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) == path {
+				found = true
+				break
+			}
+		}
+		file.Close()
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// ManagedInstall returns the package manager that installed the running nlch binary, or nil.
+func ManagedInstall() *PackageManager {
+	exe, err := executablePath()
+	if err != nil {
+		return nil
+	}
+	return DetectPackageManager(exe)
+}
+
+// UpgradeCommand returns the command the user should run to update nlch.
+func UpgradeCommand() string {
+	if pm := ManagedInstall(); pm != nil {
+		return pm.Upgrade
+	}
+	return "nlch update"
+}
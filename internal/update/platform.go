@@ -0,0 +1,83 @@
+// Package update names release assets for the platforms nlch is built for.
+package update
+
+import (
+	"runtime"
+	"runtime/debug"
+	"slices"
+)
+
+// assetArchs returns the architecture names that release assets for this build
+// may use, best match first. 32-bit ARM assets carry the ARM version they were
+// built for ("armv7"); an ARMv7 machine can also run the ARMv6 build.
+func assetArchs() []string {
+	if runtime.GOARCH != "arm" {
+		return []string{runtime.GOARCH}
+	}
+	switch goarm() {
+	case '7':
+		return []string{"armv7", "armv6", "arm"}
+	case '5':
+		return []string{"armv5", "arm"}
+	}
+	return []string{"armv6", "arm"}
+}
+
+// goarm returns the ARM version this binary was built for, from its build settings.
+func goarm() byte {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			// The value may carry a suffix, as in "7,softfloat"
+			if s.Key == "GOARM" && s.Value != "" {
+				return s.Value[0]
+			}
+		}
+	}
+	return '6'
+}
+
+// platformAssetNames returns the names of the plain binary assets that suit this build, best match first.
+func platformAssetNames() []string {
+	names := []string{}
+	for _, arch := range assetArchs() {
+		name := "nlch-" + runtime.GOOS + "-" + arch
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// findPlatformAsset returns the name of the plain binary asset for this build, or "".
+func findPlatformAsset(release *Release) string {
+	for _, name := range platformAssetNames() {
+		if findAsset(release, name) != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// matchesPlatform reports whether the words of an asset name include this build's OS and architecture.
+func matchesPlatform(fields []string) bool {
+	if !slices.Contains(fields, runtime.GOOS) {
+		return false
+	}
+	for _, arch := range assetArchs() {
+		if slices.Contains(fields, arch) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPlatformAsset reports whether the release can be installed on this platform.
+func HasPlatformAsset(release *Release) bool {
+	return findPlatformAsset(release) != "" || findArchiveAsset(release) != ""
+}
+
+// PlatformName describes this build's platform, e.g. "linux/armv7".
+func PlatformName() string {
+	return runtime.GOOS + "/" + assetArchs()[0]
+}
@@ -0,0 +1,86 @@
+// Package update installs the running binary into a per-user directory, for
+// users who downloaded a release asset by hand.
+package update
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// BinaryName returns the file name of the nlch executable on this platform.
+func BinaryName() string {
+	if runtime.GOOS == "windows" {
+		return "nlch.exe"
+	}
+	return "nlch"
+}
+
+// UserInstallDir returns the standard per-user directory for the nlch binary:
+// %LOCALAPPDATA%\Programs\nlch on Windows and ~/.local/bin elsewhere.
+func UserInstallDir() (string, error) {
+	if runtime.GOOS == "windows" {
+		localAppData := os.Getenv("LOCALAPPDATA")
+		if localAppData == "" {
+			return "", fmt.Errorf("LOCALAPPDATA environment variable not set")
+		}
+		return filepath.Join(localAppData, "Programs", "nlch"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "bin"), nil
+}
+
+// SelfInstall copies the running binary into dir, creating it if needed, and
+// returns the installed path. Nothing is copied when the running binary
+// already is the one in dir.
+func SelfInstall(dir string) (string, error) {
+	src, err := executablePath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	dst := filepath.Join(dir, BinaryName())
+	if sameFile(src, dst) {
+		return dst, nil
+	}
+	if err := replaceExecutable(src, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// sameFile reports whether both paths name the same existing file.
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
+
+// OnPath reports whether dir is one of the directories in PATH.
+func OnPath(dir string) bool {
+	dir = filepath.Clean(dir)
+	return slices.ContainsFunc(filepath.SplitList(os.Getenv("PATH")), func(entry string) bool {
+		if entry == "" {
+			return false
+		}
+		entry = filepath.Clean(entry)
+		if runtime.GOOS == "windows" {
+			return strings.EqualFold(entry, dir)
+		}
+		return entry == dir
+	})
+}
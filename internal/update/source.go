@@ -0,0 +1,88 @@
+// Package update fetches release information from GitHub or a configured
+// mirror, such as GitHub Enterprise or a static manifest in an S3 bucket.
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+)
+
+// sourceURL returns the releases endpoint to query: the configured source or GitHub.
+func sourceURL() string {
+	if settings.URL != "" {
+		return strings.TrimRight(settings.URL, "/")
+	}
+	return ReleasesURL
+}
+
+// isManifest reports whether the source is a static JSON file listing
+// releases rather than a GitHub-compatible releases API.
+func isManifest() bool {
+	u, err := url.Parse(sourceURL())
+	return err == nil && strings.HasSuffix(strings.ToLower(u.Path), ".json")
+}
+
+// fetchReleases returns the releases published by the source.
+func fetchReleases(perPage int) ([]Release, error) {
+	if !isManifest() {
+		var releases []Release
+		err := getJSON(fmt.Sprintf("%s?per_page=%d", sourceURL(), perPage), &releases)
+		return releases, err
+	}
+
+	// A manifest holds either a list of releases or a single release
+	var raw json.RawMessage
+	if err := getJSON(sourceURL(), &raw); err != nil {
+		return nil, err
+	}
+	var releases []Release
+	if err := json.Unmarshal(raw, &releases); err == nil {
+		return releases, nil
+	}
+	var release Release
+	if err := json.Unmarshal(raw, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release manifest: %v", err)
+	}
+	return []Release{release}, nil
+}
+
+// httpGet fetches a URL with the headers from newRequest.
+func httpGet(rawURL string) (*http.Response, error) {
+	req, err := newRequest(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return httpclient.Default().Do(req)
+}
+
+// newRequest creates a GET request for the URL. The configured headers are only
+// sent to the update source's own host, and GITHUB_TOKEN only to the GitHub API,
+// so credentials never leak to other servers.
+func newRequest(rawURL string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(settings.Headers) > 0 && sameHost(rawURL, sourceURL()) {
+		for name, value := range settings.Headers {
+			req.Header.Set(name, os.ExpandEnv(value))
+		}
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" && req.Header.Get("Authorization") == "" && sameHost(rawURL, ReleasesURL) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// sameHost reports whether two URLs point at the same host.
+func sameHost(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && strings.EqualFold(ua.Host, ub.Host)
+}
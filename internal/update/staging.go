@@ -0,0 +1,111 @@
+// Package update stages downloaded updates so that the binary is swapped at the
+// start of the next invocation rather than while nlch is running.
+package update
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// stagedUpdate describes a downloaded update waiting to be installed.
+type stagedUpdate struct {
+	Version string `json:"version"`
+	Binary  string `json:"binary"`
+	SHA256  string `json:"sha256"`          // of the binary as verified when it was downloaded
+	Force   bool   `json:"force,omitempty"` // install even if it is not newer than the running version
+}
+
+// stagingDir returns the directory staged updates are kept in.
+func stagingDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "staged-update"), nil
+}
+
+// Stage downloads and verifies the release and keeps it until nlch next starts.
+func Stage(release *Release, force bool) error {
+	updatePath, err := DownloadUpdate(release)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(updatePath)
+	sum, err := fileSHA256(updatePath)
+	if err != nil {
+		return err
+	}
+
+	dir, err := stagingDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	binary := filepath.Join(dir, filepath.Base(updatePath))
+	if err := copyFile(updatePath, binary); err != nil {
+		return fmt.Errorf("failed to stage update: %v", err)
+	}
+
+	// The marker is written last, so an interrupted download is never installed
+	data, err := json.Marshal(stagedUpdate{Version: release.TagName, Binary: binary, SHA256: sum, Force: force})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "staged.json"), data, 0644)
+}
+
+// ApplyStaged installs an update staged by an earlier run. It returns the
+// installed version, or "" if no update was staged.
+func ApplyStaged() (string, error) {
+	dir, err := stagingDir()
+	if err != nil {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "staged.json"))
+	if err != nil {
+		return "", nil
+	}
+	// Whatever happens, a staged update is only tried once
+	defer os.RemoveAll(dir)
+
+	var staged stagedUpdate
+	if err := json.Unmarshal(data, &staged); err != nil {
+		return "", fmt.Errorf("invalid staged update: %v", err)
+	}
+	// Skip updates overtaken by another installation in the meantime
+	if newer, _ := isNewer(staged.Version, GetCurrentVersion()); !newer && !staged.Force {
+		return "", nil
+	}
+	// The binary waited on disk since it was verified, so it is checked again
+	if filepath.Dir(staged.Binary) != dir {
+		return "", fmt.Errorf("staged update %s is outside %s, refusing to install it", staged.Binary, dir)
+	}
+	if sum, err := fileSHA256(staged.Binary); err != nil || sum != staged.SHA256 {
+		return "", fmt.Errorf("staged update %s does not match the checksum it was downloaded with, refusing to install it", staged.Version)
+	}
+	if err := InstallUpdate(staged.Binary); err != nil {
+		return "", fmt.Errorf("%v (run 'nlch update' again from an account that can write to the nlch binary)", err)
+	}
+	return staged.Version, nil
+}
+
+// fileSHA256 returns the hex SHA-256 checksum of a file.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,35 @@
+package update
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyStagedChecksTheBinary(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir, err := stagingDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "nlch")
+	if err := os.WriteFile(binary, []byte("replaced after download"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(stagedUpdate{Version: "v9.9.9", Binary: binary, SHA256: sha256Hex([]byte("full nlch")), Force: true})
+	if err := os.WriteFile(filepath.Join(dir, "staged.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ApplyStaged(); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("err = %v, want a checksum mismatch", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("the staged update was kept after failing")
+	}
+}
@@ -2,30 +2,74 @@
 package update
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
 )
 
 const (
-	RepoOwner = "kanishka-sahoo"
-	RepoName  = "nlch"
-	UpdateURL = "https://api.github.com/repos/" + RepoOwner + "/" + RepoName + "/releases/latest"
+	RepoOwner   = "kanishka-sahoo"
+	RepoName    = "nlch"
+	ReleasesURL = "https://api.github.com/repos/" + RepoOwner + "/" + RepoName + "/releases"
+	UpdateURL   = ReleasesURL + "/latest"
+)
+
+// Release channels
+const (
+	ChannelStable  = "stable"  // full releases only
+	ChannelBeta    = "beta"    // full releases and prereleases, except nightly builds
+	ChannelNightly = "nightly" // every release, including nightly builds
 )
 
 // Build version can be set during compilation
 var BuildVersion = "0.1.0"
 
+// settings holds the updater configuration
+var settings config.UpdateConfig
+
+// Configure applies the update settings from the config file.
+func Configure(cfg config.UpdateConfig) error {
+	switch cfg.Channel {
+	case "", ChannelStable, ChannelBeta, ChannelNightly:
+	default:
+		return fmt.Errorf("unknown update channel %q (use %s, %s or %s)", cfg.Channel, ChannelStable, ChannelBeta, ChannelNightly)
+	}
+	settings = cfg
+	return nil
+}
+
+// Channel returns the configured release channel.
+func Channel() string {
+	if settings.Channel == "" {
+		return ChannelStable
+	}
+	return settings.Channel
+}
+
 // Release represents a GitHub release
 type Release struct {
-	TagName string `json:"tag_name"`
-	Assets  []struct {
+	TagName    string `json:"tag_name"`
+	Name       string `json:"name"`
+	Body       string `json:"body"` // release notes
+	Prerelease bool   `json:"prerelease"`
+	Draft      bool   `json:"draft"`
+	Assets     []struct {
 		Name               string `json:"name"`
 		BrowserDownloadURL string `json:"browser_download_url"`
 	} `json:"assets"`
@@ -36,73 +80,205 @@ func GetCurrentVersion() string {
 	return BuildVersion
 }
 
-// CheckForUpdates checks if a newer version is available
+// CheckForUpdates checks if a newer version is available in the configured channel
 func CheckForUpdates() (*Release, bool, error) {
-	resp, err := http.Get(UpdateURL)
+	release, err := latestRelease(Channel())
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to check for updates: %v", err)
+		return nil, false, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, false, fmt.Errorf("GitHub API returned status: %d", resp.StatusCode)
+	hasUpdate, err := isNewer(release.TagName, GetCurrentVersion())
+	if err != nil {
+		return nil, false, err
 	}
 
-	var release Release
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return nil, false, fmt.Errorf("failed to parse release info: %v", err)
+	return release, hasUpdate, nil
+}
+
+// isNewer reports whether the release tag is a newer version than current.
+func isNewer(tag, current string) (bool, error) {
+	latest, err := ParseVersion(tag)
+	if err != nil {
+		return false, fmt.Errorf("release has an unrecognised version: %v", err)
 	}
+	cur, err := ParseVersion(current)
+	if err != nil {
+		// A development build with no real version can always be updated
+		return true, nil
+	}
+	return latest.Compare(cur) > 0, nil
+}
 
-	currentVersion := "v" + GetCurrentVersion()
-	hasUpdate := release.TagName != currentVersion
+// UpdateKind describes the update to the release as "major", "minor", "patch"
+// or "prerelease", or returns "" if it can't be determined.
+func UpdateKind(release *Release) string {
+	latest, err := ParseVersion(release.TagName)
+	if err != nil {
+		return ""
+	}
+	cur, err := ParseVersion(GetCurrentVersion())
+	if err != nil {
+		return ""
+	}
+	return latest.Kind(cur)
+}
+
+// latestRelease returns the newest release in the given channel.
+func latestRelease(channel string) (*Release, error) {
+	// The stable channel can use the API's own notion of the latest release
+	if channel == ChannelStable && !isManifest() {
+		var release Release
+		if err := getJSON(sourceURL()+"/latest", &release); err != nil {
+			return nil, err
+		}
+		return &release, nil
+	}
 
-	return &release, hasUpdate, nil
+	// Otherwise pick the highest version among the matching releases
+	releases, err := fetchReleases(30)
+	if err != nil {
+		return nil, err
+	}
+	var newest *Release
+	var newestVersion Version
+	for i := range releases {
+		r := &releases[i]
+		if !inChannel(r, channel) {
+			continue
+		}
+		v, err := ParseVersion(r.TagName)
+		if err != nil {
+			continue
+		}
+		if newest == nil || v.Compare(newestVersion) > 0 {
+			newest, newestVersion = r, v
+		}
+	}
+	if newest == nil {
+		return nil, fmt.Errorf("no releases found in the %s channel", channel)
+	}
+	return newest, nil
 }
 
-// GetPlatformAssetName returns the asset name for the current platform
-func GetPlatformAssetName() string {
-	osName := runtime.GOOS
-	archName := runtime.GOARCH
+// inChannel reports whether a release is published in the given channel.
+func inChannel(r *Release, channel string) bool {
+	switch {
+	case r.Draft:
+		return false
+	case !r.Prerelease:
+		return true
+	case channel == ChannelBeta:
+		return !strings.Contains(strings.ToLower(r.TagName), "nightly")
+	default:
+		return channel == ChannelNightly
+	}
+}
 
-	// Convert Go OS names to our naming convention
-	switch osName {
-	case "darwin":
-		osName = "darwin"
-	case "linux":
-		osName = "linux"
-	case "windows":
-		osName = "windows"
+// getJSON fetches a release API URL and decodes the JSON response into v.
+// Responses are cached with their ETag and revalidated with a conditional request.
+func getJSON(url string, v any) error {
+	req, err := newRequest(url)
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %v", err)
+	}
+	cached, haveCache := cachedBody(url)
+	if haveCache {
+		req.Header.Set("If-None-Match", cached.ETag)
 	}
 
-	binaryName := "nlch-" + osName + "-" + archName
-	if osName == "windows" {
-		binaryName += ".exe"
+	resp, err := httpclient.Default().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %v", err)
 	}
+	defer resp.Body.Close()
 
-	return binaryName
+	var body []byte
+	switch {
+	case resp.StatusCode == http.StatusNotModified && haveCache:
+		body = cached.Body
+	case resp.StatusCode == http.StatusOK:
+		if body, err = io.ReadAll(resp.Body); err != nil {
+			return fmt.Errorf("failed to read release info: %v", err)
+		}
+		storeResponse(url, resp.Header.Get("ETag"), body)
+	default:
+		return fmt.Errorf("update source returned status: %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse release info: %v", err)
+	}
+	return nil
 }
 
-// DownloadUpdate downloads the latest version
+// GetPlatformAssetName returns the name of the binary asset for the current
+// platform, such as "nlch-linux-amd64" or "nlch-linux-armv7".
+func GetPlatformAssetName() string {
+	return platformAssetNames()[0]
+}
+
+// DownloadUpdate downloads the latest version and returns the path of the new binary.
+// It prefers a binary patch against the installed version, then the plain binary,
+// then a tar.gz or zip archive containing it. Every download is checksum-verified,
+// and so is the binary rebuilt from a patch.
 func DownloadUpdate(release *Release) (string, error) {
 	assetName := GetPlatformAssetName()
 
-	var downloadURL string
-	for _, asset := range release.Assets {
-		if asset.Name == assetName {
-			downloadURL = asset.BrowserDownloadURL
-			break
+	// A patch is much smaller, but any problem with it falls back to a full download
+	if patchName := patchAssetName(); findAsset(release, patchName) != "" {
+		if path, err := downloadPatched(release, patchName); err == nil {
+			return path, nil
 		}
 	}
 
-	if downloadURL == "" {
-		return "", fmt.Errorf("no asset found for platform: %s", assetName)
+	var tempFile string
+	var err error
+	if name := findPlatformAsset(release); name != "" {
+		tempFile, err = downloadVerified(release, name)
+	} else if archiveName := findArchiveAsset(release); archiveName != "" {
+		var archive string
+		if archive, err = downloadVerified(release, archiveName); err == nil {
+			tempFile, err = extractBinary(archive, filepath.Join(os.TempDir(), assetName))
+			os.Remove(archive)
+		}
+	} else {
+		return "", noAssetError(release)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Make executable on Unix systems
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(tempFile, 0755); err != nil {
+			return "", fmt.Errorf("failed to make executable: %v", err)
+		}
+	}
+
+	return tempFile, nil
+}
+
+// noAssetError explains that a release has no build for this platform.
+func noAssetError(release *Release) error {
+	return fmt.Errorf("%s has no prebuilt binary for %s. Build it from source instead:\n  go install github.com/kanishka-sahoo/nlch@%s", release.TagName, PlatformName(), release.TagName)
+}
+
+// downloadVerified downloads a release asset to a temporary file and checks it
+// against the release's checksums.
+func downloadVerified(release *Release, assetName string) (string, error) {
+	downloadURL := findAsset(release, assetName)
+
+	// Look up the expected checksum before downloading anything
+	expected, err := fetchChecksum(release, assetName)
+	if err != nil {
+		return "", err
 	}
 
 	// Create temporary file
 	tempDir := os.TempDir()
 	tempFile := filepath.Join(tempDir, assetName)
 
-	resp, err := http.Get(downloadURL)
+	resp, err := httpGet(downloadURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to download update: %v", err)
 	}
@@ -118,88 +294,83 @@ func DownloadUpdate(release *Release) (string, error) {
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
+	// Hash the asset while writing it
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("failed to write update: %v", err)
 	}
 
-	// Make executable on Unix systems
-	if runtime.GOOS != "windows" {
-		if err := os.Chmod(tempFile, 0755); err != nil {
-			return "", fmt.Errorf("failed to make executable: %v", err)
-		}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		os.Remove(tempFile)
+		return "", fmt.Errorf("checksum mismatch for %s (expected %s, got %s), refusing to install", assetName, expected, actual)
 	}
 
 	return tempFile, nil
 }
 
-// InstallUpdate replaces the current binary with the updated one
-func InstallUpdate(updatePath string) error {
+// executablePath returns the path of the running binary with symlinks resolved
+func executablePath() (string, error) {
 	// Get current executable path
 	currentExe, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to get current executable path: %v", err)
+		return "", fmt.Errorf("failed to get current executable path: %v", err)
 	}
 
 	// Resolve symlinks
 	currentExe, err = filepath.EvalSymlinks(currentExe)
 	if err != nil {
-		return fmt.Errorf("failed to resolve symlinks: %v", err)
+		return "", fmt.Errorf("failed to resolve symlinks: %v", err)
 	}
+	return currentExe, nil
+}
 
-	// On Windows, we need to use a different approach
-	if runtime.GOOS == "windows" {
-		return installUpdateWindows(updatePath, currentExe)
+// InstallUpdate replaces the current binary with the updated one.
+func InstallUpdate(updatePath string) error {
+	currentExe, err := executablePath()
+	if err != nil {
+		return err
 	}
-
-	return installUpdateUnix(updatePath, currentExe)
+	if err := replaceExecutable(updatePath, currentExe); err != nil {
+		return err
+	}
+	os.Remove(updatePath)
+	return nil
 }
 
-// installUpdateUnix handles update installation on Unix systems
-func installUpdateUnix(updatePath, currentExe string) error {
-	// Create backup
-	backupPath := currentExe + ".backup"
-	if err := copyFile(currentExe, backupPath); err != nil {
-		return fmt.Errorf("failed to create backup: %v", err)
+// replaceExecutable puts a copy of src at dst. The copy is made next to dst and
+// renamed over it, which is safe while dst is running; on Windows a running
+// binary has to be moved aside first.
+func replaceExecutable(src, dst string) error {
+	// Copy into the same directory so the final rename is atomic
+	newPath := dst + ".new"
+	if err := copyFile(src, newPath); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("failed to copy the new binary next to %s: %v", dst, err)
 	}
 
-	// Replace current executable
-	if err := copyFile(updatePath, currentExe); err != nil {
-		// Restore backup on failure
-		copyFile(backupPath, currentExe)
-		return fmt.Errorf("failed to replace executable: %v", err)
+	oldPath := dst + ".old"
+	os.Remove(oldPath)
+	movedAside := false
+	if runtime.GOOS == "windows" {
+		if err := os.Rename(dst, oldPath); err == nil {
+			movedAside = true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			os.Remove(newPath)
+			return fmt.Errorf("failed to move current executable aside: %v", err)
+		}
 	}
 
-	// Remove backup and temp file
-	os.Remove(backupPath)
-	os.Remove(updatePath)
-
-	return nil
-}
+	if err := os.Rename(newPath, dst); err != nil {
+		if movedAside {
+			os.Rename(oldPath, dst)
+		}
+		os.Remove(newPath)
+		return fmt.Errorf("failed to replace executable: %v", err)
+	}
 
-// installUpdateWindows handles update installation on Windows
-func installUpdateWindows(updatePath, currentExe string) error {
-	// On Windows, we can't replace a running executable directly
-	// We'll create a batch script to do it after the process exits
-	batchScript := `@echo off
-timeout /t 2 /nobreak >nul
-move /y "%s" "%s"
-start "" "%s"
-del "%%0"
-`
-	batchPath := filepath.Join(os.TempDir(), "nlch_update.bat")
-	batchContent := fmt.Sprintf(batchScript, updatePath, currentExe, currentExe)
-
-	if err := os.WriteFile(batchPath, []byte(batchContent), 0755); err != nil {
-		return fmt.Errorf("failed to create update script: %v", err)
-	}
-
-	// Start the batch script and exit
-	cmd := exec.Command("cmd", "/c", batchPath)
-	cmd.Start()
-
-	fmt.Println("Update will complete after nlch exits. Please restart nlch.")
-	os.Exit(0)
+	// A running Windows binary can't be deleted; it is cleaned up by the next update
+	os.Remove(oldPath)
 	return nil
 }
 
@@ -231,8 +402,9 @@ func copyFile(src, dst string) error {
 	return os.Chmod(dst, srcInfo.Mode())
 }
 
-// AutoUpdate performs an automatic update check and update
-func AutoUpdate(force bool) error {
+// AutoUpdate performs an update check and, after showing the release notes
+// and asking for confirmation (unless yes is set), stages the update for installation
+func AutoUpdate(force, yes bool) error {
 	fmt.Println("Checking for updates...")
 
 	release, hasUpdate, err := CheckForUpdates()
@@ -246,23 +418,42 @@ func AutoUpdate(force bool) error {
 	}
 
 	if hasUpdate {
-		fmt.Printf("New version available: %s (current: v%s)\n", release.TagName, GetCurrentVersion())
-	}
+		fmt.Println(Message(release))
 
-	if force || hasUpdate {
-		fmt.Println("Downloading update...")
-		updatePath, err := DownloadUpdate(release)
-		if err != nil {
-			return fmt.Errorf("download failed: %v", err)
+		// Show what changed since the installed version
+		if releases, err := ReleasesBetween(release); err == nil && len(releases) > 0 {
+			PrintChangelog(os.Stdout, releases)
+		} else {
+			PrintChangelog(os.Stdout, []Release{*release})
 		}
+	}
 
-		fmt.Println("Installing update...")
-		if err := InstallUpdate(updatePath); err != nil {
-			return fmt.Errorf("installation failed: %v", err)
+	if !HasPlatformAsset(release) {
+		fmt.Println(noAssetError(release))
+		return nil
+	}
+
+	// Binaries owned by a package manager must be upgraded through it
+	if pm := ManagedInstall(); pm != nil && !force {
+		fmt.Printf("nlch was installed with %s. To update, run:\n  %s\n", pm.Name, pm.Upgrade)
+		fmt.Println("Use 'nlch update --force' to replace the binary anyway.")
+		return nil
+	}
+
+	if !yes {
+		answer := strings.ToLower(strings.TrimSpace(shell.ReadLine(fmt.Sprintf("Install %s? [y/N]: ", release.TagName))))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("Update cancelled.")
+			return nil
 		}
+	}
 
-		fmt.Printf("Successfully updated to %s!\n", release.TagName)
+	// The binary is only swapped when nlch next starts, never while it runs
+	fmt.Println("Downloading update...")
+	if err := Stage(release, force); err != nil {
+		return fmt.Errorf("download failed: %v", err)
 	}
+	fmt.Printf("%s downloaded. It will be installed the next time you run nlch.\n", release.TagName)
 
 	return nil
 }
@@ -270,6 +461,9 @@ func AutoUpdate(force bool) error {
 // ShouldCheckForUpdates returns true if we should check for updates
 // This implements a simple time-based check (once per day)
 func ShouldCheckForUpdates() bool {
+	if httpclient.IsOffline() {
+		return false
+	}
 	configDir, err := getConfigDir()
 	if err != nil {
 		return false
@@ -324,22 +518,103 @@ func getConfigDir() (string, error) {
 	return filepath.Join(homeDir, ".config", "nlch"), nil
 }
 
-// NotifyUpdateAvailable shows a subtle notification about available updates
+// Message announces the release, naming the kind of update when it is known.
+func Message(release *Release) string {
+	kind := UpdateKind(release)
+	if kind == "" {
+		return fmt.Sprintf("A new version of nlch is available: %s.", release.TagName)
+	}
+	what := kind + " release"
+	if kind == "prerelease" {
+		what = kind
+	}
+	msg := fmt.Sprintf("A new %s of nlch is available: %s (current: v%s).", what, release.TagName, GetCurrentVersion())
+	if kind == "major" {
+		msg += " It may include breaking changes."
+	}
+	return msg
+}
+
+// noticeWait is how long ShowUpdateNotice waits for an unfinished background check.
+const noticeWait = time.Second
+
+// notice is the result of the background update check started by NotifyUpdateAvailable.
+var notice struct {
+	done    chan struct{} // closed when the check has finished
+	message string        // notice to show, empty if there is nothing to report
+}
+
+// NotifyUpdateAvailable starts a background check for updates, at most once a day.
+// Nothing is printed until ShowUpdateNotice is called, so the notice can't
+// interleave with command output or prompts.
 func NotifyUpdateAvailable() {
-	if !ShouldCheckForUpdates() {
+	if notice.done != nil || !ShouldCheckForUpdates() {
 		return
 	}
+	// Recorded first: the check dies with nlch if the command finishes before it
+	UpdateLastCheckTime()
 
+	// With automatic updates a separate process stages the release quietly, to
+	// be installed on the next start, since downloading may outlast the command
+	if settings.Auto && ManagedInstall() == nil {
+		startStager()
+		return
+	}
+
+	done := make(chan struct{})
+	notice.done = done
 	go func() {
-		defer UpdateLastCheckTime()
+		defer close(done)
 
-		_, hasUpdate, err := CheckForUpdates()
-		if err != nil {
+		release, hasUpdate, err := CheckForUpdates()
+		if err != nil || !hasUpdate {
 			return // Silently fail for background checks
 		}
-
-		if hasUpdate {
-			fmt.Fprintf(os.Stderr, "\n💡 A new version of nlch is available! Run 'nlch --update' to update.\n\n")
-		}
+		notice.message = fmt.Sprintf("%s%s Run '%s' to update.", ui.Icon("💡 ", "Notice: "), Message(release), UpgradeCommand())
 	}()
 }
+
+// startStager runs 'nlch update --stage' in the background, detached from
+// this process so that it keeps going after nlch exits.
+func startStager() {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(exe, "update", "--stage")
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	cmd.Process.Release()
+}
+
+// StageLatest downloads and stages the latest release if it is newer than
+// the running version, for 'nlch update --stage'. It prints nothing.
+func StageLatest() error {
+	release, hasUpdate, err := CheckForUpdates()
+	if err != nil || !hasUpdate || !HasPlatformAsset(release) {
+		return err
+	}
+	if ManagedInstall() != nil {
+		return nil
+	}
+	return Stage(release, false)
+}
+
+// ShowUpdateNotice prints the result of the background update check, if one was
+// started. Call it once the command has finished its own output. It waits
+// briefly for a check that is still running and otherwise gives up silently.
+func ShowUpdateNotice() {
+	if notice.done == nil {
+		return
+	}
+	select {
+	case <-notice.done:
+	case <-time.After(noticeWait):
+		return
+	}
+	if notice.message != "" {
+		fmt.Fprintf(os.Stderr, "\n%s\n", notice.message)
+	}
+}
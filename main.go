@@ -1,18 +1,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
-	"github.com/kanishka-sahoo/nlch/internal/config"
 	"github.com/kanishka-sahoo/nlch/internal/context"
-	"github.com/kanishka-sahoo/nlch/internal/plugin"
-	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
 	"github.com/kanishka-sahoo/nlch/internal/provider"
-	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
 	"github.com/kanishka-sahoo/nlch/internal/update"
 )
 
@@ -29,258 +28,191 @@ func init() {
 	provider.Register(&EchoProvider{})
 }
 
-func gatherContext() *context.Context {
-	wd, _ := os.Getwd()
-	files := []string{}
-	entries, err := os.ReadDir(wd)
-	if err == nil {
-		for _, entry := range entries {
-			files = append(files, entry.Name())
-		}
-	}
-	ctx := &context.Context{
-		WorkingDir: wd,
-		Files:      files,
-		GitInfo:    map[string]string{},
-		Extra:      map[string]any{},
-	}
-	// Gather git info
-	ctx.GatherGitInfo()
-	// Run plugins
-	for _, p := range plugin.List() {
-		_ = p.Gather(ctx)
-	}
-	return ctx
-}
-
 const version = "0.1.0"
 
 // This variable can be overridden at build time using -ldflags
 var buildVersion = version
 
-// cleanCommand removes markdown code blocks and extracts the actual command
-func cleanCommand(cmd string) string {
-	cmd = strings.TrimSpace(cmd)
+// command is a single nlch subcommand.
+type command struct {
+	name    string
+	usage   string // argument synopsis shown in help
+	summary string
+	run     func(args []string) error
+}
 
-	// Handle empty commands
-	if cmd == "" {
-		return cmd
+// commands returns all subcommands in the order they are listed in help.
+func commands() []*command {
+	return []*command{
+		runCommand,
+		explainCommand,
+		whyCommand,
+		aliasCommand,
+		scriptCommand,
+		batchCommand,
+		mapCommand,
+		scheduleCommand,
+		gitCommand,
+		saveCommand,
+		runSavedCommand,
+		historyCommand,
+		statsCommand,
+		auditCommand,
+		sessionsCommand,
+		benchCommand,
+		feedbackCommand,
+		initCommand,
+		configCommand,
+		useCommand,
+		providersCommand,
+		pluginCommand,
+		explainContextCommand,
+		doctorCommand,
+		shellInitCommand,
+		daemonCommand,
+		updateCommand,
+		selfInstallCommand,
+		versionCommand,
 	}
+}
 
-	// Remove markdown code blocks
-	if strings.HasPrefix(cmd, "```") {
-		lines := strings.Split(cmd, "\n")
-		if len(lines) > 1 {
-			// Remove first line (```bash or ```)
-			lines = lines[1:]
+// findCommand returns the subcommand with the given name.
+func findCommand(name string) (*command, bool) {
+	for _, c := range commands() {
+		if c.name == name {
+			return c, true
 		}
-		if len(lines) > 0 && strings.HasPrefix(lines[len(lines)-1], "```") {
-			// Remove last line (```)
-			lines = lines[:len(lines)-1]
-		}
-		cmd = strings.Join(lines, "\n")
-		cmd = strings.TrimSpace(cmd)
 	}
+	return nil, false
+}
 
-	// Remove backticks at start/end
-	cmd = strings.Trim(cmd, "`")
-	cmd = strings.TrimSpace(cmd)
-
-	// Get first non-empty line as the command
-	lines := strings.Split(cmd, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && !strings.HasPrefix(line, "#") {
-			return line
+// newFlagSet creates a flag set for a subcommand with a consistent help message.
+func newFlagSet(c *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: nlch %s %s\n\n%s\n", c.name, c.usage, c.summary)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(fs.Output(), "\nFlags:")
+			fs.PrintDefaults()
 		}
 	}
-
-	return cmd
+	return fs
 }
 
-func main() {
-	// Set the build version for the update package
-	update.BuildVersion = buildVersion
+// errUsage is returned for invalid invocations that have already been reported to the user.
+var errUsage = errors.New("invalid usage")
 
-	// CLI flags
-	showVersion := flag.Bool("version", false, "Show version and exit")
-	dryRun := flag.Bool("dry-run", false, "Show the command but do not execute it")
-	model := flag.String("model", "", "Override the model to use")
-	providerFlag := flag.String("provider", "", "Override the provider to use")
-	yesSure := flag.Bool("yes-im-sure", false, "Bypass confirmation for all commands, including dangerous ones")
-	verbose := flag.Bool("verbose", false, "Show provider and model information")
-	updateFlag := flag.Bool("update", false, "Check for and install updates")
-	checkUpdate := flag.Bool("check-update", false, "Check for updates without installing")
-	flag.Parse()
-
-	if *showVersion {
-		fmt.Printf("nlch version %s\n", buildVersion)
-		os.Exit(0)
+// parseFlags parses subcommand flags. Parse errors are reported by the flag
+// package itself, so they are returned as errUsage to avoid printing them twice.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errUsage
 	}
+	return err
+}
 
-	if *updateFlag {
-		if err := update.AutoUpdate(false); err != nil {
-			log.Fatalf("Update failed: %v", err)
-		}
-		os.Exit(0)
-	}
+// printUsage prints the top-level help listing all subcommands.
+func printUsage() {
+	fmt.Println("nlch - generate shell commands from natural language")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  nlch [flags] \"Describe your command here\"")
+	fmt.Println("  nlch <command> [flags] [args]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, c := range commands() {
+		fmt.Printf("  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Println()
+	fmt.Println("Run 'nlch help <command>' or 'nlch <command> --help' for details.")
+	fmt.Println("Put --offline before any command to only use local providers and never connect to another machine.")
+}
 
-	if *checkUpdate {
-		release, hasUpdate, err := update.CheckForUpdates()
-		if err != nil {
-			log.Fatalf("Update check failed: %v", err)
-		}
-		if hasUpdate {
-			fmt.Printf("New version available: %s (current: v%s)\n", release.TagName, update.GetCurrentVersion())
-			fmt.Println("Run 'nlch --update' to install the update.")
-		} else {
-			fmt.Println("nlch is up to date.")
-		}
-		os.Exit(0)
-	}
+var versionCommand = &command{
+	name:    "version",
+	summary: "Show version and exit",
+	run: func(args []string) error {
+		fmt.Printf("nlch version %s\n", buildVersion)
+		return nil
+	},
+}
 
-	if flag.NArg() < 1 {
-		fmt.Println("Usage: nlch [flags] \"Describe your command here\"")
-		flag.PrintDefaults()
-		os.Exit(1)
-	}
-	userInput := flag.Arg(0)
+func main() {
+	// Handle Ctrl-C and SIGTERM so requests, commands and history are stopped cleanly
+	interrupt.Start()
 
-	// Check for updates in the background (non-blocking)
-	update.NotifyUpdateAvailable()
+	// Set the build version for the update package
+	update.BuildVersion = buildVersion
 
-	// Load config (or create if first launch)
-	cfg, err := config.LoadOrCreate()
-	if err != nil {
-		log.Fatalf("Failed to load or create config: %v", err)
+	// Install an update downloaded by a previous run before doing anything else
+	if installed, err := update.ApplyStaged(); err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: failed to install staged update: %v\n", err)
+	} else if installed != "" {
+		fmt.Fprintf(os.Stderr, "nlch: updated to %s, the new version takes effect from the next command\n", installed)
 	}
 
-	// Register providers from config
-	provider.RegisterProvidersFromConfig(cfg.Providers)
-
-	// Gather context
-	ctx := gatherContext()
-
-	// Build prompt
-	promptStr := prompt.BuildPrompt(ctx, userInput)
-
-	// Select provider
-	providerName := cfg.DefaultProvider
-	if *providerFlag != "" {
-		providerName = *providerFlag
-	}
-	prov, ok := provider.Get(providerName)
-	if !ok {
-		log.Fatalf("Provider '%s' not found. Available: %v", providerName, provider.List())
+	args := os.Args[1:]
+	// --offline applies to any command, and to nlch processes they start
+	for len(args) > 0 && args[0] == "--offline" {
+		os.Setenv(httpclient.OfflineEnv, "1")
+		args = args[1:]
 	}
-
-	// Provider options
-	opts := provider.ProviderOptions{
-		Model:    *model,
-		Provider: providerName,
+	if len(args) == 0 {
+		printUsage()
+		os.Exit(1)
 	}
 
-	if *verbose {
-		fmt.Printf("Provider: %s\n", providerName)
-		modelUsed := opts.Model
-		// Try to get model from provider instance if not overridden
-		if modelUsed == "" {
-			// Try to get model from known provider types
-			switch p := prov.(type) {
-			case interface{ Model() string }:
-				modelUsed = p.Model()
-			case interface{ GetModel() string }:
-				modelUsed = p.GetModel()
-			case *provider.OpenRouterProvider:
-				modelUsed = p.Model
-			default:
-				// Fallback to config
-				if provCfg, ok := cfg.Providers[providerName]; ok {
-					modelUsed = provCfg.DefaultModel
-				}
+	// Translate legacy top-level flags into their subcommands
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		if len(args) > 1 {
+			if c, ok := findCommand(args[1]); ok {
+				_ = c.run([]string{"--help"})
+				return
 			}
 		}
-		fmt.Printf("Model: %s\n", modelUsed)
-	}
-
-	// Generate command
-	cmd, err := prov.GenerateCommand(*ctx, promptStr, opts)
-	if err != nil {
-		log.Fatalf("Provider error: %v", err)
-	}
-
-	// Clean up the command (remove markdown code blocks, etc.)
-	cmd = cleanCommand(cmd)
-
-	// Safety and confirmation logic - let LLM decide what's dangerous
-	const DangerPrefix = "danger: "
-	isDanger := strings.HasPrefix(cmd, DangerPrefix)
-	if isDanger && !*yesSure {
-		fmt.Println("This is a dangerous command, use --yes-im-sure to bypass.")
-		os.Exit(1)
+		printUsage()
+		return
+	case "-version", "--version":
+		args[0] = "version"
+	case "-update", "--update":
+		args[0] = "update"
+	case "-check-update", "--check-update":
+		args = append([]string{"update", "--check"}, args[1:]...)
 	}
 
-	// Remove danger prefix if approved by user
-	if *yesSure && isDanger {
-		cmd = cmd[len(DangerPrefix):]
+	// Anything that isn't a subcommand is a request for the run command
+	c, ok := findCommand(args[0])
+	if ok {
+		args = args[1:]
+	} else {
+		c = runCommand
 	}
 
-	// Only confirm for non-dangerous commands
-	requireConfirm := !*yesSure && !isDanger
-
-	// Execute or dry-run with retry logic
-	exec := shell.Executor{DryRun: *dryRun}
-	stdout, stderr, err := exec.Run(cmd, requireConfirm)
-
-	// If command failed and not in dry-run mode, ask LLM to fix it
-	if err != nil && !*dryRun {
-		fmt.Println("\n> Command failed. Asking LLM to provide a corrected version...")
-
-		// Build a prompt with the error information
-		errorPrompt := fmt.Sprintf(
-			"The previous command failed:\n"+
-				"Command: %s\n"+
-				"Error: %s\n"+
-				"Stderr: %s\n"+
-				"Stdout: %s\n\n"+
-				"Please provide a corrected command for the original request: %s\n"+
-				"Return ONLY the shell command, nothing else. Do not use markdown code blocks.",
-			cmd, err.Error(), stderr, stdout, userInput)
-
-		// Get corrected command from LLM
-		correctedCmd, corrErr := prov.GenerateCommand(*ctx, errorPrompt, opts)
-		if corrErr != nil {
-			log.Fatalf("Failed to get corrected command: %v", corrErr)
-		}
-
-		// Clean up the corrected command (remove markdown code blocks, etc.)
-		correctedCmd = cleanCommand(correctedCmd)
+	err := c.run(args)
 
-		// Check if we got a valid corrected command
-		if strings.TrimSpace(correctedCmd) == "" {
-			log.Fatalf("LLM did not provide a valid corrected command")
-		}
+	// Report a pending update only now, after the command's own output
+	update.ShowUpdateNotice()
 
-		// Check if corrected command is dangerous
-		isCorrectedDanger := strings.HasPrefix(correctedCmd, DangerPrefix)
-		if isCorrectedDanger && !*yesSure {
-			fmt.Println("The corrected command is dangerous, use --yes-im-sure to bypass.")
-			os.Exit(1)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
 		}
-
-		// Remove danger prefix if approved
-		if *yesSure && isCorrectedDanger {
-			correctedCmd = correctedCmd[len(DangerPrefix):]
+		if errors.Is(err, interrupt.ErrInterrupted) || interrupt.Interrupted() {
+			if interrupt.DeadlineExceeded() {
+				fmt.Fprintln(os.Stderr, "nlch: deadline exceeded")
+			} else {
+				fmt.Fprintln(os.Stderr, "nlch: interrupted")
+			}
+			os.Exit(interrupt.ExitCode())
 		}
-
-		// Execute corrected command (with confirmation if not bypassed)
-		requireCorrectedConfirm := !*yesSure && !isCorrectedDanger
-		fmt.Printf("\n> Trying corrected command: %s\n", correctedCmd)
-		_, _, corrErr = exec.Run(correctedCmd, requireCorrectedConfirm)
-		if corrErr != nil {
-			log.Fatalf("Corrected command also failed: %v", corrErr)
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
 		}
-	} else if err != nil {
-		log.Fatalf("Command failed: %v", err)
+		fmt.Fprintf(os.Stderr, "nlch: %s\n", ui.Error(strings.TrimSpace(err.Error())))
+		os.Exit(1)
 	}
 }
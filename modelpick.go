@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+// pickAvailableModel handles a provider saying that the model a request was
+// sent to doesn't exist. On a terminal, it lists the models the provider
+// offers, lets the user pick one and saves it as the provider's default
+// model, returning it. Otherwise, and when the provider can't list its
+// models, it returns err as it is; shell.ErrAborted when the user picks none.
+func pickAvailableModel(cfg *config.Config, providerName, model string, err error) (string, error) {
+	if !provider.ModelNotFound(err) || !onTerminal() {
+		return "", err
+	}
+	p, newErr := provider.New(providerName, cfg.Providers[providerName])
+	lister, ok := p.(provider.ModelLister)
+	if newErr != nil || !ok {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "nlch: %s does not offer model %q: %v\n", providerName, model, err)
+	available, listErr := lister.ListModels()
+	if listErr != nil || len(available) == 0 {
+		return "", err
+	}
+
+	choices := make([]useChoice, len(available))
+	for i, m := range available {
+		choices[i] = useChoice{provider: providerName, model: m, source: "offered by " + providerName}
+	}
+	picked, pickErr := pickChoice(choices, modelLabel(providerName, model), "")
+	if pickErr != nil {
+		return "", pickErr
+	}
+	if err := config.SetModel(providerName, picked.model); err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: failed to save the model as the default: %v\n", err)
+	} else {
+		fmt.Printf("> %s is now the default model of %s.\n", ui.Highlight(picked.model), providerName)
+	}
+	return picked.model, nil
+}
+
+// onTerminal reports whether both stdin and stdout are terminals, so that
+// the user can be asked a question and its answer doesn't end up in a pipe.
+func onTerminal() bool {
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nlch/internal/app"
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/daemon"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/httpclient"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+	"github.com/kanishka-sahoo/nlch/internal/models"
+	"github.com/kanishka-sahoo/nlch/internal/plugin"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/tokens"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+	"github.com/kanishka-sahoo/nlch/internal/update"
+)
+
+// newExecutor returns what runs the commands nlch generates. Tests replace it
+// to confirm and "run" commands without a terminal or real processes.
+var newExecutor = func(e shell.Executor) shell.CommandExecutor {
+	gitBefore()
+	if e.WatchLimit == 0 {
+		e.WatchLimit = watchLimit()
+	}
+	return &e
+}
+
+// watchLimit returns how long watch-style commands may run according to the
+// config, the default when it doesn't say, or 0 for no limit.
+func watchLimit() time.Duration {
+	cfg, err := config.Load()
+	if err != nil || cfg.WatchLimit == "" {
+		return shell.DefaultWatchLimit
+	}
+	limit, err := time.ParseDuration(cfg.WatchLimit)
+	if err != nil || limit < 0 {
+		fmt.Fprintf(os.Stderr, "nlch: warning: invalid watch_limit %q, using %s\n", cfg.WatchLimit, shell.FormatLimit(shell.DefaultWatchLimit))
+		return shell.DefaultWatchLimit
+	}
+	return limit
+}
+
+// targetShell returns the shell to write commands for, from the flag, the
+// config or $SHELL, and the program that runs them. When that program is not
+// installed the target is returned along with the error, for commands that
+// are only printed. Commands that run in a container are always written for
+// its POSIX shell.
+func targetShell(cfg *config.Config, flag, inContainer string) (string, string, error) {
+	if inContainer != "" {
+		if flag != "" && !shell.POSIX(flag) {
+			return "", "", fmt.Errorf("commands run in containers with sh, so --shell %s cannot be combined with --in-container", flag)
+		}
+		return "sh", "", nil
+	}
+	name := flag
+	if name == "" {
+		name = cfg.Shell
+	}
+	target, err := shell.Target(name)
+	if err != nil {
+		return "", "", err
+	}
+	program, err := shell.Program(target)
+	return target, program, err
+}
+
+// gitBefore is the git state of the working directory before nlch ran any
+// command, kept with history entries. It is taken the first time it's needed,
+// which newExecutor makes sure is before the first command runs.
+var gitBefore = sync.OnceValue(func() *history.GitState {
+	wd, _ := os.Getwd()
+	return history.SnapshotGit(wd)
+})
+
+// DangerPrefix marks commands the LLM considers dangerous.
+const DangerPrefix = prompt.DangerPrefix
+
+// fullGitStatus is set by --git-status to include the full git status, not
+// summarized, even in repositories too large for it to be gathered quickly.
+var fullGitStatus bool
+
+// gatherContext collects the context of the working directory for a prompt.
+func gatherContext() *context.Context {
+	wd, _ := os.Getwd()
+	ctx := &context.Context{
+		WorkingDir: wd,
+		Extra:      map[string]any{},
+	}
+	// List the directory while git runs, since either can be slow in a large tree
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx.GatherFiles()
+	}()
+	// Gather git info, from the daemon's cache when one is running; it
+	// summarizes long status and leaves out that of large repositories, as
+	// GatherGitInfo does
+	if client := daemonClient(); client != nil && !fullGitStatus {
+		if info, err := client.GitInfo(wd); err == nil && info != nil {
+			ctx.GitInfo = info
+		} else {
+			ctx.GatherGitInfo(false)
+		}
+	} else {
+		ctx.GatherGitInfo(fullGitStatus)
+	}
+	ctx.GatherLocale()
+	wg.Wait()
+	// Run plugins
+	for _, p := range plugin.List() {
+		_ = p.Gather(ctx)
+	}
+	return ctx
+}
+
+// setupProvider loads the config (running first-time setup if needed),
+// registers the configured providers and selects the provider to use.
+func setupProvider(providerOverride string) (*config.Config, provider.Provider, string, error) {
+	// Load config (or create if first launch)
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to load or create config: %v", err)
+	}
+
+	// Register providers from config
+	provider.RegisterProvidersFromConfig(cfg.Providers)
+	ui.SetTheme(cfg.Theme)
+	ui.SetAccessible(cfg.Accessible)
+	models.Configure(cfg.Models)
+	if err := update.Configure(cfg.Update); err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: %v\n", err)
+	}
+	if err := httpclient.Configure(cfg.Network); err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: %v\n", err)
+	}
+	if err := prompt.CheckPacks(cfg.Packs, cfg.DisabledPacks); err != nil {
+		return nil, nil, "", err
+	}
+
+	// Select provider
+	providerName := cfg.DefaultProvider
+	if providerOverride != "" {
+		providerName = providerOverride
+	}
+	prov, ok := provider.Get(providerName)
+	if !ok {
+		return nil, nil, "", provider.NotFound(providerName)
+	}
+	if err := checkOffline(cfg, providerName); err != nil {
+		return nil, nil, "", err
+	}
+	// Let a running daemon serve the request over its warm connections,
+	// unless offline, since the daemon may have been started without it
+	if client := daemonClient(); client != nil && !httpclient.IsOffline() {
+		prov = daemon.NewRemote(client, prov)
+	}
+	// Record or replay provider interactions for tests
+	prov, err = provider.WithFixtures(prov)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return cfg, validated(cfg, prov), providerName, nil
+}
+
+// How long to wait for Ollama to say whether it is running.
+const ollamaProbeTimeout = 500 * time.Millisecond
+
+// localCheck returns the local danger check the config asks for, or nil when
+// it is off, the provider is Ollama already, or Ollama isn't running. A model
+// that isn't pulled is worth a warning, since the user asked for the check.
+func localCheck(cfg *config.Config, providerName string) *app.LocalCheck {
+	if !cfg.LocalCheck.Enabled || providerName == "ollama" {
+		return nil
+	}
+	ollama := cfg.Providers["ollama"]
+	url, model := cfg.LocalCheck.URL, cfg.LocalCheck.Model
+	if url == "" {
+		url = provider.OllamaURL(ollama)
+	}
+	if model == "" {
+		model = ollama.DefaultModel
+	}
+	if model == "" {
+		model = "llama3.2"
+	}
+	prov := &provider.OllamaProvider{URL: url, Model: model}
+	installed, err := prov.InstalledModels(ollamaProbeTimeout)
+	if err != nil {
+		return nil
+	}
+	if !slices.Contains(installed, model) && !slices.Contains(installed, model+":latest") {
+		fmt.Fprintf(os.Stderr, "nlch: warning: local_check: model %s is not in Ollama, run `ollama pull %s`\n", model, model)
+		return nil
+	}
+	return &app.LocalCheck{Provider: prov, Model: model}
+}
+
+// checkOffline refuses providers that would send requests to another machine
+// in offline mode.
+func checkOffline(cfg *config.Config, name string) error {
+	if !httpclient.IsOffline() || name == "echo" || provider.Local(name, cfg.Providers[name]) {
+		return nil
+	}
+	return fmt.Errorf("offline mode: provider '%s' sends requests to another machine; use a local provider, such as ollama on localhost", name)
+}
+
+// validated wraps a provider so that replies that are not commands are asked
+// for again, unless the config turns that off.
+func validated(cfg *config.Config, prov provider.Provider) provider.Provider {
+	if cfg.Validation.Disabled {
+		return prov
+	}
+	retries := cfg.Validation.Retries
+	if retries <= 0 {
+		retries = app.DefaultValidationRetries
+	}
+	return &app.ValidatingProvider{Provider: prov, Retries: retries}
+}
+
+var (
+	daemonOnce sync.Once
+	daemonConn *daemon.Client
+)
+
+// daemonClient returns a client for the running nlch daemon, or nil if there is none.
+func daemonClient() *daemon.Client {
+	daemonOnce.Do(func() {
+		path, err := daemon.SocketPath()
+		if err != nil {
+			return
+		}
+		daemonConn, _ = daemon.Dial(path)
+	})
+	return daemonConn
+}
+
+// resolveModel returns the model that will be used for the request.
+func resolveModel(prov provider.Provider, cfg *config.Config, providerName, override string) string {
+	if override != "" {
+		return override
+	}
+	// Try to get model from known provider types
+	switch p := prov.(type) {
+	case interface{ Model() string }:
+		return p.Model()
+	case interface{ GetModel() string }:
+		return p.GetModel()
+	case *provider.OpenRouterProvider:
+		return p.Model
+	}
+	// Fallback to config
+	if provCfg, ok := cfg.Providers[providerName]; ok {
+		return provCfg.DefaultModel
+	}
+	return ""
+}
+
+// projectInstructions returns the path and text of the instructions file of
+// the project nlch runs in, or empty strings when there is none or the config
+// ignores them. Problems reading it are warnings, as the request can go on without.
+func projectInstructions(cfg *config.Config, model string) (string, string) {
+	if cfg != nil && cfg.IgnoreProject {
+		return "", ""
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", ""
+	}
+	path, text, err := context.FindInstructions(wd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: failed to read project instructions: %v\n", err)
+		return "", ""
+	}
+	budget := prompt.BudgetFor(model).Instructions
+	text, truncated := tokens.Truncate(model, text, budget)
+	if truncated {
+		fmt.Fprintf(os.Stderr, "nlch: warning: %s is longer than %d tokens, only its start is used\n", path, budget)
+	}
+	return path, text
+}
+
+// withProjectInstructions appends the project's instructions, if any, to a system prompt.
+func withProjectInstructions(cfg *config.Config, system string) string {
+	_, instructions := projectInstructions(cfg, "")
+	return prompt.WithInstructions(system, instructions)
+}
+
+// recordHistory appends an entry to the history store and returns its ID. History is
+// best-effort, so failures are reported as warnings and never abort the command.
+// The privacy settings in the config decide whether and what is recorded.
+func recordHistory(e history.Entry) int {
+	// Finish writing the entry even if a signal arrives meanwhile
+	defer interrupt.Busy()()
+	var policy config.HistoryConfig
+	if cfg, err := config.Load(); err == nil {
+		policy = cfg.History
+	}
+	if history.Excluded(policy, e.Dir) {
+		return 0
+	}
+	history.ApplyPolicy(&e, policy)
+	// The snapshot is of the local working directory, not a container's
+	if wd, _ := os.Getwd(); e.Git == nil && e.Dir == wd {
+		e.Git = gitBefore()
+	}
+
+	store, err := history.Open()
+	if err == nil {
+		err = store.Append(&e)
+	}
+	if err == nil {
+		_, err = store.Prune(policy.MaxDays, policy.MaxEntries)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: failed to record history: %v\n", err)
+		return 0
+	}
+
+	// Executed commands also go to atuin, when the shell uses it, so its search finds them
+	if history.AtuinEnabled() {
+		if err := history.RecordAtuin(e); err != nil {
+			fmt.Fprintf(os.Stderr, "nlch: warning: failed to record history in atuin: %v\n", err)
+		}
+	}
+	return e.ID
+}
+
+// historyEntryOrLast returns the history entry with the given id, or the most recent one if id is 0.
+func historyEntryOrLast(id int) (*history.Entry, error) {
+	store, err := history.Open()
+	if err != nil {
+		return nil, err
+	}
+	if id != 0 {
+		return store.Get(id)
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("the history is empty")
+	}
+	return &entries[len(entries)-1], nil
+}
+
+// lastExchange returns the most recent history entry as an exchange for a follow-up request.
+func lastExchange() (*prompt.Exchange, error) {
+	entry, err := historyEntryOrLast(0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot continue: %v", err)
+	}
+	return &prompt.Exchange{
+		Request:  entry.Request,
+		Command:  entry.Command,
+		Executed: entry.Decision == history.DecisionExecuted,
+		ExitCode: entry.ExitCode,
+		Output:   entry.Output,
+	}, nil
+}
+
+// Maximum number of past-feedback lessons added to a prompt.
+const maxLessons = 3
+
+// feedbackLessons returns lessons from feedback on past requests similar to this one.
+// Lessons are an optional improvement, so any error simply yields none.
+func feedbackLessons(request string) []prompt.Lesson {
+	store, err := history.Open()
+	if err != nil {
+		return nil
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return nil
+	}
+	feedback, err := store.Feedback()
+	if err != nil {
+		return nil
+	}
+	var lessons []prompt.Lesson
+	for _, l := range history.Lessons(entries, feedback, request, maxLessons) {
+		lessons = append(lessons, prompt.Lesson{Request: l.Request, Bad: l.Bad, Note: l.Note, Good: l.Good})
+	}
+	return lessons
+}
+
+// estimateCost returns the estimated cost in US dollars of sending the prompt,
+// counting the reply at its token limit, and whether the model's price is known.
+func estimateCost(model string, opts provider.ProviderOptions, promptStr string) (float64, bool) {
+	if _, ok := tokens.PriceFor(model); !ok {
+		return 0, false
+	}
+	var u app.Usage
+	u.Add(model, opts, promptStr, "")
+	output := opts.MaxTokens
+	if output <= 0 {
+		output = provider.DefaultMaxTokens
+	}
+	return tokens.Cost(model, u.Input, output), true
+}
+
+// formatCost formats an estimated cost for display, e.g. "≈$0.0004".
+func formatCost(cost float64) string {
+	if cost < 0.0001 {
+		return "<$0.0001"
+	}
+	return fmt.Sprintf("≈$%.4f", cost)
+}
+
+// errNotSent is returned when the user declines to send an expensive request.
+var errNotSent = errors.New("the request was not sent")
+
+// confirmCost asks before sending a request that is estimated to cost more
+// than the configured max_cost, such as one with a large previous output.
+func confirmCost(cfg *config.Config, model string, opts provider.ProviderOptions, promptStr string) error {
+	if cfg.MaxCost <= 0 {
+		return nil
+	}
+	cost, ok := estimateCost(model, opts, promptStr)
+	if !ok || cost <= cfg.MaxCost {
+		return nil
+	}
+	question := fmt.Sprintf("> This request to %s is estimated to cost %s, more than max_cost ($%g). Send it? [y/N]: ", model, formatCost(cost), cfg.MaxCost)
+	answer, err := shell.ReadTerminalLine(question)
+	if errors.Is(err, shell.ErrNoTerminal) {
+		return fmt.Errorf("the request is estimated to cost %s, more than max_cost ($%g); raise max_cost to send it", formatCost(cost), cfg.MaxCost)
+	}
+	if err != nil {
+		return err
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return errNotSent
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+)
+
+// fakeExecutor stands in for the terminal: it records each command with the
+// confirmation it would have asked for, and fails the commands in fail. Like
+// the real one, it runs nothing that is blocked.
+type fakeExecutor struct {
+	ran      []string
+	confirms []shell.Confirmation
+	fail     map[string]error
+}
+
+func (f *fakeExecutor) Run(cmd string, confirm shell.Confirmation) (string, string, error) {
+	f.confirms = append(f.confirms, confirm)
+	if confirm == shell.ConfirmBlock {
+		return "", "", shell.ErrBlocked
+	}
+	f.ran = append(f.ran, cmd)
+	if err := f.fail[cmd]; err != nil {
+		return "", "cat: missing.txt: No such file or directory\n", err
+	}
+	return "", "", nil
+}
+
+func (f *fakeExecutor) ReadLine(prompt string) string { return "" }
+
+// setupTest points nlch at a config in a temporary home whose mock provider
+// gives the responses in order, and replaces the executor with a fake.
+func setupTest(t *testing.T, config string, responses ...string) *fakeExecutor {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("NLCH_RECORD", "")
+	t.Setenv("NLCH_REPLAY", "")
+	dir := filepath.Join(home, ".config", "nlch")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	yaml := "default_provider: mock\nproviders:\n  mock:\n    responses:\n"
+	for _, r := range responses {
+		yaml += "      - " + quoteYAML(r) + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml+config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	exec := &fakeExecutor{}
+	previous := newExecutor
+	newExecutor = func(shell.Executor) shell.CommandExecutor { return exec }
+	t.Cleanup(func() { newExecutor = previous })
+	return exec
+}
+
+func quoteYAML(s string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// lastEntry returns the newest entry of the test's history.
+func lastEntry(t *testing.T) history.Entry {
+	t.Helper()
+	store, err := history.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.Load()
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("no history entries: %v", err)
+	}
+	return entries[len(entries)-1]
+}
+
+func TestRunConfirmsByRisk(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		flags    []string
+		want     string
+		confirm  shell.Confirmation
+	}{
+		{"low risk runs at once", "ls -la", nil, "ls -la", shell.ConfirmNone},
+		{"medium risk asks Y/n", "mkdir out", nil, "mkdir out", shell.ConfirmYesNo},
+		{"high risk asks for yes", "rm -rf build", nil, "rm -rf build", shell.ConfirmTyped},
+		{"danger marker raises the risk", "danger: ls -la", nil, "ls -la", shell.ConfirmTyped},
+		{"--yes-im-sure skips the question", "rm -rf build", []string{"--yes-im-sure"}, "rm -rf build", shell.ConfirmNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := setupTest(t, "", tt.response)
+			if err := runRun(append(tt.flags, "do", "it")); err != nil {
+				t.Fatal(err)
+			}
+			if len(exec.ran) != 1 || exec.ran[0] != tt.want {
+				t.Fatalf("ran %q, want [%s]", exec.ran, tt.want)
+			}
+			if exec.confirms[0] != tt.confirm {
+				t.Errorf("confirmation = %s, want %s", exec.confirms[0], tt.confirm)
+			}
+			if e := lastEntry(t); e.Command != tt.want || e.Decision != history.DecisionExecuted {
+				t.Errorf("history has %q %s", e.Command, e.Decision)
+			}
+		})
+	}
+}
+
+func TestRunBlocksCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		response string
+		flags    []string
+	}{
+		{"critical risk", "", "rm -rf /", nil},
+		{"never constraint", "never:\n  - \"rm -rf\"\n", "rm -rf build", []string{"--yes-im-sure"}},
+		{"read-only mode", "", "touch file", []string{"--read-only"}},
+		{"blocked in the config", "confirm:\n  medium: block\n", "mkdir out", []string{"--yes-im-sure"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := setupTest(t, tt.config, tt.response)
+			if err := runRun(append(tt.flags, "do", "it")); err == nil {
+				t.Fatal("expected the command to be refused")
+			}
+			if len(exec.ran) > 0 {
+				t.Errorf("ran %q", exec.ran)
+			}
+			if e := lastEntry(t); e.Decision != history.DecisionBlocked {
+				t.Errorf("history decision = %s, want %s", e.Decision, history.DecisionBlocked)
+			}
+		})
+	}
+}
+
+func TestRunCorrectsFailedCommand(t *testing.T) {
+	exec := setupTest(t, "", "cat missing.txt", "cat notes.txt")
+	exec.fail = map[string]error{"cat missing.txt": errors.New("exit status 1")}
+	if err := runRun([]string{"show", "the", "notes"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cat missing.txt", "cat notes.txt"}; strings.Join(exec.ran, "|") != strings.Join(want, "|") {
+		t.Fatalf("ran %q, want %q", exec.ran, want)
+	}
+	// The correction is confirmed according to its own risk
+	if exec.confirms[1] != shell.ConfirmNone {
+		t.Errorf("correction confirmation = %s, want %s", exec.confirms[1], shell.ConfirmNone)
+	}
+	if e := lastEntry(t); e.Command != "cat notes.txt" || !e.Corrected {
+		t.Errorf("history has %q corrected=%v", e.Command, e.Corrected)
+	}
+}
+
+func TestRunDoesNotRunBlockedCorrection(t *testing.T) {
+	exec := setupTest(t, "never:\n  - \"rm -rf\"\n", "make clean", "rm -rf build")
+	exec.fail = map[string]error{"make clean": errors.New("exit status 2")}
+	if err := runRun([]string{"clean", "up"}); err == nil {
+		t.Fatal("expected the correction to be refused")
+	}
+	if len(exec.ran) != 1 {
+		t.Errorf("ran %q, want only the failed command", exec.ran)
+	}
+}
+
+func TestHistoryRunEnforcesConstraints(t *testing.T) {
+	exec := setupTest(t, "", "rm -rf build")
+	if err := runRun([]string{"--yes-im-sure", "clean"}); err != nil {
+		t.Fatal(err)
+	}
+	id := lastEntry(t).ID
+
+	// The constraint is added after the command was recorded
+	config := filepath.Join(os.Getenv("HOME"), ".config", "nlch", "config.yaml")
+	data, err := os.ReadFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, append(data, "never:\n  - \"rm -rf\"\n"...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	exec.ran = nil
+	if err := runHistoryRun([]string{strconv.Itoa(id)}); err == nil {
+		t.Fatal("expected history run to refuse the command")
+	}
+	if len(exec.ran) > 0 {
+		t.Errorf("replayed %q", exec.ran)
+	}
+}
+
+// pipeStdin makes input the test's stdin, as when it is piped into nlch.
+func pipeStdin(t *testing.T, input string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.WriteString(input)
+		w.Close()
+	}()
+	previous := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = previous
+		r.Close()
+	})
+}
+
+func TestMapConfirmsByRisk(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		response string
+		wantRan  bool
+	}{
+		{"--yes applies a low-risk filter", "", "cut -d, -f1", true},
+		{"--yes does not skip a blocked level", "confirm:\n  low: block\n", "cut -d, -f1", false},
+		{"--yes does not skip a never constraint", "never:\n  - \"rm \"\n", "xargs rm -f", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := setupTest(t, tt.config, tt.response)
+			pipeStdin(t, "a,1\nb,2\n")
+			err := runMap([]string{"--yes", "first", "column"})
+			if ran := len(exec.ran) > 0; ran != tt.wantRan {
+				t.Fatalf("ran %q (err %v), want run %v", exec.ran, err, tt.wantRan)
+			}
+			if (err != nil) == tt.wantRan {
+				t.Errorf("err = %v", err)
+			}
+		})
+	}
+}
+
+func TestGitCommitConfirmsByConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		confirm shell.Confirmation
+		wantErr bool
+	}{
+		{"asks Y/n by default", "", shell.ConfirmYesNo, false},
+		{"asks at least Y/n", "confirm:\n  medium: run\n", shell.ConfirmYesNo, false},
+		{"asks for yes where configured", "confirm:\n  medium: type\n", shell.ConfirmTyped, false},
+		{"is refused where blocked", "confirm:\n  medium: block\n", shell.ConfirmBlock, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := setupTest(t, tt.config, "feat: add notes")
+			repo := t.TempDir()
+			t.Chdir(repo)
+			if err := os.WriteFile(filepath.Join(repo, "notes.txt"), []byte("notes\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for _, args := range [][]string{{"init", "-q"}, {"add", "notes.txt"}} {
+				if _, err := gitOutput(args...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := runGitCommit(nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(exec.confirms) != 1 || exec.confirms[0] != tt.confirm {
+				t.Errorf("confirmations = %q, want [%s]", exec.confirms, tt.confirm)
+			}
+		})
+	}
+}
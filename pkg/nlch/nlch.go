@@ -0,0 +1,183 @@
+// Package nlch is the public API for embedding nlch's command generation in
+// other Go programs.
+//
+// A typical caller gathers context for a directory, picks a provider and asks a
+// Generator for a command:
+//
+//	prov, err := nlch.LoadProvider("")
+//	if err != nil {
+//		return err
+//	}
+//	ctx := nlch.GatherContext(".")
+//	g := &nlch.Generator{Provider: prov}
+//	cmd, err := g.Generate(ctx, "list the five largest files")
+//	if err != nil {
+//		return err
+//	}
+//	if !cmd.Dangerous {
+//		fmt.Println(cmd.Text)
+//	}
+//
+// The types and functions in this package are stable: they only change in a
+// backwards-compatible way within a major version. Everything under internal/
+// may change at any time.
+package nlch
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/context"
+	"github.com/kanishka-sahoo/nlch/internal/plugin"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/provider"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+)
+
+// Context describes the environment a command is generated for.
+type Context = context.Context
+
+// Provider is an LLM backend that turns a prompt into a command.
+type Provider = provider.Provider
+
+// ProviderOptions adjusts a single provider call.
+type ProviderOptions = provider.ProviderOptions
+
+// ProviderConfig holds the settings of one provider, as in the config file.
+type ProviderConfig = config.ProviderConfig
+
+// Plugin adds information to the context before a command is generated.
+type Plugin = plugin.Plugin
+
+// RegisterProvider makes a provider available to LoadProvider by its name,
+// replacing any provider of the same name. It is safe to call concurrently.
+func RegisterProvider(p Provider) {
+	provider.Register(p)
+}
+
+// RegisterPlugin adds a context plugin that GatherContext runs, replacing any
+// plugin of the same name. It is safe to call concurrently.
+func RegisterPlugin(p Plugin) {
+	plugin.Register(p)
+}
+
+// UnregisterProvider removes a provider by name, reporting whether there was one.
+func UnregisterProvider(name string) bool {
+	return provider.Unregister(name)
+}
+
+// UnregisterPlugin removes a context plugin by name, reporting whether there was one.
+func UnregisterPlugin(name string) bool {
+	return plugin.Unregister(name)
+}
+
+// NewProvider creates one of the built-in providers ("openai", "anthropic",
+// "gemini", "openrouter", "groq", "cohere", "bedrock", "vertex" or "ollama")
+// from its settings.
+func NewProvider(name string, cfg ProviderConfig) (Provider, error) {
+	return provider.New(name, cfg)
+}
+
+// LoadProvider returns the provider with the given name as configured in the
+// user's nlch config file, or the default provider if name is empty.
+func LoadProvider(name string) (Provider, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %v", err)
+	}
+	provider.RegisterProvidersFromConfig(cfg.Providers)
+	if name == "" {
+		name = cfg.DefaultProvider
+	}
+	prov, ok := provider.Get(name)
+	if !ok {
+		return nil, provider.NotFound(name)
+	}
+	return prov, nil
+}
+
+// GatherContext collects the files, git information, locale, timezone and
+// plugin context of dir. In repositories tracking more than 100,000 files,
+// git status is left out.
+func GatherContext(dir string) *Context {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	ctx := &Context{
+		WorkingDir: dir,
+		Extra:      map[string]any{},
+	}
+	ctx.GatherFiles()
+	ctx.GatherGitInfo(false)
+	ctx.GatherLocale()
+	for _, p := range plugin.List() {
+		_ = p.Gather(ctx)
+	}
+	return ctx
+}
+
+// ProjectInstructions returns the contents of the .nlch/instructions.md file
+// nearest to dir, within its git repository, or "" when there is none.
+func ProjectInstructions(dir string) (string, error) {
+	_, text, err := context.FindInstructions(dir)
+	return text, err
+}
+
+// Generator generates shell commands with a provider.
+type Generator struct {
+	Provider Provider
+	Model    string   // overrides the provider's default model
+	Packs    []string // prompt packs to always include
+	Never    []string // hard constraints the command must respect
+
+	Instructions string // the project's instructions for the model, see ProjectInstructions
+}
+
+// Command is a generated shell command.
+type Command struct {
+	Text       string   // the command, ready to run
+	Dangerous  bool     // the model or nlch's own checks consider it destructive
+	Violations []string // rules from Generator.Never that the command breaks
+}
+
+// Generate asks the provider for a command that fulfils request in ctx.
+func (g *Generator) Generate(ctx *Context, request string) (*Command, error) {
+	opts := prompt.Options{Packs: g.Packs, Never: g.Never, Model: g.Model, Instructions: g.Instructions}
+	fitted := prompt.Fit(ctx, request, opts, provider.DefaultMaxTokens)
+	reply, err := g.Provider.GenerateCommand(*ctx, fitted.Prompt, ProviderOptions{
+		Model:    g.Model,
+		Provider: g.Provider.Name(),
+		System:   fitted.System,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("provider error: %v", err)
+	}
+	return parseCommand(prompt.CleanCommand(reply), g.Never), nil
+}
+
+// Explain asks the provider for a flag-by-flag explanation of command.
+func (g *Generator) Explain(ctx *Context, command string) (string, error) {
+	return g.Provider.GenerateCommand(*ctx, prompt.BuildExplainPrompt(ctx, command), ProviderOptions{
+		Model:     g.Model,
+		Provider:  g.Provider.Name(),
+		System:    prompt.ExplainSystemPrompt,
+		MaxTokens: 1024,
+		Raw:       true,
+	})
+}
+
+// parseCommand interprets a cleaned provider reply.
+func parseCommand(reply string, never []string) *Command {
+	cmd := &Command{Text: strings.TrimPrefix(reply, prompt.DangerPrefix)}
+	cmd.Dangerous = strings.HasPrefix(reply, prompt.DangerPrefix)
+	cmd.Dangerous = cmd.Dangerous || shell.IsDangerousCommand(cmd.Text)
+	cmd.Violations = shell.ViolatedConstraints(cmd.Text, never)
+	return cmd
+}
+
+// IsDangerous reports whether nlch's built-in checks consider cmd destructive.
+func IsDangerous(cmd string) bool {
+	return shell.IsDangerousCommand(cmd)
+}
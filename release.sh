@@ -66,10 +66,6 @@ update_version_in_files() {
     sed -i.bak "s/const version = \".*\"/const version = \"$version_no_v\"/" main.go
     rm -f main.go.bak
     
-    # Update Homebrew formula
-    sed -i.bak "s/version \".*\"/version \"$version_no_v\"/" nlch.rb
-    rm -f nlch.rb.bak
-    
     # Update update package
     sed -i.bak "s/var BuildVersion = \".*\"/var BuildVersion = \"$version_no_v\"/" internal/update/update.go
     rm -f internal/update/update.go.bak
@@ -148,7 +144,7 @@ create_version_branch() {
     
     # Commit version changes
     log_info "Committing version changes..."
-    git add main.go nlch.rb internal/update/update.go
+    git add main.go internal/update/update.go
     git commit -m "Bump version to $version"
     
     # Push version branch
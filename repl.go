@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kanishka-sahoo/nlch/internal/config"
+	"github.com/kanishka-sahoo/nlch/internal/history"
+	"github.com/kanishka-sahoo/nlch/internal/interrupt"
+	"github.com/kanishka-sahoo/nlch/internal/prompt"
+	"github.com/kanishka-sahoo/nlch/internal/session"
+	"github.com/kanishka-sahoo/nlch/internal/shell"
+	"github.com/kanishka-sahoo/nlch/internal/ui"
+)
+
+// maxEarlier is how many turns before the last one a session's prompt summarizes.
+const maxEarlier = 10
+
+// replState is the conversation of the running interactive session, or of a
+// single request made in a named session.
+type replState struct {
+	store *session.Store   // nil for a session that is not saved
+	sess  *session.Session // the conversation so far
+	last  *history.Entry   // the outcome of the current turn, once recorded
+}
+
+// repl is set while a request runs as a turn of a session.
+var repl *replState
+
+// openSession returns the state of the named session, or of an unsaved one
+// when name is empty.
+func openSession(name string) (*replState, error) {
+	wd, _ := os.Getwd()
+	if name == "" {
+		return &replState{sess: &session.Session{Dir: wd}}, nil
+	}
+	store, err := session.Open()
+	if err != nil {
+		return nil, err
+	}
+	sess, err := store.Get(name, wd)
+	if err != nil {
+		return nil, err
+	}
+	return &replState{store: store, sess: sess}, nil
+}
+
+// promptContext returns the last turn, which the next request may follow up
+// on, and a summary of the turns before it.
+func (r *replState) promptContext() (earlier []prompt.Exchange, previous *prompt.Exchange) {
+	turns := r.sess.Turns
+	if len(turns) == 0 {
+		return nil, nil
+	}
+	exchange := func(t session.Turn) prompt.Exchange {
+		return prompt.Exchange{Request: t.Request, Command: t.Command, Executed: t.Executed, ExitCode: t.ExitCode, Output: t.Output}
+	}
+	last := exchange(turns[len(turns)-1])
+	turns = turns[:len(turns)-1]
+	if len(turns) > maxEarlier {
+		turns = turns[len(turns)-maxEarlier:]
+	}
+	for _, t := range turns {
+		earlier = append(earlier, exchange(t))
+	}
+	return earlier, &last
+}
+
+// record wraps recordHistory to also keep the entry as the outcome of the turn,
+// with its output as the history would store it.
+func (r *replState) record(policy config.HistoryConfig) func(history.Entry) int {
+	return func(e history.Entry) int {
+		id := recordHistory(e)
+		if history.Excluded(policy, e.Dir) {
+			return id
+		}
+		history.ApplyPolicy(&e, policy)
+		e.ID = id
+		r.last = &e
+		return id
+	}
+}
+
+// finishTurn adds the outcome of the current turn to the session and saves it.
+func (r *replState) finishTurn() {
+	e := r.last
+	r.last = nil
+	if e == nil {
+		return
+	}
+	t := session.Turn{Request: e.Request, Command: e.Command, HistoryID: e.ID}
+	if e.Decision == history.DecisionExecuted {
+		t.Executed, t.ExitCode, t.Output = true, e.ExitCode, e.Output
+	}
+	r.sess.Add(t)
+	if r.store == nil {
+		return
+	}
+	if err := r.store.Save(r.sess); err != nil {
+		fmt.Fprintf(os.Stderr, "nlch: warning: failed to save session %s: %v\n", r.sess.Name, err)
+	}
+}
+
+// runTurn runs one request of a session with the run command's flags.
+func runTurn(r *replState, flags []string, request string) error {
+	repl = r
+	defer func() { repl = nil }()
+	err := runRun(append(flags, "--", request))
+	r.finishTurn()
+	return err
+}
+
+// runInteractive reads requests from the terminal until exit, quit or
+// end of input, running each with the run command's flags. Every request may
+// refer to the ones before it, and a named session keeps them for next time.
+func runInteractive(flags []string, name, first string) error {
+	r, err := openSession(name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case name == "":
+		fmt.Fprintln(os.Stderr, ui.Dim("> Interactive mode. Requests may refer to earlier ones; type exit or press Ctrl-D to leave."))
+	case len(r.sess.Turns) > 0:
+		fmt.Fprintln(os.Stderr, ui.Dim(fmt.Sprintf("> Resuming session %s: %d earlier requests, last at %s.", name, len(r.sess.Turns), r.sess.Updated.Local().Format("2006-01-02 15:04"))))
+	default:
+		fmt.Fprintln(os.Stderr, ui.Dim(fmt.Sprintf("> Started session %s. Resume it later with nlch -i --session %s; type exit or press Ctrl-D to leave.", name, name)))
+	}
+
+	request := first
+	for {
+		if request == "" {
+			line, err := shell.ReadTerminalLine("nlch> ")
+			if errors.Is(err, io.EOF) {
+				fmt.Fprintln(os.Stderr)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			request = strings.TrimSpace(line)
+		}
+		switch request {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+		err := runTurn(r, flags, request)
+		request = ""
+		if interrupt.Interrupted() {
+			return interrupt.ErrInterrupted
+		}
+		if err != nil && !errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "nlch: %s\n", ui.Error(strings.TrimSpace(err.Error())))
+		}
+	}
+}